@@ -0,0 +1,118 @@
+package amazoncaptcha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// labeledExtensions are the file extensions of the captcha images read by ReadLabeledCaptchas.
+var labeledExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".webp": true,
+}
+
+// AccuracyError is returned by RequireAccuracy when a corpus of labeled captchas is solved below the required
+// accuracy.
+type AccuracyError struct {
+	// Min is the required accuracy.
+	Min float64
+	// Evaluation counts how the corpus was solved.
+	Evaluation *Evaluation
+}
+
+// Error implements the error interface.
+func (e *AccuracyError) Error() string {
+	ev := e.Evaluation
+	return fmt.Sprintf("accuracy %.1f%% (%d of %d captchas) is below %.1f%%: %d wrong, %d unsolved, %d failed",
+		100*ev.Accuracy(), ev.Correct, ev.Total, 100*e.Min, ev.Wrong, ev.Unsolved, ev.Failed)
+}
+
+// RequireAccuracy solves the captcha images in dir, named after their answers, e.g. ABCDEF.jpg, and returns an
+// *AccuracyError if the share solved to their answers is below min, between 0 and 1. Downstream projects can call
+// it from their own test suites to check that the library, with their custom training data and options, still
+// meets their accuracy bar after upgrades:
+//
+//	func TestCaptchaAccuracy(t *testing.T) {
+//		if err := solver.RequireAccuracy("testdata/captchas", 0.95); err != nil {
+//			t.Fatal(err)
+//		}
+//	}
+//
+// Files that are not images labeled with answers of the captchas of the Solver and subdirectories are ignored,
+// see ReadLabeledCaptchas, and an error is returned if there are no labeled captchas at all. Like Evaluate, it
+// does not record the solves in the statistics or the journal.
+func RequireAccuracy(dir string, min float64) error {
+	return defaultSolver().RequireAccuracy(dir, min)
+}
+
+// RequireAccuracy works like the package-level RequireAccuracy, using the configuration and training data of the Solver.
+func (s *Solver) RequireAccuracy(dir string, min float64) error {
+	if min < 0 || min > 1 {
+		return errors.New("minimum accuracy must be between 0 and 1")
+	}
+	captchas, err := s.ReadLabeledCaptchas(dir)
+	if err != nil {
+		return err
+	}
+	if len(captchas) == 0 {
+		return fmt.Errorf("no labeled captchas in %s", dir)
+	}
+
+	e := s.Evaluate(context.Background(), captchas)
+	if e.Accuracy() < min {
+		return &AccuracyError{Min: min, Evaluation: e}
+	}
+	return nil
+}
+
+// ReadLabeledCaptchas reads the captcha images in dir labeled with their answers by their file names, e.g.
+// ABCDEF.jpg, in the order of their names. Files that are not images labeled with 6 capital letters, or with
+// answers of the profile of the default solver, and subdirectories are ignored.
+func ReadLabeledCaptchas(dir string) ([]LabeledCaptcha, error) {
+	return defaultSolver().ReadLabeledCaptchas(dir)
+}
+
+// ReadLabeledCaptchas works like the package-level ReadLabeledCaptchas, ignoring the files that are not labeled
+// with answers of the captchas of the Solver, of its captcha length and charset, see WithProfile.
+func (s *Solver) ReadLabeledCaptchas(dir string) ([]LabeledCaptcha, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read captchas: %w", err)
+	}
+	var captchas []LabeledCaptcha
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		answer := strings.ToUpper(strings.TrimSuffix(entry.Name(), ext))
+		if entry.IsDir() || !labeledExtensions[strings.ToLower(ext)] || !s.isLabel(answer) {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read captchas: %w", err)
+		}
+		captchas = append(captchas, LabeledCaptcha{Name: entry.Name(), Answer: answer, Image: b})
+	}
+	return captchas, nil
+}
+
+// isLabel reports whether label can be the answer of a captcha of the Solver: made of as many letters as its
+// captchas, all of its charset, or capital letters if its profile has no charset.
+func (s *Solver) isLabel(label string) bool {
+	if utf8.RuneCountInString(label) != s.captchaLength {
+		return false
+	}
+	for _, c := range label {
+		if s.charset != "" && !strings.ContainsRune(s.charset, c) || s.charset == "" && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+	return true
+}
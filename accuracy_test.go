@@ -0,0 +1,57 @@
+package amazoncaptcha
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireAccuracy(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, b []byte) {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), b, 0o644))
+	}
+	write("ABCEFG.png", syntheticCaptcha(t, "ABCEFG"))
+	write("hjklmn.png", syntheticCaptcha(t, "HJKLMN"))
+	write("KLMNPR.png", flipPixel(t, syntheticCaptcha(t, "KLMNPR"), 1))
+	write("README.txt", []byte("captchas named after their answers"))
+	write("captcha.png", syntheticCaptcha(t, "ABCEFG"))
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "ABCDEF.png"), 0o755))
+
+	assert.NoError(t, RequireAccuracy(dir, 0.6))
+
+	err := RequireAccuracy(dir, 0.9)
+	var accuracyErr *AccuracyError
+	assert.True(t, errors.As(err, &accuracyErr))
+	assert.Equal(t, 3, accuracyErr.Evaluation.Total)
+	assert.Equal(t, 2, accuracyErr.Evaluation.Correct)
+	assert.Equal(t, 1, accuracyErr.Evaluation.Unsolved)
+	assert.EqualError(t, err, "accuracy 66.7% (2 of 3 captchas) is below 90.0%: 0 wrong, 1 unsolved, 0 failed")
+
+	assert.ErrorContains(t, RequireAccuracy(t.TempDir(), 0.9), "no labeled captchas")
+	assert.Error(t, RequireAccuracy(filepath.Join(dir, "missing"), 0.9))
+	assert.Error(t, RequireAccuracy(dir, 1.5))
+
+	// Solvers of another profile take the captchas labeled with answers of their length and charset
+	write("HJKLM.png", syntheticCaptcha(t, "HJKLM"))
+	write("HJKLX.png", syntheticCaptcha(t, "HJKLM"))
+	p := profile()
+	p.Length = 5
+	p.Charset = "HJKLM"
+	solver, err := NewSolver(WithProfile(p))
+	if !assert.NoError(t, err) {
+		return
+	}
+	captchas, err := solver.ReadLabeledCaptchas(dir)
+	assert.NoError(t, err)
+	if assert.Len(t, captchas, 1) {
+		assert.Equal(t, "HJKLM", captchas[0].Answer)
+	}
+	assert.NoError(t, solver.RequireAccuracy(dir, 1))
+	captchas, err = ReadLabeledCaptchas(dir)
+	assert.NoError(t, err)
+	assert.Len(t, captchas, 3)
+}
@@ -0,0 +1,58 @@
+package amazoncaptcha
+
+import "image"
+
+// AlignedLetterWidth is the width of the canvas letters are centered on by AlignLetter,
+// wide enough for the widest letters, including those merged from a wrapped letter.
+const AlignedLetterWidth = 48
+
+// AlignLetter centers a letter on a white canvas of AlignedLetterWidth by CaptchaHeight pixels, moving the
+// centroid of its black pixels to the center of the canvas. The same glyph segmented with some horizontal
+// jitter, e.g. with an extra blank column, then has the same pixels and thus the same feature string.
+// Black pixels that would fall outside the canvas are dropped. A letter without black pixels becomes blank.
+func AlignLetter(img *image.Gray) *image.Gray {
+	return alignLetter(img, nil)
+}
+
+// alignLetter implements AlignLetter, allocating the canvas from a.
+func alignLetter(img *image.Gray, a *arena) *image.Gray {
+
+	// Create a white canvas
+	canvas := a.newGray(image.Rect(0, 0, AlignedLetterWidth, CaptchaHeight))
+	for i := range canvas.Pix {
+		canvas.Pix[i] = 255
+	}
+
+	// Find the centroid of the black pixels
+	bounds := img.Bounds()
+	sumX, sumY, ink := 0, 0, 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if img.GrayAt(x, y).Y == 0 {
+				sumX += x
+				sumY += y
+				ink++
+			}
+		}
+	}
+	if ink == 0 {
+		return canvas
+	}
+
+	// Move the black pixels so that the centroid, rounded to the nearest pixel, lands on the center
+	dx := AlignedLetterWidth/2 - (2*sumX+ink)/(2*ink)
+	dy := CaptchaHeight/2 - (2*sumY+ink)/(2*ink)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if img.GrayAt(x, y).Y != 0 {
+				continue
+			}
+			p := image.Pt(x+dx, y+dy)
+			if p.In(canvas.Rect) {
+				canvas.Pix[canvas.PixOffset(p.X, p.Y)] = 0
+			}
+		}
+	}
+
+	return canvas
+}
@@ -0,0 +1,50 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlignLetter(t *testing.T) {
+	_, binaryStr := trainingLetter(t, "K")
+	letter := featureImage(binaryStr, CaptchaHeight)
+	if !assert.NotNil(t, letter) {
+		return
+	}
+
+	// Shifting the letter within its segment does not change the aligned letter
+	shifted := image.NewGray(image.Rect(0, 0, letter.Bounds().Dx()+3, CaptchaHeight))
+	for i := range shifted.Pix {
+		shifted.Pix[i] = 255
+	}
+	for y := 0; y < CaptchaHeight; y++ {
+		copy(shifted.Pix[y*shifted.Stride+3:], letter.Pix[y*letter.Stride:(y+1)*letter.Stride])
+	}
+	aligned := AlignLetter(letter)
+	assert.Equal(t, image.Rect(0, 0, AlignedLetterWidth, CaptchaHeight), aligned.Bounds())
+	assert.Equal(t, aligned.Pix, AlignLetter(shifted).Pix)
+
+	// Aligning is idempotent
+	assert.Equal(t, aligned.Pix, AlignLetter(aligned).Pix)
+
+	// Blank letters stay blank
+	blank := image.NewGray(image.Rect(0, 0, 10, CaptchaHeight))
+	for i := range blank.Pix {
+		blank.Pix[i] = 255
+	}
+	assert.Equal(t, 0, countInk(AlignLetter(blank)))
+}
+
+func TestNewSolverWithLetterAlignment(t *testing.T) {
+	solver, err := NewSolver(WithLetterAlignment())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	answer, err := solver.Solve(bytes.NewReader(syntheticCaptcha(t, "ABCEFG")))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", answer)
+}
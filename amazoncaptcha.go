@@ -1,12 +1,18 @@
 package amazoncaptcha
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"image"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	_ "image/jpeg"
 	_ "image/png"
@@ -19,131 +25,354 @@ const MonoWeight = 1
 const MaximumLetterLength = 33
 
 // MinimumLetterLength Define a constant MinimumLetterLength with a value of 14, representing the minimum width of the first letter.
-// If the width of the first letter is less than this value, all letters will be replaced with blank letters.
+// If the width of the first letter is less than this value, the segmentation fails.
 const MinimumLetterLength = 14
 
+// CaptchaHeight Define a constant CaptchaHeight with a value of 70, representing the height of a captcha image
+// and therefore of every letter extracted from it.
+const CaptchaHeight = 70
+
 // FindLetters attempts to locate the letters in a captcha image and returns a slice of grayscale letter images.
 // It takes an io.Reader as input, which should contain a valid captcha image.
 // It returns a slice of grayscale letter images and an error if the letter extraction process fails.
+// If the letters could not be segmented, it returns a *SegmentationError matching ErrSegmentationFailed.
 func FindLetters(r io.Reader) ([]*image.Gray, error) {
+	return defaultSolver().FindLetters(r)
+}
 
-	// Decode the input image
-	img, _, err := image.Decode(r)
+// FindLetters works like the package-level FindLetters, using the configuration of the Solver.
+func (s *Solver) FindLetters(r io.Reader) ([]*image.Gray, error) {
+	return s.findLetters(r, nil)
+}
+
+// findLetters implements FindLetters, allocating the intermediate and letter images from a.
+func (s *Solver) findLetters(r io.Reader, a *arena) ([]*image.Gray, error) {
+
+	// Decode the input image and find the letter boxes in it
+	grayImg, letterBoxes, err := s.locateLetters(r, a)
 	if err != nil {
-		return nil, fmt.Errorf("error decoding image: %v", err)
+		return nil, err
 	}
 
-	// Convert the input image to grayscale
-	grayImg := Grayscale(img)
+	// Extract the letters from the monochrome image based on the letter boxes
+	return s.cropLetters(grayImg, letterBoxes, a)
+}
+
+// cropLetters crops the letters described by letterBoxes out of a monochrome image, allocating them from a.
+func (s *Solver) cropLetters(grayImg *image.Gray, letterBoxes []image.Rectangle, a *arena) ([]*image.Gray, error) {
+	letters := make([]*image.Gray, 0, s.captchaLength)
+	err := s.walkLetters(grayImg, letterBoxes, a, func(_ int, letter *image.Gray) bool {
+		letters = append(letters, letter)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Warning: Cropping is disabled by default since it may reduce recognition accuracy, as the training data
+	// is not cropped; it is only enabled for the members of an EnsembleSolver asking for it. WithGridNormalization
+	// crops letters together with the training data instead
+	// Remove white borders from each letter image
+	if s.cutTheWhite {
+		for i, letter := range letters {
+			letters[i] = CutTheWhite(letter)
+		}
+	}
+
+	return letters, nil
+}
+
+// locateLetters decodes a captcha image, converts it to monochrome and finds the letter boxes in it.
+// The intermediate images are allocated from a, which is sized for the whole solve of the captcha.
+func (s *Solver) locateLetters(r io.Reader, a *arena) (*image.Gray, []image.Rectangle, error) {
+
+	// Decode the input image and convert it to grayscale
+	grayImg, err := s.decodeGrayscale(r, a)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Convert the grayscale image to monochrome using a threshold value
-	grayImg = MonoChrome(grayImg, MonoWeight)
+	grayImg = s.binarize(grayImg, s.threshold(grayImg), a)
 
 	// Find the letter boxes in the monochrome image
-	letterBoxes := FindLetterBoxes(grayImg, MaximumLetterLength)
+	return grayImg, s.findLetterBoxes(grayImg), nil
+}
 
-	// Extract the letters from the monochrome image based on the letter boxes
-	letters := make([]*image.Gray, len(letterBoxes))
-	for i, box := range letterBoxes {
+// decodeGrayscale decodes a captcha image and converts it to grayscale, sizing a for the whole solve of the captcha.
+func (s *Solver) decodeGrayscale(r io.Reader, a *arena) (*image.Gray, error) {
 
-		// Calculate the width and height of the letter box
-		width := box.Max.X - box.Min.X
-		height := box.Max.Y - box.Min.Y
+	// Decode the input image
+	img, err := s.decodeImage(r)
+	if err != nil {
+		return nil, err
+	}
 
-		// Create a new grayscale image for the letter
-		letterImg := image.NewGray(image.Rect(0, 0, width, height))
+	// Reserve room for the grayscale and monochrome images, the letter crops and their features
+	size := img.Bounds().Dx() * img.Bounds().Dy()
+	a.reserve(4 * size)
 
-		// Copy the pixels from the original grayscale image to the new letter image
-		for y := 0; y < height; y++ {
-			for x := 0; x < width; x++ {
-				// Calculate the position of the pixel in the original grayscale image
-				origX := box.Min.X + x
-				origY := box.Min.Y + y
+	// Convert the input image to grayscale
+	return grayscale(img, a), nil
+}
 
-				// Copy the pixel from the original grayscale image to the new letter image
-				letterImg.SetGray(x, y, grayImg.GrayAt(origX, origY))
-			}
+// walkLetters crops the letters described by letterBoxes out of a monochrome image and passes them
+// to yield one at a time, in captcha order. It stops early when yield returns false.
+// If the boxes do not describe a valid captcha, nothing is yielded and a *SegmentationError is returned.
+// The letters are allocated from a.
+func (s *Solver) walkLetters(grayImg *image.Gray, letterBoxes []image.Rectangle, a *arena, yield func(int, *image.Gray) bool) error {
+
+	// If the number of letters is not exactly the captcha length or one more, or the width of the first letter
+	// is too small, the letters could not be segmented
+	n := s.captchaLength
+	if (len(letterBoxes) == n && letterBoxes[0].Dx() < s.minLetterLength) || (len(letterBoxes) != n && len(letterBoxes) != n+1) {
+		segmentErr := &SegmentationError{Segments: len(letterBoxes), Widths: make([]int, len(letterBoxes))}
+		for i, box := range letterBoxes {
+			segmentErr.Widths[i] = box.Dx()
 		}
-
-		// Add the new letter image to the letters slice
-		letters[i] = letterImg
+		return segmentErr
 	}
 
-	// If the number of letters is not exactly 6 or 7, or the width of the first letter is too small,
-	// replace all letters with blank letters
-	if (len(letters) == 6 && letters[0].Bounds().Dx() < MinimumLetterLength) || (len(letters) != 6 && len(letters) != 7) {
-		blankLetter := image.NewGray(image.Rect(0, 0, 200, 70))
-		letters = make([]*image.Gray, 6)
-		for i := range letters {
-			letters[i] = blankLetter
+	// If there is one letter too many, the first one is the tail of the last letter,
+	// so it is skipped here and merged into the last letter below
+	first := 0
+	if len(letterBoxes) == n+1 {
+		first = 1
+	}
+	for i := first; i < n; i++ {
+		if !yield(i-first, cropLetter(grayImg, letterBoxes[i], a)) {
+			return nil
 		}
 	}
 
-	// If there are 7 letters, merge the first and last letters together
-	if len(letters) == 7 {
+	if len(letterBoxes) == n+1 {
 		// Merge the first and last letters horizontally
-		merged, err := MergeHorizontally(letters[6], letters[0])
+		merged, err := MergeHorizontally(cropLetter(grayImg, letterBoxes[n], a), cropLetter(grayImg, letterBoxes[0], a))
 		if err != nil {
-			return nil, err
+			return err
 		}
+		yield(n-1, merged)
+	}
+
+	return nil
+}
 
-		// Replace the last letter with the merged letter
-		letters[6] = merged
+// cropLetter copies the pixels inside a letter box into a new grayscale image allocated from a.
+func cropLetter(grayImg *image.Gray, box image.Rectangle, a *arena) *image.Gray {
 
-		// Remove the first letter from the slice
-		copy(letters[0:], letters[1:])
-		letters[len(letters)-1] = nil
-		letters = letters[:len(letters)-1]
-	}
+	// Calculate the width and height of the letter box
+	width := box.Max.X - box.Min.X
+	height := box.Max.Y - box.Min.Y
 
-	// Warning: Commenting out the following line since it may reduce recognition accuracy
-	// Remove white borders from each letter image
-	// for i, letter := range letters {
-	// letters[i] = CutTheWhite(letter)
-	// }
+	// Create a new grayscale image for the letter
+	letterImg := a.newGray(image.Rect(0, 0, width, height))
 
-	// Join the recognition results into a single string and return it
-	return letters, nil
+	// Copy the pixels from the original grayscale image to the new letter image
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			// Calculate the position of the pixel in the original grayscale image
+			origX := box.Min.X + x
+			origY := box.Min.Y + y
+
+			// Copy the pixel from the original grayscale image to the new letter image
+			letterImg.SetGray(x, y, grayImg.GrayAt(origX, origY))
+		}
+	}
+
+	return letterImg
 }
 
 // Solve attempts to solve a captcha image and returns a list of character images.
+// If the letters could not be segmented, it returns a *SegmentationError matching ErrSegmentationFailed.
+// If some letters could not be recognized, it returns the answer with a "-" in place of each of them,
+// together with an *UnrecognizedLetterError matching ErrUnrecognizedLetter. If the answer filter of the Solver
+// rejected the answer, it returns the answer together with an *AnswerRejectedError matching ErrAnswerRejected.
 func Solve(r io.Reader) (string, error) {
+	return defaultSolver().Solve(r)
+}
 
-	// Call the FindLetters function to extract the letter images from the input image
-	letters, err := FindLetters(r)
+// Solve works like the package-level Solve, using the configuration and training data of the Solver.
+func (s *Solver) Solve(r io.Reader) (string, error) {
+	b, err := s.readImage(r)
 	if err != nil {
 		return "", err
 	}
+	return s.SolveBytes(b)
+}
+
+// SolveBytes works like Solve for an image held in memory. Unlike a reader, the bytes can be read several
+// times, so strategies making more than one pass over the image do not need to copy it first.
+// The bytes are not modified.
+func SolveBytes(b []byte) (string, error) {
+	return defaultSolver().SolveBytes(b)
+}
+
+// SolveBytes works like the package-level SolveBytes, using the configuration and training data of the Solver.
+func (s *Solver) SolveBytes(b []byte) (string, error) {
+	result, err := s.solveBytes(b)
+	if err != nil {
+		return "", err
+	}
+	return result.Text, result.failure()
+}
+
+// SolveDetailed works like Solve but returns a Result describing the answer, including the confidence
+// of every letter and whether all of them were recognized, so that partial failures can be detected
+// without scanning the answer for placeholders. Unlike Solve, it returns no error for unrecognized letters or answers
+// rejected by the answer filter, see Result.Rejected.
+func SolveDetailed(r io.Reader) (*Result, error) {
+	return defaultSolver().SolveDetailed(r)
+}
+
+// SolveDetailed works like the package-level SolveDetailed, using the configuration and training data of the Solver.
+func (s *Solver) SolveDetailed(r io.Reader) (*Result, error) {
+
+	// Read the whole input so that it can be hashed for the journal and outcome reports
+	b, err := s.readImage(r)
+	if err != nil {
+		return nil, err
+	}
+	return s.solveBytes(b)
+}
+
+// solveBytes implements SolveDetailed for an image held in memory, recording the solve in the statistics and the journal.
+func (s *Solver) solveBytes(b []byte) (*Result, error) {
+	start := time.Now()
+	result, err := s.solve(bytes.NewReader(b))
+	s.finishSolve(b, start, result, err)
+
+	return result, err
+}
+
+// solve implements Solve and returns the exact matches of the letters as a result.
+func (s *Solver) solve(r io.Reader) (*Result, error) {
+	if err := s.checkTrainingData(); err != nil {
+		return nil, err
+	}
+
+	// Allocate the intermediate images of the solve from a single arena, released when the solve ends
+	a := getArena()
+	defer a.release()
+
+	// Decode the input image and convert it to grayscale once for every threshold tried
+	grayImg, err := s.decodeGrayscale(r, a)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use the same training data snapshot for every letter
+	m := s.recognitionModel()
+
+	// Recognize the letters of the captcha binarized at the mono threshold, or its adaptive threshold
+	threshold := s.threshold(grayImg)
+	mono := s.binarize(grayImg, threshold, a)
+	letterBoxes := s.findLetterBoxes(mono)
+	letters, matches, err := s.recognizeLetters(m, mono, letterBoxes, a)
+	s.observeDrift(len(letterBoxes), letters)
+	if err != nil {
+		return nil, err
+	}
+
+	// Join the recognition results into a single string
+	result := s.newResult(StrategyExact, matches)
+
+	// Retry at the fallback thresholds until every letter is recognized, if enabled
+	for _, fallback := range s.fallbackThresholds {
+		if result.Solved {
+			break
+		}
+		if fallback == threshold {
+			continue
+		}
+		mono := s.binarize(grayImg, fallback, a)
+		retryLetters, retryMatches, err := s.recognizeLetters(m, mono, s.findLetterBoxes(mono), a)
+		if err != nil {
+			if errors.Is(err, ErrSegmentationFailed) {
+				continue
+			}
+			return nil, err
+		}
+		if retry := s.newResult(StrategyThreshold, retryMatches); retry.Solved {
+			result, letters, matches = retry, retryLetters, retryMatches
+		}
+	}
+
+	// Count the training entries used by the answer
+	for _, match := range matches {
+		if match.entry != "" {
+			s.usage.record(match.entry)
+		}
+	}
+	s.addCandidates(m, result, matches)
+
+	// Hand the unknown letters over to the training inbox if capture is enabled
+	if sink := s.currentLetterSink(); sink != nil && len(result.unknown) > 0 {
+		captureUnknownLetters(sink, m, letters, matches, result)
+	}
+
+	return result, nil
+}
+
+// recognizeLetters crops the letters described by letterBoxes out of a monochrome captcha and recognizes
+// every letter, returning the letters with their recognition. Unknown letters keep an empty letter.
+// The intermediate images are allocated from a.
+func (s *Solver) recognizeLetters(m *model, mono *image.Gray, letterBoxes []image.Rectangle, a *arena) ([]*image.Gray, []letterMatch, error) {
+
+	// Extract the letter images from the monochrome image
+	letters, err := s.cropLetters(mono, letterBoxes, a)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	// Define a slice to hold the recognition results
-	result := make([]string, len(letters))
+	// Define a slice to hold the recognition results, unknown letters keep an empty letter
+	matches := make([]letterMatch, len(letters))
 
 	// Loop over each letter image and extract its features
 	for i, letter := range letters {
-		features, err := ExtractFeatures(letter)
+		feature, err := s.letterFeature(letter, a)
 		if err != nil {
-			return "", err
+			return nil, nil, err
 		}
+		matches[i].feature = feature
 		//if v, ok := trainingDataSyncMap.Load(features); ok {
 		//	result[i] = v.(string)
 		//} else {
 		//	result[i] = "-"
 		//}
-		if v, ok := featureMap[features]; ok {
-			result[i] = v
-		} else {
-			result[i] = "-"
+		if entry, v, ok := m.lookup(feature); ok {
+			matches[i].letter, matches[i].confidence, matches[i].entry = v, 1, entry
+		} else if s.fuzzyDistance > 0 {
+			matches[i] = s.matchFuzzy(m, feature)
+		}
+
+		// Ask the recognizer about the letters the training data does not know
+		if matches[i].letter == "" && s.recognizer != nil {
+			matches[i].letter, matches[i].confidence = s.recognizer.Recognize(letter)
+		}
+
+		// Break ties between easily confused letters
+		if len(s.rules) > 0 {
+			matches[i].letter = s.disambiguate(letter, matches[i], a)
+		}
+
+		// Letters outside the charset of the profile cannot be part of the answer
+		if !s.inCharset(matches[i].letter) {
+			matches[i].letter, matches[i].confidence, matches[i].entry = "", 0, ""
 		}
 	}
 
-	// Join the recognition results into a single string and return it
-	return strings.Join(result, ""), nil
+	return letters, matches, nil
 }
 
 // SolveFromImageFile takes a file path of an image file as input, opens the file,
 // and processes the data from the image file using the Solve function.
 // It returns the processed result as a string and an error if any error occurs during the process.
 func SolveFromImageFile(filepath string) (string, error) {
+	return defaultSolver().SolveFromImageFile(filepath)
+}
+
+// SolveFromImageFile works like the package-level SolveFromImageFile, using the Solver.
+func (s *Solver) SolveFromImageFile(filepath string) (string, error) {
 	// Open the image file
 	file, err := os.Open(filepath)
 	if err != nil {
@@ -152,9 +381,9 @@ func SolveFromImageFile(filepath string) (string, error) {
 	defer file.Close()
 
 	// Use the Solve function to process the data from the image file
-	result, err := Solve(file)
+	result, err := s.Solve(file)
 	if err != nil {
-		return "", fmt.Errorf("failed to solve: %w", err)
+		return result, fmt.Errorf("failed to solve: %w", err)
 	}
 
 	return result, nil
@@ -164,8 +393,38 @@ func SolveFromImageFile(filepath string) (string, error) {
 // and processes the data from the URL using the Solve function.
 // It returns the processed result as a string and an error if any error occurs during the process.
 func SolveFromURL(url string) (string, error) {
+	return defaultSolver().SolveFromURL(url)
+}
+
+// SolveFromURL works like the package-level SolveFromURL, using the Solver.
+func (s *Solver) SolveFromURL(url string) (string, error) {
+	return s.SolveFromURLWithClient(context.Background(), http.DefaultClient, url, nil)
+}
+
+// SolveFromURLWithClient works like SolveFromURL, but makes the HTTP request with client and the given headers,
+// e.g. a realistic User-Agent and Referer, so that scrapers can reuse their session transport, cookies and proxies.
+// The request is canceled when ctx is done. A nil client means http.DefaultClient.
+func SolveFromURLWithClient(ctx context.Context, client *http.Client, url string, headers map[string]string) (string, error) {
+	return defaultSolver().SolveFromURLWithClient(ctx, client, url, headers)
+}
+
+// SolveFromURLWithClient works like the package-level SolveFromURLWithClient, using the Solver.
+func (s *Solver) SolveFromURLWithClient(ctx context.Context, client *http.Client, url string, headers map[string]string) (string, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	// Create the HTTP request with the given headers
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
 	// Make an HTTP request to the given URL
-	resp, err := http.Get(url)
+	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to make HTTP request: %w", err)
 	}
@@ -177,10 +436,88 @@ func SolveFromURL(url string) (string, error) {
 	}
 
 	// Use the Solve function to process the data from the URL
-	result, err := Solve(resp.Body)
+	result, err := s.Solve(resp.Body)
+	if err != nil {
+		return result, fmt.Errorf("failed to solve: %w", err)
+	}
+
+	return result, nil
+}
+
+// SolveFromDataURI takes a data URI such as "data:image/jpeg;base64,...", as scraped from the DOM by headless
+// browsers, decodes the image it holds and solves it using the SolveBytes function. Both base64 and
+// percent-encoded data are accepted. It returns the processed result as a string and an error if any error
+// occurs during the process.
+func SolveFromDataURI(uri string) (string, error) {
+	return defaultSolver().SolveFromDataURI(uri)
+}
+
+// SolveFromDataURI works like the package-level SolveFromDataURI, using the Solver.
+func (s *Solver) SolveFromDataURI(uri string) (string, error) {
+	// Decode the image held by the data URI
+	b, err := decodeDataURI(uri)
+	if err != nil {
+		return "", err
+	}
+
+	// Use the SolveBytes function to process the image
+	result, err := s.SolveBytes(b)
 	if err != nil {
-		return "", fmt.Errorf("failed to solve: %w", err)
+		return result, fmt.Errorf("failed to solve: %w", err)
 	}
 
 	return result, nil
 }
+
+// decodeDataURI returns the data held by a data URI of the form "data:[<media type>][;base64],<data>".
+func decodeDataURI(uri string) ([]byte, error) {
+	// Split the URI into its header and its data, the scheme is case-insensitive
+	uri = strings.TrimSpace(uri)
+	if len(uri) < len("data:") || !strings.EqualFold(uri[:len("data:")], "data:") {
+		return nil, errors.New("invalid data URI: missing data scheme")
+	}
+	comma := strings.IndexByte(uri, ',')
+	if comma < 0 {
+		return nil, errors.New("invalid data URI: missing comma")
+	}
+	header, payload := uri[len("data:"):comma], uri[comma+1:]
+
+	params := strings.Split(header, ";")
+	if mediaType := strings.TrimSpace(params[0]); mediaType != "" && !strings.HasPrefix(strings.ToLower(mediaType), "image/") {
+		return nil, fmt.Errorf("invalid data URI: unsupported media type %q", mediaType)
+	}
+	isBase64 := strings.EqualFold(strings.TrimSpace(params[len(params)-1]), "base64")
+
+	if !isBase64 {
+		b, err := url.PathUnescape(payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data URI: %w", err)
+		}
+		return []byte(b), nil
+	}
+
+	// Base64 data may be percent-encoded, wrapped over several lines, URL-safe or unpadded
+	if strings.Contains(payload, "%") {
+		unescaped, err := url.PathUnescape(payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data URI: %w", err)
+		}
+		payload = unescaped
+	}
+	payload = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n':
+			return -1
+		case '-':
+			return '+'
+		case '_':
+			return '/'
+		}
+		return r
+	}, payload)
+	b, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(payload, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid data URI: %w", err)
+	}
+	return b, nil
+}
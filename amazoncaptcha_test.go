@@ -2,20 +2,26 @@ package amazoncaptcha
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
+	"image/color"
 	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"testing"
 
-	"github.com/PuerkitoBio/goquery"
-	"github.com/go-resty/resty/v2"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
@@ -74,55 +80,49 @@ func TestSolveBatch(t *testing.T) {
 		testFiles = append(testFiles, file.Name())
 	}
 
-	var successes, total int32
+	var successes, total int
 	var failedFiles []string
 
 	t.Logf("Processing %d files with %d workers...\n", len(testFiles), maxWorkers)
 
-	fileChan := make(chan string, len(testFiles))
-	for _, file := range testFiles {
-		fileChan <- file
-	}
-	close(fileChan)
+	// Feed the image files to the batch solver, which numbers them in the order of testFiles
+	inputs := make(chan io.Reader)
+	go func() {
+		defer close(inputs)
+		for _, file := range testFiles {
+			imagePath := filepath.Join(dirName, file)
+			imageFile, err := os.ReadFile(imagePath)
+			if err != nil {
+				t.Logf("Failed to open image file %s: %v", imagePath, err)
+				imageFile = nil
+			}
+			inputs <- bytes.NewReader(imageFile)
+		}
+	}()
 
-	var wg sync.WaitGroup
-	for i := 0; i < maxWorkers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for file := range fileChan {
-				imagePath := filepath.Join(dirName, file)
-				imageFile, err := os.ReadFile(imagePath)
-				if err != nil {
-					t.Logf("Failed to open image file %s: %v", imagePath, err)
-					continue
-				}
-				result, err := Solve(bytes.NewReader(imageFile))
-				if err != nil {
-					t.Logf("Failed to solve captcha in image file %s: %v", imagePath, err)
-					continue
-				}
-				atomic.AddInt32(&total, 1)
-				if result == file[:len(file)-4] {
-					atomic.AddInt32(&successes, 1)
-				} else {
-					failedFiles = append(failedFiles, fmt.Sprintf("%s -> %s", path.Join(dirName, file), result))
-					err = os.Rename(imagePath, filepath.Join(failedDir, file))
-					if err != nil {
-						t.Logf("Failed to move failed image file %s: %v", imagePath, err)
-					}
-				}
-				curSuccesses := atomic.LoadInt32(&successes)
-				curTotal := atomic.LoadInt32(&total)
-				successRate := float32(curSuccesses) / float32(curTotal) * 100
-				progress := float32(curTotal) / float32(len(testFiles)) * 100
-				t.Logf("Processing file %s... success rate: %.2f%%, progress: %.2f%%", file, successRate, progress)
+	for batchResult := range SolveBatch(context.Background(), inputs, maxWorkers) {
+		file := testFiles[batchResult.Index]
+		imagePath := filepath.Join(dirName, file)
+		if batchResult.Err != nil {
+			t.Logf("Failed to solve captcha in image file %s: %v", imagePath, batchResult.Err)
+			continue
+		}
+		result := batchResult.Result.Text
+		total++
+		if result == file[:len(file)-4] {
+			successes++
+		} else {
+			failedFiles = append(failedFiles, fmt.Sprintf("%s -> %s", path.Join(dirName, file), result))
+			err = os.Rename(imagePath, filepath.Join(failedDir, file))
+			if err != nil {
+				t.Logf("Failed to move failed image file %s: %v", imagePath, err)
 			}
-		}()
+		}
+		successRate := float32(successes) / float32(total) * 100
+		progress := float32(total) / float32(len(testFiles)) * 100
+		t.Logf("Processing file %s... success rate: %.2f%%, progress: %.2f%%", file, successRate, progress)
 	}
 
-	wg.Wait()
-
 	t.Logf("Processed %d files with success rate: %d/%d (%.2f%%)", total, successes, total, float32(successes)/float32(total)*100)
 
 	if len(failedFiles) > 0 {
@@ -263,7 +263,7 @@ func TestExtractFeatures(t *testing.T) {
 		return
 	}
 
-	jsonPath := "./training_data.json"
+	jsonPath := filepath.Join(t.TempDir(), "training_data.json")
 	if err := os.WriteFile(jsonPath, jsonBytes, 0644); err != nil {
 		t.Errorf("Failed to write feature map json file: %v\n", err)
 		return
@@ -272,103 +272,6 @@ func TestExtractFeatures(t *testing.T) {
 	t.Logf("Extracted features for %d training images and saved to %s", len(files), jsonPath)
 }
 
-func TestDownloadCaptchaImages(t *testing.T) {
-
-	var NotFeatures = make(map[string]string)
-
-	client := resty.New()
-	client.SetHeaders(map[string]string{
-		"User-Agent":      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/58.0.3029.110 Safari/537.3",
-		"Referer":         "https://www.amazon.com/errors/validateCaptcha",
-		"Accept-Language": "en-US,en;q=0.9",
-	})
-
-	var wg sync.WaitGroup
-	urls := make(chan string, 5000)
-
-	// Start workers to download images
-	for i := 0; i < maxWorkers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for url := range urls {
-				resp, err := client.R().Get(url)
-				if err != nil {
-					fmt.Println(err)
-					continue
-				}
-				letters, err := FindLetters(bytes.NewReader(resp.Body()))
-				if err != nil {
-					fmt.Println(err)
-					continue
-				}
-				if len(letters) < 6 {
-					continue
-				}
-				for _, v := range letters {
-					feature, err := ExtractFeatures(v)
-					if err != nil {
-						panic(err)
-					}
-					if _, ok := featureMap[feature]; !ok {
-						if _, ok := NotFeatures[feature]; ok {
-							continue
-						}
-						NotFeatures[feature] = ""
-						filename := fmt.Sprintf("./captchas1/%s", path.Base(url))
-						err = os.WriteFile(filename, resp.Body(), os.ModePerm)
-						if err != nil {
-							panic(err)
-						}
-						break
-					}
-				}
-			}
-		}()
-	}
-
-	// Start workers to get image URLs
-	for i := 0; i < maxWorkers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for j := 0; j < 5000/maxWorkers; j++ {
-				resp, err := client.R().Get("https://www.amazon.com/errors/validateCaptcha")
-				if err != nil {
-					fmt.Println(err)
-					continue
-				}
-				doc, err := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body()))
-				if err != nil {
-					fmt.Println(err)
-					continue
-				}
-				captchaImgURL, exists := doc.Find("div.a-row.a-text-center > img").Attr("src")
-				if !exists {
-					fmt.Println("failed to find captcha image URL")
-					continue
-				}
-				fmt.Println("enqueue -> ", captchaImgURL)
-
-				select {
-				case urls <- captchaImgURL:
-					// Send URL to the urls channel
-				default:
-					// Ignore the URL if the urls channel is closed
-				}
-				//time.Sleep(10 * time.Millisecond)
-			}
-		}()
-	}
-
-	// Wait for all the goroutines to exit before closing the urls channel
-	wg.Wait()
-	close(urls)
-
-	// Wait for workers to finish downloading images
-	wg.Wait()
-}
-
 func removeDuplicates(strSlice []string) []string {
 	uniqueMap := make(map[string]bool, len(strSlice))
 	for _, str := range strSlice {
@@ -384,6 +287,7 @@ func removeDuplicates(strSlice []string) []string {
 }
 
 func TestSolveFromImageFile(t *testing.T) {
+	requireTrainingData(t)
 	// Test the SolveFromImageFile function
 	result, err := SolveFromImageFile(path.Join(dirName, "AABTRE.jpg"))
 	assert.NoError(t, err)
@@ -391,8 +295,205 @@ func TestSolveFromImageFile(t *testing.T) {
 }
 
 func TestSolveFromURL(t *testing.T) {
+	requireTrainingData(t)
 	// Test the SolveFromURL function
 	result, err := SolveFromURL("https://images-na.ssl-images-amazon.com/captcha/sargzmyv/Captcha_kvvvwatlha.jpg")
 	assert.NoError(t, err)
 	assert.Equal(t, "MYKYAN", result)
 }
+
+// requireTrainingData skips tests that need the embedded training data in builds with the noembed tag, whose
+// solvers fail with ErrNoTrainingData.
+func requireTrainingData(t *testing.T) {
+	t.Helper()
+	if len(data) == 0 {
+		t.Skip("no embedded training data in builds with the noembed tag")
+	}
+}
+
+// trainingLetter returns a training entry for letter whose bitmap has ink in every column,
+// so that it survives segmentation unchanged when rendered into a captcha.
+func trainingLetter(t *testing.T, letter string) (string, []byte) {
+	t.Helper()
+	requireTrainingData(t)
+	features := defaultSolver().trainingData().features
+	keys := make([]string, 0, len(features))
+	for k, v := range features {
+		if v == letter {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		bits, err := decodeFeature(k)
+		if err != nil || len(bits)%70 != 0 {
+			continue
+		}
+		width := len(bits) / 70
+		if width < MinimumLetterLength || width > MaximumLetterLength {
+			continue
+		}
+		inked := true
+		for x := 0; x < width && inked; x++ {
+			inked = false
+			for y := 0; y < 70; y++ {
+				if bits[y*width+x] == '1' {
+					inked = true
+					break
+				}
+			}
+		}
+		if inked {
+			return k, bits
+		}
+	}
+	t.Fatalf("no usable training entry for letter %s", letter)
+	return "", nil
+}
+
+// syntheticCaptcha renders the training bitmaps of the letters of answer side by side
+// into a PNG encoded captcha image.
+func syntheticCaptcha(t *testing.T, answer string) []byte {
+	t.Helper()
+	bitmaps := make([][]byte, len(answer))
+	width := 2
+	for i, c := range answer {
+		_, bitmaps[i] = trainingLetter(t, string(c))
+		width += len(bitmaps[i])/70 + 2
+	}
+	img := image.NewGray(image.Rect(0, 0, width, 70))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	offset := 2
+	for _, bits := range bitmaps {
+		w := len(bits) / 70
+		for y := 0; y < 70; y++ {
+			for x := 0; x < w; x++ {
+				if bits[y*w+x] == '1' {
+					img.Pix[y*img.Stride+offset+x] = 0
+				}
+			}
+		}
+		offset += w + 2
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// flipPixel turns a white pixel of the letter at position black, so that the letter
+// no longer matches its training entry exactly.
+func flipPixel(t *testing.T, captcha []byte, position int) []byte {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(captcha))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gray := img.(*image.Gray)
+	box := FindLetterBoxes(gray, MaximumLetterLength)[position]
+	for y := 0; y < gray.Bounds().Dy(); y++ {
+		if gray.GrayAt(box.Min.X+1, y).Y == 255 {
+			gray.SetGray(box.Min.X+1, y, color.Gray{Y: 0})
+			break
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, gray); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSolveDetailed(t *testing.T) {
+	result, err := SolveDetailed(bytes.NewReader(syntheticCaptcha(t, "ABCEFG")))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", result.Text)
+	assert.True(t, result.Solved)
+	assert.Equal(t, []float64{1, 1, 1, 1, 1, 1}, result.LetterConfidence)
+
+	captcha := flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)
+	result, err = SolveDetailed(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "AB-EFG", result.Text)
+	assert.False(t, result.Solved)
+	assert.Equal(t, []float64{1, 1, 0, 1, 1, 1}, result.LetterConfidence)
+	assert.InDelta(t, 5.0/6, result.Confidence, 1e-9)
+}
+
+func TestSolveBytes(t *testing.T) {
+	captcha := syntheticCaptcha(t, "ABCEFG")
+	original := append([]byte(nil), captcha...)
+
+	// The same bytes can be solved repeatedly and are left untouched
+	for i := 0; i < 2; i++ {
+		answer, err := SolveBytes(captcha)
+		assert.NoError(t, err)
+		assert.Equal(t, "ABCEFG", answer)
+	}
+	assert.Equal(t, original, captcha)
+
+	answer, err := SolveBytes(flipPixel(t, captcha, 2))
+	assert.True(t, errors.Is(err, ErrUnrecognizedLetter))
+	assert.Equal(t, "AB-EFG", answer)
+
+	_, err = SolveBytes(nil)
+	assert.Error(t, err)
+}
+
+func TestSolveFromDataURI(t *testing.T) {
+	captcha := syntheticCaptcha(t, "ABCEFG")
+	encoded := base64.StdEncoding.EncodeToString(captcha)
+
+	for _, uri := range []string{
+		"data:image/png;base64," + encoded,
+		"DATA:image/png;BASE64," + encoded,
+		"data:;base64," + base64.RawURLEncoding.EncodeToString(captcha),
+		"data:image/png;base64," + encoded[:40] + "\n" + encoded[40:],
+		"data:image/png;base64," + url.PathEscape(encoded),
+		"data:image/png," + url.PathEscape(string(captcha)),
+	} {
+		answer, err := SolveFromDataURI(uri)
+		assert.NoError(t, err)
+		assert.Equal(t, "ABCEFG", answer)
+	}
+
+	for _, uri := range []string{
+		"image/png;base64," + encoded,
+		"data:image/png;base64",
+		"data:text/plain;base64," + encoded,
+		"data:image/png;base64,!!!",
+	} {
+		_, err := SolveFromDataURI(uri)
+		assert.Error(t, err, uri)
+	}
+}
+
+func TestSolveFromURLWithClient(t *testing.T) {
+	captcha := syntheticCaptcha(t, "ABCEFG")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "test-agent" || r.Header.Get("Referer") != "https://www.amazon.com/" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write(captcha)
+	}))
+	defer server.Close()
+
+	headers := map[string]string{"User-Agent": "test-agent", "Referer": "https://www.amazon.com/"}
+	answer, err := SolveFromURLWithClient(context.Background(), server.Client(), server.URL, headers)
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", answer)
+
+	// Requests without the headers are rejected by the server
+	_, err = SolveFromURLWithClient(context.Background(), nil, server.URL, nil)
+	assert.Error(t, err)
+
+	// Canceled requests fail
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = SolveFromURLWithClient(ctx, server.Client(), server.URL, headers)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
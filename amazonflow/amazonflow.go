@@ -0,0 +1,209 @@
+// Package amazonflow runs the whole captcha flow of Amazon, from fetching the captcha page to submitting the
+// answer, and returns the cookies of the unlocked session. Solving the image is only half of the job: the
+// answer must be submitted with GET to the action of the captcha form, together with its hidden amzn and amzn-r
+// tokens, in the field-keywords field and under the session the captcha was fetched with.
+//
+//	client := &amazonflow.Client{}
+//	cookies, err := client.Solve(ctx)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	// Send the cookies along with the next requests to Amazon
+//
+// The Client fetches, solves and submits captchas like an amazoncaptcha.Prefetcher, under its fallback policy.
+// FetchCaptcha and Submit run the two halves of the flow separately, e.g. to solve the captcha elsewhere.
+// Scrapers using net/http can instead leave the flow to NewTransport, which solves the captchas served in place
+// of the requested pages on the fly:
+//
+//	jar, _ := cookiejar.New(nil)
+//	client := &http.Client{Jar: jar, Transport: amazonflow.NewTransport(nil)}
+package amazonflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"sync"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// DefaultAttempts is the number of captchas Solve tries, unless configured otherwise.
+const DefaultAttempts = 3
+
+// ErrNoCaptcha is returned by Submit when no captcha was fetched, or the fetched one was submitted already.
+var ErrNoCaptcha = errors.New("no captcha to submit")
+
+// Client runs the captcha flow of Amazon under a single session. The zero value fetches captchas from
+// amazoncaptcha.DefaultCaptchaPageURL with a client of its own and solves them with the default solver.
+// Captchas are fetched, solved and submitted like those of an amazoncaptcha.Prefetcher, and Amazon serving its
+// captcha page again after an answer is reported with amazoncaptcha.ErrChallengeRejected or
+// amazoncaptcha.ErrChallengeExpired. A Client is safe for concurrent use, but it holds one captcha at a time:
+// concurrent flows need a Client each.
+type Client struct {
+	// URL is the captcha page, amazoncaptcha.DefaultCaptchaPageURL if empty.
+	URL string
+	// HTTPClient is the client of the session. A client with a cookie jar is used if nil; a client without
+	// a cookie jar is copied with one, since the cookies carry the session.
+	HTTPClient *http.Client
+	// Headers are set on every request, e.g. the User-Agent of the scraper.
+	Headers map[string]string
+	// Solver solves the captchas, the default solver if nil.
+	Solver *amazoncaptcha.Solver
+	// Attempts is the number of captchas Solve tries, DefaultAttempts if 0.
+	Attempts int
+	// Fallback governs what happens to captchas that are not solved well enough locally, see
+	// amazoncaptcha.FallbackPolicy. Its retries and external solver apply to every captcha Solve tries.
+	Fallback amazoncaptcha.FallbackPolicy
+
+	initOnce   sync.Once
+	initErr    error
+	client     *http.Client
+	prefetcher *amazoncaptcha.Prefetcher
+
+	mu      sync.Mutex
+	captcha *Captcha
+}
+
+// Captcha is a captcha fetched from the captcha page, with its image. Its answer is set by Submit.
+type Captcha struct {
+	*amazoncaptcha.Challenge
+	// Image is the captcha image.
+	Image []byte
+}
+
+// init sets up the client and the prefetcher fetching the captchas of the Client once.
+func (c *Client) init() error {
+	c.initOnce.Do(func() {
+		// Keep the cookies of the session in a jar, without changing the client of the caller
+		client := &http.Client{}
+		if c.HTTPClient != nil {
+			copied := *c.HTTPClient
+			client = &copied
+		}
+		if client.Jar == nil {
+			if client.Jar, c.initErr = cookiejar.New(nil); c.initErr != nil {
+				return
+			}
+		}
+		c.client = client
+
+		solver := c.Solver
+		if solver == nil {
+			solver = amazoncaptcha.DefaultSolver()
+		}
+		c.prefetcher, c.initErr = solver.NewPrefetcher(amazoncaptcha.PrefetchConfig{
+			URL:      c.URL,
+			Client:   client,
+			Headers:  c.Headers,
+			Size:     1,
+			Fallback: c.Fallback,
+		})
+	})
+	return c.initErr
+}
+
+// FetchCaptcha fetches the captcha page and its image, and makes the captcha the one submitted by Submit.
+func (c *Client) FetchCaptcha(ctx context.Context) (*Captcha, error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	challenge, image, err := c.prefetcher.Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	captcha := &Captcha{Challenge: challenge, Image: image}
+	c.setCaptcha(captcha)
+	return captcha, nil
+}
+
+// Submit submits answer to the captcha fetched last and returns the cookies of the session once Amazon accepted
+// it, see amazoncaptcha.Challenge.Submit. If Amazon serves its captcha page again, the returned error matches
+// amazoncaptcha.ErrChallengeRejected or amazoncaptcha.ErrChallengeExpired, and a new captcha must be fetched.
+// Submit fails with ErrNoCaptcha if no captcha was fetched.
+func (c *Client) Submit(ctx context.Context, answer string) ([]*http.Cookie, error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	captcha := c.takeCaptcha()
+	if captcha == nil {
+		return nil, ErrNoCaptcha
+	}
+	challenge := *captcha.Challenge
+	challenge.Answer = answer
+	return c.submit(ctx, &challenge)
+}
+
+// Solve runs the whole flow: it fetches a captcha, solves it as governed by Fallback, submits the answer and
+// returns the cookies of the session once Amazon accepted it. Rejected answers, expired captchas and captchas
+// the solver cannot read are replaced by new captchas, up to Attempts captchas in total, after which the
+// returned error matches amazoncaptcha.ErrChallengeRejected, amazoncaptcha.ErrChallengeExpired or the error of
+// the solver. A captcha fetched by FetchCaptcha and not submitted is discarded.
+func (c *Client) Solve(ctx context.Context) ([]*http.Cookie, error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	attempts := c.Attempts
+	if attempts == 0 {
+		attempts = DefaultAttempts
+	}
+
+	c.takeCaptcha()
+	for attempt := 1; ; attempt++ {
+		challenge, err := c.prefetcher.Solve(ctx)
+		if err == nil {
+			var cookies []*http.Cookie
+			if cookies, err = c.submit(ctx, challenge); err == nil {
+				return cookies, nil
+			}
+		}
+		if !retryable(err) || ctx.Err() != nil {
+			return nil, err
+		}
+		if attempt >= attempts {
+			return nil, fmt.Errorf("failed to solve captcha after %d attempts: %w", attempt, err)
+		}
+	}
+}
+
+// submit submits a solved captcha and returns the cookies of the session for the page behind it.
+func (c *Client) submit(ctx context.Context, challenge *amazoncaptcha.Challenge) ([]*http.Cookie, error) {
+	resp, err := challenge.Submit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return c.client.Jar.Cookies(resp.Request.URL), nil
+}
+
+// retryable reports whether err is the error of a captcha that another captcha may not fail with: an answer
+// Amazon did not accept, or a captcha the solver could not read.
+func retryable(err error) bool {
+	return errors.Is(err, amazoncaptcha.ErrChallengeRejected) || errors.Is(err, amazoncaptcha.ErrChallengeExpired) ||
+		unreadable(err)
+}
+
+// unreadable reports whether err is the error of a solve failing on the captcha itself, which another captcha
+// may not fail on.
+func unreadable(err error) bool {
+	return errors.Is(err, amazoncaptcha.ErrUnrecognizedLetter) || errors.Is(err, amazoncaptcha.ErrSegmentationFailed) ||
+		errors.Is(err, amazoncaptcha.ErrAnswerRejected)
+}
+
+// setCaptcha makes captcha the one submitted next.
+func (c *Client) setCaptcha(captcha *Captcha) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.captcha = captcha
+}
+
+// takeCaptcha returns the captcha submitted next and forgets it, since the tokens of a captcha are valid once.
+func (c *Client) takeCaptcha() *Captcha {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	captcha := c.captcha
+	c.captcha = nil
+	return captcha
+}
@@ -0,0 +1,227 @@
+package amazonflow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+	"github.com/stretchr/testify/assert"
+)
+
+// captchaSite serves captcha pages like Amazon does: every page carries new amzn tokens, answers are
+// accepted only with the tokens and the session cookie of their page, and wrong answers get a new captcha with an
+// error alert.
+// The product page is served in place of a captcha once the session is unlocked.
+type captchaSite struct {
+	t        *testing.T
+	captchas []amazoncaptcha.LabeledCaptcha
+
+	mu    sync.Mutex
+	pages int
+	// submissions holds the query of every submitted answer.
+	submissions []string
+	// reject is the number of correct answers still to be rejected, as if their tokens expired.
+	reject int
+	// brokenImages makes the captcha pages link to missing images.
+	brokenImages bool
+}
+
+// searchPage is a page with an image in a form, which is no captcha page.
+const searchPage = `<html><body><form action="/s"><img src="/logo.png"><input name="q"></form></body></html>`
+
+func newCaptchaSite(t *testing.T) (*captchaSite, *httptest.Server) {
+	captchas, err := amazoncaptcha.SelfTestCaptchas()
+	if !assert.NoError(t, err) || !assert.NotEmpty(t, captchas) {
+		t.FailNow()
+	}
+	site := &captchaSite{t: t, captchas: captchas}
+	server := httptest.NewServer(site)
+	t.Cleanup(server.Close)
+	return site, server
+}
+
+func (s *captchaSite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/":
+		_, _ = w.Write([]byte("<html><body>Welcome back</body></html>"))
+	case r.URL.Path == "/search":
+		_, _ = w.Write([]byte(searchPage))
+	case r.URL.Path == "/large":
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(strings.Repeat(" ", 2*amazoncaptcha.MaxPageSize)))
+	case r.URL.Path == "/product":
+		if cookie, err := r.Cookie("session-token"); err == nil && cookie.Value == "unlocked" {
+			_, _ = w.Write([]byte("<html><body>Product page</body></html>"))
+			return
+		}
+		s.servePage(w, false)
+	case strings.HasPrefix(r.URL.Path, "/captcha/"):
+		i, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/captcha/"), ".png"))
+		if err != nil || i >= len(s.captchas) {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(s.captchas[i].Image)
+	case r.URL.Path == "/errors/validateCaptcha":
+		query := r.URL.Query()
+		if query.Has(amazoncaptcha.AnswerField) {
+			s.mu.Lock()
+			s.submissions = append(s.submissions, r.URL.RawQuery)
+			rejected := s.reject > 0
+			if rejected {
+				s.reject--
+			}
+			s.mu.Unlock()
+			i, err := strconv.Atoi(strings.TrimPrefix(query.Get("amzn"), "token-"))
+			_, cookieErr := r.Cookie("session-id")
+			correct := err == nil && i < len(s.captchas) && query.Get(amazoncaptcha.AnswerField) == s.captchas[i].Answer
+			if !rejected && cookieErr == nil && correct {
+				http.SetCookie(w, &http.Cookie{Name: "session-token", Value: "unlocked", Path: "/"})
+				http.Redirect(w, r, query.Get("amzn-r"), http.StatusFound)
+				return
+			}
+			s.servePage(w, !correct)
+			return
+		}
+		s.servePage(w, false)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// servePage serves the next captcha page, with the error alert of a wrong answer if wrong.
+func (s *captchaSite) servePage(w http.ResponseWriter, wrong bool) {
+	s.mu.Lock()
+	i := s.pages % len(s.captchas)
+	s.pages++
+	image := fmt.Sprintf("/captcha/%d.png", i)
+	if s.brokenImages {
+		image = "/captcha/missing.png"
+	}
+	s.mu.Unlock()
+	alert := ""
+	if wrong {
+		alert = `<div class="a-alert-error">Please try again.</div>`
+	}
+	http.SetCookie(w, &http.Cookie{Name: "session-id", Value: "session", Path: "/"})
+	fmt.Fprintf(w, `<html><body>%s<form method="get" action="/errors/validateCaptcha">
+<input type=hidden name="amzn" value="token-%d"><input type=hidden name="amzn-r" value="/">
+<div class="a-row a-text-center"><img src="%s"></div>
+<input type="text" id="captchacharacters" name="field-keywords">
+</form></body></html>`, alert, i, image)
+}
+
+func TestClientSolve(t *testing.T) {
+	site, server := newCaptchaSite(t)
+	ctx := context.Background()
+
+	// The whole flow returns the cookies of the unlocked session
+	client := &Client{URL: server.URL + "/errors/validateCaptcha", Headers: map[string]string{"User-Agent": "scraper"}}
+	cookies, err := client.Solve(ctx)
+	assert.NoError(t, err)
+	names := make([]string, len(cookies))
+	for i, cookie := range cookies {
+		names[i] = cookie.Name
+	}
+	assert.ElementsMatch(t, []string{"session-id", "session-token"}, names)
+	if assert.Len(t, site.submissions, 1) {
+		assert.Contains(t, site.submissions[0], "amzn=token-0")
+		assert.Contains(t, site.submissions[0], "amzn-r=%2F")
+		assert.Contains(t, site.submissions[0], "field-keywords="+site.captchas[0].Answer)
+	}
+
+	// Nothing is left to submit
+	_, err = client.Submit(ctx, "ABCDEF")
+	assert.True(t, errors.Is(err, ErrNoCaptcha))
+}
+
+func TestClientSubmit(t *testing.T) {
+	site, server := newCaptchaSite(t)
+	ctx := context.Background()
+	httpClient := &http.Client{}
+	client := &Client{URL: server.URL + "/errors/validateCaptcha", HTTPClient: httpClient}
+
+	captcha, err := client.FetchCaptcha(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, server.URL+"/captcha/0.png", captcha.ImageURL)
+	assert.Equal(t, site.captchas[0].Image, captcha.Image)
+	assert.Equal(t, server.URL+"/errors/validateCaptcha", captcha.Action())
+	assert.Equal(t, "token-0", captcha.Form().Get("amzn"))
+	assert.Equal(t, "127.0.0.1", captcha.Domain())
+
+	// A wrong answer is rejected, and Solve starts over with a new captcha
+	_, err = client.Submit(ctx, "XXXXXX")
+	assert.True(t, errors.Is(err, amazoncaptcha.ErrChallengeRejected))
+	assert.Empty(t, captcha.Answer)
+	cookies, err := client.Solve(ctx)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, cookies)
+	assert.Equal(t, 3, site.pages)
+	assert.Len(t, site.submissions, 2)
+
+	// The client of the caller is used without its cookie jar being changed
+	assert.Nil(t, httpClient.Jar)
+}
+
+func TestClientSolveAttempts(t *testing.T) {
+	site, server := newCaptchaSite(t)
+
+	// Captchas the solver cannot answer are replaced by new ones, up to Attempts captchas
+	solver, err := amazoncaptcha.NewSolver(amazoncaptcha.WithAnswerFilter(func(string) error {
+		return errors.New("never")
+	}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	client := &Client{URL: server.URL + "/errors/validateCaptcha", Solver: solver, Attempts: 2}
+	_, err = client.Solve(context.Background())
+	assert.True(t, errors.Is(err, amazoncaptcha.ErrAnswerRejected))
+	assert.Equal(t, 2, site.pages)
+	assert.Empty(t, site.submissions)
+
+	// Answers Amazon does not accept are replaced too, telling expired captchas apart
+	site.reject = 2
+	pages := site.pages
+	client = &Client{URL: server.URL + "/errors/validateCaptcha", Attempts: 2}
+	_, err = client.Solve(context.Background())
+	assert.True(t, errors.Is(err, amazoncaptcha.ErrChallengeExpired))
+	assert.False(t, errors.Is(err, amazoncaptcha.ErrChallengeRejected))
+	assert.Equal(t, pages+4, site.pages)
+
+	// The fallback policy hands the captchas the solver cannot answer to the external solver
+	external := answerAll{site}
+	client = &Client{URL: server.URL + "/errors/validateCaptcha", Solver: solver, Fallback: amazoncaptcha.FallbackPolicy{External: external}}
+	cookies, err := client.Solve(context.Background())
+	assert.NoError(t, err)
+	assert.NotEmpty(t, cookies)
+
+	_, err = (&Client{URL: server.URL + "/missing"}).FetchCaptcha(context.Background())
+	assert.Error(t, err)
+	_, err = (&Client{URL: server.URL + "/"}).FetchCaptcha(context.Background())
+	assert.True(t, errors.Is(err, amazoncaptcha.ErrNoCaptchaImage))
+}
+
+// answerAll is an external solver answering the captchas of a captchaSite.
+type answerAll struct {
+	site *captchaSite
+}
+
+// Solve implements amazoncaptcha.ExternalSolver.
+func (a answerAll) Solve(_ context.Context, img []byte) (string, error) {
+	for _, captcha := range a.site.captchas {
+		if bytes.Equal(captcha.Image, img) {
+			return captcha.Answer, nil
+		}
+	}
+	return "", errors.New("unknown captcha")
+}
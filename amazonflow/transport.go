@@ -0,0 +1,369 @@
+package amazonflow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// TransportOption configures the http.RoundTripper created by NewTransport.
+type TransportOption func(*transport)
+
+// WithSolver makes the transport solve captchas with solver instead of the default solver.
+func WithSolver(solver *amazoncaptcha.Solver) TransportOption {
+	return func(t *transport) {
+		t.solver = solver
+	}
+}
+
+// WithAttempts sets the number of captchas the transport tries per request, DefaultAttempts by default.
+func WithAttempts(attempts int) TransportOption {
+	return func(t *transport) {
+		if attempts > 0 {
+			t.attempts = attempts
+		}
+	}
+}
+
+// WithHosts makes the transport also look for captchas in the responses of hosts besides those of the Amazon
+// stores, e.g. of a mirror or a test server. Hosts are host names, without ports.
+func WithHosts(hosts ...string) TransportOption {
+	return func(t *transport) {
+		for _, host := range hosts {
+			t.hosts[strings.ToLower(host)] = true
+		}
+	}
+}
+
+// amazonDomains are the top-level domains of the Amazon stores.
+var amazonDomains = map[string]bool{
+	"ae": true, "ca": true, "cn": true, "co.jp": true, "co.uk": true, "co.za": true, "com": true, "com.au": true,
+	"com.be": true, "com.br": true, "com.mx": true, "com.tr": true, "de": true, "eg": true, "es": true, "fr": true,
+	"ie": true, "in": true, "it": true, "nl": true, "pl": true, "sa": true, "se": true, "sg": true,
+}
+
+// amazonHost reports whether host is a host name of an Amazon store, e.g. www.amazon.com or amazon.co.uk.
+func amazonHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	i := strings.LastIndex("."+host, ".amazon.")
+	return i >= 0 && amazonDomains[host[i+len("amazon."):]]
+}
+
+// transport is the http.RoundTripper created by NewTransport.
+type transport struct {
+	base     http.RoundTripper
+	solver   *amazoncaptcha.Solver
+	attempts int
+	hosts    map[string]bool
+}
+
+// NewTransport returns an http.RoundTripper that makes requests with base, http.DefaultTransport if nil, and
+// solves the captcha pages Amazon serves in place of the requested pages: the captcha is solved, the answer is
+// submitted with the headers and cookies of the request, and the request is made again, transparently to the
+// caller. The cookies set along the way, e.g. those of the unlocked session, are added to the returned response,
+// so that the cookie jar of the http.Client keeps them for the requests that follow.
+//
+// Only the responses of Amazon stores, and of the hosts of WithHosts, are looked at. The first
+// amazoncaptcha.MaxPageSize bytes of their HTML bodies are read to look for captcha pages; other responses, and
+// pages that are not captcha pages, are passed through unchanged. Requests with a body are only retried if the
+// body can be replayed, see http.Request.GetBody; otherwise the captcha page is returned as it is, and so is it
+// if its captcha image cannot be fetched or solved at all, e.g. because it cannot be decoded. If no captcha is
+// solved within the attempts, the request fails with the error of the last attempt, matching
+// amazoncaptcha.ErrChallengeRejected or amazoncaptcha.ErrChallengeExpired if Amazon did not accept the answers,
+// as told by amazoncaptcha.AnswerPageError. Concurrent requests hitting a captcha each solve their own.
+func NewTransport(base http.RoundTripper, opts ...TransportOption) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &transport{base: base, solver: amazoncaptcha.DefaultSolver(), attempts: DefaultAttempts, hosts: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if host := strings.ToLower(req.URL.Hostname()); !amazonHost(host) && !t.hosts[host] {
+		return resp, nil
+	}
+	captcha, err := captchaInterstitial(resp)
+	if err != nil || captcha == nil {
+		return resp, err
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	// Solve captchas under the session of the request until it gets through, keeping the captcha page to return
+	// it as it is if its captcha cannot be solved at all
+	page := resp
+	s := newSession(req)
+	s.update(resp)
+	var lastErr error
+	for attempt := 1; attempt <= t.attempts; attempt++ {
+		err := t.solve(req.Context(), s, captcha)
+		var rejected *rejectedError
+		if errors.As(err, &rejected) {
+			lastErr, captcha, page = err, rejected.next, rejected.page
+			continue
+		}
+		var imageErr *imageError
+		if errors.As(err, &imageErr) {
+			s.addCookies(page)
+			return page, nil
+		}
+		if err != nil && !unreadable(err) {
+			return nil, err
+		}
+		lastErr = err
+
+		// Make the request again with the cookies of the session, which gets through once the captcha is solved,
+		// and gets a new captcha if the solver could not read this one
+		retry, err := s.retry(req)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := t.base.RoundTrip(retry)
+		if err != nil {
+			return nil, err
+		}
+		s.update(resp)
+		if captcha, err = captchaInterstitial(resp); err != nil || captcha == nil {
+			s.addCookies(resp)
+			return resp, err
+		}
+		page = resp
+		if lastErr == nil {
+			lastErr = fmt.Errorf("%w: captcha served again after it was solved", amazoncaptcha.ErrChallengeExpired)
+		}
+	}
+	return nil, fmt.Errorf("failed to solve captcha after %d attempts: %w", t.attempts, lastErr)
+}
+
+// rejectedError is the error of an answer Amazon did not accept, carrying the captcha it served in its place and
+// the page of that captcha. err is amazoncaptcha.ErrChallengeRejected or amazoncaptcha.ErrChallengeExpired.
+type rejectedError struct {
+	err    error
+	answer string
+	next   *amazoncaptcha.CaptchaForm
+	page   *http.Response
+}
+
+// Error implements the error interface.
+func (e *rejectedError) Error() string {
+	return fmt.Sprintf("%v: %s", e.err, e.answer)
+}
+
+// Unwrap returns the error telling a rejected answer from an expired captcha.
+func (e *rejectedError) Unwrap() error {
+	return e.err
+}
+
+// imageError is the error of a captcha whose image could not be fetched or solved at all, as opposed to a
+// captcha the solver could not read.
+type imageError struct {
+	err error
+}
+
+// Error implements the error interface.
+func (e *imageError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *imageError) Unwrap() error {
+	return e.err
+}
+
+// solve downloads the image of a captcha, solves it and submits the answer under the session. If Amazon serves
+// another captcha in return, the error is a *rejectedError; if the image cannot be fetched or solved at all, it
+// is an *imageError.
+func (t *transport) solve(ctx context.Context, s *session, captcha *amazoncaptcha.CaptchaForm) error {
+	req, err := s.request(ctx, captcha.ImageURL)
+	if err != nil {
+		return err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return &imageError{fmt.Errorf("failed to fetch captcha image: %w", err)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return &imageError{fmt.Errorf("failed to fetch captcha image: unexpected HTTP status code: %d", resp.StatusCode)}
+	}
+	image, err := readImage(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return &imageError{fmt.Errorf("failed to read captcha image: %w", err)}
+	}
+	answer, err := t.solver.SolveBytes(image)
+	if err != nil && !unreadable(err) {
+		return &imageError{fmt.Errorf("failed to solve captcha: %w", err)}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to solve captcha: %w", err)
+	}
+
+	// Submit the answer, Amazon redirects to the page behind the captcha once it is accepted
+	submitURL, err := captcha.SubmitURL(answer)
+	if err != nil {
+		return err
+	}
+	if req, err = s.request(ctx, submitURL); err != nil {
+		return err
+	}
+	if resp, err = t.base.RoundTrip(req); err != nil {
+		return fmt.Errorf("failed to submit captcha: %w", err)
+	}
+	s.update(resp)
+	next, err := captchaInterstitial(resp)
+	if err != nil {
+		return err
+	}
+	if next != nil {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return &rejectedError{err: amazoncaptcha.AnswerPageError(bytes.NewReader(body)), answer: answer, next: next, page: resp}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("failed to submit captcha: unexpected HTTP status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// readImage reads a captcha image, refusing images above amazoncaptcha.MaxCaptchaImageBytes or
+// amazoncaptcha.MaxCaptchaImagePixels.
+func readImage(r io.Reader) ([]byte, error) {
+	limited, err := amazoncaptcha.LimitImageReader(r, amazoncaptcha.MaxCaptchaImageBytes, amazoncaptcha.MaxCaptchaImagePixels)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(limited)
+}
+
+// captchaInterstitial returns the captcha form of a response serving a captcha page, or nil if it serves another
+// page. Up to amazoncaptcha.MaxPageSize bytes of the body of an HTML response are read and replayed, so that the
+// body can be read again from the start; larger bodies are not captcha pages.
+func captchaInterstitial(resp *http.Response) (*amazoncaptcha.CaptchaForm, error) {
+	contentType := resp.Header.Get("Content-Type")
+	if contentType != "" && !strings.Contains(contentType, "html") {
+		return nil, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, amazoncaptcha.MaxPageSize+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(body) > amazoncaptcha.MaxPageSize {
+		resp.Body = replayedBody{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return nil, nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if resp.Request == nil {
+		return nil, nil
+	}
+	form, err := amazoncaptcha.ParseCaptchaForm(resp.Request.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil
+	}
+	return form, nil
+}
+
+// replayedBody is the body of a response whose first bytes were read already, replaying them before the rest.
+type replayedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// session holds the headers and cookies of a request solving a captcha, with the cookies set along the way.
+// A RoundTripper sees no cookie jar, so the cookies are carried by hand, and only to the host of the request.
+type session struct {
+	host    string
+	header  http.Header
+	cookies []*http.Cookie
+	// setCookies holds the Set-Cookie headers received from the host while solving the captcha.
+	setCookies []string
+}
+
+// newSession starts a session with the headers and cookies of req.
+func newSession(req *http.Request) *session {
+	s := &session{host: req.URL.Hostname(), header: req.Header.Clone(), cookies: req.Cookies()}
+	s.header.Del("Cookie")
+	return s
+}
+
+// update applies the cookies set by a response of the host of the session.
+func (s *session) update(resp *http.Response) {
+	if resp.Request == nil || resp.Request.URL.Hostname() != s.host {
+		return
+	}
+	for _, cookie := range resp.Cookies() {
+		kept := s.cookies[:0]
+		for _, c := range s.cookies {
+			if c.Name != cookie.Name {
+				kept = append(kept, c)
+			}
+		}
+		s.cookies = kept
+		if cookie.MaxAge >= 0 {
+			s.cookies = append(s.cookies, &http.Cookie{Name: cookie.Name, Value: cookie.Value})
+		}
+	}
+	s.setCookies = append(s.setCookies, resp.Header.Values("Set-Cookie")...)
+}
+
+// setCookieHeader sets the Cookie header of req to the cookies of the session.
+func (s *session) setCookieHeader(req *http.Request) {
+	req.Header.Del("Cookie")
+	for _, cookie := range s.cookies {
+		req.AddCookie(cookie)
+	}
+}
+
+// request creates a GET request with the headers of the session, and its cookies if rawURL is on its host.
+func (s *session) request(ctx context.Context, rawURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header = s.header.Clone()
+	if req.URL.Hostname() == s.host {
+		s.setCookieHeader(req)
+	}
+	return req, nil
+}
+
+// retry returns a copy of req with the cookies of the session, replaying its body.
+func (s *session) retry(req *http.Request) (*http.Request, error) {
+	retry := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to replay request body: %w", err)
+		}
+		retry.Body = body
+	}
+	s.setCookieHeader(retry)
+	return retry, nil
+}
+
+// addCookies replaces the Set-Cookie headers of the final response of the session with all those received while
+// solving the captcha, its own last, so that the cookie jar of the client learns the cookies of the session.
+func (s *session) addCookies(resp *http.Response) {
+	if len(s.setCookies) > 0 {
+		resp.Header["Set-Cookie"] = s.setCookies
+	}
+}
@@ -0,0 +1,138 @@
+package amazonflow
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"testing"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+	"github.com/stretchr/testify/assert"
+)
+
+// fetch makes a request with client and returns the body of the response.
+func fetch(t *testing.T, client *http.Client, req *http.Request) (string, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return string(body), nil
+}
+
+func TestTransport(t *testing.T) {
+	site, server := newCaptchaSite(t)
+	jar, err := cookiejar.New(nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	client := &http.Client{Jar: jar, Transport: NewTransport(nil, WithHosts("127.0.0.1"))}
+
+	// The captcha interstitial is solved and the request made again, transparently
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/product", nil)
+	req.Header.Set("User-Agent", "scraper")
+	body, err := fetch(t, client, req)
+	assert.NoError(t, err)
+	assert.Contains(t, body, "Product page")
+	assert.Len(t, site.submissions, 1)
+
+	// The cookie jar keeps the unlocked session, so that later requests get through right away
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/product", nil)
+	body, err = fetch(t, client, req)
+	assert.NoError(t, err)
+	assert.Contains(t, body, "Product page")
+	assert.Len(t, site.submissions, 1)
+	assert.Equal(t, 1, site.pages)
+
+	// Other pages pass through
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/captcha/0.png", nil)
+	body, err = fetch(t, client, req)
+	assert.NoError(t, err)
+	assert.Equal(t, string(site.captchas[0].Image), body)
+}
+
+func TestTransportRejected(t *testing.T) {
+	site, server := newCaptchaSite(t)
+	site.reject = 1
+
+	// A rejected answer is followed by the captcha served in its place
+	client := &http.Client{Transport: NewTransport(http.DefaultTransport, WithHosts("127.0.0.1"))}
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/product", nil)
+	body, err := fetch(t, client, req)
+	assert.NoError(t, err)
+	assert.Contains(t, body, "Product page")
+	assert.Len(t, site.submissions, 2)
+
+	// Without attempts left, the request fails
+	site.reject = 2
+	client = &http.Client{Transport: NewTransport(nil, WithHosts("127.0.0.1"), WithAttempts(2))}
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/product", nil)
+	_, err = fetch(t, client, req)
+	assert.True(t, errors.Is(err, amazoncaptcha.ErrChallengeExpired))
+
+	// Captchas the solver cannot answer are replaced by new ones
+	solver, err := amazoncaptcha.NewSolver(amazoncaptcha.WithAnswerFilter(func(string) error {
+		return errors.New("never")
+	}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	pages := site.pages
+	client = &http.Client{Transport: NewTransport(nil, WithHosts("127.0.0.1"), WithSolver(solver), WithAttempts(3))}
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/product", nil)
+	_, err = fetch(t, client, req)
+	assert.True(t, errors.Is(err, amazoncaptcha.ErrAnswerRejected))
+	assert.Equal(t, pages+4, site.pages)
+
+	// Requests whose body cannot be replayed get the captcha page
+	req, _ = http.NewRequest(http.MethodPost, server.URL+"/product", io.NopCloser(strings.NewReader("form")))
+	body, err = fetch(t, client, req)
+	assert.NoError(t, err)
+	assert.True(t, amazoncaptcha.IsCaptchaPage(strings.NewReader(body)))
+}
+
+func TestTransportPassThrough(t *testing.T) {
+	site, server := newCaptchaSite(t)
+
+	// Responses of other hosts than Amazon are not looked at
+	client := &http.Client{Transport: NewTransport(nil)}
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/product", nil)
+	body, err := fetch(t, client, req)
+	assert.NoError(t, err)
+	assert.True(t, amazoncaptcha.IsCaptchaPage(strings.NewReader(body)))
+	assert.Empty(t, site.submissions)
+
+	// Pages that are not captcha pages pass through unchanged, however large
+	client = &http.Client{Transport: NewTransport(nil, WithHosts("127.0.0.1"))}
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/search", nil)
+	body, err = fetch(t, client, req)
+	assert.NoError(t, err)
+	assert.Equal(t, searchPage, body)
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/large", nil)
+	body, err = fetch(t, client, req)
+	assert.NoError(t, err)
+	assert.Len(t, body, 2*amazoncaptcha.MaxPageSize)
+
+	// Captcha pages whose image cannot be fetched are returned as they are
+	site.mu.Lock()
+	site.brokenImages = true
+	site.mu.Unlock()
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/product", nil)
+	body, err = fetch(t, client, req)
+	assert.NoError(t, err)
+	assert.True(t, amazoncaptcha.IsCaptchaPage(strings.NewReader(body)))
+	assert.Empty(t, site.submissions)
+}
+
+func TestAmazonHost(t *testing.T) {
+	for _, host := range []string{"www.amazon.com", "amazon.co.uk", "smile.amazon.de", "WWW.AMAZON.CO.JP."} {
+		assert.True(t, amazonHost(host), host)
+	}
+	for _, host := range []string{"example.com", "amazon.example.com", "notamazon.com", "amazon.com.example.org", "amazon"} {
+		assert.False(t, amazonHost(host), host)
+	}
+}
@@ -0,0 +1,184 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Store archives the images of the captchas a Solver failed to solve, e.g. to label them later and add their
+// letters to the training data. Names are made of the hash of the image and its extension, see
+// SetFailureStore, so every name is a valid file name and object key.
+type Store interface {
+	Put(name string, b []byte) error
+}
+
+// DirStore is a Store that writes every image into a directory, created on first use.
+type DirStore struct {
+	Dir string
+}
+
+// Put implements Store, replacing an image with the same name.
+func (s *DirStore) Put(name string, b []byte) error {
+	if err := os.MkdirAll(s.Dir, 0777); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(s.Dir, filepath.Base(name)), b, 0644); err != nil {
+		return fmt.Errorf("failed to write archived image: %w", err)
+	}
+	return nil
+}
+
+// DefaultArchiveQuality is the JPEG quality CompressingStore re-encodes images at if no Quality is set.
+const DefaultArchiveQuality = 75
+
+// CompressingStore is a Store that re-encodes every image as a JPEG before passing it on to Store, so that
+// long-running services don't fill their disks with full-size duplicates. Images larger than MaxWidth by
+// MaxHeight are scaled down to fit, keeping their aspect ratio, and the quality is lowered step by step until
+// the encoded image fits into MaxBytes. Images that cannot be decoded, and images whose original bytes are
+// already smaller than their re-encoded copy, are stored as they are. To archive the original bytes of every
+// image, use the underlying Store directly.
+type CompressingStore struct {
+	// Store receives the re-encoded images, named with the extension ".jpg".
+	Store Store
+	// Quality is the JPEG quality from 1 to 100, DefaultArchiveQuality if zero.
+	Quality int
+	// MaxWidth and MaxHeight cap the size of the images in pixels, no cap if zero.
+	MaxWidth  int
+	MaxHeight int
+	// MaxBytes caps the size of the encoded images, no cap if zero. Images that do not fit even at the
+	// lowest quality are not stored, and Put returns an error.
+	MaxBytes int
+}
+
+// Put implements Store, re-encoding the image before storing it.
+func (s *CompressingStore) Put(name string, b []byte) error {
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return s.Store.Put(name, b)
+	}
+	img = fitImage(img, s.MaxWidth, s.MaxHeight)
+
+	// Lower the quality until the image fits into the size cap
+	quality := s.Quality
+	if quality <= 0 || quality > 100 {
+		quality = DefaultArchiveQuality
+	}
+	var buf bytes.Buffer
+	for {
+		buf.Reset()
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return fmt.Errorf("failed to encode archived image: %w", err)
+		}
+		if s.MaxBytes <= 0 || buf.Len() <= s.MaxBytes || quality == 1 {
+			break
+		}
+		if quality -= 10; quality < 1 {
+			quality = 1
+		}
+	}
+
+	// Keep the original if it is not scaled down and smaller than its copy
+	if len(b) <= buf.Len() && img.Bounds().Size() == imageSize(b) && (s.MaxBytes <= 0 || len(b) <= s.MaxBytes) {
+		return s.Store.Put(name, b)
+	}
+	if s.MaxBytes > 0 && buf.Len() > s.MaxBytes {
+		return fmt.Errorf("archived image of %d bytes exceeds the maximum of %d bytes", buf.Len(), s.MaxBytes)
+	}
+	return s.Store.Put(strings.TrimSuffix(name, filepath.Ext(name))+".jpg", buf.Bytes())
+}
+
+// imageSize returns the size of an encoded image, or the zero size if it cannot be decoded.
+func imageSize(b []byte) image.Point {
+	config, _, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return image.Point{}
+	}
+	return image.Pt(config.Width, config.Height)
+}
+
+// fitImage scales img down to fit into maxWidth by maxHeight pixels, keeping its aspect ratio, by averaging the
+// colors of the pixels every scaled pixel covers. Images that already fit are returned as they are.
+func fitImage(img image.Image, maxWidth, maxHeight int) image.Image {
+	bounds := img.Bounds()
+	scale := 1.0
+	if maxWidth > 0 && bounds.Dx() > maxWidth {
+		scale = float64(maxWidth) / float64(bounds.Dx())
+	}
+	if maxHeight > 0 && float64(bounds.Dy())*scale > float64(maxHeight) {
+		scale = float64(maxHeight) / float64(bounds.Dy())
+	}
+	if scale == 1 {
+		return img
+	}
+
+	width := int(float64(bounds.Dx()) * scale)
+	if width < 1 {
+		width = 1
+	}
+	height := int(float64(bounds.Dy()) * scale)
+	if height < 1 {
+		height = 1
+	}
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*bounds.Dy()/height, (y+1)*bounds.Dy()/height
+		for x := 0; x < width; x++ {
+			x0, x1 := x*bounds.Dx()/width, (x+1)*bounds.Dx()/width
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(bounds.Min.X+sx, bounds.Min.Y+sy).RGBA()
+					r, g, b, a, n = r+cr, g+cg, b+cb, a+ca, n+1
+				}
+			}
+			scaled.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return scaled
+}
+
+// SetFailureStore enables archiving into store the image of every captcha that could not be solved: images
+// that could not be decoded or segmented, and captchas with letters that could not be recognized. Every image
+// is named after its ImageHash with the extension of its format, e.g. "<hash>.jpg", so that it can be matched
+// with its journal entries. Errors returned by the store are ignored so that archiving never fails a solve.
+// Passing nil disables the archive, which is the default.
+func SetFailureStore(store Store) {
+	defaultSolver().SetFailureStore(store)
+}
+
+// SetFailureStore enables archiving the images of the captchas the Solver failed to solve into store.
+func (s *Solver) SetFailureStore(store Store) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+	s.failureStore = store
+}
+
+// currentFailureStore returns the configured failure store, or nil if archiving is disabled.
+func (s *Solver) currentFailureStore() Store {
+	s.hooksMu.RLock()
+	defer s.hooksMu.RUnlock()
+	return s.failureStore
+}
+
+// imageExtension returns the file extension of the format of an encoded image, ".bin" if it is unknown.
+func imageExtension(b []byte) string {
+	switch http.DetectContentType(b) {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	default:
+		return ".bin"
+	}
+}
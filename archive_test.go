@@ -0,0 +1,135 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryStore struct {
+	mu     sync.Mutex
+	images map[string][]byte
+}
+
+func (s *memoryStore) Put(name string, b []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.images[name] = b
+	return nil
+}
+
+func TestFailureStore(t *testing.T) {
+	store := &memoryStore{images: make(map[string][]byte)}
+	solver, err := NewSolver(WithFailureStore(store))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Solved captchas are not archived
+	_, err = solver.Solve(bytes.NewReader(syntheticCaptcha(t, "ABCEFG")))
+	assert.NoError(t, err)
+	assert.Empty(t, store.images)
+
+	// Captchas with unknown letters and images that are not captchas are
+	unsolved := flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)
+	_, err = solver.Solve(bytes.NewReader(unsolved))
+	assert.Error(t, err)
+	_, err = solver.Solve(bytes.NewReader([]byte("not an image")))
+	assert.Error(t, err)
+	assert.Equal(t, map[string][]byte{
+		ImageHash(unsolved) + ".png":               unsolved,
+		ImageHash([]byte("not an image")) + ".bin": []byte("not an image"),
+	}, store.images)
+
+	// Disabling the archive stops it
+	solver.SetFailureStore(nil)
+	_, _ = solver.Solve(bytes.NewReader(flipPixel(t, syntheticCaptcha(t, "HJKLMN"), 0)))
+	assert.Len(t, store.images, 2)
+}
+
+func TestDirStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "failed")
+	store := &DirStore{Dir: dir}
+	assert.NoError(t, store.Put("captcha.jpg", []byte("image")))
+
+	b, err := os.ReadFile(filepath.Join(dir, "captcha.jpg"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("image"), b)
+
+	// Names cannot escape the directory
+	assert.NoError(t, store.Put("../escaped.jpg", []byte("image")))
+	assert.FileExists(t, filepath.Join(dir, "escaped.jpg"))
+}
+
+// noisyPNG encodes an image of random colors, which PNG cannot compress.
+func noisyPNG(t *testing.T, width, height int) []byte {
+	rnd := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(rnd.Intn(256))
+	}
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestCompressingStore(t *testing.T) {
+	memory := &memoryStore{images: make(map[string][]byte)}
+	store := &CompressingStore{Store: memory, MaxWidth: 200, MaxHeight: 200}
+
+	// Large images are scaled down and re-encoded as JPEGs
+	noisy := noisyPNG(t, 400, 140)
+	assert.NoError(t, store.Put("noisy.png", noisy))
+	if assert.Contains(t, memory.images, "noisy.jpg") {
+		b := memory.images["noisy.jpg"]
+		assert.Less(t, len(b), len(noisy))
+		img, err := jpeg.Decode(bytes.NewReader(b))
+		assert.NoError(t, err)
+		assert.Equal(t, image.Pt(200, 70), img.Bounds().Size())
+	}
+
+	// Images smaller than their copies and images that cannot be decoded are stored as they are
+	captcha := syntheticCaptcha(t, "ABCEFG")
+	assert.NoError(t, store.Put("captcha.png", captcha))
+	assert.Equal(t, captcha, memory.images["captcha.png"])
+	assert.NoError(t, store.Put("captcha.bin", []byte("not an image")))
+	assert.Equal(t, []byte("not an image"), memory.images["captcha.bin"])
+
+	// The quality is lowered to fit into the size cap
+	store = &CompressingStore{Store: memory, Quality: 100, MaxBytes: len(memory.images["noisy.jpg"])}
+	assert.NoError(t, store.Put("capped.png", noisyPNG(t, 200, 70)))
+	assert.LessOrEqual(t, len(memory.images["capped.jpg"]), store.MaxBytes)
+
+	// Images that never fit are not stored
+	store.MaxBytes = 10
+	assert.Error(t, store.Put("tiny.png", noisy))
+	assert.NotContains(t, memory.images, "tiny.jpg")
+	assert.NotContains(t, memory.images, "tiny.png")
+}
+
+func TestFitImage(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 4, 2))
+	img.SetGray(0, 0, color.Gray{Y: 255})
+	img.SetGray(1, 1, color.Gray{Y: 255})
+
+	// Every scaled pixel averages the pixels it covers, at 8 bits per channel
+	scaled := fitImage(img, 2, 0)
+	assert.Equal(t, image.Pt(2, 1), scaled.Bounds().Size())
+	r, _, _, _ := scaled.At(0, 0).RGBA()
+	assert.Equal(t, uint32(0x7f7f), r)
+
+	// Images that fit are not copied
+	assert.True(t, fitImage(img, 4, 2) == image.Image(img))
+}
@@ -0,0 +1,94 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"compress/zlib"
+	"image"
+	"sync"
+)
+
+// arena is a per-solve allocator: one buffer sliced for the gray image, the binary image, the letter crops
+// and the uncompressed features of a captcha, plus a reusable zlib compressor. Arenas are pooled, so a solve
+// in steady state makes a handful of allocations instead of one per intermediate image, and its memory
+// usage is bounded by the size of the captcha. A nil *arena allocates from the heap, for results that
+// outlive the solve.
+type arena struct {
+	buf []byte
+	off int
+
+	compressed bytes.Buffer
+	compressor *zlib.Writer
+}
+
+// arenaPool holds the arenas released by finished solves.
+var arenaPool = sync.Pool{
+	New: func() interface{} {
+		return &arena{}
+	},
+}
+
+// getArena returns an empty arena from the pool.
+func getArena() *arena {
+	return arenaPool.Get().(*arena)
+}
+
+// release returns the arena to the pool. Nothing allocated from it may be used afterwards.
+func (a *arena) release() {
+	if a == nil {
+		return
+	}
+	a.off = 0
+	arenaPool.Put(a)
+}
+
+// reserve makes sure the arena holds at least n free bytes, growing its buffer if it is still unused.
+// It is called once the size of the captcha is known.
+func (a *arena) reserve(n int) {
+	if a == nil || a.off != 0 || len(a.buf) >= n {
+		return
+	}
+	a.buf = make([]byte, n)
+}
+
+// alloc returns n zeroed bytes, from the arena while it has room and from the heap otherwise.
+func (a *arena) alloc(n int) []byte {
+	if a == nil || a.off+n > len(a.buf) {
+		return make([]byte, n)
+	}
+	b := a.buf[a.off : a.off+n : a.off+n]
+	a.off += n
+	for i := range b {
+		b[i] = 0
+	}
+	return b
+}
+
+// newGray returns a gray image with bounds r allocated from the arena, like image.NewGray.
+func (a *arena) newGray(r image.Rectangle) *image.Gray {
+	return &image.Gray{Pix: a.alloc(r.Dx() * r.Dy()), Stride: r.Dx(), Rect: r}
+}
+
+// compress compresses b with zlib at the best compression level, as ExtractFeatures does,
+// reusing the compressor of the arena. The returned slice is only valid until the next call.
+func (a *arena) compress(b []byte) ([]byte, error) {
+	if a == nil {
+		return compressFeature(b)
+	}
+	a.compressed.Reset()
+	if a.compressor == nil {
+		zw, err := zlib.NewWriterLevel(&a.compressed, zlib.BestCompression)
+		if err != nil {
+			return nil, err
+		}
+		a.compressor = zw
+	} else {
+		a.compressor.Reset(&a.compressed)
+	}
+	if _, err := a.compressor.Write(b); err != nil {
+		return nil, err
+	}
+	if err := a.compressor.Close(); err != nil {
+		return nil, err
+	}
+	return a.compressed.Bytes(), nil
+}
@@ -0,0 +1,81 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArena(t *testing.T) {
+	a := &arena{}
+	a.reserve(16)
+
+	// Allocations are sliced from the buffer while it has room, and zeroed
+	b := a.alloc(10)
+	assert.Len(t, b, 10)
+	assert.Equal(t, 10, a.off)
+	b[0] = 1
+	assert.Equal(t, 10, cap(b))
+
+	// Larger allocations fall back to the heap
+	assert.Len(t, a.alloc(10), 10)
+	assert.Equal(t, 10, a.off)
+
+	// Reused buffers are zeroed again
+	a.off = 0
+	assert.Equal(t, make([]byte, 10), a.alloc(10))
+
+	// A nil arena allocates from the heap
+	var none *arena
+	none.reserve(8)
+	assert.Len(t, none.alloc(8), 8)
+	img := none.newGray(image.Rect(0, 0, 3, 2))
+	assert.Len(t, img.Pix, 6)
+	none.release()
+}
+
+func TestExtractFeaturesWithArena(t *testing.T) {
+	letters, err := FindLetters(bytes.NewReader(syntheticCaptcha(t, "ABCEFG")))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Features extracted with a reused arena are the same as with the heap
+	a := getArena()
+	defer a.release()
+	a.reserve(1 << 16)
+	for i := 0; i < 2; i++ {
+		for _, letter := range letters {
+			want, err := ExtractFeatures(letter)
+			assert.NoError(t, err)
+			got, err := extractFeatures(letter, a)
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+		}
+	}
+}
+
+func TestSolveReusesArenas(t *testing.T) {
+	sink := &collectingSink{}
+	SetLetterSink(sink)
+	defer SetLetterSink(nil)
+
+	result, err := SolveDetailed(bytes.NewReader(flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "AB-EFG", result.Text)
+	}
+	if !assert.Len(t, sink.letters, 1) {
+		return
+	}
+	captured := append([]byte(nil), sink.letters[0].Image.Pix...)
+
+	// A later solve reuses the arena, but captured letter images outlive the solve they come from
+	for i := 0; i < 3; i++ {
+		answer, err := Solve(bytes.NewReader(syntheticCaptcha(t, "XYTUKH")))
+		assert.NoError(t, err)
+		assert.Equal(t, "XYTUKH", answer)
+	}
+	assert.Equal(t, captured, sink.letters[0].Image.Pix)
+}
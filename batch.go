@@ -0,0 +1,137 @@
+package amazoncaptcha
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// BatchResult is the outcome of solving one captcha of a batch.
+type BatchResult struct {
+	// Index is the position of the captcha in the input channel, starting at 0.
+	Index int
+	// Result is the result returned by SolveDetailed, nil if Err is set.
+	Result *Result
+	// Err is the error returned by SolveDetailed, if any.
+	Err error
+}
+
+// BatchError is returned by CollectBatch when some captchas of a batch could not be solved.
+type BatchError struct {
+	// Total is the number of captchas in the batch.
+	Total int
+	// Failures holds the results of the captchas that could not be solved, in input order.
+	Failures []BatchResult
+}
+
+// Error implements the error interface.
+func (e *BatchError) Error() string {
+	if len(e.Failures) == 0 {
+		return fmt.Sprintf("failed to solve 0 of %d captchas", e.Total)
+	}
+	return fmt.Sprintf("failed to solve %d of %d captchas, first at index %d: %v",
+		len(e.Failures), e.Total, e.Failures[0].Index, e.Failures[0].Err)
+}
+
+// Unwrap returns the error of the first failed captcha, so that errors.Is and errors.As can inspect it.
+func (e *BatchError) Unwrap() error {
+	if len(e.Failures) == 0 {
+		return nil
+	}
+	return e.Failures[0].Err
+}
+
+// SolveBatch solves the captchas received from inputs with the default solver, see Solver.SolveBatch.
+func SolveBatch(ctx context.Context, inputs <-chan io.Reader, workers int) <-chan BatchResult {
+	return defaultSolver().SolveBatch(ctx, inputs, workers)
+}
+
+// SolveBatch solves the captchas received from inputs concurrently on a pool of workers goroutines,
+// or runtime.GOMAXPROCS(0) goroutines if workers is not positive, and sends a BatchResult for every
+// captcha on the returned channel, in completion order. The channel is closed once inputs is closed
+// and every captcha is solved.
+//
+// The returned channel is unbuffered, so a slow consumer holds the workers back, and the workers
+// only receive a new input when they are idle: at most workers captchas are in memory at a time.
+// When ctx is done, the workers stop receiving inputs and results that could not be sent yet are
+// dropped, so the channel is closed soon even if nobody reads it anymore.
+func (s *Solver) SolveBatch(ctx context.Context, inputs <-chan io.Reader, workers int) <-chan BatchResult {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	// Number the inputs in the order they are received, before they are shared among the workers
+	type job struct {
+		index int
+		r     io.Reader
+	}
+	jobs := make(chan job)
+	go func() {
+		defer close(jobs)
+		for index := 0; ; index++ {
+			select {
+			case <-ctx.Done():
+				return
+			case r, ok := <-inputs:
+				if !ok {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case jobs <- job{index: index, r: r}:
+				}
+			}
+		}
+	}()
+
+	results := make(chan BatchResult)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				result, err := s.SolveDetailed(j.r)
+				select {
+				case <-ctx.Done():
+				case results <- BatchResult{Index: j.index, Result: result, Err: err}:
+				}
+			}
+		}()
+	}
+
+	// Close the results once every worker is done
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// CollectBatch drains the results of SolveBatch and returns them ordered by Index. If some captchas
+// could not be solved, it also returns a *BatchError listing them.
+func CollectBatch(results <-chan BatchResult) ([]BatchResult, error) {
+	var collected []BatchResult
+	for result := range results {
+		collected = append(collected, result)
+	}
+	sort.Slice(collected, func(i, j int) bool {
+		return collected[i].Index < collected[j].Index
+	})
+
+	batchErr := &BatchError{Total: len(collected)}
+	for _, result := range collected {
+		if result.Err != nil {
+			batchErr.Failures = append(batchErr.Failures, result)
+		}
+	}
+	if len(batchErr.Failures) > 0 {
+		return collected, batchErr
+	}
+	return collected, nil
+}
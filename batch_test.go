@@ -0,0 +1,57 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSolveBatch_Synthetic(t *testing.T) {
+	requireTrainingData(t)
+	answers := []string{"ABCEFG", "XYTUKH", "", "MNPRJL"}
+
+	inputs := make(chan io.Reader)
+	go func() {
+		defer close(inputs)
+		for _, answer := range answers {
+			if answer == "" {
+				inputs <- bytes.NewReader([]byte("not an image"))
+				continue
+			}
+			inputs <- bytes.NewReader(syntheticCaptcha(t, answer))
+		}
+	}()
+
+	results, err := CollectBatch(SolveBatch(context.Background(), inputs, 2))
+	var batchErr *BatchError
+	if assert.True(t, errors.As(err, &batchErr)) {
+		assert.Equal(t, len(answers), batchErr.Total)
+		if assert.Len(t, batchErr.Failures, 1) {
+			assert.Equal(t, 2, batchErr.Failures[0].Index)
+		}
+	}
+	if assert.Len(t, results, len(answers)) {
+		for i, result := range results {
+			assert.Equal(t, i, result.Index)
+			if answers[i] != "" {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, answers[i], result.Result.Text)
+			}
+		}
+	}
+}
+
+func TestSolveBatchCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The inputs are never closed, yet the results are closed once the context is done
+	inputs := make(chan io.Reader)
+	results, err := CollectBatch(SolveBatch(ctx, inputs, 0))
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+}
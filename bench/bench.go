@@ -0,0 +1,248 @@
+// Package bench measures the throughput, latency and allocations of the stages of the amazoncaptcha pipeline on
+// the hardware it runs on, and reports them in a machine-readable form, so that deployments can be sized without
+// writing a harness:
+//
+//	report, err := bench.Run(bench.Config{Duration: 2 * time.Second})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	_ = json.NewEncoder(os.Stdout).Encode(report)
+//
+// Every stage is run over the captchas of the corpus in a loop, by several goroutines at once, until its
+// duration is spent. Stages consume the output of the previous ones, computed before the measurement starts.
+package bench
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// Stages of the pipeline, in the order they run when solving a captcha.
+const (
+	// StageDecode decodes the image of a captcha and converts it to grayscale.
+	StageDecode = "decode"
+	// StageBinarize converts a grayscale captcha into a monochrome one at MonoWeight.
+	StageBinarize = "binarize"
+	// StageSegment finds the letter boxes of a monochrome captcha.
+	StageSegment = "segment"
+	// StageFeatures extracts the features of the 6 letters of a captcha.
+	StageFeatures = "features"
+	// StageSolve solves a captcha from its encoded image, the whole pipeline including recognition.
+	StageSolve = "solve"
+)
+
+// DefaultDuration is how long every stage runs, unless configured otherwise.
+const DefaultDuration = time.Second
+
+// Config configures a benchmark run.
+type Config struct {
+	// Solver solves the captchas of StageSolve, a Solver of the default configuration if nil. Its statistics
+	// count the solves of the benchmark.
+	Solver *amazoncaptcha.Solver
+	// Captchas holds the encoded captcha images run through the pipeline, the self-test corpus of the
+	// package if empty, see amazoncaptcha.SelfTestCaptchas.
+	Captchas [][]byte
+	// Stages names the stages to run, all of them in pipeline order if empty.
+	Stages []string
+	// Duration is how long every stage runs, DefaultDuration if 0.
+	Duration time.Duration
+	// Workers is the number of goroutines running every stage at once, runtime.GOMAXPROCS if 0.
+	Workers int
+}
+
+// Report is the result of a benchmark run, with JSON tags for machine-readable output.
+type Report struct {
+	// GoVersion, GOOS and GOARCH describe the runtime the benchmark ran on.
+	GoVersion string `json:"go_version"`
+	GOOS      string `json:"goos"`
+	GOARCH    string `json:"goarch"`
+	// NumCPU is the number of logical CPUs of the machine.
+	NumCPU int `json:"num_cpu"`
+	// Workers is the number of goroutines that ran every stage at once.
+	Workers int `json:"workers"`
+	// Stages holds the measurements of every stage, in the order they ran.
+	Stages []StageReport `json:"stages"`
+}
+
+// StageReport holds the measurements of a stage.
+type StageReport struct {
+	// Name names the stage, e.g. StageSolve.
+	Name string `json:"name"`
+	// Ops is the number of operations run, an operation processing one captcha.
+	Ops int `json:"ops"`
+	// Throughput is the number of operations per second, all workers together.
+	Throughput float64 `json:"ops_per_second"`
+	// P50, P95 and P99 are percentiles of the latency of an operation, in milliseconds.
+	P50 float64 `json:"p50_ms"`
+	P95 float64 `json:"p95_ms"`
+	P99 float64 `json:"p99_ms"`
+	// AllocsPerOp and BytesPerOp are the heap allocations of an operation, counted over the whole process
+	// while the stage ran.
+	AllocsPerOp float64 `json:"allocs_per_op"`
+	BytesPerOp  float64 `json:"bytes_per_op"`
+}
+
+// stage is an operation of the pipeline on the i-th captcha of a prepared corpus.
+type stage func(i int)
+
+// Run benchmarks the stages of the pipeline configured by config. An error is returned if the configuration is
+// invalid or if no captcha of the corpus can be decoded and segmented into letters.
+func Run(config Config) (*Report, error) {
+	if config.Duration < 0 {
+		return nil, errors.New("benchmark duration must not be negative")
+	}
+	if config.Workers < 0 {
+		return nil, errors.New("number of workers must not be negative")
+	}
+	if config.Duration == 0 {
+		config.Duration = DefaultDuration
+	}
+	if config.Workers == 0 {
+		config.Workers = runtime.GOMAXPROCS(0)
+	}
+	if len(config.Stages) == 0 {
+		config.Stages = []string{StageDecode, StageBinarize, StageSegment, StageFeatures, StageSolve}
+	}
+	if config.Solver == nil {
+		solver, err := amazoncaptcha.NewSolver()
+		if err != nil {
+			return nil, err
+		}
+		config.Solver = solver
+	}
+	if len(config.Captchas) == 0 {
+		captchas, err := amazoncaptcha.SelfTestCaptchas()
+		if err != nil {
+			return nil, err
+		}
+		for _, captcha := range captchas {
+			config.Captchas = append(config.Captchas, captcha.Image)
+		}
+	}
+
+	stages, err := prepare(config)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{
+		GoVersion: runtime.Version(),
+		GOOS:      runtime.GOOS,
+		GOARCH:    runtime.GOARCH,
+		NumCPU:    runtime.NumCPU(),
+		Workers:   config.Workers,
+	}
+	for _, name := range config.Stages {
+		report.Stages = append(report.Stages, measure(name, stages[name], config.Duration, config.Workers))
+	}
+	return report, nil
+}
+
+// prepare computes the inputs of every stage from the captchas that can be segmented and returns the stages
+// by name, or an error if a configured stage is unknown.
+func prepare(config Config) (map[string]stage, error) {
+	var images [][]byte
+	var grays, monos []*image.Gray
+	var letters [][]*image.Gray
+	for _, b := range config.Captchas {
+		img, _, err := image.Decode(bytes.NewReader(b))
+		if err != nil {
+			continue
+		}
+		found, err := config.Solver.FindLetters(bytes.NewReader(b))
+		if err != nil {
+			continue
+		}
+		gray := amazoncaptcha.Grayscale(img)
+		images = append(images, b)
+		grays = append(grays, gray)
+		monos = append(monos, amazoncaptcha.MonoChrome(gray, amazoncaptcha.MonoWeight))
+		letters = append(letters, found)
+	}
+	if len(images) == 0 {
+		return nil, errors.New("no captcha of the corpus could be segmented into letters")
+	}
+
+	n := len(images)
+	stages := map[string]stage{
+		// The inputs were processed once already, so the stages cannot fail on them
+		StageDecode: func(i int) {
+			img, _, _ := image.Decode(bytes.NewReader(images[i%n]))
+			amazoncaptcha.Grayscale(img)
+		},
+		StageBinarize: func(i int) {
+			amazoncaptcha.MonoChrome(grays[i%n], amazoncaptcha.MonoWeight)
+		},
+		StageSegment: func(i int) {
+			amazoncaptcha.FindLetterBoxes(monos[i%n], amazoncaptcha.MaximumLetterLength)
+		},
+		StageFeatures: func(i int) {
+			for _, letter := range letters[i%n] {
+				_, _ = amazoncaptcha.ExtractFeatures(letter)
+			}
+		},
+		StageSolve: func(i int) {
+			// Unrecognized letters still run the whole pipeline, so only the answer is discarded
+			_, _ = config.Solver.SolveBytes(images[i%n])
+		},
+	}
+	for _, name := range config.Stages {
+		if _, ok := stages[name]; !ok {
+			return nil, fmt.Errorf("unknown benchmark stage %q", name)
+		}
+	}
+	return stages, nil
+}
+
+// measure runs a stage with workers goroutines for duration, every goroutine running at least one operation.
+func measure(name string, run stage, duration time.Duration, workers int) StageReport {
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	var wg sync.WaitGroup
+	latencies := make([][]time.Duration, workers)
+	start := time.Now()
+	deadline := start.Add(duration)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i == w || time.Now().Before(deadline); i += workers {
+				opStart := time.Now()
+				run(i)
+				latencies[w] = append(latencies[w], time.Since(opStart))
+			}
+		}(w)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	var all []time.Duration
+	for _, l := range latencies {
+		all = append(all, l...)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+	percentile := func(p float64) float64 {
+		return float64(all[int(p*float64(len(all)-1))]) / float64(time.Millisecond)
+	}
+	ops := float64(len(all))
+	return StageReport{
+		Name:        name,
+		Ops:         len(all),
+		Throughput:  ops / elapsed.Seconds(),
+		P50:         percentile(0.50),
+		P95:         percentile(0.95),
+		P99:         percentile(0.99),
+		AllocsPerOp: float64(after.Mallocs-before.Mallocs) / ops,
+		BytesPerOp:  float64(after.TotalAlloc-before.TotalAlloc) / ops,
+	}
+}
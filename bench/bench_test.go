@@ -0,0 +1,45 @@
+package bench
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	report, err := Run(Config{Duration: 20 * time.Millisecond, Workers: 2})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 2, report.Workers)
+	assert.NotEmpty(t, report.GoVersion)
+	names := make([]string, len(report.Stages))
+	for i, stage := range report.Stages {
+		names[i] = stage.Name
+		assert.GreaterOrEqual(t, stage.Ops, 2)
+		assert.Greater(t, stage.Throughput, 0.0)
+		assert.LessOrEqual(t, stage.P50, stage.P95)
+		assert.LessOrEqual(t, stage.P95, stage.P99)
+	}
+	assert.Equal(t, []string{StageDecode, StageBinarize, StageSegment, StageFeatures, StageSolve}, names)
+
+	b, err := json.Marshal(report)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"name":"solve"`)
+	assert.Contains(t, string(b), `"ops_per_second":`)
+
+	// A single stage runs alone
+	report, err = Run(Config{Stages: []string{StageSegment}, Duration: time.Millisecond})
+	if assert.NoError(t, err) && assert.Len(t, report.Stages, 1) {
+		assert.Equal(t, StageSegment, report.Stages[0].Name)
+	}
+
+	_, err = Run(Config{Stages: []string{"train"}})
+	assert.Error(t, err)
+	_, err = Run(Config{Duration: -time.Second})
+	assert.Error(t, err)
+	_, err = Run(Config{Captchas: [][]byte{[]byte("not an image")}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,263 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"io"
+	"time"
+)
+
+// Strategies tried by SolveBestEffort, from cheapest to most expensive.
+const (
+	StrategyExact     = "exact"
+	StrategyThreshold = "threshold"
+	StrategyNearest   = "nearest"
+	StrategyEnsemble  = "ensemble"
+)
+
+// bestEffortThresholds are the binarization thresholds tried by the threshold strategy
+// after the mono threshold of the Solver.
+var bestEffortThresholds = []uint8{16, 32, 64, 96, 128}
+
+// nearestNeighbors is the number of training entries voting on a letter in the nearest strategy.
+const nearestNeighbors = 3
+
+// letterMatch is the recognition of a single letter.
+type letterMatch struct {
+	letter     string
+	confidence float64
+	feature    string
+	// entry is the feature of the training entry the letter was found as, if any.
+	entry string
+}
+
+// SolveBestEffort solves a captcha by trying progressively more expensive strategies until one
+// recognizes every letter or the context is done, and returns the best answer found so far:
+//
+//   - StrategyExact looks the letters up in the training data, like Solve.
+//   - StrategyThreshold repeats the lookup with the captcha binarized at other thresholds.
+//   - StrategyNearest recognizes the remaining letters by their nearest training entries.
+//   - StrategyEnsemble votes on every letter across all previous attempts.
+//
+// The exact strategy always runs, so an answer is returned even for an expired context.
+// If the letters cannot be segmented at any threshold, a *SegmentationError is returned.
+func SolveBestEffort(ctx context.Context, r io.Reader) (*Result, error) {
+	return defaultSolver().SolveBestEffort(ctx, r)
+}
+
+// SolveBestEffort works like the package-level SolveBestEffort, using the configuration and training data of the Solver.
+func (s *Solver) SolveBestEffort(ctx context.Context, r io.Reader) (*Result, error) {
+
+	// Read the whole input so that it can be hashed for the journal and outcome reports
+	b, err := s.readImage(r)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	result, err := s.solveBestEffort(ctx, bytes.NewReader(b))
+	s.finishSolve(b, start, result, err)
+
+	return result, err
+}
+
+// solveBestEffort implements SolveBestEffort.
+func (s *Solver) solveBestEffort(ctx context.Context, r io.Reader) (best *Result, err error) {
+	if err := s.checkTrainingData(); err != nil {
+		return nil, err
+	}
+
+	// Decode the input image and convert it to grayscale once for all strategies
+	img, err := s.decodeImage(r)
+	if err != nil {
+		return nil, err
+	}
+	grayImg := Grayscale(img)
+	m := s.recognitionModel()
+
+	// Add the candidates of the letters to the best answer, whichever strategy produced it
+	var bestMatches []letterMatch
+	defer func() {
+		if best != nil {
+			s.addCandidates(m, best, bestMatches)
+		}
+	}()
+
+	var attempts [][]letterMatch
+	consider := func(strategy string, matches []letterMatch) bool {
+		result := s.newResult(strategy, matches)
+		if best == nil || result.Confidence > best.Confidence {
+			best, bestMatches = result, matches
+		}
+		return best.Confidence == 1
+	}
+
+	// Strategies 1 and 2: exact lookup at the default threshold, then at the other thresholds
+	var segmentErr error
+	thresholds := append([]uint8{s.threshold(grayImg)}, bestEffortThresholds...)
+	for i, threshold := range thresholds {
+		if i > 0 && ctx.Err() != nil {
+			break
+		}
+		matches, err := s.matchExact(m, grayImg, threshold)
+		if err != nil {
+			if errors.Is(err, ErrSegmentationFailed) {
+				if segmentErr == nil {
+					segmentErr = err
+				}
+				continue
+			}
+			return nil, err
+		}
+		attempts = append(attempts, matches)
+
+		strategy := StrategyExact
+		if i > 0 {
+			strategy = StrategyThreshold
+		}
+		if consider(strategy, matches) {
+			return best, nil
+		}
+	}
+	if best == nil {
+		return nil, segmentErr
+	}
+
+	// Strategy 3: recognize the unknown letters by their nearest training entries
+	for _, matches := range attempts {
+		if ctx.Err() != nil {
+			return best, nil
+		}
+		for i := range matches {
+			if matches[i].letter == "" {
+				matches[i] = matchNearest(m, matches[i].feature)
+			}
+		}
+		if consider(StrategyNearest, matches) {
+			return best, nil
+		}
+	}
+
+	// Strategy 4: vote on every position across all attempts
+	if ctx.Err() != nil {
+		return best, nil
+	}
+	consider(StrategyEnsemble, vote(attempts))
+
+	return best, nil
+}
+
+// matchExact binarizes a grayscale captcha at threshold, segments it and recognizes every letter like Solve does,
+// looking it up in the training data, then by fuzzy matching, the recognizer and the disambiguation rules of the
+// Solver, if any. Unknown letters have an empty letter and a confidence of 0.
+func (s *Solver) matchExact(m *model, grayImg *image.Gray, threshold uint8) ([]letterMatch, error) {
+	mono := s.binarize(grayImg, threshold, nil)
+	_, matches, err := s.recognizeLetters(m, mono, s.findLetterBoxes(mono), nil)
+	return matches, err
+}
+
+// matchFuzzy recognizes a letter by its nearest training entry within the fuzzy distance of the Solver.
+// The confidence is the similarity to that entry.
+func (s *Solver) matchFuzzy(m *model, feature string) letterMatch {
+	match := letterMatch{feature: feature}
+	b, err := decodeBitmap(feature)
+	if err != nil {
+		return match
+	}
+	neighbors := m.nearest(b, 1, s.fuzzyDistance)
+	if len(neighbors) == 0 {
+		return match
+	}
+	match.letter = neighbors[0].entry.letter
+	match.confidence = b.similarity(neighbors[0].entry.bitmap, neighbors[0].distance)
+	return match
+}
+
+// matchNearest recognizes a letter by a majority vote of its nearest training entries.
+// The confidence is the similarity to the closest entry of the winning letter.
+func matchNearest(m *model, feature string) letterMatch {
+	match := letterMatch{feature: feature}
+	b, err := decodeBitmap(feature)
+	if err != nil {
+		return match
+	}
+
+	// Find the nearest training entries, closest first
+	neighbors := m.nearest(b, nearestNeighbors, -1)
+	if len(neighbors) == 0 {
+		return match
+	}
+
+	// Let the neighbors vote, breaking ties in favor of the closest one
+	votes := make(map[string]int)
+	for _, n := range neighbors {
+		votes[n.entry.letter]++
+	}
+	for _, n := range neighbors {
+		if votes[n.entry.letter] > votes[match.letter] {
+			match.letter = n.entry.letter
+			match.confidence = b.similarity(n.entry.bitmap, n.distance)
+		}
+	}
+
+	return match
+}
+
+// vote combines several attempts into one by summing the confidences of every candidate letter per position.
+func vote(attempts [][]letterMatch) []letterMatch {
+	if len(attempts) == 0 {
+		return nil
+	}
+
+	combined := make([]letterMatch, len(attempts[0]))
+	for i := range combined {
+		combined[i].feature = attempts[0][i].feature
+		scores := make(map[string]float64)
+		total := 0.0
+		for _, matches := range attempts {
+			if i < len(matches) && matches[i].letter != "" {
+				scores[matches[i].letter] += matches[i].confidence
+				total += matches[i].confidence
+			}
+		}
+		for letter, score := range scores {
+			if score > scores[combined[i].letter] || (score == scores[combined[i].letter] && letter < combined[i].letter) {
+				combined[i].letter = letter
+			}
+		}
+		if total > 0 {
+			combined[i].confidence = scores[combined[i].letter] / total * maxConfidence(attempts, i, combined[i].letter)
+		}
+	}
+
+	return combined
+}
+
+// maxConfidence returns the highest confidence any attempt has for letter at position i.
+func maxConfidence(attempts [][]letterMatch, i int, letter string) float64 {
+	confidence := 0.0
+	for _, matches := range attempts {
+		if i < len(matches) && matches[i].letter == letter && matches[i].confidence > confidence {
+			confidence = matches[i].confidence
+		}
+	}
+	return confidence
+}
+
+// addCandidates sets the candidates of the letters of a result, if enabled.
+func (s *Solver) addCandidates(m *model, result *Result, matches []letterMatch) {
+	if s.candidates <= 0 {
+		return
+	}
+	result.Candidates = make([][]Candidate, len(matches))
+	for i, match := range matches {
+		b, err := decodeBitmap(match.feature)
+		if err != nil {
+			continue
+		}
+		for _, n := range m.nearestLetters(b, s.candidates) {
+			result.Candidates[i] = append(result.Candidates[i], Candidate{Letter: n.entry.letter, Distance: n.distance})
+		}
+	}
+}
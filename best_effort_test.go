@@ -0,0 +1,60 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSolveBestEffort(t *testing.T) {
+	ctx := context.Background()
+
+	result, err := SolveBestEffort(ctx, bytes.NewReader(syntheticCaptcha(t, "ABCEFG")))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", result.Text)
+	assert.Equal(t, 1.0, result.Confidence)
+	assert.Equal(t, StrategyExact, result.Strategy)
+	assert.True(t, result.Solved)
+
+	captcha := flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)
+	result, err = SolveBestEffort(ctx, bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", result.Text)
+	assert.Equal(t, StrategyNearest, result.Strategy)
+	assert.Greater(t, result.Confidence, 0.9)
+	assert.Less(t, result.Confidence, 1.0)
+
+	// An expired context only allows the exact strategy
+	expired, cancel := context.WithCancel(ctx)
+	cancel()
+	result, err = SolveBestEffort(expired, bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "AB-EFG", result.Text)
+	assert.Equal(t, StrategyExact, result.Strategy)
+	assert.Equal(t, []int{2}, result.UnknownPositions())
+
+	_, err = SolveBestEffort(ctx, bytes.NewReader(syntheticCaptcha(t, "AB")))
+	assert.True(t, errors.Is(err, ErrSegmentationFailed))
+}
+
+func TestSolveBestEffortRecognizer(t *testing.T) {
+	captcha := flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)
+	solver, err := NewSolver(WithRecognizer(fixedRecognizer("C")))
+	assert.NoError(t, err)
+
+	// The exact strategy recognizes letters like Solve, asking the recognizer about unknown letters
+	answer, err := solver.Solve(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", answer)
+
+	expired, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := solver.SolveBestEffort(expired, bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", result.Text)
+	assert.Equal(t, StrategyExact, result.Strategy)
+	assert.True(t, result.Solved)
+}
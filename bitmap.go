@@ -0,0 +1,99 @@
+package amazoncaptcha
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// bitmap is a decoded letter feature with its pixels packed into 64-bit words, row by row.
+// A set bit is a black pixel.
+type bitmap struct {
+	width  int
+	height int
+	stride int
+	words  []uint64
+	ink    int
+}
+
+// newBitmap packs a binary string as produced by ExtractFeatures into a bitmap of the given height.
+func newBitmap(binaryStr []byte, height int) (*bitmap, error) {
+	if height <= 0 || len(binaryStr)%height != 0 {
+		return nil, fmt.Errorf("feature of %d pixels is not %d pixels high", len(binaryStr), height)
+	}
+
+	width := len(binaryStr) / height
+	stride := (width + 63) / 64
+	b := &bitmap{width: width, height: height, stride: stride, words: make([]uint64, stride*height)}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if binaryStr[y*width+x] == '1' {
+				b.words[y*stride+x/64] |= 1 << uint(x%64)
+				b.ink++
+			}
+		}
+	}
+
+	return b, nil
+}
+
+// decodeBitmap decodes a feature string into a bitmap, assuming letters are CaptchaHeight pixels high.
+func decodeBitmap(feature string) (*bitmap, error) {
+	binaryStr, err := decodeFeature(feature)
+	if err != nil {
+		return nil, err
+	}
+	return newBitmap(binaryStr, CaptchaHeight)
+}
+
+// word returns the i-th word of row y, or 0 if it lies outside the bitmap.
+func (b *bitmap) word(y, i int) uint64 {
+	if y >= b.height || i >= b.stride {
+		return 0
+	}
+	return b.words[y*b.stride+i]
+}
+
+// distance returns the number of pixels in which two bitmaps differ.
+// The bitmaps are aligned at their top-left corners and pixels outside a bitmap count as white.
+func (b *bitmap) distance(other *bitmap) int {
+	height, stride := b.height, b.stride
+	if other.height > height {
+		height = other.height
+	}
+	if other.stride > stride {
+		stride = other.stride
+	}
+
+	distance := 0
+	for y := 0; y < height; y++ {
+		for i := 0; i < stride; i++ {
+			distance += bits.OnesCount64(b.word(y, i) ^ other.word(y, i))
+		}
+	}
+
+	return distance
+}
+
+// similarity converts the distance between two bitmaps into a score between 0 and 1,
+// the Dice coefficient of their black pixels: 1 means identical letters, 0 means no shared pixel.
+func (b *bitmap) similarity(other *bitmap, distance int) float64 {
+	if b.ink+other.ink == 0 {
+		return 1
+	}
+	return 1 - float64(distance)/float64(b.ink+other.ink)
+}
+
+// FeatureDistance decodes two features as produced by ExtractFeatures and returns the number of
+// pixels in which their letters differ. Letters of different widths are aligned at their left edge,
+// with the missing columns of the narrower letter counting as white.
+func FeatureDistance(f1, f2 string) (int, error) {
+	b1, err := decodeBitmap(f1)
+	if err != nil {
+		return 0, err
+	}
+	b2, err := decodeBitmap(f2)
+	if err != nil {
+		return 0, err
+	}
+	return b1.distance(b2), nil
+}
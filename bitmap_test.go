@@ -0,0 +1,38 @@
+package amazoncaptcha
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureDistance(t *testing.T) {
+	letter := image.NewGray(image.Rect(0, 0, 20, CaptchaHeight))
+	for i := range letter.Pix {
+		letter.Pix[i] = 255
+	}
+	letter.Pix[0] = 0
+	f1, err := ExtractFeatures(letter)
+	assert.NoError(t, err)
+
+	// The same pixels shifted into a wider letter only differ in the moved pixel
+	wider := image.NewGray(image.Rect(0, 0, 70, CaptchaHeight))
+	for i := range wider.Pix {
+		wider.Pix[i] = 255
+	}
+	wider.Pix[65] = 0
+	f2, err := ExtractFeatures(wider)
+	assert.NoError(t, err)
+
+	distance, err := FeatureDistance(f1, f1)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, distance)
+
+	distance, err = FeatureDistance(f1, f2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, distance)
+
+	_, err = FeatureDistance(f1, "not a feature")
+	assert.Error(t, err)
+}
@@ -0,0 +1,130 @@
+package amazoncaptcha
+
+import "sort"
+
+// bkTree is a BK-tree over decoded training entries: a metric tree that finds the entries nearest to
+// a bitmap without computing the distance to every entry. It relies on bitmap distances being a metric,
+// which holds since they count the pixels in which two white-padded bitmaps differ.
+type bkTree struct {
+	root *bkNode
+}
+
+// bkNode is a node of a bkTree. Every child is at the distance given by its key from the node's entry.
+type bkNode struct {
+	entry    *decodedFeature
+	children map[int]*bkNode
+}
+
+// neighbor is a training entry found by a nearest neighbor search, with its distance to the query.
+type neighbor struct {
+	distance int
+	entry    *decodedFeature
+}
+
+// less orders neighbors by distance, breaking ties by feature so that searches are deterministic.
+func (n neighbor) less(other neighbor) bool {
+	if n.distance != other.distance {
+		return n.distance < other.distance
+	}
+	return n.entry.feature < other.entry.feature
+}
+
+// newBKTree builds a BK-tree holding entries.
+func newBKTree(entries []decodedFeature) *bkTree {
+	t := &bkTree{}
+	for i := range entries {
+		t.insert(&entries[i])
+	}
+	return t
+}
+
+// insert adds an entry to the tree.
+func (t *bkTree) insert(entry *decodedFeature) {
+	if t.root == nil {
+		t.root = &bkNode{entry: entry}
+		return
+	}
+	node := t.root
+	for {
+		distance := entry.bitmap.distance(node.entry.bitmap)
+		child, ok := node.children[distance]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[int]*bkNode)
+			}
+			node.children[distance] = &bkNode{entry: entry}
+			return
+		}
+		node = child
+	}
+}
+
+// nearest returns the k entries nearest to b within maxDistance, closest first.
+// A negative maxDistance means no limit.
+func (t *bkTree) nearest(b *bitmap, k int, maxDistance int) []neighbor {
+	return t.search(b, k, maxDistance, false)
+}
+
+// nearestLetters returns the nearest entry of each of the k letters nearest to b, closest first.
+func (t *bkTree) nearestLetters(b *bitmap, k int) []neighbor {
+	return t.search(b, k, -1, true)
+}
+
+// search implements nearest and nearestLetters. If perLetter is set, at most one entry,
+// the nearest one, is returned for every letter.
+func (t *bkTree) search(b *bitmap, k int, maxDistance int, perLetter bool) []neighbor {
+	if t.root == nil || k <= 0 {
+		return nil
+	}
+
+	neighbors := make([]neighbor, 0, k+1)
+	// radius is the distance within which closer neighbors may still be found
+	radius := func() int {
+		if len(neighbors) == k {
+			return neighbors[k-1].distance
+		}
+		return maxDistance
+	}
+
+	stack := []*bkNode{t.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		distance := b.distance(node.entry.bitmap)
+		candidate := neighbor{distance: distance, entry: node.entry}
+		if (maxDistance < 0 || distance <= maxDistance) && (len(neighbors) < k || candidate.less(neighbors[k-1])) {
+			// Keep only the nearest entry of a letter, replacing a farther one already found
+			same := -1
+			if perLetter {
+				for i, n := range neighbors {
+					if n.entry.letter == node.entry.letter {
+						same = i
+						break
+					}
+				}
+			}
+			switch {
+			case same < 0:
+				neighbors = append(neighbors, candidate)
+			case candidate.less(neighbors[same]):
+				neighbors[same] = candidate
+			}
+			sort.Slice(neighbors, func(i, j int) bool { return neighbors[i].less(neighbors[j]) })
+			if len(neighbors) > k {
+				neighbors = neighbors[:k]
+			}
+		}
+
+		// By the triangle inequality, only children at a distance within the radius of
+		// the query's distance can hold entries within the radius
+		r := radius()
+		for d, child := range node.children {
+			if r < 0 || (d >= distance-r && d <= distance+r) {
+				stack = append(stack, child)
+			}
+		}
+	}
+
+	return neighbors
+}
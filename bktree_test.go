@@ -0,0 +1,71 @@
+package amazoncaptcha
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBKTreeNearest(t *testing.T) {
+	requireTrainingData(t)
+	entries := defaultSolver().trainingData().index()
+	tree := newBKTree(entries)
+
+	for i := 0; i < len(entries); i += len(entries) / 25 {
+		query := entries[i].bitmap
+
+		// The tree finds the same distances as a linear scan
+		distances := make([]int, len(entries))
+		for j, entry := range entries {
+			distances[j] = query.distance(entry.bitmap)
+		}
+		sort.Ints(distances)
+
+		neighbors := tree.nearest(query, 3, -1)
+		if assert.Len(t, neighbors, 3) {
+			for j, n := range neighbors {
+				assert.Equal(t, distances[j], n.distance)
+			}
+		}
+
+		// Entries beyond the maximum distance are ignored
+		for _, n := range tree.nearest(query, 5, distances[1]) {
+			assert.LessOrEqual(t, n.distance, distances[1])
+		}
+	}
+
+	assert.Empty(t, newBKTree(nil).nearest(entries[0].bitmap, 1, -1))
+}
+
+func TestBKTreeNearestLetters(t *testing.T) {
+	requireTrainingData(t)
+	entries := defaultSolver().trainingData().index()
+	tree := newBKTree(entries)
+	query := entries[0].bitmap
+
+	// The nearest entry of every letter, found by a linear scan
+	nearest := make(map[string]int)
+	for _, entry := range entries {
+		distance := query.distance(entry.bitmap)
+		if d, ok := nearest[entry.letter]; !ok || distance < d {
+			nearest[entry.letter] = distance
+		}
+	}
+	distances := make([]int, 0, len(nearest))
+	for _, d := range nearest {
+		distances = append(distances, d)
+	}
+	sort.Ints(distances)
+
+	neighbors := tree.nearestLetters(query, 5)
+	if assert.Len(t, neighbors, 5) {
+		seen := make(map[string]bool)
+		for i, n := range neighbors {
+			assert.False(t, seen[n.entry.letter])
+			seen[n.entry.letter] = true
+			assert.Equal(t, distances[i], n.distance)
+			assert.Equal(t, nearest[n.entry.letter], n.distance)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// imageExtensions are the file extensions of the captcha images picked up from directories.
+var imageExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".webp": true,
+}
+
+// runBatch implements the batch command, solving every captcha image of a directory.
+func runBatch(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("batch", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	quiet := flags.Bool("quiet", false, "print only the answers")
+	parallel := flags.Int("parallel", 4, "number of images solved concurrently")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: amazoncaptcha batch [flags] <dir>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 || *parallel < 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	inputs, err := listImages(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "amazoncaptcha: %v\n", err)
+		return exitFetch
+	}
+	return solveInputs(inputs, nil, stdout, stderr, *parallel, *quiet, true)
+}
+
+// listImages returns the paths of the captcha images in dir, sorted by name. Subdirectories are not searched.
+func listImages(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	var paths []string
+	for _, entry := range entries {
+		if !entry.IsDir() && imageExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// labelOf returns the answer a captcha image is labeled with by its file name, e.g. "ABCDEF" for "abcdef.jpg",
+// or an empty string if the name is not made of 6 letters.
+func labelOf(path string) string {
+	name := filepath.Base(path)
+	label := strings.ToUpper(strings.TrimSuffix(name, filepath.Ext(name)))
+	if len(label) != 6 {
+		return ""
+	}
+	for _, c := range label {
+		if c < 'A' || c > 'Z' {
+			return ""
+		}
+	}
+	return label
+}
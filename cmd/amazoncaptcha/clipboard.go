@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// clipboardCommand is an external program printing the clipboard image to its standard output.
+type clipboardCommand struct {
+	name string
+	args []string
+}
+
+// readClipboardImage returns the image held by the system clipboard, trying every clipboard command
+// of the platform in turn until one succeeds.
+func readClipboardImage() ([]byte, error) {
+	commands := clipboardCommands()
+	if len(commands) == 0 {
+		return nil, errors.New("reading the clipboard is not supported on this platform")
+	}
+
+	var failures []string
+	for _, command := range commands {
+		path, err := exec.LookPath(command.name)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s not found", command.name))
+			continue
+		}
+
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command(path, command.args...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v %s", command.name, err, strings.TrimSpace(stderr.String())))
+			continue
+		}
+		if stdout.Len() == 0 {
+			failures = append(failures, fmt.Sprintf("%s: the clipboard holds no image", command.name))
+			continue
+		}
+		return stdout.Bytes(), nil
+	}
+
+	return nil, fmt.Errorf("failed to read clipboard image: %s", strings.Join(failures, "; "))
+}
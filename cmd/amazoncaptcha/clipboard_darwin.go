@@ -0,0 +1,9 @@
+//go:build darwin
+// +build darwin
+
+package main
+
+// clipboardCommands returns the commands reading the clipboard image: pngpaste, installable with Homebrew.
+func clipboardCommands() []clipboardCommand {
+	return []clipboardCommand{{name: "pngpaste", args: []string{"-"}}}
+}
@@ -0,0 +1,16 @@
+//go:build linux
+// +build linux
+
+package main
+
+import "os"
+
+// clipboardCommands returns the commands reading the clipboard image: wl-paste on Wayland, xclip on X11.
+func clipboardCommands() []clipboardCommand {
+	xclip := clipboardCommand{name: "xclip", args: []string{"-selection", "clipboard", "-target", "image/png", "-out"}}
+	wlPaste := clipboardCommand{name: "wl-paste", args: []string{"--no-newline", "--type", "image/png"}}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		return []clipboardCommand{wlPaste, xclip}
+	}
+	return []clipboardCommand{xclip, wlPaste}
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package main
+
+// clipboardCommands returns no commands, reading the clipboard is not supported on this platform.
+func clipboardCommands() []clipboardCommand {
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build windows
+// +build windows
+
+package main
+
+// clipboardScript writes the clipboard image to the standard output as a PNG.
+const clipboardScript = `Add-Type -AssemblyName System.Windows.Forms, System.Drawing
+$image = [System.Windows.Forms.Clipboard]::GetImage()
+if ($image -eq $null) { exit 0 }
+$stream = New-Object System.IO.MemoryStream
+$image.Save($stream, [System.Drawing.Imaging.ImageFormat]::Png)
+$stdout = [Console]::OpenStandardOutput()
+$stdout.Write($stream.ToArray(), 0, $stream.Length)
+$stdout.Flush()`
+
+// clipboardCommands returns the commands reading the clipboard image: a PowerShell script.
+func clipboardCommands() []clipboardCommand {
+	return []clipboardCommand{{name: "powershell", args: []string{"-NoProfile", "-NonInteractive", "-STA", "-Command", clipboardScript}}}
+}
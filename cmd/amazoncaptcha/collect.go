@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+	"github.com/gopkg-dev/amazoncaptcha/collect"
+)
+
+// runCollect implements the collect command, downloading captchas from the captcha page for training.
+func runCollect(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("collect", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	count := flags.Int("n", 100, "number of captchas to save")
+	parallel := flags.Int("parallel", collect.DefaultConcurrency, "number of captchas fetched concurrently")
+	proxies := flags.String("proxies", "", "read HTTP proxy URLs from a file, one per line, each used by the workers in turn")
+	output := flags.String("o", "captchas", "directory to save the captchas to")
+	unknownOnly := flags.Bool("unknown", false, "save only captchas with letters missing from the training data")
+	training := flags.String("training", "", "recognize letters for --unknown with this training data instead of the embedded one")
+	pageURL := flags.String("url", amazoncaptcha.DefaultCaptchaPageURL, "captcha page to fetch the captchas from")
+	rate := flags.Float64("rate", collect.DefaultRate, "requests per second to every host, unlimited if negative")
+	burst := flags.Int("burst", collect.DefaultBurst, "requests made to a host at once after a pause")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: amazoncaptcha collect [flags]")
+		fmt.Fprintln(stderr, "Downloads captchas into a directory, named after their hashes, to label them for training.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 0 || *count < 1 || *parallel < 1 || *rate == 0 || *burst < 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	config := collect.Config{
+		URL:         *pageURL,
+		Count:       *count,
+		Concurrency: *parallel,
+		Output:      *output,
+		UnknownOnly: *unknownOnly,
+		Limit:       collect.Limit{Rate: *rate, Burst: *burst},
+	}
+	if *proxies != "" {
+		list, err := readInputList(*proxies, nil)
+		if err != nil {
+			fmt.Fprintf(stderr, "amazoncaptcha: %v\n", err)
+			return 1
+		}
+		config.Proxies = list
+	}
+	if *training != "" {
+		file, err := os.Open(*training)
+		if err != nil {
+			fmt.Fprintf(stderr, "amazoncaptcha: failed to open training data: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		solver, err := amazoncaptcha.NewSolver(amazoncaptcha.WithTrainingData(file))
+		if err != nil {
+			fmt.Fprintf(stderr, "amazoncaptcha: %v\n", err)
+			return 1
+		}
+		config.Solver = solver
+	}
+
+	// Stop on interrupt, keeping the captchas saved so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	stats, err := collect.Run(ctx, config)
+	if stats != nil {
+		fmt.Fprintf(stdout, "saved %d of %d captchas fetched, %d skipped, %d failed fetches, %d throttled\n", stats.Saved, stats.Fetched, stats.Skipped, stats.Failed, stats.Throttled)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "amazoncaptcha: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// runConvert implements the convert command, converting training data between its JSON and binary forms.
+func runConvert(args []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	lossy := flags.Bool("lossy", false, lossyUsage)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: amazoncaptcha convert [flags] <input> <output>")
+		fmt.Fprintln(stderr, "Converts training data to JSON if output ends in .json, and to the binary form otherwise.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return exitUsage
+	}
+	input, output := flags.Arg(0), flags.Arg(1)
+
+	if err := convertTrainingData(input, output, *lossy, stderr); err != nil {
+		fmt.Fprintf(stderr, "amazoncaptcha: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// lossyUsage describes the --lossy flag of the commands writing training data.
+const lossyUsage = "drop entries with invalid features and lowercase hex features when writing the binary form, which cannot store them as they are"
+
+// convertTrainingData converts the training data file at input into output, see writeTrainingData.
+func convertTrainingData(input, output string, lossy bool, stderr io.Writer) error {
+	features, err := readTrainingData(input)
+	if err != nil {
+		return err
+	}
+	return writeTrainingData(output, features, lossy, stderr)
+}
+
+// readTrainingData reads the training data file at path, in its JSON or binary form.
+func readTrainingData(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open training data: %w", err)
+	}
+	defer file.Close()
+	return amazoncaptcha.DecodeTrainingData(file)
+}
+
+// writeTrainingData writes features to the file at path, as JSON if its name ends in .json,
+// and in the binary form otherwise. The binary form stores features hex-decoded, so entries whose features
+// are not hex cannot be stored, and features in uppercase hex come back lowercase. Unless lossy, such entries
+// fail the conversion instead of being dropped or changed; if lossy, their numbers are reported to stderr.
+// features is left unchanged either way.
+func writeTrainingData(path string, features map[string]string, lossy bool, stderr io.Writer) error {
+	var buf bytes.Buffer
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		b, err := json.MarshalIndent(features, "", "	")
+		if err != nil {
+			return fmt.Errorf("failed to marshal training data: %w", err)
+		}
+		buf.Write(b)
+	} else {
+		encodable, invalid, lowercased := binaryFeatures(features)
+		if invalid > 0 || lowercased > 0 {
+			if !lossy {
+				return fmt.Errorf("%d entries with invalid features and %d features in uppercase hex cannot be stored in the binary form as they are, use --lossy to drop and lowercase them", invalid, lowercased)
+			}
+			fmt.Fprintf(stderr, "amazoncaptcha: dropped %d entries with invalid features, lowercased %d features\n", invalid, lowercased)
+		}
+		if err := amazoncaptcha.EncodeTrainingData(&buf, encodable); err != nil {
+			return err
+		}
+	}
+
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write training data: %w", err)
+	}
+	return nil
+}
+
+// binaryFeatures returns a copy of features as the binary form stores them, without the entries whose features
+// are not hex and with the features lowercased, together with the numbers of entries dropped and lowercased.
+func binaryFeatures(features map[string]string) (map[string]string, int, int) {
+	encodable := make(map[string]string, len(features))
+	invalid, lowercased := 0, 0
+	for feature, letter := range features {
+		raw, err := hex.DecodeString(feature)
+		if err != nil {
+			invalid++
+			continue
+		}
+		canonical := hex.EncodeToString(raw)
+		if canonical != feature {
+			lowercased++
+		}
+		encodable[canonical] = letter
+	}
+	return encodable, invalid, lowercased
+}
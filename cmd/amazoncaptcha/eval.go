@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// evaluation counts how the labeled captchas of an eval run were solved.
+type evaluation struct {
+	total       int
+	correct     int
+	unsolved    int
+	wrong       int
+	notCaptchas int
+	confusions  amazoncaptcha.ConfusionMatrix
+}
+
+// accuracy returns the share of correctly solved captchas, between 0 and 1.
+func (e *evaluation) accuracy() float64 {
+	if e.total == 0 {
+		return 0
+	}
+	return float64(e.correct) / float64(e.total)
+}
+
+// runEval implements the eval command, measuring the accuracy of the solver on labeled captchas.
+func runEval(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	training := flags.String("training", "", "evaluate this training data instead of the embedded one, in its JSON or binary form")
+	minAccuracy := flags.Float64("min-accuracy", 0, "exit with 1 if the share of correct answers is below this, between 0 and 1")
+	quiet := flags.Bool("quiet", false, "print only the summary, not every incorrect answer")
+	sample := flags.Int("sample", 0, "evaluate a random sample of this many captchas instead of all of them")
+	seed := flags.Int64("seed", 1, "seed of the random sample, the same seed sampling the same captchas")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: amazoncaptcha eval [flags] <file|dir>...")
+		fmt.Fprintln(stderr, "Solves captchas named after their answers, e.g. ABCDEF.jpg, and reports the accuracy.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 || *sample < 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	var opts []amazoncaptcha.Option
+	if *training != "" {
+		file, err := os.Open(*training)
+		if err != nil {
+			fmt.Fprintf(stderr, "amazoncaptcha: failed to open training data: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		opts = append(opts, amazoncaptcha.WithTrainingData(file))
+	}
+	solver, err := amazoncaptcha.NewSolver(opts...)
+	if err != nil {
+		fmt.Fprintf(stderr, "amazoncaptcha: %v\n", err)
+		return 1
+	}
+	inputs, err := expandInputs(flags.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "amazoncaptcha: %v\n", err)
+		return 1
+	}
+	if *sample > 0 && *sample < len(inputs) {
+		inputs = sampleInputs(inputs, *sample, *seed)
+	}
+
+	// Print every incorrect answer as "input<TAB>want<TAB>got" while solving
+	e := &evaluation{confusions: make(amazoncaptcha.ConfusionMatrix)}
+	for _, input := range inputs {
+		want := labelOf(input)
+		if want == "" {
+			fmt.Fprintf(stderr, "amazoncaptcha: skipping %s: file name is not a 6-letter answer\n", input)
+			continue
+		}
+		b, err := os.ReadFile(input)
+		if err != nil {
+			fmt.Fprintf(stderr, "amazoncaptcha: %v\n", err)
+			return 1
+		}
+		e.total++
+		result, err := solver.SolveDetailed(bytes.NewReader(b))
+		got := ""
+		switch {
+		case err != nil:
+			e.notCaptchas++
+		case result.Text == want:
+			e.correct++
+			continue
+		case !result.Solved:
+			e.unsolved++
+			got = result.Text
+		default:
+			e.wrong++
+			got = result.Text
+		}
+		e.confusions.Record(want, got)
+		if !*quiet {
+			fmt.Fprintf(stdout, "%s\t%s\t%s\n", input, want, got)
+		}
+	}
+
+	printEvaluation(stdout, e)
+	if e.accuracy() < *minAccuracy {
+		return 1
+	}
+	return 0
+}
+
+// sampleInputs returns n of the inputs picked at random with seed, in their original order. The same seed
+// picks the same inputs on every machine, so that sampled runs can be reproduced and compared.
+func sampleInputs(inputs []string, n int, seed int64) []string {
+	picked := rand.New(rand.NewSource(seed)).Perm(len(inputs))[:n]
+	sort.Ints(picked)
+	sampled := make([]string, n)
+	for i, j := range picked {
+		sampled[i] = inputs[j]
+	}
+	return sampled
+}
+
+// printEvaluation prints the summary of an eval run, including the most confused letter pairs.
+func printEvaluation(w io.Writer, e *evaluation) {
+	fmt.Fprintf(w, "captchas:     %d\n", e.total)
+	fmt.Fprintf(w, "correct:      %d (%.1f%%)\n", e.correct, 100*e.accuracy())
+	fmt.Fprintf(w, "unsolved:     %d\n", e.unsolved)
+	fmt.Fprintf(w, "wrong:        %d\n", e.wrong)
+	fmt.Fprintf(w, "not captchas: %d\n", e.notCaptchas)
+	pairs := e.confusions.Pairs(1)
+	if len(pairs) > 10 {
+		pairs = pairs[:10]
+	}
+	for _, pair := range pairs {
+		a, b := pair[0], pair[1]
+		fmt.Fprintf(w, "confused:     %s/%s %d\n", a, b, e.confusions[a][b]+e.confusions[b][a])
+	}
+}
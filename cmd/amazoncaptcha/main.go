@@ -0,0 +1,115 @@
+// Command amazoncaptcha solves Amazon captchas from the command line.
+//
+// Usage:
+//
+//	amazoncaptcha solve [flags] [file|url|-]...
+//
+// An input is read from standard input when it is "-", downloaded when it is an http or https URL,
+// and read from a file otherwise. With --clipboard, the image currently held by the system clipboard
+// is solved instead. A single input prints its answer alone. Several inputs, or the inputs listed in the
+// file given by --input-list, print one "input<TAB>answer" line each, in input order, so that the command
+// can be used as a batch tool; --parallel sets how many of them are solved concurrently.
+//
+// The exit code tells how the worst input went:
+//
+//	0   every letter was solved
+//	2   some letters could not be recognized
+//	3   an input is not a captcha: it cannot be decoded or its letters cannot be segmented
+//	4   an input could not be fetched
+//	64  the command line is invalid
+//
+// With --quiet, only the answers are printed, one per line, and nothing is reported on standard error.
+// With --montage file, the segmented letters of a single input are also written to file as one PNG image,
+// side by side, to review the segmentation at a glance.
+//
+// The batch command solves every captcha image of a directory, reporting them like several inputs of solve:
+//
+//	amazoncaptcha batch [flags] <dir>
+//
+// The split, train and eval commands work on captchas labeled by their file names, e.g. ABCDEF.jpg.
+// Split cuts them into one PNG image per letter, in one sub-directory per letter; train extracts the
+// features of such letter images into training data, optionally extending existing training data;
+// eval solves them and reports the accuracy, the incorrect answers and the most confused letters:
+//
+//	amazoncaptcha split [-o dir] <file|dir>...
+//	amazoncaptcha train [--base training data] <letters dir> <output>
+//	amazoncaptcha eval [flags] <file|dir>...
+//
+// The collect command downloads captchas from the captcha page into a directory, named after their hashes,
+// to be labeled for training. With --unknown, only captchas showing letters missing from the training data
+// are saved; --proxies names a file of HTTP proxy URLs, one per line, that the workers use in turn. Requests are
+// limited to --rate per second to every host and paused with an exponential backoff when Amazon throttles them:
+//
+//	amazoncaptcha collect [-n count] [-parallel n] [-proxies file] [-o dir] [-unknown] [-rate n]
+//
+// The convert command converts training data between its JSON and binary forms:
+//
+//	amazoncaptcha convert [-lossy] <input> <output>
+//
+// The output is written as JSON if its name ends in .json, and in the binary form embedded by the package otherwise.
+// Entries the binary form cannot store as they are fail the conversion, unless --lossy drops or lowercases them.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Exit codes of the command, ordered by severity for the solve outcomes.
+const (
+	exitSolved     = 0
+	exitUnknown    = 2
+	exitNotCaptcha = 3
+	exitFetch      = 4
+	exitUsage      = 64
+)
+
+const usage = `Usage: amazoncaptcha <command> [flags] [arguments]
+
+Commands:
+  solve    solve captcha images from files, URLs, standard input or the clipboard
+  batch    solve every captcha image of a directory
+  split    split labeled captchas into letter images for training
+  train    build training data from letter images
+  eval     measure the accuracy on labeled captchas
+  collect  download captchas from the captcha page for labeling
+  convert  convert training data between its JSON and binary forms
+
+Run "amazoncaptcha <command> -h" for the flags of a command.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command line args and returns the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "solve":
+		return runSolve(args[1:], stdin, stdout, stderr)
+	case "batch":
+		return runBatch(args[1:], stdout, stderr)
+	case "split":
+		return runSplit(args[1:], stdout, stderr)
+	case "train":
+		return runTrain(args[1:], stdout, stderr)
+	case "eval":
+		return runEval(args[1:], stdout, stderr)
+	case "collect":
+		return runCollect(args[1:], stdout, stderr)
+	case "convert":
+		return runConvert(args[1:], stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return exitSolved
+	default:
+		fmt.Fprintf(stderr, "amazoncaptcha: unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+}
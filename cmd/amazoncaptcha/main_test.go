@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCaptcha renders a captcha showing answer from letters of the training data and writes it to path as PNG.
+func writeCaptcha(t *testing.T, path, answer string) {
+	t.Helper()
+	templates := amazoncaptcha.Templates(3)
+	width := 2
+	for _, c := range answer {
+		width += templates[string(c)][1].Bounds().Dx() + 2
+	}
+	img := image.NewGray(image.Rect(0, 0, width, amazoncaptcha.CaptchaHeight))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	offset := 2
+	for _, c := range answer {
+		letter := templates[string(c)][1]
+		for y := 0; y < letter.Bounds().Dy(); y++ {
+			copy(img.Pix[y*img.Stride+offset:], letter.Pix[y*letter.Stride:(y+1)*letter.Stride])
+		}
+		offset += letter.Bounds().Dx() + 2
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, img))
+	assert.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+}
+
+// writeCorpus writes labeled captchas to a new directory: two correctly labeled, one mislabeled
+// and one that is not a captcha.
+func writeCorpus(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeCaptcha(t, filepath.Join(dir, "ABCEFG.png"), "ABCEFG")
+	writeCaptcha(t, filepath.Join(dir, "HJKLMN.png"), "HJKLMN")
+	writeCaptcha(t, filepath.Join(dir, "ABCEFX.png"), "ABCEFG")
+	var blank bytes.Buffer
+	assert.NoError(t, png.Encode(&blank, image.NewGray(image.Rect(0, 0, 200, 70))))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "PRTUXY.png"), blank.Bytes(), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not an image"), 0644))
+	return dir
+}
+
+func TestRun(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, exitUsage, run(nil, nil, &stdout, &stderr))
+	assert.Equal(t, exitUsage, run([]string{"unknown"}, nil, &stdout, &stderr))
+	assert.Equal(t, exitSolved, run([]string{"help"}, nil, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "solve")
+}
+
+func TestRunSolve(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	// Either the clipboard or some inputs are required, but not both
+	assert.Equal(t, exitUsage, run([]string{"solve"}, nil, &stdout, &stderr))
+	assert.Equal(t, exitUsage, run([]string{"solve", "--clipboard", "captcha.jpg"}, nil, &stdout, &stderr))
+
+	// A blank image is not a captcha
+	var blank bytes.Buffer
+	assert.NoError(t, png.Encode(&blank, image.NewGray(image.Rect(0, 0, 200, 70))))
+	stderr.Reset()
+	assert.Equal(t, exitNotCaptcha, run([]string{"solve", "-"}, bytes.NewReader(blank.Bytes()), &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "not a captcha")
+
+	// Inputs that cannot be fetched are the worst outcome, and do not stop the other inputs
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	stderr.Reset()
+	missing := filepath.Join(t.TempDir(), "missing.jpg")
+	code := run([]string{"solve", missing, "-", server.URL}, bytes.NewReader(blank.Bytes()), &stdout, &stderr)
+	assert.Equal(t, exitFetch, code)
+	assert.Contains(t, stderr.String(), missing)
+	assert.Contains(t, stderr.String(), "not a captcha")
+	assert.Contains(t, stderr.String(), "404")
+
+	// Quiet mode reports nothing but the answers
+	stderr.Reset()
+	assert.Equal(t, exitFetch, run([]string{"solve", "--quiet", missing}, nil, &stdout, &stderr))
+	assert.Empty(t, stderr.String())
+}
+
+func TestRunSolveMontage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	dir := t.TempDir()
+	captcha := filepath.Join(dir, "ABCEFG.png")
+	writeCaptcha(t, captcha, "ABCEFG")
+
+	// The montage shows the letters of the captcha in a single row
+	montage := filepath.Join(dir, "montage.png")
+	assert.Equal(t, exitSolved, run([]string{"solve", "-montage", montage, captcha}, nil, &stdout, &stderr))
+	assert.Equal(t, "ABCEFG\n", stdout.String())
+	file, err := os.Open(montage)
+	if assert.NoError(t, err) {
+		defer file.Close()
+		img, err := png.Decode(file)
+		assert.NoError(t, err)
+		assert.Greater(t, img.Bounds().Dx(), img.Bounds().Dy())
+	}
+
+	// A montage needs a single input
+	assert.Equal(t, exitUsage, run([]string{"solve", "-montage", montage, captcha, captcha}, nil, &stdout, &stderr))
+
+	// Failing to write the montage fails the command, but still reports the answer
+	stdout.Reset()
+	stderr.Reset()
+	code := run([]string{"solve", "-montage", filepath.Join(dir, "missing", "montage.png"), captcha}, nil, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "ABCEFG\n", stdout.String())
+	assert.Contains(t, stderr.String(), "failed to write montage")
+}
+
+func TestRunCollect(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	dir := t.TempDir()
+	writeCaptcha(t, filepath.Join(dir, "captcha.png"), "ABCEFG")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/captcha.png" {
+			http.ServeFile(w, r, filepath.Join(dir, "captcha.png"))
+			return
+		}
+		_, _ = w.Write([]byte(`<form action="/errors/validateCaptcha"><img src="/captcha.png"></form>`))
+	}))
+	defer server.Close()
+
+	// The captcha is saved under its hash, through the proxy read from the list
+	proxies := filepath.Join(dir, "proxies.txt")
+	assert.NoError(t, os.WriteFile(proxies, []byte(server.URL+"\n"), 0644))
+	output := filepath.Join(dir, "captchas")
+	code := run([]string{"collect", "-n", "1", "-parallel", "1", "-proxies", proxies, "-o", output, "-url", "http://captcha.invalid/", "-rate", "-1"}, nil, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "saved 1 of 1 captchas fetched, 0 skipped, 0 failed fetches, 0 throttled\n", stdout.String())
+	entries, err := os.ReadDir(output)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	assert.Equal(t, exitUsage, run([]string{"collect", "-n", "0"}, nil, &stdout, &stderr))
+	assert.Equal(t, exitUsage, run([]string{"collect", "extra"}, nil, &stdout, &stderr))
+	assert.Equal(t, exitUsage, run([]string{"collect", "-rate", "0"}, nil, &stdout, &stderr))
+	assert.Equal(t, 1, run([]string{"collect", "-proxies", filepath.Join(dir, "missing.txt")}, nil, &stdout, &stderr))
+}
+
+func TestRunSolveInputList(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	dir := t.TempDir()
+	var inputs []string
+	for _, name := range []string{"a.png", "b.png", "c.png"} {
+		inputs = append(inputs, filepath.Join(dir, name))
+	}
+	var blank bytes.Buffer
+	assert.NoError(t, png.Encode(&blank, image.NewGray(image.Rect(0, 0, 200, 70))))
+	assert.NoError(t, os.WriteFile(inputs[1], blank.Bytes(), 0644))
+
+	list := filepath.Join(dir, "inputs.txt")
+	assert.NoError(t, os.WriteFile(list, []byte(inputs[0]+"\n\n"+inputs[1]+"\n"+inputs[2]+"\n"), 0644))
+
+	// Every input is reported as TSV, in order
+	code := run([]string{"solve", "--parallel", "3", "--input-list", list}, nil, &stdout, &stderr)
+	assert.Equal(t, exitFetch, code)
+	assert.Equal(t, inputs[0]+"\t\n"+inputs[1]+"\t\n"+inputs[2]+"\t\n", stdout.String())
+
+	assert.Equal(t, exitUsage, run([]string{"solve", "--parallel", "0", inputs[0]}, nil, &stdout, &stderr))
+	assert.Equal(t, exitUsage, run([]string{"solve", "--input-list", filepath.Join(dir, "missing.txt")}, nil, &stdout, &stderr))
+}
+
+func TestRunConvert(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	dir := t.TempDir()
+
+	input := filepath.Join(dir, "training_data.json")
+	assert.NoError(t, os.WriteFile(input, []byte(`{"78da": "A", "78daff": "B"}`), 0644))
+
+	// Converting to binary and back restores the JSON form
+	binary := filepath.Join(dir, "training_data.bin")
+	assert.Equal(t, 0, run([]string{"convert", input, binary}, nil, &stdout, &stderr))
+	output := filepath.Join(dir, "converted.json")
+	assert.Equal(t, 0, run([]string{"convert", binary, output}, nil, &stdout, &stderr))
+	b, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"78da": "A", "78daff": "B"}`, string(b))
+
+	// Entries the binary form cannot store as they are fail the conversion, unless it is lossy
+	assert.NoError(t, os.WriteFile(input, []byte(`{"78da": "A", "78DAFF": "B", "invalid": "C"}`), 0644))
+	stderr.Reset()
+	assert.Equal(t, 1, run([]string{"convert", input, binary}, nil, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "1 entries with invalid features and 1 features in uppercase hex")
+	stderr.Reset()
+	assert.Equal(t, 0, run([]string{"convert", "--lossy", input, binary}, nil, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "dropped 1 entries with invalid features, lowercased 1 features")
+	assert.Equal(t, 0, run([]string{"convert", binary, output}, nil, &stdout, &stderr))
+	b, err = os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"78da": "A", "78daff": "B"}`, string(b))
+
+	// The features of the caller are left unchanged
+	features := map[string]string{"78da": "A", "invalid": "C"}
+	assert.NoError(t, writeTrainingData(binary, features, true, &stderr))
+	assert.Len(t, features, 2)
+
+	assert.Equal(t, exitUsage, run([]string{"convert", input}, nil, &stdout, &stderr))
+	assert.Equal(t, 1, run([]string{"convert", filepath.Join(dir, "missing.json"), binary}, nil, &stdout, &stderr))
+}
+
+func TestRunBatch(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	dir := writeCorpus(t)
+
+	// Every image of the directory is reported as TSV, sorted by name
+	code := run([]string{"batch", dir}, nil, &stdout, &stderr)
+	assert.Equal(t, exitNotCaptcha, code)
+	assert.Equal(t, strings.Join([]string{
+		filepath.Join(dir, "ABCEFG.png") + "\tABCEFG",
+		filepath.Join(dir, "ABCEFX.png") + "\tABCEFG",
+		filepath.Join(dir, "HJKLMN.png") + "\tHJKLMN",
+		filepath.Join(dir, "PRTUXY.png") + "\t",
+	}, "\n")+"\n", stdout.String())
+
+	assert.Equal(t, exitUsage, run([]string{"batch"}, nil, &stdout, &stderr))
+	assert.Equal(t, exitFetch, run([]string{"batch", filepath.Join(dir, "missing")}, nil, &stdout, &stderr))
+}
+
+func TestRunSplitTrainEval(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	dir := writeCorpus(t)
+	letters := filepath.Join(t.TempDir(), "letters")
+
+	// The captchas that can be segmented are split into their letters
+	assert.Equal(t, 0, run([]string{"split", "-o", letters, dir}, nil, &stdout, &stderr))
+	assert.Equal(t, "split 3 of 4 captchas into 18 letters\n", stdout.String())
+	assert.Contains(t, stderr.String(), "PRTUXY.png")
+	assert.FileExists(t, filepath.Join(letters, "X", "ABCEFX_5.png"))
+	assert.FileExists(t, filepath.Join(letters, "H", "HJKLMN_0.png"))
+
+	// Training on the letters learns every letter once, the mislabeled G conflicts with the correct one
+	stdout.Reset()
+	stderr.Reset()
+	output := filepath.Join(t.TempDir(), "training_data.json")
+	assert.Equal(t, 0, run([]string{"train", letters, output}, nil, &stdout, &stderr))
+	assert.Equal(t, "added 12 features, 12 in total\n", stdout.String())
+	assert.Contains(t, stderr.String(), "already known as G")
+
+	// Extending the training data adds nothing new
+	stdout.Reset()
+	assert.Equal(t, 0, run([]string{"train", "--base", output, letters, filepath.Join(t.TempDir(), "training_data.bin")}, nil, &stdout, &stderr))
+	assert.Equal(t, "added 0 features, 12 in total\n", stdout.String())
+
+	// Evaluating reports the incorrect answers and the confused letters
+	stdout.Reset()
+	assert.Equal(t, 0, run([]string{"eval", "--training", output, dir}, nil, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), filepath.Join(dir, "ABCEFX.png")+"\tABCEFX\tABCEFG\n")
+	assert.Contains(t, stdout.String(), filepath.Join(dir, "PRTUXY.png")+"\tPRTUXY\t\n")
+	assert.Contains(t, stdout.String(), "captchas:     4\n")
+	assert.Contains(t, stdout.String(), "correct:      2 (50.0%)\n")
+	assert.Contains(t, stdout.String(), "wrong:        1\n")
+	assert.Contains(t, stdout.String(), "not captchas: 1\n")
+	assert.Contains(t, stdout.String(), "confused:     G/X 1\n")
+
+	stdout.Reset()
+	assert.Equal(t, 1, run([]string{"eval", "--quiet", "--min-accuracy", "0.9", dir}, nil, &stdout, &stderr))
+	assert.NotContains(t, stdout.String(), "ABCEFX.png")
+
+	// Sampling with the same seed evaluates the same captchas
+	stdout.Reset()
+	assert.Equal(t, 0, run([]string{"eval", "--sample", "2", "--seed", "7", dir}, nil, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "captchas:     2\n")
+	sampled := stdout.String()
+	stdout.Reset()
+	assert.Equal(t, 0, run([]string{"eval", "--sample", "2", "--seed", "7", dir}, nil, &stdout, &stderr))
+	assert.Equal(t, sampled, stdout.String())
+	assert.Equal(t, exitUsage, run([]string{"eval", "--sample", "-1", dir}, nil, &stdout, &stderr))
+
+	assert.Equal(t, exitUsage, run([]string{"split"}, nil, &stdout, &stderr))
+	assert.Equal(t, exitUsage, run([]string{"train", letters}, nil, &stdout, &stderr))
+	assert.Equal(t, exitUsage, run([]string{"eval"}, nil, &stdout, &stderr))
+}
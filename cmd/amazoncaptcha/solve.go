@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// outcome is the result of solving a single input.
+type outcome struct {
+	answer string
+	code   int
+	// message describes a failure or partial answer, it is empty for solved inputs.
+	message string
+}
+
+// runSolve implements the solve command.
+func runSolve(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("solve", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	clipboard := flags.Bool("clipboard", false, "solve the image held by the system clipboard")
+	quiet := flags.Bool("quiet", false, "print only the answers")
+	parallel := flags.Int("parallel", 1, "number of inputs solved concurrently")
+	inputList := flags.String("input-list", "", "read the inputs from a file, one per line, or from standard input for \"-\"")
+	montage := flags.String("montage", "", "write the segmented letters of a single input as a PNG `file`")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: amazoncaptcha solve [flags] [file|url|-]...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	inputs := flags.Args()
+	if *inputList != "" {
+		list, err := readInputList(*inputList, stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "amazoncaptcha: %v\n", err)
+			return exitUsage
+		}
+		inputs = append(inputs, list...)
+	}
+	if *clipboard == (len(inputs) > 0 || *inputList != "") || *parallel < 1 {
+		flags.Usage()
+		return exitUsage
+	}
+	if *montage != "" && (len(inputs) > 1 || *inputList != "") {
+		fmt.Fprintln(stderr, "amazoncaptcha: -montage needs a single input")
+		return exitUsage
+	}
+
+	// Solve the clipboard image
+	if *clipboard {
+		b, err := readClipboardImage()
+		if err != nil {
+			return report(stdout, stderr, "clipboard", outcome{code: exitFetch, message: err.Error()}, *quiet, false)
+		}
+		return report(stdout, stderr, "clipboard", solveWithMontage(b, *montage), *quiet, false)
+	}
+
+	// Solve a single input with its montage
+	if *montage != "" {
+		b, err := readInput(inputs[0], stdin)
+		if err != nil {
+			return report(stdout, stderr, inputs[0], outcome{code: exitFetch, message: err.Error()}, *quiet, false)
+		}
+		return report(stdout, stderr, inputs[0], solveWithMontage(b, *montage), *quiet, false)
+	}
+
+	// Several inputs are reported as TSV
+	tsv := len(inputs) > 1 || *inputList != ""
+	return solveInputs(inputs, stdin, stdout, stderr, *parallel, *quiet, tsv)
+}
+
+// solveInputs solves the inputs concurrently with parallel workers, but reports them in order,
+// and returns the exit code of the worst outcome.
+func solveInputs(inputs []string, stdin io.Reader, stdout, stderr io.Writer, parallel int, quiet, tsv bool) int {
+	results := make([]chan outcome, len(inputs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := range results {
+		results[i] = make(chan outcome, 1)
+	}
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] <- solveInput(inputs[i], stdin)
+			}
+		}()
+	}
+	go func() {
+		for i := range inputs {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	// Exit with the worst outcome
+	code := exitSolved
+	for i, input := range inputs {
+		code = worst(code, report(stdout, stderr, input, <-results[i], quiet, tsv))
+	}
+	wg.Wait()
+	return code
+}
+
+// report prints the outcome of an input and returns its exit code.
+func report(stdout, stderr io.Writer, input string, o outcome, quiet, tsv bool) int {
+	if tsv {
+		fmt.Fprintf(stdout, "%s\t%s\n", input, o.answer)
+	} else if o.answer != "" {
+		fmt.Fprintln(stdout, o.answer)
+	}
+	if o.message != "" && !quiet {
+		fmt.Fprintf(stderr, "amazoncaptcha: %s: %s\n", input, o.message)
+	}
+	return o.code
+}
+
+// worst returns the more severe of two exit codes.
+func worst(a, b int) int {
+	if b > a {
+		return b
+	}
+	return a
+}
+
+// solveInput reads and solves the captcha image named by input.
+func solveInput(input string, stdin io.Reader) outcome {
+	b, err := readInput(input, stdin)
+	if err != nil {
+		return outcome{code: exitFetch, message: err.Error()}
+	}
+	return solveImage(b)
+}
+
+// solveImage solves a captcha image.
+func solveImage(b []byte) outcome {
+	result, err := amazoncaptcha.SolveDetailed(bytes.NewReader(b))
+	if err != nil {
+		return outcome{code: exitNotCaptcha, message: fmt.Sprintf("not a captcha: %v", err)}
+	}
+	if !result.Solved {
+		return outcome{
+			answer:  result.Text,
+			code:    exitUnknown,
+			message: fmt.Sprintf("unknown letters at positions %v", result.UnknownPositions()),
+		}
+	}
+	return outcome{answer: result.Text, code: exitSolved}
+}
+
+// solveWithMontage solves a captcha image and, if path is not empty, writes the montage of its letters to path.
+// Captchas that cannot be segmented have no montage.
+func solveWithMontage(b []byte, path string) outcome {
+	o := solveImage(b)
+	if path == "" || o.code == exitNotCaptcha {
+		return o
+	}
+	letters, err := amazoncaptcha.FindLetters(bytes.NewReader(b))
+	if err == nil {
+		err = amazoncaptcha.SaveGrayToPNG(path, amazoncaptcha.Montage(letters, 0))
+	}
+	if err != nil {
+		message := fmt.Sprintf("failed to write montage: %v", err)
+		if o.message != "" {
+			message = o.message + "; " + message
+		}
+		return outcome{answer: o.answer, code: worst(o.code, 1), message: message}
+	}
+	return o
+}
+
+// readInput reads the captcha image named by input: standard input for "-", a download for an http or https URL,
+// and a file otherwise.
+func readInput(input string, stdin io.Reader) ([]byte, error) {
+	switch {
+	case input == "-":
+		return io.ReadAll(stdin)
+	case strings.HasPrefix(input, "http://") || strings.HasPrefix(input, "https://"):
+		resp, err := http.Get(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to make HTTP request: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected HTTP status code: %d", resp.StatusCode)
+		}
+		return io.ReadAll(resp.Body)
+	default:
+		return os.ReadFile(input)
+	}
+}
+
+// readInputList reads the inputs listed one per line in the file at path, or in stdin for "-".
+// Blank lines are skipped.
+func readInputList(path string, stdin io.Reader) ([]string, error) {
+	r := stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open input list: %w", err)
+		}
+		defer file.Close()
+		r = file
+	}
+
+	var inputs []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			inputs = append(inputs, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read input list: %w", err)
+	}
+	return inputs, nil
+}
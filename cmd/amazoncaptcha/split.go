@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// runSplit implements the split command, cutting labeled captchas into letter images for training.
+func runSplit(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("split", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "letters", "directory to write the letters to, one sub-directory per letter")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: amazoncaptcha split [flags] <file|dir>...")
+		fmt.Fprintln(stderr, "Splits captchas named after their answers, e.g. ABCDEF.jpg, into one PNG image per letter.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	inputs, err := expandInputs(flags.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "amazoncaptcha: %v\n", err)
+		return 1
+	}
+
+	// Skip the captchas that are not labeled or cannot be segmented, but fail on write errors
+	split, letters := 0, 0
+	for _, input := range inputs {
+		label, images, err := labeledLetters(input)
+		if err != nil {
+			fmt.Fprintf(stderr, "amazoncaptcha: skipping %s: %v\n", input, err)
+			continue
+		}
+		if err := saveLetters(input, label, images, *output); err != nil {
+			fmt.Fprintf(stderr, "amazoncaptcha: %s: %v\n", input, err)
+			return 1
+		}
+		split++
+		letters += len(images)
+	}
+	fmt.Fprintf(stdout, "split %d of %d captchas into %d letters\n", split, len(inputs), letters)
+	return 0
+}
+
+// labeledLetters reads the captcha at path, named after its answer, and returns the answer and the letters.
+func labeledLetters(path string) (string, []*image.Gray, error) {
+	label := labelOf(path)
+	if label == "" {
+		return "", nil, errors.New("file name is not a 6-letter answer")
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	letters, err := amazoncaptcha.FindLetters(bytes.NewReader(b))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(letters) != len(label) {
+		return "", nil, fmt.Errorf("found %d letters for a %d-letter answer", len(letters), len(label))
+	}
+	return label, letters, nil
+}
+
+// saveLetters writes the letters of the captcha at path into the directories of their letters below output.
+// The letters are named after the captcha, so that splitting it again overwrites them.
+func saveLetters(path, label string, letters []*image.Gray, output string) error {
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	for i, letter := range letters {
+		dir := filepath.Join(output, label[i:i+1])
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create letter directory: %w", err)
+		}
+		name := filepath.Join(dir, fmt.Sprintf("%s_%d.png", base, i))
+		if err := amazoncaptcha.SaveGrayToPNG(name, letter); err != nil {
+			return fmt.Errorf("failed to save letter: %w", err)
+		}
+	}
+	return nil
+}
+
+// expandInputs replaces the directories among inputs by the captcha images they contain.
+func expandInputs(inputs []string) ([]string, error) {
+	var paths []string
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, input)
+			continue
+		}
+		images, err := listImages(input)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, images...)
+	}
+	return paths, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// runTrain implements the train command, building training data from letter images as written by split.
+func runTrain(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("train", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	base := flags.String("base", "", "training data to extend with the letters, in its JSON or binary form")
+	lossy := flags.Bool("lossy", false, lossyUsage)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: amazoncaptcha train [flags] <letters dir> <output>")
+		fmt.Fprintln(stderr, "Extracts the features of the letter images in one sub-directory per letter, e.g. letters/A/*.png,")
+		fmt.Fprintln(stderr, "and writes them as JSON if output ends in .json, and in the binary form otherwise.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	features := make(map[string]string)
+	if *base != "" {
+		var err error
+		if features, err = readTrainingData(*base); err != nil {
+			fmt.Fprintf(stderr, "amazoncaptcha: %v\n", err)
+			return 1
+		}
+	}
+	added, err := trainLetters(flags.Arg(0), features, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "amazoncaptcha: %v\n", err)
+		return 1
+	}
+	if err := writeTrainingData(flags.Arg(1), features, *lossy, stderr); err != nil {
+		fmt.Fprintf(stderr, "amazoncaptcha: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "added %d features, %d in total\n", added, len(features))
+	return 0
+}
+
+// trainLetters adds the features of the letter images below dir to features and returns the number of
+// features added. Features already known for another letter are reported on stderr and left unchanged.
+func trainLetters(dir string, features map[string]string, stderr io.Writer) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read letters directory: %w", err)
+	}
+
+	added := 0
+	for _, entry := range entries {
+		letter := entry.Name()
+		if !entry.IsDir() || len(letter) != 1 || letter[0] < 'A' || letter[0] > 'Z' {
+			continue
+		}
+		paths, err := filepath.Glob(filepath.Join(dir, letter, "*.png"))
+		if err != nil {
+			return added, err
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			feature, err := letterFeature(path)
+			if err != nil {
+				fmt.Fprintf(stderr, "amazoncaptcha: skipping %s: %v\n", path, err)
+				continue
+			}
+			if known, ok := features[feature]; ok {
+				if known != letter {
+					fmt.Fprintf(stderr, "amazoncaptcha: skipping %s: already known as %s\n", path, known)
+				}
+				continue
+			}
+			features[feature] = letter
+			added++
+		}
+	}
+	return added, nil
+}
+
+// letterFeature extracts the feature of the letter image at path.
+func letterFeature(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode letter: %w", err)
+	}
+	gray, ok := img.(*image.Gray)
+	if !ok {
+		gray = amazoncaptcha.Grayscale(img)
+	}
+	return amazoncaptcha.ExtractFeatures(gray)
+}
@@ -0,0 +1,146 @@
+package collect
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+	"github.com/stretchr/testify/assert"
+)
+
+// unlimited does not throttle the requests of the tests.
+var unlimited = Limit{Rate: -1}
+
+// captchaSite serves a captcha page showing the captchas in turn, and counts the pages served.
+func captchaSite(t *testing.T, captchas [][]byte) (*httptest.Server, *int64) {
+	var pages int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/errors/validateCaptcha":
+			n := atomic.AddInt64(&pages, 1) - 1
+			fmt.Fprintf(w, `<form action="/errors/validateCaptcha"><img src="/captcha/%d.png"><input type="text" name="field-keywords"></form>`, int(n)%len(captchas))
+		case strings.HasPrefix(r.URL.Path, "/captcha/"):
+			i, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/captcha/"), ".png"))
+			if err != nil || i >= len(captchas) {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write(captchas[i])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &pages
+}
+
+// selfTestImages returns the images of the self-test corpus.
+func selfTestImages(t *testing.T) [][]byte {
+	captchas, err := amazoncaptcha.SelfTestCaptchas()
+	if !assert.NoError(t, err) || !assert.True(t, len(captchas) >= 3) {
+		t.FailNow()
+	}
+	images := make([][]byte, len(captchas))
+	for i, captcha := range captchas {
+		images[i] = captcha.Image
+	}
+	return images
+}
+
+func TestRun(t *testing.T) {
+	images := selfTestImages(t)
+	server, _ := captchaSite(t, images[:2])
+	dir := t.TempDir()
+
+	// Captchas fetched twice are saved once
+	stats, err := Run(context.Background(), Config{URL: server.URL + "/errors/validateCaptcha", Count: 2, Concurrency: 1, Output: dir, Limit: unlimited})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 2, stats.Saved)
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	b, err := os.ReadFile(dir + "/" + amazoncaptcha.ImageHash(images[0]) + ".png")
+	assert.NoError(t, err)
+	assert.Equal(t, images[0], b)
+
+	// Captchas saved by earlier collections are skipped until the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	stats, err = Run(ctx, Config{URL: server.URL + "/errors/validateCaptcha", Count: 1, Concurrency: 1, Output: dir, Limit: unlimited})
+	assert.NoError(t, err)
+	assert.Zero(t, stats.Saved)
+	assert.NotZero(t, stats.Skipped)
+}
+
+func TestRunUnknownOnly(t *testing.T) {
+	images := selfTestImages(t)
+	server, pages := captchaSite(t, [][]byte{images[0], images[0], images[1]})
+
+	// With training data recognizing no letter, every captcha shows unknown letters, but the repeated one no new ones
+	solver, err := amazoncaptcha.NewSolver()
+	assert.NoError(t, err)
+	solver.SetTrainingData(map[string]string{"00ff": "A"})
+	stats, err := Run(context.Background(), Config{
+		URL:         server.URL + "/errors/validateCaptcha",
+		Count:       2,
+		Concurrency: 1,
+		Output:      t.TempDir(),
+		UnknownOnly: true,
+		Solver:      solver,
+		Limit:       unlimited,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, Stats{Fetched: 3, Saved: 2, Skipped: 1}, *stats)
+	assert.Equal(t, int64(3), atomic.LoadInt64(pages))
+
+	// With the default training data, no captcha is worth saving
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	stats, err = Run(ctx, Config{URL: server.URL + "/errors/validateCaptcha", Count: 1, Output: t.TempDir(), UnknownOnly: true, Limit: unlimited})
+	assert.NoError(t, err)
+	assert.Zero(t, stats.Saved)
+}
+
+func TestRunProxies(t *testing.T) {
+	server, pages := captchaSite(t, selfTestImages(t)[:1])
+
+	// Requests to an unreachable host go through the proxy
+	stats, err := Run(context.Background(), Config{
+		URL:     "http://captcha.invalid/errors/validateCaptcha",
+		Count:   1,
+		Proxies: []string{server.URL},
+		Output:  t.TempDir(),
+		Limit:   unlimited,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.Saved)
+	assert.NotZero(t, atomic.LoadInt64(pages))
+
+	_, err = Run(context.Background(), Config{Count: 1, Proxies: []string{"http://[::1"}, Output: t.TempDir()})
+	assert.Error(t, err)
+}
+
+func TestRunFailures(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	stats, err := Run(context.Background(), Config{URL: server.URL, Count: 1, Concurrency: 2, MaxFailures: 3, Output: t.TempDir(), Limit: unlimited})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "404")
+	assert.Equal(t, 6, stats.Failed)
+
+	_, err = Run(context.Background(), Config{Count: 0, Output: t.TempDir()})
+	assert.Error(t, err)
+	_, err = Run(context.Background(), Config{Count: 1})
+	assert.Error(t, err)
+}
@@ -0,0 +1,186 @@
+package collect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultRate is the number of requests per second made to a host unless configured otherwise.
+const DefaultRate = 2
+
+// DefaultBurst is the number of requests that can be made to a host at once unless configured otherwise.
+const DefaultBurst = 1
+
+// DefaultBackoff is the pause after the first throttled response of a host unless configured otherwise.
+const DefaultBackoff = 5 * time.Second
+
+// DefaultMaxBackoff is the longest pause after throttled responses of a host unless configured otherwise.
+const DefaultMaxBackoff = 5 * time.Minute
+
+// errThrottled is matched by the errors of responses telling that requests are made too fast.
+var errThrottled = errors.New("throttled")
+
+// Limit throttles the requests to a host with a token bucket, and pauses them with an exponential backoff
+// after responses telling that they are made too fast: a status code of 503 or 429, or a page about automated
+// access instead of a captcha.
+type Limit struct {
+	// Rate is the number of requests per second, DefaultRate if 0. A negative rate does not limit requests.
+	Rate float64
+	// Burst is the number of requests that can be made at once after a pause, DefaultBurst if 0.
+	Burst int
+	// Backoff is the pause after the first throttled response, DefaultBackoff if 0. It doubles with every
+	// throttled response in a row, and is reset by the first response that is not throttled. A longer
+	// Retry-After header takes precedence.
+	Backoff time.Duration
+	// MaxBackoff is the longest pause, DefaultMaxBackoff if 0.
+	MaxBackoff time.Duration
+}
+
+// withDefaults returns the limit with its zero fields replaced by the defaults.
+func (l Limit) withDefaults() Limit {
+	if l.Rate == 0 {
+		l.Rate = DefaultRate
+	}
+	if l.Burst == 0 {
+		l.Burst = DefaultBurst
+	}
+	if l.Backoff == 0 {
+		l.Backoff = DefaultBackoff
+	}
+	if l.MaxBackoff == 0 {
+		l.MaxBackoff = DefaultMaxBackoff
+	}
+	return l
+}
+
+// hostLimiter throttles the requests to a single host.
+type hostLimiter struct {
+	limit Limit
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	paused time.Time
+	delay  time.Duration
+}
+
+// newHostLimiter creates a limiter with a full bucket.
+func newHostLimiter(limit Limit) *hostLimiter {
+	limit = limit.withDefaults()
+	return &hostLimiter{limit: limit, tokens: float64(limit.Burst), delay: limit.Backoff}
+}
+
+// wait blocks until a request may be made, or the context is done.
+func (h *hostLimiter) wait(ctx context.Context) error {
+	for {
+		d := h.reserve(time.Now())
+		if d <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve takes a token at now if the host is not paused and a token is available, and returns 0.
+// Otherwise, it returns how long to wait before trying again.
+func (h *hostLimiter) reserve(now time.Time) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if now.Before(h.paused) {
+		return h.paused.Sub(now)
+	}
+	if h.limit.Rate < 0 {
+		return 0
+	}
+
+	// Refill the bucket for the time passed since the last reservation
+	if !h.last.IsZero() {
+		h.tokens += now.Sub(h.last).Seconds() * h.limit.Rate
+		if h.tokens > float64(h.limit.Burst) {
+			h.tokens = float64(h.limit.Burst)
+		}
+	}
+	h.last = now
+	if h.tokens >= 1 {
+		h.tokens--
+		return 0
+	}
+	return time.Duration((1 - h.tokens) / h.limit.Rate * float64(time.Second))
+}
+
+// throttled pauses the requests to the host after a throttled response at now, for the current backoff or the
+// retryAfter of the response if longer, and doubles the backoff.
+func (h *hostLimiter) throttled(now time.Time, retryAfter time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	pause := h.delay
+	if retryAfter > pause {
+		pause = retryAfter
+	}
+	if pause > h.limit.MaxBackoff {
+		pause = h.limit.MaxBackoff
+	}
+	if until := now.Add(pause); until.After(h.paused) {
+		h.paused = until
+	}
+	h.delay *= 2
+	if h.delay > h.limit.MaxBackoff {
+		h.delay = h.limit.MaxBackoff
+	}
+}
+
+// succeeded resets the backoff after a response that was not throttled.
+func (h *hostLimiter) succeeded() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.delay = h.limit.Backoff
+}
+
+// limiters holds the limiters of the hosts requested by a collection.
+type limiters struct {
+	config Config
+
+	mu    sync.Mutex
+	hosts map[string]*hostLimiter
+}
+
+// host returns the limiter of the host named host, creating it on first use with the limit configured for the
+// host, or the default limit of the collection.
+func (l *limiters) host(host string) *hostLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if h, ok := l.hosts[host]; ok {
+		return h
+	}
+	limit, ok := l.config.HostLimits[host]
+	if !ok {
+		limit = l.config.Limit
+	}
+	h := newHostLimiter(limit)
+	l.hosts[host] = h
+	return h
+}
+
+// isThrottled reports whether a response tells that requests are made too fast.
+func isThrottled(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// retryAfter returns the pause requested by the Retry-After header of a response in seconds, or 0.
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
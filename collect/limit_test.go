@@ -0,0 +1,91 @@
+package collect
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostLimiter(t *testing.T) {
+	h := newHostLimiter(Limit{Rate: 10, Burst: 2, Backoff: time.Second, MaxBackoff: 3 * time.Second})
+	now := time.Now()
+
+	// The burst is available at once, then a token every 100ms
+	assert.Zero(t, h.reserve(now))
+	assert.Zero(t, h.reserve(now))
+	assert.Equal(t, 100*time.Millisecond, h.reserve(now))
+	assert.Zero(t, h.reserve(now.Add(100*time.Millisecond)))
+	now = now.Add(time.Hour)
+
+	// Throttled responses pause the host for twice as long every time, up to the maximum
+	h.throttled(now, 0)
+	assert.Equal(t, time.Second, h.reserve(now))
+	h.throttled(now, 0)
+	assert.Equal(t, 2*time.Second, h.reserve(now))
+	h.throttled(now, 0)
+	assert.Equal(t, 3*time.Second, h.reserve(now))
+	assert.Zero(t, h.reserve(now.Add(3*time.Second)))
+
+	// A successful response resets the backoff, a longer Retry-After takes precedence
+	h.succeeded()
+	now = now.Add(time.Hour)
+	h.throttled(now, 0)
+	assert.Equal(t, time.Second, h.reserve(now))
+	h.succeeded()
+	h.throttled(now, 2*time.Second)
+	assert.Equal(t, 2*time.Second, h.reserve(now))
+
+	// Negative rates do not limit requests
+	h = newHostLimiter(Limit{Rate: -1})
+	for i := 0; i < 10; i++ {
+		assert.Zero(t, h.reserve(now))
+	}
+}
+
+func TestRunThrottled(t *testing.T) {
+	captcha := selfTestImages(t)[0]
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt64(&requests, 1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			_, _ = w.Write([]byte(`<p>To discuss automated access to Amazon data please contact api-services-support@amazon.com.</p>`))
+		default:
+			if r.URL.Path == "/captcha.png" {
+				_, _ = w.Write(captcha)
+				return
+			}
+			_, _ = w.Write([]byte(`<form action="/errors/validateCaptcha"><img src="/captcha.png"></form>`))
+		}
+	}))
+	defer server.Close()
+
+	// Throttled responses are retried after the backoff
+	start := time.Now()
+	stats, err := Run(context.Background(), Config{
+		URL:         server.URL,
+		Count:       1,
+		Concurrency: 1,
+		Output:      t.TempDir(),
+		HostLimits:  map[string]Limit{"127.0.0.1": {Rate: -1, Backoff: 50 * time.Millisecond}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, Stats{Fetched: 1, Saved: 1, Failed: 2, Throttled: 2}, *stats)
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+
+	// Requests are spread out by the rate of their host
+	atomic.StoreInt64(&requests, 2)
+	start = time.Now()
+	_, err = Run(context.Background(), Config{URL: server.URL, Count: 1, Concurrency: 1, Output: t.TempDir(), Limit: Limit{Rate: 10}})
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+
+	_, err = Run(context.Background(), Config{URL: server.URL, Count: 1, Output: t.TempDir(), Limit: Limit{Burst: -1}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,179 @@
+package amazoncaptcha
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"io"
+)
+
+// gifMagic starts every GIF image, followed by the version "87a" or "89a".
+const gifMagic = "GIF8"
+
+// decodeImage decodes a captcha image in any registered format. Captchas saved by some browser extensions
+// arrive wrapped as GIFs, possibly animated: of an animated GIF, the frame with the most black pixels after
+// binarization is returned, since the other frames are usually blank or partially drawn. Images of other
+// dimensions than the canonical ones of the Solver, if any, fail with a *DimensionError. With a pixel limit, the
+// frames of an animated GIF count towards it together, since all of them are decoded.
+func (s *Solver) decodeImage(r io.Reader) (image.Image, error) {
+
+	// Check the header of the image against the size limits, if any, before reading the rest
+	exceeded := func() bool { return false }
+	if s.maxImageBytes > 0 || s.maxImagePixels > 0 {
+		var err error
+		if r, exceeded, err = limitImage(r, s.maxImageBytes, s.maxImagePixels); err != nil {
+			return nil, err
+		}
+	}
+
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(gifMagic)); bytes.Equal(magic, []byte(gifMagic)) {
+		var gr io.Reader = br
+		if s.maxImagePixels > 0 {
+			// Count the frames before decoding any, a few bytes of LZW data decompressing to a full frame
+			b, err := io.ReadAll(br)
+			if err != nil {
+				return nil, decodeError(err, exceeded())
+			}
+			if err := checkGIFPixels(b, s.maxImagePixels); err != nil {
+				return nil, err
+			}
+			gr = bytes.NewReader(b)
+		}
+		anim, err := gif.DecodeAll(gr)
+		if err != nil {
+			return nil, decodeError(err, exceeded())
+		}
+		img := s.selectFrame(anim)
+		return img, s.checkDimensions(img.Bounds())
+	}
+
+	img, _, err := image.Decode(br)
+	if err != nil {
+		return nil, decodeError(err, exceeded())
+	}
+	return img, s.checkDimensions(img.Bounds())
+}
+
+// decodeError returns the error of an image that could not be decoded, matching ErrImageTooLarge if it
+// exceeded the byte limit while decoding.
+func decodeError(err error, tooLarge bool) error {
+	if tooLarge {
+		return fmt.Errorf("error decoding image: %w", ErrImageTooLarge)
+	}
+	return fmt.Errorf("error decoding image: %v", err)
+}
+
+// checkGIFPixels fails with ErrImageTooLarge if the frames of a GIF, each as large as its logical screen, have
+// more than maxPixels pixels in total. The frames are counted by walking the blocks of the GIF without
+// decompressing them; a malformed GIF is left to the decoder to reject.
+func checkGIFPixels(b []byte, maxPixels int) error {
+	if len(b) < 13 {
+		return nil
+	}
+	width, height := int(b[6])|int(b[7])<<8, int(b[8])|int(b[9])<<8
+	pixels := width * height
+	if pixels == 0 {
+		return nil
+	}
+
+	// Skip the header, the logical screen descriptor and the global color table
+	i := 13
+	if b[10]&0x80 != 0 {
+		i += 3 << (b[10]&0x07 + 1)
+	}
+
+	// skipSubBlocks returns the offset after the data sub-blocks starting at offset i
+	skipSubBlocks := func(i int) int {
+		for i < len(b) && b[i] != 0 {
+			i += int(b[i]) + 1
+		}
+		return i + 1
+	}
+
+	frames := 0
+	for i < len(b) {
+		switch b[i] {
+		case 0x21: // Extension: label and data sub-blocks
+			i = skipSubBlocks(i + 2)
+		case 0x2c: // Image descriptor, local color table, LZW minimum code size and data sub-blocks
+			if i+10 > len(b) {
+				return nil
+			}
+			frames++
+			if frames > maxPixels/pixels {
+				return fmt.Errorf("%w: more than %d frames of %dx%d pixels exceed the maximum of %d pixels", ErrImageTooLarge, frames-1, width, height, maxPixels)
+			}
+			flags := b[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			i = skipSubBlocks(i + 1)
+		default: // Trailer or malformed block
+			return nil
+		}
+	}
+	return nil
+}
+
+// selectFrame renders the frames of a GIF and returns the one with the most black pixels after binarization
+// at the mono threshold of the Solver. Frames are drawn over a white canvas, honoring their disposal methods.
+func (s *Solver) selectFrame(anim *gif.GIF) image.Image {
+	if len(anim.Image) == 1 {
+		return anim.Image[0]
+	}
+
+	bounds := image.Rect(0, 0, anim.Config.Width, anim.Config.Height)
+	canvas := image.NewRGBA(bounds)
+	draw.Draw(canvas, bounds, image.White, image.Point{}, draw.Src)
+
+	var best *image.RGBA
+	bestInk := -1
+	for i, frame := range anim.Image {
+		// Keep the canvas to restore if the frame is to be disposed to the previous state
+		var previous *image.RGBA
+		disposal := byte(gif.DisposalNone)
+		if i < len(anim.Disposal) {
+			disposal = anim.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = cloneRGBA(canvas)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		gray := Grayscale(canvas)
+		if ink := countInk(MonoChrome(gray, s.threshold(gray))); ink > bestInk {
+			best, bestInk = cloneRGBA(canvas), ink
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.White, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return best
+}
+
+// countInk returns the number of black pixels of a monochrome image.
+func countInk(img *image.Gray) int {
+	ink := 0
+	for _, p := range img.Pix {
+		if p == 0 {
+			ink++
+		}
+	}
+	return ink
+}
+
+// cloneRGBA returns a copy of img.
+func cloneRGBA(img *image.RGBA) *image.RGBA {
+	clone := image.NewRGBA(img.Bounds())
+	copy(clone.Pix, img.Pix)
+	return clone
+}
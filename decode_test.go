@@ -0,0 +1,57 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// gifCaptcha wraps a synthetic captcha into a GIF, preceded by blank frames.
+func gifCaptcha(t *testing.T, answer string, blankFrames int) []byte {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(syntheticCaptcha(t, answer)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	anim := &gif.GIF{}
+	for i := 0; i <= blankFrames; i++ {
+		frame := image.NewPaletted(img.Bounds(), palette.Plan9)
+		draw.Draw(frame, frame.Bounds(), image.White, image.Point{}, draw.Src)
+		if i == blankFrames {
+			draw.Draw(frame, frame.Bounds(), img, image.Point{}, draw.Src)
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 10)
+		anim.Disposal = append(anim.Disposal, gif.DisposalBackground)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSolveGIF(t *testing.T) {
+	// Single-frame GIF
+	answer, err := SolveBytes(gifCaptcha(t, "ABCEFG", 0))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", answer)
+
+	// Animated GIF whose captcha is drawn in the last frame
+	answer, err = SolveBytes(gifCaptcha(t, "XYTUKH", 2))
+	assert.NoError(t, err)
+	assert.Equal(t, "XYTUKH", answer)
+
+	// A truncated GIF is rejected
+	b := gifCaptcha(t, "XYTUKH", 1)
+	_, err = SolveBytes(b[:len(b)/2])
+	assert.Error(t, err)
+}
@@ -0,0 +1,157 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"image"
+	"io"
+)
+
+// DiffStage names a stage of the solving pipeline compared by Diff.
+type DiffStage string
+
+const (
+	// DiffNone reports that two images are solved identically at every stage.
+	DiffNone DiffStage = ""
+	// DiffDecode reports that the images could not both be decoded, or differ in size.
+	DiffDecode DiffStage = "decode"
+	// DiffBinarize reports that the monochrome images differ in some pixels.
+	DiffBinarize DiffStage = "binarize"
+	// DiffSegment reports that the letters were segmented into other boxes.
+	DiffSegment DiffStage = "segment"
+	// DiffFeatures reports that some letters have other features.
+	DiffFeatures DiffStage = "features"
+	// DiffAnswer reports that the answers differ, e.g. because a fallback threshold recognized one of them.
+	DiffAnswer DiffStage = "answer"
+)
+
+// PipelineTrace holds the intermediate results of solving an image, as far as the pipeline got.
+type PipelineTrace struct {
+	// Size is the size of the decoded image.
+	Size image.Point
+	// Threshold is the threshold the image was binarized at.
+	Threshold uint8
+	// Boxes holds the letter boxes found in the monochrome image.
+	Boxes []image.Rectangle
+	// Features holds the features of the segmented letters.
+	Features []string
+	// Answer is the answer the Solver returns, with placeholders for unknown letters.
+	Answer string
+	// Err is the error that stopped the pipeline, if any.
+	Err error
+
+	// mono is the monochrome image.
+	mono *image.Gray
+}
+
+// PipelineDiff reports where the pipelines solving two images diverge.
+type PipelineDiff struct {
+	// Stage is the first stage at which the pipelines diverge, DiffNone if they do not.
+	Stage DiffStage
+	// A and B trace the pipelines of the two images.
+	A, B PipelineTrace
+	// Pixels holds the pixels that differ between the monochrome images, row by row. It is only computed for
+	// images of the same size.
+	Pixels []image.Point
+	// Boxes holds the positions of the letter boxes that differ, including those found in one image only.
+	Boxes []int
+	// Features holds the positions of the letters whose features differ, including letters of one image only.
+	Features []int
+}
+
+// Diff solves two images, e.g. a captcha and a re-encoded copy of it, and reports the first stage at which
+// their pipelines diverge together with the differences at every stage: the pixels differing after
+// binarization, the letter boxes and the features of the letters. It helps explaining why a captcha solved
+// locally fails elsewhere. Like Evaluate, it does not record the solves in the statistics or the journal.
+func Diff(a, b io.Reader) *PipelineDiff {
+	return defaultSolver().Diff(a, b)
+}
+
+// Diff works like the package-level Diff, using the configuration and training data of the Solver.
+func (s *Solver) Diff(a, b io.Reader) *PipelineDiff {
+	probe := s.detached()
+	d := &PipelineDiff{A: probe.trace(a), B: probe.trace(b)}
+
+	// Compare the monochrome images pixel by pixel
+	if d.A.mono != nil && d.B.mono != nil && d.A.Size == d.B.Size {
+		minA, minB := d.A.mono.Rect.Min, d.B.mono.Rect.Min
+		for y := 0; y < d.A.Size.Y; y++ {
+			for x := 0; x < d.A.Size.X; x++ {
+				if d.A.mono.GrayAt(minA.X+x, minA.Y+y) != d.B.mono.GrayAt(minB.X+x, minB.Y+y) {
+					d.Pixels = append(d.Pixels, image.Pt(x, y))
+				}
+			}
+		}
+	}
+
+	// Compare the letter boxes and features position by position
+	for i := 0; i < len(d.A.Boxes) || i < len(d.B.Boxes); i++ {
+		if i >= len(d.A.Boxes) || i >= len(d.B.Boxes) || d.A.Boxes[i] != d.B.Boxes[i] {
+			d.Boxes = append(d.Boxes, i)
+		}
+	}
+	for i := 0; i < len(d.A.Features) || i < len(d.B.Features); i++ {
+		if i >= len(d.A.Features) || i >= len(d.B.Features) || d.A.Features[i] != d.B.Features[i] {
+			d.Features = append(d.Features, i)
+		}
+	}
+
+	// Find the first stage that differs
+	switch {
+	case d.A.mono == nil || d.B.mono == nil || d.A.Size != d.B.Size:
+		d.Stage = DiffDecode
+	case len(d.Pixels) > 0:
+		d.Stage = DiffBinarize
+	case len(d.Boxes) > 0:
+		d.Stage = DiffSegment
+	case len(d.Features) > 0:
+		d.Stage = DiffFeatures
+	case d.A.Answer != d.B.Answer || (d.A.Err == nil) != (d.B.Err == nil):
+		d.Stage = DiffAnswer
+	}
+	return d
+}
+
+// trace runs the pipeline of the Solver on an image step by step, recording the intermediate results.
+func (s *Solver) trace(r io.Reader) PipelineTrace {
+	var t PipelineTrace
+	b, err := s.readImage(r)
+	if err != nil {
+		t.Err = err
+		return t
+	}
+
+	// Decode and binarize the image
+	grayImg, err := s.decodeGrayscale(bytes.NewReader(b), nil)
+	if err != nil {
+		t.Err = err
+		return t
+	}
+	t.Size = grayImg.Bounds().Size()
+	t.Threshold = s.threshold(grayImg)
+	t.mono = s.binarize(grayImg, t.Threshold, nil)
+
+	// Segment the letters and extract their features
+	t.Boxes = s.findLetterBoxes(t.mono)
+	letters, err := s.cropLetters(t.mono, t.Boxes, nil)
+	if err != nil {
+		t.Err = err
+		return t
+	}
+	for _, letter := range letters {
+		feature, err := s.letterFeature(letter, nil)
+		if err != nil {
+			t.Err = err
+			return t
+		}
+		t.Features = append(t.Features, feature)
+	}
+
+	// Solve the image as a whole, with the fallbacks of the Solver
+	result, err := s.solve(bytes.NewReader(b))
+	if err != nil {
+		t.Err = err
+		return t
+	}
+	t.Answer = result.Text
+	return t
+}
@@ -0,0 +1,67 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	captcha := syntheticCaptcha(t, "ABCEFG")
+
+	// Identical images do not diverge
+	d := Diff(bytes.NewReader(captcha), bytes.NewReader(captcha))
+	assert.Equal(t, DiffNone, d.Stage)
+	assert.Equal(t, "ABCEFG", d.A.Answer)
+	assert.Len(t, d.A.Features, 6)
+	assert.Empty(t, d.Pixels)
+
+	// A flipped pixel diverges at binarization and changes the features of its letter
+	stats := SolveStats()
+	d = Diff(bytes.NewReader(captcha), bytes.NewReader(flipPixel(t, captcha, 2)))
+	assert.Equal(t, stats.Solves, SolveStats().Solves)
+	assert.Equal(t, DiffBinarize, d.Stage)
+	assert.Len(t, d.Pixels, 1)
+	assert.Empty(t, d.Boxes)
+	assert.Equal(t, []int{2}, d.Features)
+	assert.Equal(t, "AB-EFG", d.B.Answer)
+
+	// Images of other sizes diverge at decoding, but their letters are still compared
+	d = Diff(bytes.NewReader(captcha), bytes.NewReader(syntheticCaptcha(t, "HJKLMN")))
+	assert.Equal(t, DiffDecode, d.Stage)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5}, d.Features)
+
+	// Images that cannot be decoded diverge at decoding
+	d = Diff(bytes.NewReader(captcha), bytes.NewReader([]byte("not an image")))
+	assert.Equal(t, DiffDecode, d.Stage)
+	assert.Error(t, d.B.Err)
+	assert.Empty(t, d.B.Boxes)
+}
+
+func TestDiffSegmentation(t *testing.T) {
+	// Erasing a letter changes the segmentation
+	img, err := png.Decode(bytes.NewReader(syntheticCaptcha(t, "ABCEFG")))
+	if !assert.NoError(t, err) {
+		return
+	}
+	gray := img.(*image.Gray)
+	box := FindLetterBoxes(gray, MaximumLetterLength)[5]
+	erased := image.NewGray(gray.Bounds())
+	copy(erased.Pix, gray.Pix)
+	for y := box.Min.Y; y < box.Max.Y; y++ {
+		for x := box.Min.X; x < box.Max.X; x++ {
+			erased.Pix[y*erased.Stride+x] = 255
+		}
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, erased))
+
+	d := Diff(bytes.NewReader(syntheticCaptcha(t, "ABCEFG")), &buf)
+	assert.Equal(t, DiffBinarize, d.Stage)
+	assert.Equal(t, []int{5}, d.Boxes)
+	assert.ErrorIs(t, d.B.Err, ErrSegmentationFailed)
+	assert.Empty(t, d.B.Features)
+}
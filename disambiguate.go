@@ -0,0 +1,247 @@
+package amazoncaptcha
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sort"
+)
+
+// ConfusionMatrix counts how often every letter was recognized as every other letter, keyed by the
+// expected letter and then by the recognized one. It is built from answers with known solutions and
+// tells which letter pairs deserve a DisambiguationRule.
+type ConfusionMatrix map[string]map[string]int
+
+// Record compares an answer with its known solution letter by letter and counts every letter recognized
+// as another one. Unknown letters and answers of a different length than the solution are not counted.
+func (c ConfusionMatrix) Record(want, got string) {
+	if len(want) != len(got) {
+		return
+	}
+	for i := 0; i < len(want); i++ {
+		w, g := want[i:i+1], got[i:i+1]
+		if w == g || g[0] < 'A' || g[0] > 'Z' {
+			continue
+		}
+		if c[w] == nil {
+			c[w] = make(map[string]int)
+		}
+		c[w][g]++
+	}
+}
+
+// Pairs returns the letter pairs confused with each other at least minCount times in either direction,
+// most confused first. The letters of every pair are in alphabetical order.
+func (c ConfusionMatrix) Pairs(minCount int) [][2]string {
+	counts := make(map[[2]string]int)
+	for want, row := range c {
+		for got, n := range row {
+			pair := [2]string{want, got}
+			if got < want {
+				pair = [2]string{got, want}
+			}
+			counts[pair] += n
+		}
+	}
+
+	pairs := make([][2]string, 0, len(counts))
+	for pair, n := range counts {
+		if n >= minCount {
+			pairs = append(pairs, pair)
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if counts[pairs[i]] != counts[pairs[j]] {
+			return counts[pairs[i]] > counts[pairs[j]]
+		}
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	return pairs
+}
+
+// DisambiguationRule breaks ties between two letters of the font that are easily confused, e.g. B and E,
+// by re-examining the region of the letter where they differ most. Letters are centered with AlignLetter
+// before the region is examined, so the region is in the coordinates of the aligned letter.
+type DisambiguationRule struct {
+	// Letters are the two confused letters, the one with more ink in the region first.
+	Letters [2]string
+	// Region is the area of the aligned letter that tells the letters apart.
+	Region image.Rectangle
+	// Threshold is the share of black pixels in the region, between 0 and 1, at or above which
+	// a letter is taken for the first letter rather than the second.
+	Threshold float64
+}
+
+// decide returns the letter of the rule an aligned letter is taken for.
+func (r *DisambiguationRule) decide(aligned *image.Gray) string {
+	region := r.Region.Intersect(aligned.Bounds())
+	if region.Empty() {
+		return ""
+	}
+	ink := 0
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		for x := region.Min.X; x < region.Max.X; x++ {
+			if aligned.GrayAt(x, y).Y == 0 {
+				ink++
+			}
+		}
+	}
+	if float64(ink)/float64(region.Dx()*region.Dy()) >= r.Threshold {
+		return r.Letters[0]
+	}
+	return r.Letters[1]
+}
+
+// minRuleRegion and maxRuleRegion bound the width and height of the regions learned by DisambiguationRules.
+// Smaller regions would hinge on single pixels, which binarization noise flips easily.
+const (
+	minRuleRegion = 3
+	maxRuleRegion = 12
+)
+
+// DisambiguationRules learns a DisambiguationRule for every pair of letters from the training data, e.g.
+// for the pairs returned by ConfusionMatrix.Pairs. The region of a rule is the one where the share of black
+// pixels of the aligned training letters differs most between the two letters, and its threshold lies
+// halfway between their shares. An error is returned if a letter of a pair is not in the training data.
+func DisambiguationRules(pairs ...[2]string) ([]DisambiguationRule, error) {
+	return defaultSolver().DisambiguationRules(pairs...)
+}
+
+// DisambiguationRules works like the package-level DisambiguationRules, using the training data of the Solver.
+func (s *Solver) DisambiguationRules(pairs ...[2]string) ([]DisambiguationRule, error) {
+	entries := s.trainingData().index()
+
+	// Average the aligned training letters of every letter into a map of the share of black pixels
+	inkMaps := make(map[string][]float64)
+	for _, pair := range pairs {
+		for _, letter := range pair {
+			inkMaps[letter] = nil
+		}
+	}
+	counts := make(map[string]int, len(inkMaps))
+	for _, entry := range entries {
+		if _, ok := inkMaps[entry.letter]; !ok {
+			continue
+		}
+		binaryStr, err := decodeFeature(entry.feature)
+		if err != nil {
+			continue
+		}
+		img := featureImage(binaryStr, CaptchaHeight)
+		if img == nil {
+			continue
+		}
+		if inkMaps[entry.letter] == nil {
+			inkMaps[entry.letter] = make([]float64, AlignedLetterWidth*CaptchaHeight)
+		}
+		for i, p := range AlignLetter(img).Pix {
+			if p == 0 {
+				inkMaps[entry.letter][i]++
+			}
+		}
+		counts[entry.letter]++
+	}
+	for letter, inkMap := range inkMaps {
+		for i := range inkMap {
+			inkMap[i] /= float64(counts[letter])
+		}
+	}
+
+	rules := make([]DisambiguationRule, 0, len(pairs))
+	for _, pair := range pairs {
+		if pair[0] == pair[1] {
+			return nil, fmt.Errorf("cannot disambiguate letter %q from itself", pair[0])
+		}
+		a, b := inkMaps[pair[0]], inkMaps[pair[1]]
+		if a == nil || b == nil {
+			return nil, fmt.Errorf("failed to learn rule for %s and %s: letter not in training data", pair[0], pair[1])
+		}
+		rules = append(rules, learnRule(pair, a, b))
+	}
+	return rules, nil
+}
+
+// learnRule finds the region where the ink maps of two letters differ most, using summed-area tables
+// so that every region is scored in constant time.
+func learnRule(pair [2]string, a, b []float64) DisambiguationRule {
+	width, height := AlignedLetterWidth, CaptchaHeight
+	sumA, sumB := summedArea(a, width, height), summedArea(b, width, height)
+	regionSum := func(sum []float64, r image.Rectangle) float64 {
+		w := width + 1
+		return sum[r.Max.Y*w+r.Max.X] - sum[r.Min.Y*w+r.Max.X] - sum[r.Max.Y*w+r.Min.X] + sum[r.Min.Y*w+r.Min.X]
+	}
+
+	var best image.Rectangle
+	bestA, bestB, bestDiff := 0.0, 0.0, -1.0
+	for h := minRuleRegion; h <= maxRuleRegion; h++ {
+		for w := minRuleRegion; w <= maxRuleRegion; w++ {
+			area := float64(w * h)
+			for y := 0; y+h <= height; y++ {
+				for x := 0; x+w <= width; x++ {
+					r := image.Rect(x, y, x+w, y+h)
+					shareA, shareB := regionSum(sumA, r)/area, regionSum(sumB, r)/area
+					diff := shareA - shareB
+					if diff < 0 {
+						diff = -diff
+					}
+					if diff > bestDiff {
+						best, bestA, bestB, bestDiff = r, shareA, shareB, diff
+					}
+				}
+			}
+		}
+	}
+
+	// Put the letter with more ink in the region first
+	if bestA < bestB {
+		pair[0], pair[1] = pair[1], pair[0]
+		bestA, bestB = bestB, bestA
+	}
+	return DisambiguationRule{Letters: pair, Region: best, Threshold: (bestA + bestB) / 2}
+}
+
+// summedArea returns the summed-area table of a plane, with an extra leading row and column of zeros.
+func summedArea(plane []float64, width, height int) []float64 {
+	w := width + 1
+	sum := make([]float64, w*(height+1))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			sum[(y+1)*w+x+1] = plane[y*width+x] + sum[y*w+x+1] + sum[(y+1)*w+x] - sum[y*w+x]
+		}
+	}
+	return sum
+}
+
+// disambiguate re-examines a letter that was not matched exactly and was recognized as a letter of
+// one of the rules of the Solver, and returns the letter the first such rule takes it for.
+func (s *Solver) disambiguate(letter *image.Gray, match letterMatch, a *arena) string {
+	if match.letter == "" || match.confidence >= 1 {
+		return match.letter
+	}
+	for i := range s.rules {
+		rule := &s.rules[i]
+		if match.letter != rule.Letters[0] && match.letter != rule.Letters[1] {
+			continue
+		}
+		if decided := rule.decide(alignLetter(letter, a)); decided != "" {
+			return decided
+		}
+	}
+	return match.letter
+}
+
+// validateRules reports an error for rules that cannot be applied.
+func validateRules(rules []DisambiguationRule) error {
+	for _, rule := range rules {
+		if rule.Letters[0] == "" || rule.Letters[1] == "" || rule.Letters[0] == rule.Letters[1] {
+			return errors.New("disambiguation rule must name two different letters")
+		}
+		if rule.Region.Empty() {
+			return fmt.Errorf("disambiguation rule for %s and %s has an empty region", rule.Letters[0], rule.Letters[1])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfusionMatrix(t *testing.T) {
+	c := make(ConfusionMatrix)
+	c.Record("ABCEFG", "AECBFG")
+	c.Record("KXKXKX", "XKXKKX")
+	c.Record("ABCEFG", "A-CEFG")
+	c.Record("ABCEFG", "ABC")
+
+	assert.Equal(t, 1, c["B"]["E"])
+	assert.Equal(t, 1, c["E"]["B"])
+	assert.Equal(t, 2, c["K"]["X"])
+	assert.Equal(t, [][2]string{{"K", "X"}}, c.Pairs(3))
+	assert.Equal(t, [][2]string{{"K", "X"}, {"B", "E"}}, c.Pairs(2))
+}
+
+func TestDisambiguationRules(t *testing.T) {
+	requireTrainingData(t)
+	rules, err := DisambiguationRules([2]string{"B", "E"}, [2]string{"K", "X"})
+	assert.NoError(t, err)
+	assert.Len(t, rules, 2)
+
+	// The rules tell the training letters of their pairs apart
+	for _, rule := range rules {
+		assert.False(t, rule.Region.Empty())
+		for _, letter := range rule.Letters {
+			_, binaryStr := trainingLetter(t, letter)
+			assert.Equal(t, letter, rule.decide(AlignLetter(featureImage(binaryStr, CaptchaHeight))))
+		}
+	}
+
+	_, err = DisambiguationRules([2]string{"B", "?"})
+	assert.Error(t, err)
+	_, err = DisambiguationRules([2]string{"B", "B"})
+	assert.Error(t, err)
+}
+
+// fixedRecognizer is a Recognizer that recognizes every letter as the same letter.
+type fixedRecognizer string
+
+func (r fixedRecognizer) Recognize(*image.Gray) (string, float64) {
+	return string(r), 0.5
+}
+
+func TestNewSolverWithDisambiguation(t *testing.T) {
+	captcha := flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 1)
+	rules, err := DisambiguationRules([2]string{"B", "E"})
+	assert.NoError(t, err)
+
+	// Without the rules, the recognizer mistakes the B for an E
+	solver, err := NewSolver(WithRecognizer(fixedRecognizer("E")))
+	assert.NoError(t, err)
+	result, err := solver.SolveDetailed(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "AECEFG", result.Text)
+
+	solver, err = NewSolver(WithRecognizer(fixedRecognizer("E")), WithDisambiguation(rules...))
+	assert.NoError(t, err)
+	result, err = solver.SolveDetailed(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", result.Text)
+	assert.Equal(t, 0.5, result.LetterConfidence[1])
+
+	_, err = NewSolver(WithDisambiguation(DisambiguationRule{Letters: [2]string{"B", "E"}}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,267 @@
+package amazoncaptcha
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"sync"
+)
+
+// CorpusStats describes the distribution of a set of captcha letters, either the letters of recently solved
+// captchas or the training corpus. Letters are counted as segmented, before any normalization.
+type CorpusStats struct {
+	// Images is the number of captchas, 0 for the training corpus, which holds letters only.
+	Images int
+	// Letters is the number of letters.
+	Letters int
+	// InkDensity is the mean share of black pixels of the letters, between 0 and 1.
+	InkDensity float64
+	// LetterWidth is the mean width of the letters in pixels.
+	LetterWidth float64
+	// LetterWidthStdDev is the standard deviation of the width of the letters in pixels.
+	LetterWidthStdDev float64
+	// Segments counts the captchas by the number of segments found in them, nil for the training corpus.
+	// Captchas are segmented into 6 letters, or 7 if the last letter wraps around.
+	Segments map[int]int
+}
+
+// DriftThresholds are the limits beyond which the recent captchas are taken to have drifted from the training corpus.
+type DriftThresholds struct {
+	// MinImages is the number of recent captchas needed before drift is reported.
+	MinImages int
+	// InkDensity is the largest relative change of the ink density, e.g. 0.25 for 25%.
+	InkDensity float64
+	// LetterWidth is the largest change of the mean letter width, in standard deviations of the training corpus.
+	LetterWidth float64
+	// SegmentationFailures is the largest share of recent captchas that could not be segmented, between 0 and 1.
+	SegmentationFailures float64
+}
+
+// DefaultDriftThresholds are the thresholds used by the drift monitor unless configured otherwise.
+var DefaultDriftThresholds = DriftThresholds{
+	MinImages:            100,
+	InkDensity:           0.25,
+	LetterWidth:          1,
+	SegmentationFailures: 0.1,
+}
+
+// DriftReport compares the letters of the recently solved captchas with the training corpus.
+type DriftReport struct {
+	// Recent describes the letters of the captchas in the window of the drift monitor.
+	Recent CorpusStats
+	// Training describes the letters of the training corpus.
+	Training CorpusStats
+	// InkDensityShift is the relative change of the ink density of the recent letters, e.g. 0.3 for 30% more ink.
+	InkDensityShift float64
+	// LetterWidthShift is the change of the mean width of the recent letters, in standard deviations of the training corpus.
+	LetterWidthShift float64
+	// SegmentationFailures is the share of recent captchas that could not be segmented, between 0 and 1.
+	SegmentationFailures float64
+	// Drifted reports whether any of the changes exceeds its threshold, given enough recent captchas.
+	Drifted bool
+	// Reasons describes the changes exceeding their thresholds.
+	Reasons []string
+}
+
+// driftSample holds the statistics of a single solved captcha, or the sums of those of several captchas.
+type driftSample struct {
+	images       int
+	failures     int
+	letters      int
+	ink          float64
+	width        float64
+	widthSquares float64
+}
+
+// add adds the statistics of other to the sums, or subtracts them for a sign of -1.
+func (d *driftSample) add(other driftSample, sign int) {
+	d.images += sign * other.images
+	d.failures += sign * other.failures
+	d.letters += sign * other.letters
+	d.ink += float64(sign) * other.ink
+	d.width += float64(sign) * other.width
+	d.widthSquares += float64(sign) * other.widthSquares
+}
+
+// driftMonitor keeps the statistics of the captchas solved most recently in a ring, together with
+// their running sums, so that every solve updates the statistics in constant time.
+type driftMonitor struct {
+	mu         sync.Mutex
+	thresholds DriftThresholds
+	alarm      func(*DriftReport)
+	alarmed    bool
+
+	samples  []driftSample
+	segments []int
+	next     int
+	sums     driftSample
+	counts   map[int]int
+}
+
+// SetDriftMonitor enables collecting the statistics of the window most recently solved captchas, so that
+// CheckDrift can compare them with the training corpus. When a solve makes the recent captchas drift beyond
+// thresholds, the report is passed to alarm, if not nil, e.g. to raise an alert before the accuracy collapses
+// because Amazon changed their renderer. The alarm is raised again only after the drift has receded.
+// Passing a window of 0 disables the monitor, which is the default.
+func SetDriftMonitor(window int, thresholds DriftThresholds, alarm func(*DriftReport)) error {
+	return defaultSolver().SetDriftMonitor(window, thresholds, alarm)
+}
+
+// SetDriftMonitor enables the drift monitor of the Solver, see the package-level SetDriftMonitor.
+func (s *Solver) SetDriftMonitor(window int, thresholds DriftThresholds, alarm func(*DriftReport)) error {
+	if window < 0 {
+		return errors.New("drift window must not be negative")
+	}
+	d := &s.drift
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.thresholds = thresholds
+	d.alarm = alarm
+	d.alarmed = false
+	d.samples = make([]driftSample, window)
+	d.segments = make([]int, window)
+	d.next = 0
+	d.sums = driftSample{}
+	d.counts = make(map[int]int)
+	return nil
+}
+
+// CheckDrift compares the statistics of the recently solved captchas with those of the training corpus,
+// using the thresholds of the drift monitor. Without the monitor enabled, no captchas are recent.
+func CheckDrift() *DriftReport {
+	return defaultSolver().CheckDrift()
+}
+
+// CheckDrift works like the package-level CheckDrift, for the solves and the training data of the Solver.
+func (s *Solver) CheckDrift() *DriftReport {
+	training := s.trainingData().corpusStats()
+	s.drift.mu.Lock()
+	defer s.drift.mu.Unlock()
+	return s.drift.report(training)
+}
+
+// observeDrift records the segments and letters found in a solved captcha if the drift monitor is enabled,
+// and raises the alarm if the captcha makes the recent captchas drift. Letters is nil if the captcha could
+// not be segmented.
+func (s *Solver) observeDrift(segments int, letters []*image.Gray) {
+	d := &s.drift
+	d.mu.Lock()
+	if len(d.samples) == 0 {
+		d.mu.Unlock()
+		return
+	}
+
+	// Compute the statistics of the letters
+	sample := driftSample{images: 1, letters: len(letters)}
+	if letters == nil {
+		sample.failures = 1
+	}
+	for _, letter := range letters {
+		width := float64(letter.Bounds().Dx())
+		sample.ink += float64(countInk(letter)) / float64(len(letter.Pix))
+		sample.width += width
+		sample.widthSquares += width * width
+	}
+
+	// Replace the oldest sample of the ring
+	d.sums.add(d.samples[d.next], -1)
+	if d.samples[d.next].images > 0 {
+		d.counts[d.segments[d.next]]--
+		if d.counts[d.segments[d.next]] == 0 {
+			delete(d.counts, d.segments[d.next])
+		}
+	}
+	d.samples[d.next], d.segments[d.next] = sample, segments
+	d.next = (d.next + 1) % len(d.samples)
+	d.sums.add(sample, 1)
+	d.counts[segments]++
+
+	// Raise the alarm once when the recent captchas start drifting
+	var report *DriftReport
+	alarm := d.alarm
+	if alarm != nil {
+		report = d.report(s.trainingData().corpusStats())
+		raise := report.Drifted && !d.alarmed
+		d.alarmed = report.Drifted
+		if !raise {
+			report = nil
+		}
+	}
+	d.mu.Unlock()
+
+	if report != nil {
+		alarm(report)
+	}
+}
+
+// report compares the recent captchas with the training corpus. The monitor must be locked.
+func (d *driftMonitor) report(training CorpusStats) *DriftReport {
+	recent := d.sums.stats()
+	recent.Segments = make(map[int]int, len(d.counts))
+	for k, v := range d.counts {
+		recent.Segments[k] = v
+	}
+	report := &DriftReport{Recent: recent, Training: training}
+	if recent.Images == 0 {
+		return report
+	}
+
+	report.SegmentationFailures = float64(d.sums.failures) / float64(recent.Images)
+	if recent.Letters > 0 && training.Letters > 0 {
+		if training.InkDensity > 0 {
+			report.InkDensityShift = (recent.InkDensity - training.InkDensity) / training.InkDensity
+		}
+		if training.LetterWidthStdDev > 0 {
+			report.LetterWidthShift = (recent.LetterWidth - training.LetterWidth) / training.LetterWidthStdDev
+		}
+	}
+
+	// Only report drift once enough captchas were solved for the statistics to be meaningful
+	if recent.Images < d.thresholds.MinImages {
+		return report
+	}
+	if math.Abs(report.InkDensityShift) > d.thresholds.InkDensity {
+		report.Reasons = append(report.Reasons, fmt.Sprintf("ink density changed by %+.0f%%", 100*report.InkDensityShift))
+	}
+	if math.Abs(report.LetterWidthShift) > d.thresholds.LetterWidth {
+		report.Reasons = append(report.Reasons, fmt.Sprintf("mean letter width changed by %+.1f standard deviations", report.LetterWidthShift))
+	}
+	if report.SegmentationFailures > d.thresholds.SegmentationFailures {
+		report.Reasons = append(report.Reasons, fmt.Sprintf("%.0f%% of captchas could not be segmented", 100*report.SegmentationFailures))
+	}
+	report.Drifted = len(report.Reasons) > 0
+	return report
+}
+
+// stats turns the sums of the statistics of letters into their distribution.
+func (d *driftSample) stats() CorpusStats {
+	stats := CorpusStats{Images: d.images, Letters: d.letters}
+	if d.letters == 0 {
+		return stats
+	}
+	n := float64(d.letters)
+	stats.InkDensity = d.ink / n
+	stats.LetterWidth = d.width / n
+	stats.LetterWidthStdDev = math.Sqrt(math.Max(0, d.widthSquares/n-stats.LetterWidth*stats.LetterWidth))
+	return stats
+}
+
+// corpusStats returns the statistics of the letters of the training data, computing them on first use.
+func (m *model) corpusStats() CorpusStats {
+	m.statsOnce.Do(func() {
+		var sums driftSample
+		for _, entry := range m.index() {
+			if entry.bitmap.width == 0 {
+				continue
+			}
+			width := float64(entry.bitmap.width)
+			sums.letters++
+			sums.ink += float64(entry.bitmap.ink) / float64(entry.bitmap.width*entry.bitmap.height)
+			sums.width += width
+			sums.widthSquares += width * width
+		}
+		m.stats = sums.stats()
+	})
+	return m.stats
+}
@@ -0,0 +1,67 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSolverDriftMonitor(t *testing.T) {
+	solver, err := NewSolver()
+	assert.NoError(t, err)
+
+	// Without the monitor, no captchas are recent
+	_, _ = solver.Solve(bytes.NewReader(syntheticCaptcha(t, "ABCEFG")))
+	report := solver.CheckDrift()
+	assert.Equal(t, 0, report.Recent.Images)
+	assert.False(t, report.Drifted)
+	assert.Greater(t, report.Training.Letters, 9000)
+	assert.Greater(t, report.Training.LetterWidth, 0.0)
+
+	var alarms []*DriftReport
+	thresholds := DefaultDriftThresholds
+	thresholds.MinImages = 4
+	assert.NoError(t, solver.SetDriftMonitor(4, thresholds, func(report *DriftReport) {
+		alarms = append(alarms, report)
+	}))
+
+	// Captchas rendered from the training data do not drift
+	for _, answer := range []string{"ABCEFG", "HJKLMN", "PRTUXY", "ABCEFG"} {
+		_, err := solver.Solve(bytes.NewReader(syntheticCaptcha(t, answer)))
+		assert.NoError(t, err)
+	}
+	report = solver.CheckDrift()
+	assert.Equal(t, 4, report.Recent.Images)
+	assert.Equal(t, 24, report.Recent.Letters)
+	assert.Equal(t, map[int]int{6: 4}, report.Recent.Segments)
+	assert.Less(t, report.InkDensityShift, thresholds.InkDensity)
+	assert.Greater(t, report.InkDensityShift, -thresholds.InkDensity)
+	assert.False(t, report.Drifted)
+	assert.Empty(t, alarms)
+
+	// Captchas that cannot be segmented raise the alarm once
+	blank := image.NewGray(image.Rect(0, 0, 200, CaptchaHeight))
+	for i := range blank.Pix {
+		blank.Pix[i] = 255
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, blank))
+	for i := 0; i < 3; i++ {
+		_, err := solver.Solve(bytes.NewReader(buf.Bytes()))
+		assert.ErrorIs(t, err, ErrSegmentationFailed)
+	}
+	report = solver.CheckDrift()
+	assert.Equal(t, 4, report.Recent.Images)
+	assert.Equal(t, map[int]int{0: 3, 6: 1}, report.Recent.Segments)
+	assert.InDelta(t, 0.75, report.SegmentationFailures, 1e-9)
+	assert.True(t, report.Drifted)
+	assert.Len(t, report.Reasons, 1)
+	if assert.Len(t, alarms, 1) {
+		assert.True(t, alarms[0].Drifted)
+	}
+
+	assert.Error(t, solver.SetDriftMonitor(-1, thresholds, nil))
+}
@@ -0,0 +1,126 @@
+package amazoncaptcha
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DuplicateAnswerError describes an answer that was produced for two captchas with different letters within
+// the window of the duplicate-answer guard. Real captchas practically never repeat an answer in a short time,
+// so this is a symptom of a broken model or segmentation, e.g. every captcha being solved to the same text.
+type DuplicateAnswerError struct {
+	// Answer is the repeated answer.
+	Answer string
+	// ImageHash identifies the captcha that repeated the answer, see ImageHash.
+	ImageHash string
+	// PreviousImageHash identifies the earlier captcha with the same answer.
+	PreviousImageHash string
+	// Elapsed is the time between the two solves.
+	Elapsed time.Duration
+}
+
+// Error implements the error interface.
+func (e *DuplicateAnswerError) Error() string {
+	return fmt.Sprintf("answer %q repeated for different captchas %s and %s within %v",
+		e.Answer, e.PreviousImageHash, e.ImageHash, e.Elapsed)
+}
+
+// duplicateGuard remembers the answers solved within a time window, to flag answers repeated
+// for captchas with different letters.
+type duplicateGuard struct {
+	mu     sync.Mutex
+	window time.Duration
+	hook   func(*DuplicateAnswerError)
+	recent map[string]answeredCaptcha
+	order  []answeredCaptcha
+}
+
+// answeredCaptcha is a captcha remembered by the duplicate-answer guard.
+type answeredCaptcha struct {
+	answer      string
+	hash        string
+	fingerprint [sha256.Size]byte
+	time        time.Time
+}
+
+// SetDuplicateGuard enables flagging answers that are repeated within window for captchas whose letters differ.
+// Every flagged answer is counted in Stats.Duplicates and passed to hook, if not nil, e.g. to log a warning or
+// raise an alert. Captchas whose letters have the same pixels, such as the same captcha submitted twice or
+// re-encoded, are not flagged. Passing a window of 0 disables the guard, which is the default.
+func SetDuplicateGuard(window time.Duration, hook func(*DuplicateAnswerError)) {
+	defaultSolver().SetDuplicateGuard(window, hook)
+}
+
+// SetDuplicateGuard enables the duplicate-answer guard of the Solver, see the package-level SetDuplicateGuard.
+func (s *Solver) SetDuplicateGuard(window time.Duration, hook func(*DuplicateAnswerError)) {
+	g := &s.duplicates
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.window = window
+	g.hook = hook
+	g.recent = nil
+	g.order = nil
+}
+
+// checkDuplicate flags the answer of a solve if it repeats the answer of a captcha with different
+// letters solved within the window of the guard.
+func (s *Solver) checkDuplicate(hash string, result *Result) {
+	if result.Text == "" {
+		return
+	}
+	duplicate, hook := s.duplicates.check(answeredCaptcha{
+		answer:      result.Text,
+		hash:        hash,
+		fingerprint: result.fingerprint,
+	})
+	if duplicate == nil {
+		return
+	}
+	s.outcomes.countDuplicate()
+	if hook != nil {
+		hook(duplicate)
+	}
+}
+
+// check remembers a captcha solved now and returns the duplicate it forms with an earlier one, if any,
+// together with the hook to call outside of the lock.
+func (g *duplicateGuard) check(captcha answeredCaptcha) (*DuplicateAnswerError, func(*DuplicateAnswerError)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.window <= 0 {
+		return nil, nil
+	}
+
+	// Take the time under the lock, so that the remembered captchas are ordered by time
+	captcha.time = time.Now()
+
+	// Forget the captchas solved before the window, oldest first
+	expired := 0
+	for expired < len(g.order) && captcha.time.Sub(g.order[expired].time) > g.window {
+		oldest := g.order[expired]
+		if previous := g.recent[oldest.answer]; previous.hash == oldest.hash && previous.time.Equal(oldest.time) {
+			delete(g.recent, oldest.answer)
+		}
+		expired++
+	}
+	g.order = g.order[expired:]
+	if g.recent == nil {
+		g.recent = make(map[string]answeredCaptcha)
+	}
+
+	var duplicate *DuplicateAnswerError
+	if previous, ok := g.recent[captcha.answer]; ok && previous.fingerprint != captcha.fingerprint {
+		duplicate = &DuplicateAnswerError{
+			Answer:            captcha.answer,
+			ImageHash:         captcha.hash,
+			PreviousImageHash: previous.hash,
+			Elapsed:           captcha.time.Sub(previous.time),
+		}
+	}
+	g.recent[captcha.answer] = captcha
+	g.order = append(g.order, captcha)
+
+	return duplicate, g.hook
+}
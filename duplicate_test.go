@@ -0,0 +1,56 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuplicateGuard(t *testing.T) {
+	var flagged []*DuplicateAnswerError
+	s, err := NewSolver(WithDuplicateGuard(time.Minute, func(e *DuplicateAnswerError) {
+		flagged = append(flagged, e)
+	}))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The same captcha solved twice, even re-encoded, is not flagged
+	captcha := flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)
+	for i := 0; i < 2; i++ {
+		_, err := s.SolveDetailed(bytes.NewReader(captcha))
+		assert.NoError(t, err)
+	}
+	_, err = s.SolveDetailed(bytes.NewReader(flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)))
+	assert.NoError(t, err)
+	assert.Empty(t, flagged)
+
+	// A captcha with different letters solved to the same answer is flagged
+	other := flipPixel(t, captcha, 2)
+	result, err := s.SolveDetailed(bytes.NewReader(other))
+	assert.NoError(t, err)
+	assert.Equal(t, "AB-EFG", result.Text)
+	if assert.Len(t, flagged, 1) {
+		assert.Equal(t, "AB-EFG", flagged[0].Answer)
+		assert.Equal(t, ImageHash(other), flagged[0].ImageHash)
+		assert.Equal(t, ImageHash(captcha), flagged[0].PreviousImageHash)
+		assert.Contains(t, flagged[0].Error(), "AB-EFG")
+	}
+	assert.Equal(t, uint64(1), s.Stats().Duplicates)
+
+	// Answers outside of the window are forgotten
+	s.SetDuplicateGuard(time.Nanosecond, nil)
+	_, err = s.SolveDetailed(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	time.Sleep(time.Millisecond)
+	_, err = s.SolveDetailed(bytes.NewReader(other))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), s.Stats().Duplicates)
+	assert.Len(t, s.duplicates.order, 1)
+
+	// The window of the option must be positive
+	_, err = NewSolver(WithDuplicateGuard(0, nil))
+	assert.Error(t, err)
+}
@@ -0,0 +1,177 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"time"
+)
+
+// EnsembleMember is one way of recognizing the letters of a captcha taking part in the vote of an EnsembleSolver.
+type EnsembleMember struct {
+	// Threshold binarizes the captcha, the mono threshold of the Solver, or its adaptive threshold, if 0.
+	Threshold uint8
+	// CutTheWhite crops the white border of every letter with CutTheWhite before recognizing it. Cropped letters
+	// are rarely found in the training data, so it is meant to be combined with a Recognizer.
+	CutTheWhite bool
+	// Recognizer recognizes the letters the training data does not know, in place of the recognizer of the Solver,
+	// if not nil.
+	Recognizer Recognizer
+	// Weight scales the votes of the member, 1 if 0.
+	Weight float64
+}
+
+// EnsembleSolver solves captchas by recognizing them with several members, e.g. at different thresholds or with
+// different recognizers, and letting the members vote on every letter position. Borderline captchas that a
+// single configuration gets partly wrong are often recognized by the majority, without retry loops on the
+// side of the caller. An EnsembleSolver is safe for concurrent use by multiple goroutines.
+type EnsembleSolver struct {
+	solver  *Solver
+	members []EnsembleMember
+}
+
+// NewEnsembleSolver creates an EnsembleSolver voting with members, or with DefaultEnsemble if there are none,
+// using the default configuration and training data of the package.
+func NewEnsembleSolver(members ...EnsembleMember) (*EnsembleSolver, error) {
+	return defaultSolver().NewEnsembleSolver(members...)
+}
+
+// NewEnsembleSolver works like the package-level NewEnsembleSolver, using the configuration and training data
+// of the Solver, whose hooks, statistics and journal also observe the solves of the ensemble.
+func (s *Solver) NewEnsembleSolver(members ...EnsembleMember) (*EnsembleSolver, error) {
+	if len(members) == 0 {
+		members = s.DefaultEnsemble()
+	}
+	for _, member := range members {
+		if member.Weight < 0 {
+			return nil, errors.New("ensemble member weight must not be negative")
+		}
+	}
+	return &EnsembleSolver{solver: s, members: members}, nil
+}
+
+// DefaultEnsemble returns the members of an EnsembleSolver created without members: the mono threshold of the
+// package, two other thresholds, and a TemplateMatcher built from the training data, once on whole letters and
+// once on letters cropped with CutTheWhite.
+func DefaultEnsemble() []EnsembleMember {
+	return defaultSolver().DefaultEnsemble()
+}
+
+// DefaultEnsemble works like the package-level DefaultEnsemble, using the configuration and training data of
+// the Solver.
+func (s *Solver) DefaultEnsemble() []EnsembleMember {
+	matcher := NewTemplateMatcher(s.Templates(3))
+	return []EnsembleMember{
+		{},
+		{Threshold: 32},
+		{Threshold: 96},
+		{Recognizer: matcher},
+		{CutTheWhite: true, Recognizer: matcher},
+	}
+}
+
+// Solve solves a captcha with every member and returns the majority answer. For every position, each member
+// votes for the letter it recognized with its weight times its confidence, and the letter with the most votes
+// wins; ties go to the alphabetically first letter. The confidence of a letter is the share of the votes for
+// it among the members that recognized the position at all, so members that cannot tell lower no confidence.
+// The Strategy of the result is StrategyEnsemble.
+//
+// If no member can segment the letters, a *SegmentationError is returned.
+func (e *EnsembleSolver) Solve(r io.Reader) (*Result, error) {
+
+	// Read the whole input so that it can be hashed for the journal and outcome reports
+	b, err := e.solver.readImage(r)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	result, err := e.solve(b)
+	e.solver.finishSolve(b, start, result, err)
+
+	return result, err
+}
+
+// solve implements Solve.
+func (e *EnsembleSolver) solve(b []byte) (*Result, error) {
+	if err := e.solver.checkTrainingData(); err != nil {
+		return nil, err
+	}
+
+	// Decode the input image and convert it to grayscale once for all members
+	img, err := e.solver.decodeImage(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	grayImg := Grayscale(img)
+	m := e.solver.recognitionModel()
+
+	// Recognize the letters with every member, each one configured as a copy of the Solver
+	var segmentErr error
+	var attempts [][]letterMatch
+	var weights []float64
+	base := e.solver.threshold(grayImg)
+	for _, member := range e.members {
+		s := e.solver.detached()
+		threshold := base
+		if member.Threshold != 0 {
+			threshold = member.Threshold
+		}
+		if member.Recognizer != nil {
+			s.recognizer = member.Recognizer
+		}
+		s.cutTheWhite = member.CutTheWhite
+
+		mono := s.binarize(grayImg, threshold, nil)
+		_, matches, err := s.recognizeLetters(m, mono, s.findLetterBoxes(mono), nil)
+		if err != nil {
+			if errors.Is(err, ErrSegmentationFailed) {
+				if segmentErr == nil {
+					segmentErr = err
+				}
+				continue
+			}
+			return nil, err
+		}
+		weight := member.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		attempts = append(attempts, matches)
+		weights = append(weights, weight)
+	}
+	if len(attempts) == 0 {
+		return nil, segmentErr
+	}
+
+	matches := weightedVote(attempts, weights)
+	result := e.solver.newResult(StrategyEnsemble, matches)
+	e.solver.addCandidates(m, result, matches)
+	return result, nil
+}
+
+// weightedVote combines the attempts of several members into one by summing, for every position, the weight
+// times the confidence of every candidate letter. The confidence of the winning letter is its share of the
+// weights of the members that recognized the position.
+func weightedVote(attempts [][]letterMatch, weights []float64) []letterMatch {
+	combined := make([]letterMatch, len(attempts[0]))
+	for i := range combined {
+		combined[i].feature = attempts[0][i].feature
+		scores := make(map[string]float64)
+		total := 0.0
+		for j, matches := range attempts {
+			if matches[i].letter != "" {
+				scores[matches[i].letter] += weights[j] * matches[i].confidence
+				total += weights[j]
+			}
+		}
+		for letter, score := range scores {
+			if score > scores[combined[i].letter] || (score == scores[combined[i].letter] && letter < combined[i].letter) {
+				combined[i].letter = letter
+			}
+		}
+		if total > 0 {
+			combined[i].confidence = scores[combined[i].letter] / total
+		}
+	}
+	return combined
+}
@@ -0,0 +1,69 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnsembleSolver(t *testing.T) {
+	e, err := NewEnsembleSolver()
+	assert.NoError(t, err)
+	result, err := e.Solve(bytes.NewReader(syntheticCaptcha(t, "ABCEFG")))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", result.Text)
+	assert.True(t, result.Solved)
+	assert.Equal(t, StrategyEnsemble, result.Strategy)
+
+	// Members that cannot tell a letter do not lower its confidence
+	e, err = NewEnsembleSolver(EnsembleMember{}, EnsembleMember{CutTheWhite: true})
+	assert.NoError(t, err)
+	result, err = e.Solve(bytes.NewReader(syntheticCaptcha(t, "ABCEFG")))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", result.Text)
+	assert.Equal(t, 1.0, result.Confidence)
+
+	_, err = e.Solve(bytes.NewReader([]byte("captcha")))
+	assert.Error(t, err)
+	_, err = NewEnsembleSolver(EnsembleMember{Weight: -1})
+	assert.Error(t, err)
+}
+
+func TestEnsembleSolverVote(t *testing.T) {
+	captcha := flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)
+
+	// The majority wins the letter unknown to the training data, the known letters are agreed on
+	e, err := NewEnsembleSolver(
+		EnsembleMember{Recognizer: fixedRecognizer("X")},
+		EnsembleMember{Recognizer: fixedRecognizer("C")},
+		EnsembleMember{Recognizer: fixedRecognizer("C")},
+	)
+	assert.NoError(t, err)
+	result, err := e.Solve(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", result.Text)
+	assert.InDelta(t, 1.0/3, result.LetterConfidence[2], 1e-9)
+	assert.Equal(t, 1.0, result.LetterConfidence[0])
+
+	// Weights outvote the majority
+	e, err = NewEnsembleSolver(
+		EnsembleMember{Recognizer: fixedRecognizer("X"), Weight: 3},
+		EnsembleMember{Recognizer: fixedRecognizer("C")},
+		EnsembleMember{Recognizer: fixedRecognizer("C")},
+	)
+	assert.NoError(t, err)
+	result, err = e.Solve(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABXEFG", result.Text)
+	assert.InDelta(t, 0.3, result.LetterConfidence[2], 1e-9)
+
+	// Positions no member recognizes stay unknown
+	e, err = NewEnsembleSolver(EnsembleMember{}, EnsembleMember{Threshold: 32})
+	assert.NoError(t, err)
+	result, err = e.Solve(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "AB-EFG", result.Text)
+	assert.False(t, result.Solved)
+	assert.Equal(t, []int{2}, result.UnknownPositions())
+}
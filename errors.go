@@ -0,0 +1,91 @@
+package amazoncaptcha
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrSegmentationFailed is matched by the error returned when the letters of a captcha could not be
+// segmented, e.g. because the image is not a captcha or too noisy.
+var ErrSegmentationFailed = errors.New("letters could not be segmented")
+
+// ErrUnrecognizedLetter is matched by the error returned when some letters of a captcha were segmented
+// but could not be recognized.
+var ErrUnrecognizedLetter = errors.New("letters could not be recognized")
+
+// ErrNoTrainingData is returned by the solves of a Solver without training data, e.g. of a build with the
+// noembed tag before training data is loaded, or after loading it from a URL failed. Such a Solver can still
+// locate and segment letters, but recognizes none of them.
+var ErrNoTrainingData = errors.New("no training data loaded")
+
+// ErrAnswerRejected is matched by the error returned when the answer filter of a Solver rejected the answer
+// of a captcha, see WithAnswerFilter.
+var ErrAnswerRejected = errors.New("answer rejected by filter")
+
+// ErrChallengeExpired is matched by the error returned when the answers to captchas were not accepted because
+// their form tokens expired, Amazon serving its captcha page again instead of the requested page.
+var ErrChallengeExpired = errors.New("captcha challenge expired")
+
+// ErrChallengeRejected is matched by the error returned when the answer to a captcha was rejected as wrong,
+// Amazon serving its captcha page again with an error alert. Unlike an expired challenge, which calls for
+// fetching captchas sooner, a rejected one means the captcha was solved incorrectly.
+var ErrChallengeRejected = errors.New("captcha answer rejected")
+
+// SegmentationError describes a segmentation that did not yield the letters of a captcha,
+// so that e.g. a few merged segments can be told apart from many noisy ones.
+type SegmentationError struct {
+	// Segments is the number of segments found in the captcha.
+	Segments int
+	// Widths holds the width of every segment, in order.
+	Widths []int
+}
+
+// Error implements the error interface.
+func (e *SegmentationError) Error() string {
+	return fmt.Sprintf("%v: found %d segments with widths %v", ErrSegmentationFailed, e.Segments, e.Widths)
+}
+
+// Unwrap returns ErrSegmentationFailed so that errors.Is can be used to detect the failure.
+func (e *SegmentationError) Unwrap() error {
+	return ErrSegmentationFailed
+}
+
+// UnrecognizedLetterError lists the letters of a captcha that could not be recognized.
+type UnrecognizedLetterError struct {
+	// Positions holds the positions of the unrecognized letters, in ascending order.
+	Positions []int
+}
+
+// Error implements the error interface.
+func (e *UnrecognizedLetterError) Error() string {
+	return fmt.Sprintf("%v: unknown letters at positions %v", ErrUnrecognizedLetter, e.Positions)
+}
+
+// Unwrap returns ErrUnrecognizedLetter so that errors.Is can be used to detect the failure.
+func (e *UnrecognizedLetterError) Unwrap() error {
+	return ErrUnrecognizedLetter
+}
+
+// AnswerRejectedError describes an answer that was rejected by the answer filter of a Solver.
+type AnswerRejectedError struct {
+	// Answer is the rejected answer.
+	Answer string
+	// Err is the error returned by the filter.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *AnswerRejectedError) Error() string {
+	return fmt.Sprintf("%v: answer %s: %v", ErrAnswerRejected, e.Answer, e.Err)
+}
+
+// Unwrap returns the error of the filter.
+func (e *AnswerRejectedError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrAnswerRejected, so that errors.Is can be used to detect the failure
+// besides the error of the filter.
+func (e *AnswerRejectedError) Is(target error) bool {
+	return target == ErrAnswerRejected
+}
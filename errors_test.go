@@ -0,0 +1,43 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSegmentationError(t *testing.T) {
+	// Four letters are not a valid captcha
+	captcha := syntheticCaptcha(t, "ABCE")
+
+	letters, err := FindLetters(bytes.NewReader(captcha))
+	assert.Empty(t, letters)
+	assert.True(t, errors.Is(err, ErrSegmentationFailed))
+
+	var segmentErr *SegmentationError
+	if assert.True(t, errors.As(err, &segmentErr)) {
+		assert.Equal(t, 4, segmentErr.Segments)
+		assert.Len(t, segmentErr.Widths, 4)
+	}
+
+	result, err := Solve(bytes.NewReader(captcha))
+	assert.Empty(t, result)
+	assert.True(t, errors.Is(err, ErrSegmentationFailed))
+	assert.False(t, errors.Is(err, ErrUnrecognizedLetter))
+}
+
+func TestUnrecognizedLetterError(t *testing.T) {
+	captcha := flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)
+
+	result, err := Solve(bytes.NewReader(captcha))
+	assert.Equal(t, "AB-EFG", result)
+	assert.True(t, errors.Is(err, ErrUnrecognizedLetter))
+	assert.False(t, errors.Is(err, ErrSegmentationFailed))
+
+	var unrecognized *UnrecognizedLetterError
+	if assert.True(t, errors.As(err, &unrecognized)) {
+		assert.Equal(t, []int{2}, unrecognized.Positions)
+	}
+}
@@ -0,0 +1,86 @@
+package eval
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// TrainingData maps the features of letters to the letters, like training_data.json or the Features of a
+// train.Dataset.
+type TrainingData map[string]string
+
+// Diff compares how the captchas of a directory were solved with two versions of the training data.
+type Diff struct {
+	// A and B report the accuracy with the first and the second training data.
+	A, B *Report
+	// Fixed holds the captchas solved to their known answers with B, but not with A.
+	Fixed []Change
+	// Broken holds the captchas solved to their known answers with A, but not with B.
+	Broken []Change
+}
+
+// Change describes a captcha solved differently with two versions of the training data.
+type Change struct {
+	// Name is the file name of the captcha.
+	Name string `json:"name"`
+	// Want is the known answer of the captcha.
+	Want string `json:"want"`
+	// A and B are the answers with the first and the second training data, empty if the captcha could not be
+	// decoded or segmented.
+	A string `json:"a"`
+	B string `json:"b"`
+}
+
+// Compare solves the captchas in dir, named after their answers like ABCDEF.jpg, once with the training data a
+// and once with b, and reports the captchas that flipped from solved to unsolved and vice versa, e.g. to
+// validate an update of the embedded training data before shipping it. Both solvers use the default options.
+// An error is returned if the directory cannot be read or contains no labeled captchas, or if the training data
+// is invalid.
+func Compare(dir string, a, b TrainingData) (*Diff, error) {
+	captchas, err := amazoncaptcha.ReadLabeledCaptchas(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(captchas) == 0 {
+		return nil, fmt.Errorf("no labeled captchas in %s", dir)
+	}
+	solverA, err := newSolver(a)
+	if err != nil {
+		return nil, err
+	}
+	solverB, err := newSolver(b)
+	if err != nil {
+		return nil, err
+	}
+
+	// Solve the captchas with A first, then compare the answers with B in the same order
+	answers := make([]string, 0, len(captchas))
+	d := &Diff{}
+	d.A = evaluate(captchas, solverA, func(_ amazoncaptcha.LabeledCaptcha, answer string) {
+		answers = append(answers, answer)
+	})
+	i := 0
+	d.B = evaluate(captchas, solverB, func(captcha amazoncaptcha.LabeledCaptcha, answer string) {
+		change := Change{Name: captcha.Name, Want: captcha.Answer, A: answers[i], B: answer}
+		i++
+		switch {
+		case change.A != change.Want && change.B == change.Want:
+			d.Fixed = append(d.Fixed, change)
+		case change.A == change.Want && change.B != change.Want:
+			d.Broken = append(d.Broken, change)
+		}
+	})
+	return d, nil
+}
+
+// newSolver creates a solver with the default options and the training data.
+func newSolver(data TrainingData) (*amazoncaptcha.Solver, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal training data: %w", err)
+	}
+	return amazoncaptcha.NewSolver(amazoncaptcha.WithTrainingData(bytes.NewReader(b)))
+}
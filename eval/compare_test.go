@@ -0,0 +1,32 @@
+package eval
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	dir := writeCorpus(t)
+	withoutK, withoutE := trainingDataWithout(t, "K"), trainingDataWithout(t, "E")
+
+	// Dropping E breaks ABCEFG, adding K back fixes HJKLMN
+	d, err := Compare(dir, withoutK, withoutE)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []Change{{Name: "HJKLMN.png", Want: "HJKLMN", A: "HJ-LMN", B: "HJKLMN"}}, d.Fixed)
+	assert.Equal(t, []Change{{Name: "ABCEFG.png", Want: "ABCEFG", A: "ABCEFG", B: "ABC-FG"}}, d.Broken)
+	assert.Equal(t, 1, d.A.Correct)
+	assert.Equal(t, 1, d.B.Correct)
+
+	// The same training data changes nothing
+	d, err = Compare(dir, withoutK, withoutK)
+	assert.NoError(t, err)
+	assert.Empty(t, d.Fixed)
+	assert.Empty(t, d.Broken)
+
+	_, err = Compare(filepath.Join(dir, "missing"), withoutK, withoutE)
+	assert.Error(t, err)
+}
@@ -0,0 +1,199 @@
+// Package eval measures the accuracy of a solver on a directory of captchas labeled with their answers, per
+// captcha and per letter, with a confusion matrix of the letters, and exports the report as JSON or CSV, e.g.
+// to compare versions of the training data:
+//
+//	report, err := eval.Run("captchas", solver)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fmt.Printf("accuracy: %.2f%%\n", 100*report.Accuracy)
+//	_ = report.WriteCSV(os.Stdout)
+package eval
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// Letters is the number of letters captchas are made of, A to Z.
+const Letters = 26
+
+// Report describes how the captchas of a directory were solved.
+type Report struct {
+	// Captchas is the number of captchas evaluated.
+	Captchas int `json:"captchas"`
+	// Correct is the number of captchas solved to their known answers.
+	Correct int `json:"correct"`
+	// Unsolved is the number of captchas with letters that could not be recognized.
+	Unsolved int `json:"unsolved"`
+	// Wrong is the number of captchas solved completely, but to other answers.
+	Wrong int `json:"wrong"`
+	// Failed is the number of captchas that could not be decoded or segmented.
+	Failed int `json:"failed"`
+	// Accuracy is the share of captchas solved to their known answers, between 0 and 1.
+	Accuracy float64 `json:"accuracy"`
+	// Letters reports the letters from A to Z of the captchas that were segmented into as many letters as
+	// their answers have.
+	Letters [Letters]LetterReport `json:"letters"`
+	// Confusion counts how the letters were recognized: Confusion[i][j] is the number of times the i-th
+	// letter of the alphabet was recognized as the j-th one, so that the diagonal counts the correct letters.
+	// Letters that could not be recognized are counted in LetterReport.Unknown instead.
+	Confusion [Letters][Letters]int `json:"confusion"`
+	// Failures holds the captchas that were not solved to their known answers.
+	Failures []Failure `json:"failures,omitempty"`
+}
+
+// LetterReport describes how a letter was recognized.
+type LetterReport struct {
+	// Letter is the letter, from A to Z.
+	Letter string `json:"letter"`
+	// Total is the number of times the letter occurred.
+	Total int `json:"total"`
+	// Correct is the number of times the letter was recognized correctly.
+	Correct int `json:"correct"`
+	// Unknown is the number of times the letter could not be recognized.
+	Unknown int `json:"unknown"`
+	// Accuracy is the share of the occurrences recognized correctly, between 0 and 1, or 0 if the letter did
+	// not occur.
+	Accuracy float64 `json:"accuracy"`
+}
+
+// Failure describes a captcha that was not solved to its known answer.
+type Failure struct {
+	// Name is the file name of the captcha.
+	Name string `json:"name"`
+	// Want is the known answer of the captcha.
+	Want string `json:"want"`
+	// Got is the answer of the solver, empty if the captcha could not be decoded or segmented.
+	Got string `json:"got"`
+	// Error is the error of the solver, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// Run solves the captchas in dir, named after their answers like ABCDEF.jpg, with solver, or the default
+// solver if nil, and reports the accuracy. Like amazoncaptcha.Evaluate, it does not record the solves in the
+// statistics or the journal of the solver. An error is returned if the directory cannot be read or contains
+// no labeled captchas.
+func Run(dir string, solver *amazoncaptcha.Solver) (*Report, error) {
+	captchas, err := amazoncaptcha.ReadLabeledCaptchas(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(captchas) == 0 {
+		return nil, fmt.Errorf("no labeled captchas in %s", dir)
+	}
+	if solver == nil {
+		solver = amazoncaptcha.DefaultSolver()
+	}
+	return evaluate(captchas, solver, nil), nil
+}
+
+// evaluate solves the captchas with solver and reports the accuracy. Every captcha is also passed to observe,
+// if not nil, with the answer of the solver, empty if it failed.
+func evaluate(captchas []amazoncaptcha.LabeledCaptcha, solver *amazoncaptcha.Solver, observe func(captcha amazoncaptcha.LabeledCaptcha, answer string)) *Report {
+	r := &Report{}
+	e := solver.EvaluateEach(context.Background(), captchas, func(captcha amazoncaptcha.LabeledCaptcha, result *amazoncaptcha.Result, err error) {
+		if observe != nil {
+			answer := ""
+			if err == nil {
+				answer = result.Text
+			}
+			observe(captcha, answer)
+		}
+		if err != nil {
+			r.Failures = append(r.Failures, Failure{Name: captcha.Name, Want: captcha.Answer, Error: err.Error()})
+			return
+		}
+		if result.Text != captcha.Answer {
+			r.Failures = append(r.Failures, Failure{Name: captcha.Name, Want: captcha.Answer, Got: result.Text})
+		}
+		r.recordLetters(captcha.Answer, result)
+	})
+	r.Captchas, r.Correct, r.Unsolved, r.Wrong, r.Failed = e.Total, e.Correct, e.Unsolved, e.Wrong, e.Failed
+	r.Accuracy = e.Accuracy()
+
+	for i := range r.Letters {
+		letter := &r.Letters[i]
+		letter.Letter = string(rune('A' + i))
+		letter.Correct = r.Confusion[i][i]
+		if letter.Total > 0 {
+			letter.Accuracy = float64(letter.Correct) / float64(letter.Total)
+		}
+	}
+	return r
+}
+
+// recordLetters counts the letters of the answer of a captcha as they were recognized in result. Answers of
+// another length than the result cannot be compared letter by letter and are not counted.
+func (r *Report) recordLetters(answer string, result *amazoncaptcha.Result) {
+	if len(answer) != len(result.Text) {
+		return
+	}
+	unknown := make(map[int]bool)
+	for _, i := range result.UnknownPositions() {
+		unknown[i] = true
+	}
+	for i := 0; i < len(answer); i++ {
+		want, got := int(answer[i])-'A', int(result.Text[i])-'A'
+		if want < 0 || want >= Letters {
+			continue
+		}
+		r.Letters[want].Total++
+		if unknown[i] || got < 0 || got >= Letters {
+			r.Letters[want].Unknown++
+			continue
+		}
+		r.Confusion[want][got]++
+	}
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(r, "", "	")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	if _, err := w.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// WriteCSV writes the per-letter report as CSV, one row per letter from A to Z, with the columns letter, total,
+// correct, unknown and accuracy followed by the row of the confusion matrix, one column per recognized letter.
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{"letter", "total", "correct", "unknown", "accuracy"}
+	for i := 0; i < Letters; i++ {
+		header = append(header, string(rune('A'+i)))
+	}
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	for i, letter := range r.Letters {
+		row := []string{
+			letter.Letter,
+			strconv.Itoa(letter.Total),
+			strconv.Itoa(letter.Correct),
+			strconv.Itoa(letter.Unknown),
+			strconv.FormatFloat(letter.Accuracy, 'f', 4, 64),
+		}
+		for _, n := range r.Confusion[i] {
+			row = append(row, strconv.Itoa(n))
+		}
+		if err := cw.Write(row); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package eval
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCorpus writes the ABCEFG and HJKLMN captchas of the self-test corpus into a new directory, labeled
+// correctly, the ABCEFG captcha also labeled as ABCEFX, and a blank image that is not a captcha.
+func writeCorpus(t *testing.T) string {
+	captchas, err := amazoncaptcha.SelfTestCaptchas()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	images := make(map[string][]byte)
+	for _, captcha := range captchas {
+		images[captcha.Answer] = captcha.Image
+	}
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "ABCEFG.png"), images["ABCEFG"], 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "HJKLMN.png"), images["HJKLMN"], 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "ABCEFX.png"), images["ABCEFG"], 0644))
+	var blank bytes.Buffer
+	assert.NoError(t, png.Encode(&blank, image.NewGray(image.Rect(0, 0, 200, 70))))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "PRTUXY.png"), blank.Bytes(), 0644))
+	return dir
+}
+
+// trainingDataWithout returns the embedded training data without the features of letter.
+func trainingDataWithout(t *testing.T, letter string) TrainingData {
+	var buf bytes.Buffer
+	assert.NoError(t, amazoncaptcha.SaveTrainingData(&buf))
+	features := make(TrainingData)
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &features))
+	for feature, l := range features {
+		if l == letter {
+			delete(features, feature)
+		}
+	}
+	return features
+}
+
+// solverWithout creates a solver whose training data lacks letter.
+func solverWithout(t *testing.T, letter string) *amazoncaptcha.Solver {
+	solver, err := newSolver(trainingDataWithout(t, letter))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return solver
+}
+
+func TestRun(t *testing.T) {
+	dir := writeCorpus(t)
+	r, err := Run(dir, solverWithout(t, "K"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// ABCEFG is correct, HJKLMN has an unknown K, ABCEFX is wrong and PRTUXY is not a captcha
+	assert.Equal(t, 4, r.Captchas)
+	assert.Equal(t, 1, r.Correct)
+	assert.Equal(t, 1, r.Unsolved)
+	assert.Equal(t, 1, r.Wrong)
+	assert.Equal(t, 1, r.Failed)
+	assert.Equal(t, 0.25, r.Accuracy)
+	assert.Len(t, r.Failures, 3)
+
+	// Letters are counted for the segmented captchas only
+	assert.Equal(t, LetterReport{Letter: "A", Total: 2, Correct: 2, Accuracy: 1}, r.Letters[0])
+	assert.Equal(t, LetterReport{Letter: "K", Total: 1, Unknown: 1}, r.Letters['K'-'A'])
+	assert.Equal(t, LetterReport{Letter: "X", Total: 1}, r.Letters['X'-'A'])
+	assert.Equal(t, LetterReport{Letter: "G", Total: 1, Correct: 1, Accuracy: 1}, r.Letters['G'-'A'])
+	assert.Equal(t, 1, r.Confusion['X'-'A']['G'-'A'])
+	assert.Equal(t, 2, r.Confusion[0][0])
+
+	// Directories without labeled captchas fail
+	_, err = Run(t.TempDir(), nil)
+	assert.Error(t, err)
+	_, err = Run(filepath.Join(dir, "missing"), nil)
+	assert.Error(t, err)
+}
+
+func TestReportExport(t *testing.T) {
+	r, err := Run(writeCorpus(t), nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.WriteJSON(&buf))
+	var decoded Report
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, *r, decoded)
+
+	buf.Reset()
+	assert.NoError(t, r.WriteCSV(&buf))
+	rows, err := csv.NewReader(&buf).ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 1+Letters) {
+		assert.Equal(t, []string{"letter", "total", "correct", "unknown", "accuracy", "A"}, rows[0][:6])
+		assert.Len(t, rows[0], 5+Letters)
+		assert.Equal(t, []string{"A", "2", "2", "0", "1.0000", "2"}, rows[1][:6])
+		assert.Equal(t, "1", rows[1+'X'-'A'][5+'G'-'A'])
+	}
+}
@@ -0,0 +1,182 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// LabeledCaptcha is a captcha image together with its known answer.
+type LabeledCaptcha struct {
+	// Name identifies the captcha, e.g. its file name.
+	Name string
+	// Answer is the known answer of the captcha.
+	Answer string
+	// Image holds the encoded captcha image.
+	Image []byte
+}
+
+// Evaluation counts how a corpus of labeled captchas was solved.
+type Evaluation struct {
+	// Total is the number of captchas evaluated.
+	Total int
+	// Correct is the number of captchas solved to their known answer.
+	Correct int
+	// Unsolved is the number of captchas with letters that could not be recognized.
+	Unsolved int
+	// Wrong is the number of captchas solved completely, but to another answer.
+	Wrong int
+	// Failed is the number of captchas that could not be decoded or segmented.
+	Failed int
+	// Confusions counts the letters recognized as other letters.
+	Confusions ConfusionMatrix
+	// MarginOfError is the half-width of the 95% confidence interval of the accuracy of the whole corpus
+	// estimated from the captchas sampled by EvaluateSampled, 0 once they cover the corpus. Evaluate leaves it 0.
+	MarginOfError float64
+}
+
+// Accuracy returns the share of captchas solved to their known answer, between 0 and 1,
+// or 0 if no captcha was evaluated.
+func (e *Evaluation) Accuracy() float64 {
+	if e.Total == 0 {
+		return 0
+	}
+	return float64(e.Correct) / float64(e.Total)
+}
+
+// Evaluate solves a corpus of labeled captchas and counts how many were solved to their known answers.
+// Like SelfTest, it does not record the solves in the statistics or the journal and triggers no capture hooks,
+// so that it can run periodically, e.g. to catch regressions after the training data was reloaded.
+// Evaluation stops early when the context is done, counting the captchas evaluated so far.
+func Evaluate(ctx context.Context, captchas []LabeledCaptcha) *Evaluation {
+	return defaultSolver().Evaluate(ctx, captchas)
+}
+
+// Evaluate works like the package-level Evaluate, using the configuration and training data of the Solver.
+func (s *Solver) Evaluate(ctx context.Context, captchas []LabeledCaptcha) *Evaluation {
+	return s.evaluate(ctx, captchas, nil, nil)
+}
+
+// EvaluateEach works like Evaluate and also passes every captcha to observe with the result of solving it, or
+// the error if it could not be decoded or segmented, e.g. to compute statistics beyond those of the Evaluation.
+func EvaluateEach(ctx context.Context, captchas []LabeledCaptcha, observe func(captcha LabeledCaptcha, result *Result, err error)) *Evaluation {
+	return defaultSolver().EvaluateEach(ctx, captchas, observe)
+}
+
+// EvaluateEach works like the package-level EvaluateEach, using the configuration and training data of the Solver.
+func (s *Solver) EvaluateEach(ctx context.Context, captchas []LabeledCaptcha, observe func(captcha LabeledCaptcha, result *Result, err error)) *Evaluation {
+	return s.evaluate(ctx, captchas, nil, observe)
+}
+
+// DefaultMinSamples is the number of captchas EvaluateSampled evaluates at least before stopping at its margin
+// of error, so that the estimate is not trusted after a lucky streak.
+const DefaultMinSamples = 100
+
+// SamplingConfig configures when EvaluateSampled stops sampling a corpus.
+type SamplingConfig struct {
+	// Budget stops the evaluation once it took that long, if positive.
+	Budget time.Duration
+	// MarginOfError stops the evaluation once the 95% confidence interval of the accuracy is at most that far
+	// from the estimate on either side, e.g. 0.01 for ±1%, if positive.
+	MarginOfError float64
+	// MinSamples is the number of captchas evaluated before stopping at the margin of error, DefaultMinSamples
+	// if 0.
+	MinSamples int
+	// Seed seeds the random order in which the captchas are sampled. Evaluations with the same seed sample the
+	// same captchas on every machine and Go release, which makes them reproducible and comparable across changes
+	// of the training data. Only a time budget, which depends on the speed of the machine, makes them differ.
+	Seed int64
+}
+
+// EvaluateSampled works like Evaluate, but evaluates the captchas in a random order and stops as soon as the
+// time budget of config is spent or the accuracy of the whole corpus is estimated within its margin of error,
+// reporting the margin of error reached in the Evaluation. Iterating on large corpora, e.g. 100k captchas, then
+// takes seconds instead of full passes. The margin of error is computed from the Wilson score interval, which
+// stays meaningful at accuracies close to 100%, and shrinks to 0 as the sample covers the whole corpus.
+//
+// An error is returned if config has a negative margin of error or number of samples.
+func EvaluateSampled(ctx context.Context, captchas []LabeledCaptcha, config SamplingConfig) (*Evaluation, error) {
+	return defaultSolver().EvaluateSampled(ctx, captchas, config)
+}
+
+// EvaluateSampled works like the package-level EvaluateSampled, using the configuration and training data of
+// the Solver.
+func (s *Solver) EvaluateSampled(ctx context.Context, captchas []LabeledCaptcha, config SamplingConfig) (*Evaluation, error) {
+	if config.MarginOfError < 0 {
+		return nil, errors.New("margin of error must not be negative")
+	}
+	if config.MinSamples < 0 {
+		return nil, errors.New("minimum number of samples must not be negative")
+	}
+	if config.MinSamples == 0 {
+		config.MinSamples = DefaultMinSamples
+	}
+
+	// Sample the captchas in random order, within the time budget if any
+	if config.Budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Budget)
+		defer cancel()
+	}
+	order := rand.New(rand.NewSource(config.Seed)).Perm(len(captchas))
+	sampled := make([]LabeledCaptcha, len(captchas))
+	for i, j := range order {
+		sampled[i] = captchas[j]
+	}
+
+	e := s.evaluate(ctx, sampled, func(e *Evaluation) bool {
+		return config.MarginOfError > 0 && e.Total >= config.MinSamples &&
+			marginOfError(e.Correct, e.Total, len(captchas)) <= config.MarginOfError
+	}, nil)
+	e.MarginOfError = marginOfError(e.Correct, e.Total, len(captchas))
+	return e, nil
+}
+
+// marginOfError returns the half-width of the 95% Wilson score interval of the accuracy of a corpus of
+// population captchas, correct of n sampled captchas being solved correctly, with the finite population
+// correction. It returns 1 if nothing was sampled.
+func marginOfError(correct, n, population int) float64 {
+	if n == 0 {
+		return 1
+	}
+	const z = 1.96
+	p, size := float64(correct)/float64(n), float64(n)
+	margin := z * math.Sqrt(p*(1-p)/size+z*z/(4*size*size)) / (1 + z*z/size)
+	if population > 1 {
+		margin *= math.Sqrt(float64(population-n) / float64(population-1))
+	}
+	return margin
+}
+
+// evaluate implements Evaluate, EvaluateEach and EvaluateSampled, evaluating the captchas in order until the
+// context is done or stop, if not nil, returns true. Every captcha evaluated is passed to observe, if not nil.
+func (s *Solver) evaluate(ctx context.Context, captchas []LabeledCaptcha, stop func(*Evaluation) bool, observe func(LabeledCaptcha, *Result, error)) *Evaluation {
+	probe := s.detached()
+	e := &Evaluation{Confusions: make(ConfusionMatrix)}
+	for _, captcha := range captchas {
+		if ctx.Err() != nil || (stop != nil && stop(e)) {
+			break
+		}
+		e.Total++
+		result, err := probe.solve(bytes.NewReader(captcha.Image))
+		if observe != nil {
+			observe(captcha, result, err)
+		}
+		switch {
+		case err != nil:
+			e.Failed++
+		case result.Text == captcha.Answer:
+			e.Correct++
+		case !result.Solved:
+			e.Unsolved++
+			e.Confusions.Record(captcha.Answer, result.Text)
+		default:
+			e.Wrong++
+			e.Confusions.Record(captcha.Answer, result.Text)
+		}
+	}
+	return e
+}
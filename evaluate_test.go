@@ -0,0 +1,114 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluate(t *testing.T) {
+	var blank bytes.Buffer
+	assert.NoError(t, png.Encode(&blank, image.NewGray(image.Rect(0, 0, 200, CaptchaHeight))))
+	captchas := []LabeledCaptcha{
+		{Name: "correct", Answer: "ABCEFG", Image: syntheticCaptcha(t, "ABCEFG")},
+		{Name: "wrong", Answer: "ABCEFX", Image: syntheticCaptcha(t, "ABCEFG")},
+		{Name: "unsolved", Answer: "ABCEFG", Image: flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)},
+		{Name: "failed", Answer: "ABCEFG", Image: blank.Bytes()},
+	}
+
+	stats := SolveStats()
+	e := Evaluate(context.Background(), captchas)
+	assert.Equal(t, stats.Solves, SolveStats().Solves)
+	assert.Equal(t, 4, e.Total)
+	assert.Equal(t, 1, e.Correct)
+	assert.Equal(t, 1, e.Wrong)
+	assert.Equal(t, 1, e.Unsolved)
+	assert.Equal(t, 1, e.Failed)
+	assert.Equal(t, 0.25, e.Accuracy())
+	assert.Equal(t, [][2]string{{"G", "X"}}, e.Confusions.Pairs(1))
+
+	// A canceled evaluation evaluates nothing
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e = Evaluate(ctx, captchas)
+	assert.Equal(t, 0, e.Total)
+	assert.Equal(t, 0.0, e.Accuracy())
+}
+
+func TestEvaluateEach(t *testing.T) {
+	captchas := []LabeledCaptcha{
+		{Name: "unsolved", Answer: "ABCEFG", Image: flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)},
+		{Name: "failed", Answer: "ABCEFG", Image: []byte("not an image")},
+	}
+
+	var names, answers []string
+	var errs []error
+	e := EvaluateEach(context.Background(), captchas, func(captcha LabeledCaptcha, result *Result, err error) {
+		names = append(names, captcha.Name)
+		errs = append(errs, err)
+		if result != nil {
+			answers = append(answers, result.Text)
+		}
+	})
+	assert.Equal(t, 2, e.Total)
+	assert.Equal(t, []string{"unsolved", "failed"}, names)
+	assert.Equal(t, []string{"AB-EFG"}, answers)
+	assert.NoError(t, errs[0])
+	assert.Error(t, errs[1])
+}
+
+func TestEvaluateSampled(t *testing.T) {
+	correct, wrong := syntheticCaptcha(t, "ABCEFG"), syntheticCaptcha(t, "HJKLMN")
+	captchas := make([]LabeledCaptcha, 400)
+	for i := range captchas {
+		captchas[i] = LabeledCaptcha{Answer: "ABCEFG", Image: correct}
+		if i%4 == 0 {
+			captchas[i].Image = wrong
+		}
+	}
+
+	// Sampling stops once the accuracy is estimated within the margin of error
+	config := SamplingConfig{MarginOfError: 0.1, MinSamples: 20, Seed: 1}
+	e, err := EvaluateSampled(context.Background(), captchas, config)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, e.Total, 20)
+	assert.Less(t, e.Total, len(captchas))
+	assert.LessOrEqual(t, e.MarginOfError, 0.1)
+	assert.InDelta(t, 0.75, e.Accuracy(), e.MarginOfError)
+
+	// The same seed samples the same captchas
+	again, err := EvaluateSampled(context.Background(), captchas, config)
+	assert.NoError(t, err)
+	assert.Equal(t, e.Total, again.Total)
+	assert.Equal(t, e.Correct, again.Correct)
+
+	// Without a margin of error or budget, the whole corpus is evaluated
+	e, err = EvaluateSampled(context.Background(), captchas[:40], SamplingConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, 40, e.Total)
+	assert.Equal(t, 30, e.Correct)
+	assert.Zero(t, e.MarginOfError)
+
+	// A spent budget stops the evaluation
+	e, err = EvaluateSampled(context.Background(), captchas, SamplingConfig{Budget: time.Nanosecond})
+	assert.NoError(t, err)
+	assert.Less(t, e.Total, len(captchas))
+
+	_, err = EvaluateSampled(context.Background(), captchas, SamplingConfig{MarginOfError: -1})
+	assert.Error(t, err)
+	_, err = EvaluateSampled(context.Background(), captchas, SamplingConfig{MinSamples: -1})
+	assert.Error(t, err)
+}
+
+func TestMarginOfError(t *testing.T) {
+	// The Wilson score interval does not collapse at perfect accuracy
+	assert.InDelta(t, 0.0567, marginOfError(30, 30, 1000000), 1e-4)
+	assert.InDelta(t, 0.0962, marginOfError(50, 100, 1000000), 1e-4)
+	assert.Zero(t, marginOfError(75, 100, 100))
+	assert.Equal(t, 1.0, marginOfError(0, 0, 100))
+}
@@ -3,3 +3,7 @@ module wasm
 go 1.18
 
 require github.com/gopkg-dev/amazoncaptcha v1.0.1
+
+require golang.org/x/image v0.18.0 // indirect
+
+replace github.com/gopkg-dev/amazoncaptcha => ../..
@@ -0,0 +1,34 @@
+// worker.js runs the solver off the main thread of the page: it loads main.wasm and answers the messages
+// {id, fn, image} by calling the function fn registered by the wasm package, posting back {id, result}
+// once its Promise resolves, or {id, error} if it rejects.
+importScripts("wasm_exec.js");
+
+// This is a polyfill for FireFox and Safari
+if (!WebAssembly.instantiateStreaming) {
+	WebAssembly.instantiateStreaming = async (resp, importObject) => {
+		const source = await (await resp).arrayBuffer()
+		return await WebAssembly.instantiate(source, importObject)
+	}
+}
+
+const go = new Go()
+const ready = WebAssembly.instantiateStreaming(fetch("main.wasm"), go.importObject)
+	.then(result => {
+		go.run(result.instance)
+	})
+
+const functions = ["solveCaptcha", "findLetters"]
+
+self.onmessage = async event => {
+	const { id, fn, image } = event.data
+	try {
+		if (!functions.includes(fn)) {
+			throw new Error("unknown function " + fn)
+		}
+		await ready
+		const result = await self[fn](image)
+		self.postMessage({ id, result })
+	} catch (error) {
+		self.postMessage({ id, error: error.message || String(error) })
+	}
+}
@@ -0,0 +1,86 @@
+package external
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultAntiCaptchaURL is the base URL of the API of Anti-Captcha.
+const DefaultAntiCaptchaURL = "https://api.anti-captcha.com"
+
+// AntiCaptcha solves captchas with Anti-Captcha, or any service compatible with its createTask and
+// getTaskResult API.
+type AntiCaptcha struct {
+	// Key is the client key of the account.
+	Key string
+	// BaseURL is the base URL of the API, DefaultAntiCaptchaURL if empty.
+	BaseURL string
+	// Client makes the requests, http.DefaultClient if nil.
+	Client *http.Client
+	// PollInterval is the delay between two polls for the answer, DefaultPollInterval if 0.
+	PollInterval time.Duration
+}
+
+// antiCaptchaResponse is the JSON response of the createTask and getTaskResult endpoints.
+type antiCaptchaResponse struct {
+	ErrorID          int    `json:"errorId"`
+	ErrorCode        string `json:"errorCode"`
+	ErrorDescription string `json:"errorDescription"`
+	TaskID           int64  `json:"taskId"`
+	Status           string `json:"status"`
+	Solution         struct {
+		Text string `json:"text"`
+	} `json:"solution"`
+}
+
+// Solve implements amazoncaptcha.ExternalSolver: it creates an image to text task and polls for its answer.
+func (a *AntiCaptcha) Solve(ctx context.Context, img []byte) (string, error) {
+	baseURL := strings.TrimSuffix(a.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = DefaultAntiCaptchaURL
+	}
+
+	// Create the task, which answers with its ID
+	task := map[string]interface{}{
+		"clientKey": a.Key,
+		"task": map[string]interface{}{
+			"type": "ImageToTextTask",
+			"body": base64.StdEncoding.EncodeToString(img),
+		},
+	}
+	var created antiCaptchaResponse
+	if err := postJSON(ctx, a.Client, baseURL+"/createTask", task, &created); err != nil {
+		return "", fmt.Errorf("failed to submit captcha to anti-captcha: %w", err)
+	}
+	if created.ErrorID != 0 {
+		return "", antiCaptchaError(created)
+	}
+
+	// Poll for the answer until the workers of the service solved the captcha
+	query := map[string]interface{}{"clientKey": a.Key, "taskId": created.TaskID}
+	return poll(ctx, a.PollInterval, func() (string, bool, error) {
+		var result antiCaptchaResponse
+		if err := postJSON(ctx, a.Client, baseURL+"/getTaskResult", query, &result); err != nil {
+			return "", false, fmt.Errorf("failed to get answer from anti-captcha: %w", err)
+		}
+		switch {
+		case result.ErrorID == 0 && result.Status == "ready":
+			return result.Solution.Text, true, nil
+		case result.ErrorID == 0:
+			return "", false, nil
+		case result.ErrorCode == "ERROR_CAPTCHA_UNSOLVABLE":
+			return "", false, fmt.Errorf("%w: %v", ErrUnsolvable, antiCaptchaError(result))
+		default:
+			return "", false, antiCaptchaError(result)
+		}
+	})
+}
+
+// antiCaptchaError converts the error of an Anti-Captcha response into a *ServiceError.
+func antiCaptchaError(resp antiCaptchaResponse) error {
+	return &ServiceError{Service: "anti-captcha", Code: resp.ErrorCode, Description: resp.ErrorDescription}
+}
@@ -0,0 +1,109 @@
+// Package external implements amazoncaptcha.ExternalSolver for popular paid captcha solving services, to be set
+// as the last resort of an amazoncaptcha.FallbackPolicy:
+//
+//	policy := amazoncaptcha.FallbackPolicy{Retries: 2, External: &external.TwoCaptcha{Key: key}}
+//
+// Every service is called over its HTTP API with an image captcha task, and polled until the answer is ready or
+// the context is done. Answers are upper-cased, as Amazon captchas only hold capital letters.
+package external
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// DefaultPollInterval is the delay between two polls for the answer of a service, unless configured otherwise.
+const DefaultPollInterval = 5 * time.Second
+
+// ErrUnsolvable is matched by the error returned when a service reports that its workers could not solve the
+// captcha, as opposed to a failure of the service itself.
+var ErrUnsolvable = errors.New("captcha could not be solved by the service")
+
+// Both services implement the interface of the fallback policy.
+var (
+	_ amazoncaptcha.ExternalSolver = (*TwoCaptcha)(nil)
+	_ amazoncaptcha.ExternalSolver = (*AntiCaptcha)(nil)
+)
+
+// ServiceError is an error reported by a service, e.g. an invalid key or an empty balance.
+type ServiceError struct {
+	// Service names the service.
+	Service string
+	// Code is the error code of the service.
+	Code string
+	// Description describes the error, if the service does.
+	Description string
+}
+
+// Error implements the error interface.
+func (e *ServiceError) Error() string {
+	if e.Description == "" {
+		return fmt.Sprintf("%s error: %s", e.Service, e.Code)
+	}
+	return fmt.Sprintf("%s error: %s: %s", e.Service, e.Code, e.Description)
+}
+
+// poll calls check every interval until it reports the answer ready or fails, or ctx is done.
+func poll(ctx context.Context, interval time.Duration, check func() (string, bool, error)) (string, error) {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-timer.C:
+		}
+		answer, ready, err := check()
+		if err != nil {
+			return "", err
+		}
+		if ready {
+			return strings.ToUpper(strings.TrimSpace(answer)), nil
+		}
+		timer.Reset(interval)
+	}
+}
+
+// postJSON posts body as JSON to url with client, or http.DefaultClient if nil, and decodes the response into v.
+func postJSON(ctx context.Context, client *http.Client, url string, body, v interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return do(client, req, v)
+}
+
+// do makes an HTTP request with client, or http.DefaultClient if nil, and decodes the JSON response into v.
+func do(client *http.Client, req *http.Request, v interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected HTTP status code: %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
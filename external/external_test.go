@@ -0,0 +1,94 @@
+package external
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTwoCaptcha(t *testing.T) {
+	var polls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/in.php":
+			assert.NoError(t, r.ParseForm())
+			assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("captcha")), r.PostForm.Get("body"))
+			if r.PostForm.Get("key") != "key" {
+				_, _ = w.Write([]byte(`{"status":0,"request":"ERROR_WRONG_USER_KEY","error_text":"Wrong key"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"status":1,"request":"42"}`))
+		case "/res.php":
+			assert.Equal(t, "42", r.URL.Query().Get("id"))
+			if atomic.AddInt32(&polls, 1) < 3 {
+				_, _ = w.Write([]byte(`{"status":0,"request":"CAPCHA_NOT_READY"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"status":1,"request":"abcefg"}`))
+		}
+	}))
+	defer server.Close()
+
+	solver := &TwoCaptcha{Key: "key", BaseURL: server.URL, Client: server.Client(), PollInterval: time.Millisecond}
+	answer, err := solver.Solve(context.Background(), []byte("captcha"))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", answer)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&polls))
+
+	solver.Key = "other"
+	_, err = solver.Solve(context.Background(), []byte("captcha"))
+	var serviceErr *ServiceError
+	assert.True(t, errors.As(err, &serviceErr))
+	assert.Equal(t, "ERROR_WRONG_USER_KEY", serviceErr.Code)
+}
+
+func TestAntiCaptcha(t *testing.T) {
+	var polls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "key", body["clientKey"])
+		switch r.URL.Path {
+		case "/createTask":
+			task := body["task"].(map[string]interface{})
+			assert.Equal(t, "ImageToTextTask", task["type"])
+			assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("captcha")), task["body"])
+			_, _ = w.Write([]byte(`{"errorId":0,"taskId":7}`))
+		case "/getTaskResult":
+			assert.Equal(t, 7.0, body["taskId"])
+			switch atomic.AddInt32(&polls, 1) {
+			case 1:
+				_, _ = w.Write([]byte(`{"errorId":0,"status":"processing"}`))
+			case 2:
+				_, _ = w.Write([]byte(`{"errorId":0,"status":"ready","solution":{"text":"hjklmn"}}`))
+			default:
+				_, _ = w.Write([]byte(`{"errorId":12,"errorCode":"ERROR_CAPTCHA_UNSOLVABLE","errorDescription":"Captcha could not be solved"}`))
+			}
+		}
+	}))
+	defer server.Close()
+
+	solver := &AntiCaptcha{Key: "key", BaseURL: server.URL + "/", Client: server.Client(), PollInterval: time.Millisecond}
+	answer, err := solver.Solve(context.Background(), []byte("captcha"))
+	assert.NoError(t, err)
+	assert.Equal(t, "HJKLMN", answer)
+
+	_, err = solver.Solve(context.Background(), []byte("captcha"))
+	assert.ErrorIs(t, err, ErrUnsolvable)
+	assert.ErrorContains(t, err, "Captcha could not be solved")
+}
+
+func TestPollContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := poll(ctx, time.Millisecond, func() (string, bool, error) { return "", false, nil })
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
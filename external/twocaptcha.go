@@ -0,0 +1,90 @@
+package external
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultTwoCaptchaURL is the base URL of the API of 2Captcha.
+const DefaultTwoCaptchaURL = "https://2captcha.com"
+
+// TwoCaptcha solves captchas with 2Captcha, or any service compatible with its in.php and res.php API.
+type TwoCaptcha struct {
+	// Key is the API key of the account.
+	Key string
+	// BaseURL is the base URL of the API, DefaultTwoCaptchaURL if empty.
+	BaseURL string
+	// Client makes the requests, http.DefaultClient if nil.
+	Client *http.Client
+	// PollInterval is the delay between two polls for the answer, DefaultPollInterval if 0.
+	PollInterval time.Duration
+}
+
+// twoCaptchaResponse is the JSON response of the in.php and res.php endpoints.
+type twoCaptchaResponse struct {
+	Status  int    `json:"status"`
+	Request string `json:"request"`
+	// ErrorText describes the error, if any.
+	ErrorText string `json:"error_text"`
+}
+
+// Solve implements amazoncaptcha.ExternalSolver: it uploads the image as a normal captcha and polls for its answer.
+func (t *TwoCaptcha) Solve(ctx context.Context, img []byte) (string, error) {
+	baseURL := strings.TrimSuffix(t.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = DefaultTwoCaptchaURL
+	}
+
+	// Upload the captcha, which answers with the ID of the task
+	form := url.Values{
+		"key":    {t.Key},
+		"method": {"base64"},
+		"body":   {base64.StdEncoding.EncodeToString(img)},
+		"json":   {"1"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/in.php", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var created twoCaptchaResponse
+	if err := do(t.Client, req, &created); err != nil {
+		return "", fmt.Errorf("failed to submit captcha to 2captcha: %w", err)
+	}
+	if created.Status != 1 {
+		return "", twoCaptchaError(created)
+	}
+
+	// Poll for the answer until the workers of the service solved the captcha
+	query := url.Values{"key": {t.Key}, "action": {"get"}, "id": {created.Request}, "json": {"1"}}
+	return poll(ctx, t.PollInterval, func() (string, bool, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/res.php?"+query.Encode(), nil)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to create HTTP request: %w", err)
+		}
+		var result twoCaptchaResponse
+		if err := do(t.Client, req, &result); err != nil {
+			return "", false, fmt.Errorf("failed to get answer from 2captcha: %w", err)
+		}
+		switch {
+		case result.Status == 1:
+			return result.Request, true, nil
+		case result.Request == "CAPCHA_NOT_READY":
+			return "", false, nil
+		case result.Request == "ERROR_CAPTCHA_UNSOLVABLE":
+			return "", false, fmt.Errorf("%w: %v", ErrUnsolvable, twoCaptchaError(result))
+		default:
+			return "", false, twoCaptchaError(result)
+		}
+	})
+}
+
+// twoCaptchaError converts the error of a 2Captcha response into a *ServiceError.
+func twoCaptchaError(resp twoCaptchaResponse) error {
+	return &ServiceError{Service: "2captcha", Code: resp.Request, Description: resp.ErrorText}
+}
@@ -0,0 +1,56 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ExternalSolver solves captchas that could not be solved well enough locally, e.g. by calling a paid captcha
+// solving service, and is only used as the last resort of a FallbackPolicy. The external subpackage implements
+// it for popular services, so that integrators keep a single code path whichever service they use.
+type ExternalSolver interface {
+	// Solve returns the answer to a captcha image, as downloaded from the captcha page.
+	Solve(ctx context.Context, img []byte) (string, error)
+}
+
+// FallbackPolicy governs what a Prefetcher does when local accuracy is not enough: a captcha is first solved
+// locally, and an answer with unknown letters or a confidence below MinConfidence is not trusted. The captcha is
+// then replaced by a new one, up to Retries times in a row, and the last one is handed over to External, if set.
+// Without External, the captcha is skipped like any other failed fetch.
+type FallbackPolicy struct {
+	// MinConfidence is the confidence, between 0 and 1, below which a local answer is not trusted. Answers with
+	// unknown letters are never trusted.
+	MinConfidence float64
+	// Retries is the number of new captchas fetched right away after a captcha was not solved well enough.
+	Retries int
+	// External solves the captchas not solved well enough after all retries, if not nil.
+	External ExternalSolver
+}
+
+// validate reports an error for a policy that cannot be applied.
+func (f *FallbackPolicy) validate() error {
+	if f.MinConfidence < 0 || f.MinConfidence > 1 {
+		return errors.New("minimum confidence must be between 0 and 1")
+	}
+	if f.Retries < 0 {
+		return errors.New("fallback retries must not be negative")
+	}
+	return nil
+}
+
+// solveLocally solves a captcha image with solver, and fails unless the answer is trusted by the policy.
+func (f *FallbackPolicy) solveLocally(solver *Solver, image []byte) (string, error) {
+	result, err := solver.SolveDetailed(bytes.NewReader(image))
+	if err != nil {
+		return "", fmt.Errorf("failed to solve: %w", err)
+	}
+	if !result.Solved {
+		return "", fmt.Errorf("failed to solve: %w", result.failure())
+	}
+	if result.Confidence < f.MinConfidence {
+		return "", fmt.Errorf("confidence %.2f of answer %s is below %.2f", result.Confidence, result.Text, f.MinConfidence)
+	}
+	return result.Text, nil
+}
@@ -0,0 +1,90 @@
+package amazoncaptcha
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubSolver is an ExternalSolver answering every captcha with the same answer, or failing if it is empty.
+type stubSolver struct {
+	answer string
+	calls  int32
+	image  atomic.Value
+}
+
+func (s *stubSolver) Solve(_ context.Context, image []byte) (string, error) {
+	atomic.AddInt32(&s.calls, 1)
+	s.image.Store(image)
+	if s.answer == "" {
+		return "", errors.New("service unavailable")
+	}
+	return s.answer, nil
+}
+
+func TestFallbackPolicy(t *testing.T) {
+	captcha := flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)
+	server, fetches := captchaPage(t, captcha, nil)
+	external := &stubSolver{answer: "ABCEFG"}
+	p, err := NewPrefetcher(PrefetchConfig{
+		URL:      server.URL + "/errors/validateCaptcha",
+		Client:   sessionClient(t, server),
+		Size:     1,
+		Fallback: FallbackPolicy{Retries: 2, External: external},
+	})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go func() { _ = p.Run(ctx) }()
+
+	// The captcha with an unknown letter is retried twice, then solved externally
+	challenge, err := p.Get(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", challenge.Answer)
+	assert.True(t, challenge.External)
+	assert.Equal(t, "token-3", challenge.Form().Get("amzn"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&external.calls))
+	assert.Equal(t, captcha, external.image.Load())
+	assert.GreaterOrEqual(t, atomic.LoadInt32(fetches), int32(3))
+}
+
+func TestFallbackPolicyConfidence(t *testing.T) {
+	captcha := flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)
+	solver, err := NewSolver(WithRecognizer(fixedRecognizer("C")))
+	assert.NoError(t, err)
+
+	fetch := func(policy FallbackPolicy) (*Challenge, error) {
+		server, _ := captchaPage(t, captcha, nil)
+		p, err := solver.NewPrefetcher(PrefetchConfig{URL: server.URL + "/errors/validateCaptcha", Client: sessionClient(t, server), Size: 1, Fallback: policy})
+		assert.NoError(t, err)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return p.fetch(ctx)
+	}
+
+	// The letter recognized by the recognizer lowers the confidence to 11/12
+	challenge, err := fetch(FallbackPolicy{MinConfidence: 0.9})
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", challenge.Answer)
+	assert.False(t, challenge.External)
+
+	challenge, err = fetch(FallbackPolicy{MinConfidence: 0.95, External: &stubSolver{answer: "HJKLMN"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "HJKLMN", challenge.Answer)
+	assert.Equal(t, "HJKLMN", challenge.Form().Get("field-keywords"))
+	assert.True(t, challenge.External)
+
+	_, err = fetch(FallbackPolicy{MinConfidence: 0.95})
+	assert.ErrorContains(t, err, "below 0.95")
+	_, err = fetch(FallbackPolicy{MinConfidence: 0.95, External: &stubSolver{}})
+	assert.ErrorContains(t, err, "failed to solve captcha externally")
+
+	_, err = NewPrefetcher(PrefetchConfig{Size: 1, Fallback: FallbackPolicy{MinConfidence: 2}})
+	assert.Error(t, err)
+	_, err = NewPrefetcher(PrefetchConfig{Size: 1, Fallback: FallbackPolicy{Retries: -1}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,120 @@
+// Package fixture records captcha pages with their images and expected answers into bundles, and replays them
+// from a local test server, so that scrapers depending on this package can test their captcha handling
+// hermetically, without reaching Amazon:
+//
+//	recorder := &fixture.Recorder{}
+//	for i := 0; i < 10; i++ {
+//		if _, err := recorder.Record(ctx); err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+//	err := recorder.Bundle.Save("testdata/captchas")
+//
+// and in the tests of the scraper:
+//
+//	bundle, err := fixture.Load("testdata/captchas")
+//	server := fixture.NewServer(bundle)
+//	defer server.Close()
+//	// Point the scraper at server.PageURL()
+//
+// A bundle is a directory holding bundle.json, which lists the captchas with their URLs and expected answers,
+// along with the recorded pages and images. The expected answers are those of the solver at recording time and
+// can be corrected by hand in bundle.json.
+package fixture
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// manifestName is the name of the file listing the captchas of a bundle.
+const manifestName = "bundle.json"
+
+// Captcha is a recorded captcha page with its image and expected answer.
+type Captcha struct {
+	// PageURL is the URL the captcha page was fetched from.
+	PageURL string `json:"page_url"`
+	// ImageURL is the URL of the captcha image, as resolved from the page.
+	ImageURL string `json:"image_url"`
+	// Answer is the expected answer to the captcha.
+	Answer string `json:"answer"`
+	// PageFile and ImageFile are the names of the files holding the page and the image in the bundle directory.
+	PageFile  string `json:"page_file"`
+	ImageFile string `json:"image_file"`
+	// Page is the HTML of the captcha page.
+	Page []byte `json:"-"`
+	// Image is the captcha image.
+	Image []byte `json:"-"`
+}
+
+// Bundle is a replayable set of recorded captchas.
+type Bundle struct {
+	Captchas []*Captcha `json:"captchas"`
+}
+
+// Add adds a captcha to the bundle, e.g. one built by hand from a saved page and image, naming its files
+// after its position.
+func (b *Bundle) Add(c *Captcha) {
+	n := len(b.Captchas) + 1
+	ext := path.Ext(c.ImageURL)
+	if ext == "" {
+		ext = ".jpg"
+	}
+	c.PageFile = fmt.Sprintf("captcha-%03d.html", n)
+	c.ImageFile = fmt.Sprintf("captcha-%03d%s", n, ext)
+	b.Captchas = append(b.Captchas, c)
+}
+
+// Save writes the bundle into the directory dir, created if needed.
+func (b *Bundle) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create bundle directory: %w", err)
+	}
+	for _, c := range b.Captchas {
+		if err := os.WriteFile(filepath.Join(dir, c.PageFile), c.Page, 0644); err != nil {
+			return fmt.Errorf("failed to save captcha page: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, c.ImageFile), c.Image, 0644); err != nil {
+			return fmt.Errorf("failed to save captcha image: %w", err)
+		}
+	}
+	manifest, err := json.MarshalIndent(b, "", "	")
+	if err != nil {
+		return fmt.Errorf("failed to marshal bundle: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifestName), manifest, 0644); err != nil {
+		return fmt.Errorf("failed to save bundle: %w", err)
+	}
+	return nil
+}
+
+// Load reads the bundle saved in the directory dir.
+func Load(dir string) (*Bundle, error) {
+	manifest, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	var b Bundle
+	if err := json.Unmarshal(manifest, &b); err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	if len(b.Captchas) == 0 {
+		return nil, errors.New("bundle holds no captchas")
+	}
+	for _, c := range b.Captchas {
+		if c == nil {
+			return nil, errors.New("invalid bundle: null captcha")
+		}
+		if c.Page, err = os.ReadFile(filepath.Join(dir, filepath.Base(c.PageFile))); err != nil {
+			return nil, fmt.Errorf("failed to read captcha page: %w", err)
+		}
+		if c.Image, err = os.ReadFile(filepath.Join(dir, filepath.Base(c.ImageFile))); err != nil {
+			return nil, fmt.Errorf("failed to read captcha image: %w", err)
+		}
+	}
+	return &b, nil
+}
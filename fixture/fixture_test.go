@@ -0,0 +1,138 @@
+package fixture
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+	"github.com/stretchr/testify/assert"
+)
+
+// amazonPage mimics the captcha page of Amazon, with a relative form action, hidden tokens and a captcha image
+// served from another path.
+const amazonPage = `<!DOCTYPE html><html><head><title>Amazon.com</title></head><body>
+<form method="get" action="/errors/validateCaptcha">
+<input type="hidden" name="amzn" value="token-%d">
+<div class="a-row a-text-center"><img src="/captcha/%d.png"></div>
+<input type="text" id="captchacharacters" name="field-keywords">
+<button type="submit">Continue shopping</button>
+</form></body></html>`
+
+// amazonSite serves the self-test captchas in turn on a captcha page mimicking the one of Amazon.
+func amazonSite(t *testing.T) (*httptest.Server, []amazoncaptcha.LabeledCaptcha) {
+	captchas, err := amazoncaptcha.SelfTestCaptchas()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	var pages int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var i int
+		if _, err := fmt.Sscanf(r.URL.Path, "/captcha/%d.png", &i); err == nil && i < len(captchas) {
+			_, _ = w.Write(captchas[i].Image)
+			return
+		}
+		n := int(atomic.AddInt64(&pages, 1)-1) % len(captchas)
+		fmt.Fprintf(w, amazonPage, n, n)
+	}))
+	t.Cleanup(server.Close)
+	return server, captchas
+}
+
+// record records n captchas of the site into a bundle.
+func record(t *testing.T, server *httptest.Server, n int) *Bundle {
+	recorder := &Recorder{URL: server.URL + "/errors/validateCaptcha"}
+	for i := 0; i < n; i++ {
+		_, err := recorder.Record(context.Background())
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+	return &recorder.Bundle
+}
+
+func TestRecordSaveLoad(t *testing.T) {
+	server, captchas := amazonSite(t)
+	bundle := record(t, server, 2)
+	if !assert.Len(t, bundle.Captchas, 2) {
+		return
+	}
+
+	// The pages, images and answers of the captchas are recorded
+	for i, c := range bundle.Captchas {
+		assert.Equal(t, captchas[i].Answer, c.Answer)
+		assert.Equal(t, captchas[i].Image, c.Image)
+		assert.Equal(t, fmt.Sprintf(amazonPage, i, i), string(c.Page))
+		assert.Equal(t, fmt.Sprintf("%s/captcha/%d.png", server.URL, i), c.ImageURL)
+	}
+
+	// Saved bundles load the same
+	dir := t.TempDir()
+	assert.NoError(t, bundle.Save(dir))
+	assert.FileExists(t, filepath.Join(dir, "captcha-001.html"))
+	assert.FileExists(t, filepath.Join(dir, "captcha-002.png"))
+	loaded, err := Load(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, bundle, loaded)
+
+	// Bundles without captchas or with missing files are invalid
+	assert.NoError(t, os.Remove(filepath.Join(dir, "captcha-002.png")))
+	_, err = Load(dir)
+	assert.Error(t, err)
+	empty := t.TempDir()
+	assert.NoError(t, (&Bundle{}).Save(empty))
+	_, err = Load(empty)
+	assert.Error(t, err)
+}
+
+func TestServer(t *testing.T) {
+	site, _ := amazonSite(t)
+	bundle := record(t, site, 2)
+	bundle.Captchas[1].Answer = "WRONG"
+	server := NewServerWithSuccessPage(bundle, []byte("<html><body>Results</body></html>"))
+	defer server.Close()
+
+	// The prefetcher solves the replayed captchas, and the answer to the first one is accepted
+	jar, err := cookiejar.New(nil)
+	assert.NoError(t, err)
+	p, err := amazoncaptcha.NewPrefetcher(amazoncaptcha.PrefetchConfig{URL: server.PageURL(), Client: &http.Client{Jar: jar}, Size: 1})
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go func() { _ = p.Run(ctx) }()
+	challenge, err := p.Get(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, bundle.Captchas[0].Answer, challenge.Answer)
+	resp, err := challenge.Submit(ctx)
+	if assert.NoError(t, err) {
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "<html><body>Results</body></html>", string(body))
+		assert.NoError(t, resp.Body.Close())
+	}
+
+	// The answer to the second one is rejected with the next captcha page, showing an error alert
+	challenge, err = p.Get(ctx)
+	assert.NoError(t, err)
+	_, err = challenge.Submit(ctx)
+	assert.ErrorIs(t, err, amazoncaptcha.ErrChallengeRejected)
+	assert.Equal(t, []Submission{
+		{Captcha: 0, Answer: bundle.Captchas[0].Answer, Accepted: true},
+		{Captcha: 1, Answer: challenge.Answer, Accepted: false},
+	}, server.Submissions())
+
+	resp, err = http.Get(server.URL + "/unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.NoError(t, resp.Body.Close())
+}
@@ -0,0 +1,100 @@
+package fixture
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// Recorder records captchas fetched from a captcha page into its Bundle.
+type Recorder struct {
+	// URL is the captcha page, amazoncaptcha.DefaultCaptchaPageURL if empty.
+	URL string
+	// Client makes the requests, a client with a new cookie jar if nil.
+	Client *http.Client
+	// Headers are set on every request, e.g. a realistic User-Agent.
+	Headers map[string]string
+	// Solver solves the recorded captchas for their expected answers, the default solver if nil.
+	Solver *amazoncaptcha.Solver
+	// Bundle holds the recorded captchas.
+	Bundle Bundle
+}
+
+// Record fetches a captcha page and its image, solves the captcha for its expected answer and adds it to the
+// Bundle. Captchas that cannot be solved completely are recorded with the placeholders of the Solver in their
+// answer, to be corrected by hand.
+func (r *Recorder) Record(ctx context.Context) (*Captcha, error) {
+	if r.Client == nil {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cookie jar: %w", err)
+		}
+		r.Client = &http.Client{Jar: jar}
+	}
+	pageURL := r.URL
+	if pageURL == "" {
+		pageURL = amazoncaptcha.DefaultCaptchaPageURL
+	}
+	solver := r.Solver
+	if solver == nil {
+		solver = amazoncaptcha.DefaultSolver()
+	}
+
+	// Fetch the captcha page and find its image
+	page, resp, err := r.get(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	src, err := amazoncaptcha.ExtractCaptchaURL(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+	imageURL, err := resp.Request.URL.Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid captcha image URL: %w", err)
+	}
+	image, _, err := r.get(ctx, imageURL.String())
+	if err != nil {
+		return nil, err
+	}
+
+	// Solve the captcha for its expected answer
+	c := &Captcha{PageURL: resp.Request.URL.String(), ImageURL: imageURL.String(), Page: page, Image: image}
+	result, err := solver.SolveDetailed(bytes.NewReader(image))
+	if err != nil {
+		return nil, fmt.Errorf("failed to solve captcha: %w", err)
+	}
+	c.Answer = result.Text
+	r.Bundle.Add(c)
+	return c, nil
+}
+
+// get makes a GET request with the client and headers of the Recorder, and returns the body of the response,
+// failing unless the response is OK.
+func (r *Recorder) get(ctx context.Context, url string) ([]byte, *http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	for k, v := range r.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to make HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("unexpected HTTP status code: %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return body, resp, nil
+}
@@ -0,0 +1,244 @@
+package fixture
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// DefaultSuccessPage is the page a Server serves for accepted answers unless configured otherwise.
+const DefaultSuccessPage = `<!DOCTYPE html><html><body><p>Thank you, the captcha was solved.</p></body></html>`
+
+// imagePrefix is the path the Server serves the captcha images under.
+const imagePrefix = "/fixture/images/"
+
+// captchaField is the hidden field the Server adds to the captcha forms to tell which captcha is answered.
+const captchaField = "fixture-captcha"
+
+// rejectionAlert is the error alert the Server adds above the captcha form of the page served for a wrong answer,
+// like Amazon does.
+const rejectionAlert = `<div class="a-box a-alert a-alert-error"><div class="a-box-inner">` +
+	`<h4>The characters you entered do not match the image. Please try again.</h4></div></div>`
+
+// Submission is an answer submitted to a Server.
+type Submission struct {
+	// Captcha is the index of the answered captcha in the bundle, or -1 if no captcha was served before.
+	Captcha int
+	// Answer is the submitted answer.
+	Answer string
+	// Accepted reports whether the answer was the expected one.
+	Accepted bool
+}
+
+// replayed is a captcha prepared for replay.
+type replayed struct {
+	captcha  *Captcha
+	page     []byte
+	rejected []byte
+	field    string
+}
+
+// Server replays a bundle like Amazon serves its captchas. Every request for the path of a recorded captcha
+// page serves the next captcha of the bundle, in turn, with its image served by the Server. Submitting the
+// captcha form, i.e. any request with the answer field of a captcha, is answered with the success page if the
+// answer is the expected one, case-insensitively, and with the next captcha page otherwise, like Amazon does
+// for wrong answers and expired form tokens; for wrong answers to a served captcha, the page shows an error
+// alert above the form, as Amazon's does. Answers are matched to their captcha by a hidden field added to the
+// form, like the form tokens of Amazon, or to the captcha served last if the field is not submitted.
+type Server struct {
+	*httptest.Server
+
+	captchas []replayed
+	pages    map[string]bool
+	fields   map[string]bool
+	success  []byte
+
+	mu          sync.Mutex
+	next        int
+	last        int
+	submissions []Submission
+}
+
+// NewServer starts a Server replaying the captchas of b, serving DefaultSuccessPage for accepted answers.
+// It panics if the bundle holds no captchas or a page that cannot be parsed, and must be closed after use.
+func NewServer(b *Bundle) *Server {
+	return NewServerWithSuccessPage(b, []byte(DefaultSuccessPage))
+}
+
+// NewServerWithSuccessPage works like NewServer, serving success for accepted answers, e.g. the page the
+// scraper under test expects behind the captcha.
+func NewServerWithSuccessPage(b *Bundle, success []byte) *Server {
+	if len(b.Captchas) == 0 {
+		panic("fixture: bundle holds no captchas")
+	}
+	s := &Server{
+		pages:   make(map[string]bool),
+		fields:  make(map[string]bool),
+		success: success,
+		last:    -1,
+	}
+	for i, c := range b.Captchas {
+		r, err := prepare(i, c)
+		if err != nil {
+			panic(fmt.Sprintf("fixture: %v", err))
+		}
+		s.captchas = append(s.captchas, r)
+		s.fields[r.field] = true
+		if u, err := url.Parse(c.PageURL); err == nil {
+			s.pages[u.Path] = true
+		}
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// prepare rewrites the page of the i-th captcha of a bundle to load its image from the Server, to submit its form
+// to the Server and to tell the captcha in its form, and finds the name of its answer field. The page is also
+// rendered with an error alert above the form, to be served for wrong answers.
+func prepare(i int, c *Captcha) (replayed, error) {
+	pageURL, err := url.Parse(c.PageURL)
+	if err != nil {
+		return replayed{}, fmt.Errorf("invalid page URL of captcha %d: %w", i, err)
+	}
+	captchaForm, err := amazoncaptcha.ParseCaptchaForm(pageURL, bytes.NewReader(c.Page))
+	if err != nil {
+		return replayed{}, fmt.Errorf("failed to parse captcha form of captcha %d: %w", i, err)
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(c.Page))
+	if err != nil {
+		return replayed{}, fmt.Errorf("failed to parse page of captcha %d: %w", i, err)
+	}
+	img := doc.Find("form img").First()
+	if img.Length() == 0 {
+		return replayed{}, fmt.Errorf("failed to find image of captcha %d", i)
+	}
+	img.SetAttr("src", imagePath(i, c))
+
+	// Keep the form on the Server if it submits to the site of the page
+	form := img.Closest("form")
+	if action, ok := form.Attr("action"); ok {
+		if u, err := pageURL.Parse(action); err == nil && u.Host == pageURL.Host {
+			form.SetAttr("action", u.RequestURI())
+		}
+	}
+	form.AppendHtml(fmt.Sprintf(`<input type="hidden" name="%s" value="%d">`, captchaField, i))
+
+	page, err := doc.Html()
+	if err != nil {
+		return replayed{}, fmt.Errorf("failed to render page of captcha %d: %w", i, err)
+	}
+	form.BeforeHtml(rejectionAlert)
+	rejected, err := doc.Html()
+	if err != nil {
+		return replayed{}, fmt.Errorf("failed to render page of captcha %d: %w", i, err)
+	}
+	return replayed{captcha: c, page: []byte(page), rejected: []byte(rejected), field: captchaForm.AnswerField}, nil
+}
+
+// imagePath returns the path the Server serves the image of the i-th captcha of a bundle at.
+func imagePath(i int, c *Captcha) string {
+	return imagePrefix + strconv.Itoa(i) + path.Ext(c.ImageFile)
+}
+
+// PageURL returns the URL of the captcha page on the Server, the path of the first recorded page.
+func (s *Server) PageURL() string {
+	u, err := url.Parse(s.captchas[0].captcha.PageURL)
+	if err != nil {
+		return s.URL
+	}
+	return s.URL + u.Path
+}
+
+// Submissions returns the answers submitted so far, in order.
+func (s *Server) Submissions() []Submission {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Submission(nil), s.submissions...)
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasPrefix(r.URL.Path, imagePrefix):
+		s.serveImage(w, r)
+	case s.isSubmission(r):
+		s.serveSubmission(w, r)
+	case s.pages[r.URL.Path]:
+		s.servePage(w, false)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// isSubmission reports whether a request submits a captcha form.
+func (s *Server) isSubmission(r *http.Request) bool {
+	query := r.URL.Query()
+	for field := range s.fields {
+		if query.Has(field) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveImage serves the image of a captcha.
+func (s *Server) serveImage(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, imagePrefix)
+	i, err := strconv.Atoi(strings.TrimSuffix(name, path.Ext(name)))
+	if err != nil || i < 0 || i >= len(s.captchas) {
+		http.NotFound(w, r)
+		return
+	}
+	image := s.captchas[i].captcha.Image
+	w.Header().Set("Content-Type", http.DetectContentType(image))
+	_, _ = w.Write(image)
+}
+
+// servePage serves the next captcha page, with its error alert if rejected.
+func (s *Server) servePage(w http.ResponseWriter, rejected bool) {
+	s.mu.Lock()
+	i := s.next
+	s.next = (s.next + 1) % len(s.captchas)
+	s.last = i
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if rejected {
+		_, _ = w.Write(s.captchas[i].rejected)
+		return
+	}
+	_, _ = w.Write(s.captchas[i].page)
+}
+
+// serveSubmission checks a submitted answer against its captcha, serving the success page if it is the expected
+// one and the next captcha page otherwise, with an error alert if a captcha was answered.
+func (s *Server) serveSubmission(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	i := s.last
+	if n, err := strconv.Atoi(r.URL.Query().Get(captchaField)); err == nil && n >= 0 && n < len(s.captchas) {
+		i = n
+	}
+	submission := Submission{Captcha: i}
+	if i >= 0 {
+		submission.Answer = r.URL.Query().Get(s.captchas[i].field)
+		submission.Accepted = strings.EqualFold(strings.TrimSpace(submission.Answer), s.captchas[i].captcha.Answer)
+	}
+	s.submissions = append(s.submissions, submission)
+	s.mu.Unlock()
+
+	if !submission.Accepted {
+		s.servePage(w, i >= 0)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(s.success)
+}
@@ -0,0 +1,116 @@
+package amazoncaptcha
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// AnswerField is the name of the answer input of the captcha form, unless the page names it otherwise.
+const AnswerField = "field-keywords"
+
+// CaptchaForm is the captcha form of a captcha page, with everything needed to submit an answer to its captcha.
+type CaptchaForm struct {
+	// PageURL is the URL the captcha page was served from.
+	PageURL string
+	// ImageURL is the absolute URL of the captcha image.
+	ImageURL string
+	// Action is the absolute URL the captcha form is submitted to.
+	Action string
+	// Fields holds the hidden fields of the captcha form, the amzn and amzn-r tokens on Amazon.
+	Fields url.Values
+	// AnswerField is the name of the answer input of the form, AnswerField unless the page names it otherwise.
+	AnswerField string
+}
+
+// ParseCaptchaForm parses a captcha page served from pageURL and returns its captcha form, with the URLs of the
+// image and the action resolved against pageURL. The captcha form is the form holding the captcha image, see
+// IsCaptchaPage; Amazon submits it to its own URL if it has no action. It returns ErrNoCaptchaImage if the page
+// holds no captcha image.
+func ParseCaptchaForm(pageURL *url.URL, html io.Reader) (*CaptchaForm, error) {
+	doc, err := goquery.NewDocumentFromReader(html)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse captcha page: %w", err)
+	}
+
+	// Find the captcha image, then the form it is submitted with
+	img := captchaImage(doc)
+	src, ok := img.Attr("src")
+	if !ok || src == "" {
+		return nil, ErrNoCaptchaImage
+	}
+	imageURL, err := pageURL.Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid captcha image URL: %w", err)
+	}
+	form := img.Closest("form")
+	action, _ := form.Attr("action")
+	actionURL, err := pageURL.Parse(action)
+	if err != nil {
+		return nil, fmt.Errorf("invalid captcha form action: %w", err)
+	}
+
+	// Keep the hidden tokens of the form, they must be submitted along with the answer
+	captchaForm := &CaptchaForm{
+		PageURL:     pageURL.String(),
+		ImageURL:    imageURL.String(),
+		Action:      actionURL.String(),
+		Fields:      make(url.Values),
+		AnswerField: AnswerField,
+	}
+	form.Find("input[type=hidden]").Each(func(_ int, input *goquery.Selection) {
+		if name, ok := input.Attr("name"); ok {
+			value, _ := input.Attr("value")
+			captchaForm.Fields.Add(name, value)
+		}
+	})
+	if name, ok := form.Find("input[type=text]").First().Attr("name"); ok && name != "" {
+		captchaForm.AnswerField = name
+	}
+	return captchaForm, nil
+}
+
+// SubmitURL returns the URL submitting answer with the form, as the captcha form of Amazon is submitted with GET.
+// The fields of the form and the answer are merged into the query of the action, replacing the parameters of the
+// same name. It fails if the form submits to another origin than the one of the captcha page, another scheme,
+// host or port, so that the answer and the session it is submitted under cannot end up elsewhere.
+func (f *CaptchaForm) SubmitURL(answer string) (string, error) {
+	pageURL, err := url.Parse(f.PageURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid captcha page URL: %w", err)
+	}
+	u, err := url.Parse(f.Action)
+	if err != nil {
+		return "", fmt.Errorf("invalid captcha form action: %w", err)
+	}
+	if origin(u) != origin(pageURL) {
+		return "", fmt.Errorf("captcha form of %s submits to another origin: %s", origin(pageURL), origin(u))
+	}
+
+	query := u.Query()
+	for name, values := range f.Fields {
+		query[name] = append([]string(nil), values...)
+	}
+	query.Set(f.AnswerField, answer)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// origin returns the scheme and host of u, with the default port of the scheme made explicit.
+func origin(u *url.URL) string {
+	scheme := strings.ToLower(u.Scheme)
+	port := u.Port()
+	if port == "" {
+		switch scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+	return scheme + "://" + net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
@@ -0,0 +1,67 @@
+package amazoncaptcha
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCaptchaForm(t *testing.T) {
+	pageURL, err := url.Parse("https://www.amazon.com/errors/validateCaptcha")
+	assert.NoError(t, err)
+
+	// The form holding the image is parsed, with its URLs resolved against the page
+	page := `<html><body><form method="get" action="/search"><input type="text" name="q"></form>
+<form method="get" action="/errors/validateCaptcha">
+<input type=hidden name="amzn" value="token" /><input type=hidden name="amzn-r" value="&#047;" />
+<div class="a-row a-text-center"><img src="/captcha/Captcha_1.jpg"></div>
+<input id="captchacharacters" name="field-keywords" type="text">
+</form></body></html>`
+	form, err := ParseCaptchaForm(pageURL, strings.NewReader(page))
+	if assert.NoError(t, err) {
+		assert.Equal(t, &CaptchaForm{
+			PageURL:     "https://www.amazon.com/errors/validateCaptcha",
+			ImageURL:    "https://www.amazon.com/captcha/Captcha_1.jpg",
+			Action:      "https://www.amazon.com/errors/validateCaptcha",
+			Fields:      url.Values{"amzn": {"token"}, "amzn-r": {"/"}},
+			AnswerField: AnswerField,
+		}, form)
+		submitURL, err := form.SubmitURL("ABCEFG")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://www.amazon.com/errors/validateCaptcha?amzn=token&amzn-r=%2F&field-keywords=ABCEFG", submitURL)
+		assert.Empty(t, form.Fields.Get(AnswerField))
+	}
+
+	// A form without an action submits to the page, with the answer input named as in the page
+	page = `<html><body><form method="get"><input type=hidden name="amzn" value="token" />
+<div class="a-row a-text-center"><img src="/captcha/Captcha_2.jpg"></div><input name="answer" type="text">
+</form></body></html>`
+	form, err = ParseCaptchaForm(pageURL, strings.NewReader(page))
+	if assert.NoError(t, err) {
+		assert.Equal(t, pageURL.String(), form.Action)
+		assert.Equal(t, "token", form.Fields.Get("amzn"))
+		assert.Equal(t, "answer", form.AnswerField)
+	}
+
+	// The query of the action is kept, with the fields of the form replacing the parameters of the same name
+	form.Action = "https://WWW.amazon.com:443/errors/validateCaptcha?ref=cs_503&amzn=stale"
+	submitURL, err := form.SubmitURL("ABCEFG")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://WWW.amazon.com:443/errors/validateCaptcha?amzn=token&answer=ABCEFG&ref=cs_503", submitURL)
+
+	// Forms submitting to another origin are refused
+	for _, action := range []string{
+		"https://example.com/errors/validateCaptcha",
+		"http://www.amazon.com/errors/validateCaptcha",
+		"https://www.amazon.com:8443/errors/validateCaptcha",
+	} {
+		form.Action = action
+		_, err = form.SubmitURL("ABCEFG")
+		assert.ErrorContains(t, err, "submits to another origin", action)
+	}
+
+	_, err = ParseCaptchaForm(pageURL, strings.NewReader(`<html><body>No captcha here</body></html>`))
+	assert.ErrorIs(t, err, ErrNoCaptchaImage)
+}
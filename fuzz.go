@@ -0,0 +1,137 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Limits of the images decoded by FuzzSolve, bounding the memory and time of every call.
+const (
+	// FuzzMaxImageBytes is the maximum number of bytes of an image read by FuzzSolve.
+	FuzzMaxImageBytes = 1 << 20
+	// FuzzMaxImagePixels is the maximum number of pixels of an image decoded by FuzzSolve, all frames of an
+	// animated GIF together.
+	FuzzMaxImagePixels = 1 << 18
+)
+
+var (
+	fuzzSolverOnce sync.Once
+	fuzzSolver     *Solver
+)
+
+// FuzzSolve solves data as a captcha image and checks the invariants of the results, for fuzzing the solver
+// against arbitrary input, e.g. by services exposing it to untrusted images:
+//
+//   - It never panics, unless an invariant below is violated, which is a bug to report.
+//   - The image is read up to FuzzMaxImageBytes bytes and decoded up to FuzzMaxImagePixels pixels, larger
+//     images failing with ErrImageTooLarge before they are decoded, so that memory and time are bounded by
+//     the limits rather than by the input.
+//   - Solving either fails with an error and no result, or returns a result whose confidences are between 0
+//     and 1, with one confidence per letter and every unknown letter among them, solved only if no letter is
+//     unknown.
+//   - Segmenting either fails with an error, or returns letters with an image, a text and a confidence between
+//     0 and 1.
+//
+// FuzzSolve uses a Solver with the embedded training data and default configuration, bounded by the limits
+// above, regardless of SetDefaultSolver, so that it is a stable target across applications. Its solves are
+// not recorded in any statistics. FuzzCorpus returns seed inputs. A native Go fuzz test wraps it as:
+//
+//	func FuzzCaptcha(f *testing.F) {
+//		for _, seed := range amazoncaptcha.FuzzCorpus() {
+//			f.Add(seed)
+//		}
+//		f.Fuzz(func(t *testing.T, data []byte) {
+//			amazoncaptcha.FuzzSolve(data)
+//		})
+//	}
+func FuzzSolve(data []byte) {
+	fuzzSolverOnce.Do(func() {
+		fuzzSolver = newSolver().detached()
+		fuzzSolver.maxImageBytes = FuzzMaxImageBytes
+		fuzzSolver.maxImagePixels = FuzzMaxImagePixels
+	})
+	s := fuzzSolver
+
+	result, err := s.solve(bytes.NewReader(data))
+	if err := checkFuzzResult(result, err); err != nil {
+		panic(fmt.Sprintf("amazoncaptcha: solve: %v", err))
+	}
+
+	letters, err := s.SegmentLetters(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	for i, letter := range letters {
+		if letter.Image == nil || letter.Text == "" || !isConfidence(letter.Confidence) {
+			panic(fmt.Sprintf("amazoncaptcha: segment: invalid letter %d: text %q, confidence %v", i, letter.Text, letter.Confidence))
+		}
+	}
+}
+
+// checkFuzzResult returns an error describing the first invariant of FuzzSolve violated by a solve.
+func checkFuzzResult(result *Result, err error) error {
+	if err != nil {
+		if result != nil {
+			return fmt.Errorf("result returned with error: %v", err)
+		}
+		return nil
+	}
+	if result == nil {
+		return fmt.Errorf("no result and no error")
+	}
+	if !isConfidence(result.Confidence) {
+		return fmt.Errorf("confidence out of range: %v", result.Confidence)
+	}
+	for i, confidence := range result.LetterConfidence {
+		if !isConfidence(confidence) {
+			return fmt.Errorf("confidence of letter %d out of range: %v", i, confidence)
+		}
+	}
+	if !sort.IntsAreSorted(result.unknown) {
+		return fmt.Errorf("unknown positions not sorted: %v", result.unknown)
+	}
+	for _, position := range result.unknown {
+		if position < 0 || position >= len(result.LetterConfidence) {
+			return fmt.Errorf("unknown position %d out of %d letters", position, len(result.LetterConfidence))
+		}
+	}
+	if result.Solved && (len(result.unknown) > 0 || result.rejected != nil) {
+		return fmt.Errorf("solved with unknown letters %v", result.unknown)
+	}
+	return nil
+}
+
+// isConfidence reports whether c is a valid confidence, between 0 and 1.
+func isConfidence(c float64) bool {
+	return !math.IsNaN(c) && c >= 0 && c <= 1
+}
+
+// FuzzCorpus returns seed inputs for fuzzing FuzzSolve: the self-test captchas, which exercise the whole solve,
+// and a few malformed images exercising the decoders and limits, e.g. truncated images, an empty GIF and a GIF
+// header announcing a screen above FuzzMaxImagePixels.
+func FuzzCorpus() [][]byte {
+	corpus := [][]byte{
+		{},
+		[]byte(gifMagic + "9a"),
+		// A GIF with a 1x1 logical screen and no frame
+		[]byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;"),
+		// A GIF with a 65535x65535 logical screen
+		[]byte("GIF89a\xff\xff\xff\xff\x00\x00\x00;"),
+	}
+	captchas, _ := SelfTestCaptchas()
+	for i, captcha := range captchas {
+		corpus = append(corpus, captcha.Image)
+		if i == 0 {
+			// The first captcha truncated, and with its pixel data corrupted
+			corrupted := append([]byte(nil), captcha.Image...)
+			for j := len(corrupted) / 2; j < len(corrupted); j += 7 {
+				corrupted[j] ^= 0xff
+			}
+			corpus = append(corpus, captcha.Image[:len(captcha.Image)/2], corrupted)
+		}
+	}
+	return corpus
+}
@@ -0,0 +1,57 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"errors"
+	"image/gif"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func FuzzSolveImage(f *testing.F) {
+	for _, seed := range FuzzCorpus() {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		FuzzSolve(data)
+	})
+}
+
+func TestFuzzSolve(t *testing.T) {
+	corpus := FuzzCorpus()
+	captchas, err := SelfTestCaptchas()
+	assert.NoError(t, err)
+	assert.Greater(t, len(corpus), len(captchas))
+	for _, data := range corpus {
+		assert.NotPanics(t, func() { FuzzSolve(data) })
+	}
+
+	// Invalid results violate the invariants
+	assert.NoError(t, checkFuzzResult(nil, errors.New("failed")))
+	assert.Error(t, checkFuzzResult(&Result{}, errors.New("failed")))
+	assert.Error(t, checkFuzzResult(nil, nil))
+	assert.Error(t, checkFuzzResult(&Result{Confidence: 1.5}, nil))
+	assert.Error(t, checkFuzzResult(&Result{LetterConfidence: []float64{1}, unknown: []int{1}}, nil))
+	assert.Error(t, checkFuzzResult(&Result{LetterConfidence: []float64{0}, unknown: []int{0}, Solved: true}, nil))
+	assert.NoError(t, checkFuzzResult(&Result{LetterConfidence: []float64{1, 0}, unknown: []int{1}, Confidence: 0.5}, nil))
+}
+
+func TestDecodeImageGIFFrameLimit(t *testing.T) {
+	b := gifCaptcha(t, "XYTUKH", 9)
+	config, err := gif.DecodeConfig(bytes.NewReader(b))
+	assert.NoError(t, err)
+	frame := config.Width * config.Height
+
+	// The pixels of all ten frames count towards the limit
+	solver, err := NewSolver(WithMaxImageSize(0, 10*frame))
+	assert.NoError(t, err)
+	answer, err := solver.SolveBytes(b)
+	assert.NoError(t, err)
+	assert.Equal(t, "XYTUKH", answer)
+
+	solver, err = NewSolver(WithMaxImageSize(0, 10*frame-1))
+	assert.NoError(t, err)
+	_, err = solver.SolveBytes(b)
+	assert.True(t, errors.Is(err, ErrImageTooLarge))
+}
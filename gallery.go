@@ -0,0 +1,122 @@
+package amazoncaptcha
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"image"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FeatureImage renders a feature as produced by ExtractFeatures back into a monochrome letter image.
+// Letters are assumed to be CaptchaHeight pixels high.
+func FeatureImage(feature string) (*image.Gray, error) {
+	binaryStr, err := decodeFeature(feature)
+	if err != nil {
+		return nil, err
+	}
+	if len(binaryStr)%CaptchaHeight != 0 {
+		return nil, fmt.Errorf("feature of %d pixels is not %d pixels high", len(binaryStr), CaptchaHeight)
+	}
+
+	// Set every pixel to black or white according to the binary string
+	width := len(binaryStr) / CaptchaHeight
+	img := image.NewGray(image.Rect(0, 0, width, CaptchaHeight))
+	for i, bit := range binaryStr {
+		if bit == '1' {
+			img.Pix[i] = 0
+		} else {
+			img.Pix[i] = 255
+		}
+	}
+
+	return img, nil
+}
+
+// featureFileName returns a short, stable file name for a feature.
+func featureFileName(feature string) string {
+	sum := sha1.Sum([]byte(feature))
+	return hex.EncodeToString(sum[:8])
+}
+
+// galleryTemplate renders the index page of a training data gallery.
+var galleryTemplate = template.Must(template.New("gallery").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Training data gallery</title>
+<style>
+body { font-family: sans-serif; }
+img { margin: 2px; border: 1px solid #ccc; image-rendering: pixelated; }
+</style>
+</head>
+<body>
+<h1>Training data gallery</h1>
+{{range .}}<h2 id="{{.Letter}}">{{.Letter}} ({{len .Files}})</h2>
+<div>{{$letter := .Letter}}{{range .Files}}<img src="{{$letter}}/{{.}}" title="{{.}}">{{end}}</div>
+{{end}}</body>
+</html>
+`))
+
+// ExportGallery renders every entry of the training data into a PNG image, writing one sub-directory
+// of dir per letter, plus an index.html page showing all of them. The gallery makes it possible to
+// review the training data by eye and spot mislabeled entries.
+// Entries that cannot be decoded are skipped.
+func ExportGallery(dir string) error {
+	return defaultSolver().ExportGallery(dir)
+}
+
+// ExportGallery works like the package-level ExportGallery, for the training data of the Solver.
+func (s *Solver) ExportGallery(dir string) error {
+	type gallerySection struct {
+		Letter string
+		Files  []string
+	}
+
+	sections := make(map[string]*gallerySection)
+	for feature, letter := range s.trainingData().features {
+		img, err := FeatureImage(feature)
+		if err != nil {
+			continue
+		}
+
+		// Create the directory of the letter on first use
+		section, ok := sections[letter]
+		if !ok {
+			if err := os.MkdirAll(filepath.Join(dir, letter), 0777); err != nil {
+				return fmt.Errorf("failed to create gallery directory: %w", err)
+			}
+			section = &gallerySection{Letter: letter}
+			sections[letter] = section
+		}
+
+		name := featureFileName(feature) + ".png"
+		if err := SaveGrayToPNG(filepath.Join(dir, letter, name), img); err != nil {
+			return fmt.Errorf("failed to save gallery image: %w", err)
+		}
+		section.Files = append(section.Files, name)
+	}
+
+	// Sort the sections and their files so that the index page is stable
+	index := make([]*gallerySection, 0, len(sections))
+	for _, section := range sections {
+		sort.Strings(section.Files)
+		index = append(index, section)
+	}
+	sort.Slice(index, func(i, j int) bool { return index[i].Letter < index[j].Letter })
+
+	file, err := os.Create(filepath.Join(dir, "index.html"))
+	if err != nil {
+		return fmt.Errorf("failed to create gallery index: %w", err)
+	}
+	defer file.Close()
+
+	if err := galleryTemplate.Execute(file, index); err != nil {
+		return fmt.Errorf("failed to write gallery index: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package amazoncaptcha
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureImage(t *testing.T) {
+	feature, _ := trainingLetter(t, "H")
+
+	img, err := FeatureImage(feature)
+	assert.NoError(t, err)
+	assert.Equal(t, CaptchaHeight, img.Bounds().Dy())
+
+	// Rendering and extracting again yields the same letter
+	extracted, err := ExtractFeatures(img)
+	assert.NoError(t, err)
+	distance, err := FeatureDistance(feature, extracted)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, distance)
+}
+
+func TestExportGallery(t *testing.T) {
+	requireTrainingData(t)
+	dir := t.TempDir()
+	assert.NoError(t, ExportGallery(dir))
+
+	index, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(index), `<h2 id="A">`)
+
+	images, err := filepath.Glob(filepath.Join(dir, "A", "*.png"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, images)
+}
@@ -0,0 +1,66 @@
+package amazoncaptcha
+
+import (
+	"image"
+	"math"
+)
+
+// GridWidth is the width of the grid letters are scaled to by NormalizeGrid. The grid keeps the height of
+// CaptchaHeight, so that normalized features stay comparable by fuzzy matching and candidates.
+const GridWidth = 32
+
+// NormalizeGrid crops a letter to its black pixels, like CutTheWhite, scales it to a grid of GridWidth by
+// CaptchaHeight pixels and binarizes it again: every pixel of the grid turns black if at least half of the area
+// it covers in the letter is black. The same glyph segmented with some jitter, or drawn slightly larger or
+// smaller, then has the same or nearly the same pixels. A letter without black pixels becomes blank.
+func NormalizeGrid(img *image.Gray) *image.Gray {
+	return normalizeGrid(img, nil)
+}
+
+// normalizeGrid implements NormalizeGrid, allocating the grid from a.
+func normalizeGrid(img *image.Gray, a *arena) *image.Gray {
+
+	// Create a white grid
+	grid := a.newGray(image.Rect(0, 0, GridWidth, CaptchaHeight))
+	for i := range grid.Pix {
+		grid.Pix[i] = 255
+	}
+
+	// Find the box of the black pixels, as CutTheWhite crops the letter to
+	bounds := img.Bounds()
+	box := image.Rectangle{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if img.GrayAt(x, y).Y == 0 {
+				box = box.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if box.Empty() {
+		return grid
+	}
+
+	// Scale the box to the grid, averaging the ink over the area every grid pixel covers
+	scaleX := float64(box.Dx()) / GridWidth
+	scaleY := float64(box.Dy()) / CaptchaHeight
+	for gy := 0; gy < CaptchaHeight; gy++ {
+		y0, y1 := float64(gy)*scaleY, float64(gy+1)*scaleY
+		for gx := 0; gx < GridWidth; gx++ {
+			x0, x1 := float64(gx)*scaleX, float64(gx+1)*scaleX
+			ink := 0.0
+			for sy := int(y0); float64(sy) < y1; sy++ {
+				overlapY := math.Min(y1, float64(sy+1)) - math.Max(y0, float64(sy))
+				for sx := int(x0); float64(sx) < x1; sx++ {
+					if img.GrayAt(box.Min.X+sx, box.Min.Y+sy).Y == 0 {
+						ink += overlapY * (math.Min(x1, float64(sx+1)) - math.Max(x0, float64(sx)))
+					}
+				}
+			}
+			if 2*ink >= scaleX*scaleY {
+				grid.Pix[gy*grid.Stride+gx] = 0
+			}
+		}
+	}
+
+	return grid
+}
@@ -0,0 +1,57 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeGrid(t *testing.T) {
+	_, binaryStr := trainingLetter(t, "K")
+	letter := featureImage(binaryStr, CaptchaHeight)
+	if !assert.NotNil(t, letter) {
+		return
+	}
+	grid := NormalizeGrid(letter)
+	assert.Equal(t, image.Rect(0, 0, GridWidth, CaptchaHeight), grid.Bounds())
+	assert.NotZero(t, countInk(grid))
+
+	// Shifting the letter within its segment does not change the grid
+	shifted := image.NewGray(image.Rect(0, 0, letter.Bounds().Dx()+3, CaptchaHeight))
+	for i := range shifted.Pix {
+		shifted.Pix[i] = 255
+	}
+	for y := 0; y < CaptchaHeight; y++ {
+		copy(shifted.Pix[y*shifted.Stride+3:], letter.Pix[y*letter.Stride:(y+1)*letter.Stride])
+	}
+	assert.Equal(t, grid.Pix, NormalizeGrid(shifted).Pix)
+
+	// Drawing the letter twice as wide does not change the grid either
+	wide := image.NewGray(image.Rect(0, 0, 2*letter.Bounds().Dx(), CaptchaHeight))
+	for y := 0; y < CaptchaHeight; y++ {
+		for x := 0; x < wide.Bounds().Dx(); x++ {
+			wide.SetGray(x, y, letter.GrayAt(x/2, y))
+		}
+	}
+	assert.Equal(t, grid.Pix, NormalizeGrid(wide).Pix)
+
+	// Blank letters stay blank
+	blank := image.NewGray(image.Rect(0, 0, 10, CaptchaHeight))
+	for i := range blank.Pix {
+		blank.Pix[i] = 255
+	}
+	assert.Equal(t, 0, countInk(NormalizeGrid(blank)))
+}
+
+func TestNewSolverWithGridNormalization(t *testing.T) {
+	solver, err := NewSolver(WithGridNormalization())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	answer, err := solver.Solve(bytes.NewReader(syntheticCaptcha(t, "ABCEFG")))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", answer)
+}
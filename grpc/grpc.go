@@ -0,0 +1,238 @@
+// Package grpc exposes an amazoncaptcha Solver over gRPC, so that scraping clusters written in other languages
+// can call the solver over a typed protocol, generating their clients from solver.proto.
+//
+// The Solver service answers Solve with the Solution of a single captcha image, and SolveStream with the
+// Solution of every captcha image sent on a bidirectional stream, in order, so that many captchas can be
+// pipelined over a single connection. Server implements the service, and Client calls it from Go:
+//
+//	srv, err := grpc.NewServer(nil)
+//	...
+//	s := googlegrpc.NewServer()
+//	grpc.RegisterSolverServer(s, srv)
+//	err = s.Serve(listener)
+//
+// Regenerate solver.pb.go and solver_grpc.pb.go with go generate after changing solver.proto.
+package grpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative solver.proto
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultMaxImageSize is the size limit of captcha images, in bytes, unless configured otherwise.
+// Amazon captchas are a few kilobytes large.
+const DefaultMaxImageSize = 1 << 20
+
+// DefaultMaxImagePixels is the limit of the number of pixels of captcha images, unless configured otherwise.
+// Amazon captchas have 200 by 70 pixels.
+const DefaultMaxImagePixels = 1 << 22
+
+// Server implements the Solver service with an amazoncaptcha Solver.
+type Server struct {
+	UnimplementedSolverServer
+	solver       *amazoncaptcha.Solver
+	maxImageSize int64
+	maxPixels    int
+}
+
+// ServerOption configures a Server.
+type ServerOption func(*Server) error
+
+// WithMaxImageSize sets the size limit of the images of requests, DefaultMaxImageSize by default.
+func WithMaxImageSize(size int64) ServerOption {
+	return func(s *Server) error {
+		if size <= 0 {
+			return errors.New("maximum image size must be positive")
+		}
+		s.maxImageSize = size
+		return nil
+	}
+}
+
+// WithMaxImagePixels sets the limit of the number of pixels of the images of requests, DefaultMaxImagePixels by
+// default. It is checked from the header of an image, before the image is decoded.
+func WithMaxImagePixels(pixels int) ServerOption {
+	return func(s *Server) error {
+		if pixels <= 0 {
+			return errors.New("maximum number of pixels must be positive")
+		}
+		s.maxPixels = pixels
+		return nil
+	}
+}
+
+// NewServer creates a Server solving captchas with solver, or with a Solver of the default configuration if nil,
+// configured by opts. Without options, it accepts images of up to DefaultMaxImageSize bytes and
+// DefaultMaxImagePixels pixels.
+func NewServer(solver *amazoncaptcha.Solver, opts ...ServerOption) (*Server, error) {
+	if solver == nil {
+		var err error
+		if solver, err = amazoncaptcha.NewSolver(); err != nil {
+			return nil, err
+		}
+	}
+	s := &Server{solver: solver, maxImageSize: DefaultMaxImageSize, maxPixels: DefaultMaxImagePixels}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Solve implements SolverServer. Empty images and images that are not captchas fail with codes.InvalidArgument,
+// images above the limits with codes.ResourceExhausted, and solves without training data with codes.Unavailable.
+func (s *Server) Solve(ctx context.Context, req *SolveRequest) (*Solution, error) {
+	solution, err := s.solve(req)
+	if err != nil {
+		return nil, status.Error(errorCode(err), err.Error())
+	}
+	return solution, nil
+}
+
+// errorCode returns the status code of the error of a solve.
+func errorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, amazoncaptcha.ErrNoTrainingData):
+		return codes.Unavailable
+	case errors.Is(err, amazoncaptcha.ErrImageTooLarge):
+		return codes.ResourceExhausted
+	default:
+		return codes.InvalidArgument
+	}
+}
+
+// SolveStream implements SolverServer. Unlike Solve, the images that cannot be solved are answered with a
+// Solution holding the error, so that a single bad image does not end the stream.
+func (s *Server) SolveStream(stream Solver_SolveStreamServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		solution, err := s.solve(req)
+		if err != nil {
+			solution = &Solution{Id: req.Id, Error: err.Error()}
+		}
+		if err := stream.Send(solution); err != nil {
+			return err
+		}
+	}
+}
+
+// solve solves the captcha image of a request, refusing images above the limits of the Server from their header,
+// before they are decoded.
+func (s *Server) solve(req *SolveRequest) (*Solution, error) {
+	if len(req.Image) == 0 {
+		return nil, errors.New("image must not be empty")
+	}
+	start := time.Now()
+	image, err := amazoncaptcha.LimitImageReader(bytes.NewReader(req.Image), s.maxImageSize, s.maxPixels)
+	if errors.Is(err, amazoncaptcha.ErrImageTooLarge) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("not a captcha: %w", err)
+	}
+	result, err := s.solver.SolveDetailed(image)
+	if errors.Is(err, amazoncaptcha.ErrNoTrainingData) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("not a captcha: %w", err)
+	}
+	return &Solution{
+		Id:               req.Id,
+		Text:             result.Text,
+		Confidence:       result.Confidence,
+		LetterConfidence: result.LetterConfidence,
+		Solved:           result.Solved,
+		Strategy:         result.Strategy,
+		DurationMs:       float64(time.Since(start)) / float64(time.Millisecond),
+	}, nil
+}
+
+// Client calls a Solver service.
+type Client struct {
+	conn   *grpc.ClientConn
+	client SolverClient
+}
+
+// Dial creates a Client of the Solver service at target, connecting with opts,
+// e.g. grpc.WithTransportCredentials(insecure.NewCredentials()) for a sidecar.
+func Dial(target string, opts ...grpc.DialOption) (*Client, error) {
+	conn, err := grpc.Dial(target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial solver: %w", err)
+	}
+	return &Client{conn: conn, client: NewSolverClient(conn)}, nil
+}
+
+// NewClient creates a Client of the Solver service over an existing connection, which Close leaves open.
+func NewClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{client: NewSolverClient(conn)}
+}
+
+// Close closes the connection created by Dial.
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// Solve solves a single captcha image.
+func (c *Client) Solve(ctx context.Context, image []byte) (*Solution, error) {
+	return c.client.Solve(ctx, &SolveRequest{Image: image})
+}
+
+// SolveAll solves captcha images over a single stream, sending them while the solutions are received, and
+// returns the solutions in the order of the images. The images that could not be solved have a Solution
+// holding the error; the returned error reports a failure of the stream itself.
+func (c *Client) SolveAll(ctx context.Context, images [][]byte) ([]*Solution, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.client.SolveStream(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream: %w", err)
+	}
+
+	// Send the images in the background, so that the server never waits for solutions to be received
+	sent := make(chan error, 1)
+	go func() {
+		for i, image := range images {
+			if err := stream.Send(&SolveRequest{Image: image, Id: fmt.Sprint(i)}); err != nil {
+				sent <- err
+				return
+			}
+		}
+		sent <- stream.CloseSend()
+	}()
+
+	solutions := make([]*Solution, 0, len(images))
+	for len(solutions) < len(images) {
+		solution, err := stream.Recv()
+		if err != nil {
+			return nil, fmt.Errorf("failed to receive solution: %w", err)
+		}
+		solutions = append(solutions, solution)
+	}
+	if err := <-sent; err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to send image: %w", err)
+	}
+	return solutions, nil
+}
@@ -0,0 +1,119 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"net"
+	"testing"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// renderCaptcha renders a captcha showing answer from letters of the training data as PNG.
+func renderCaptcha(t *testing.T, answer string) []byte {
+	t.Helper()
+	templates := amazoncaptcha.Templates(3)
+	width := 2
+	for _, c := range answer {
+		width += templates[string(c)][1].Bounds().Dx() + 2
+	}
+	img := image.NewGray(image.Rect(0, 0, width, amazoncaptcha.CaptchaHeight))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	offset := 2
+	for _, c := range answer {
+		letter := templates[string(c)][1]
+		for y := 0; y < letter.Bounds().Dy(); y++ {
+			copy(img.Pix[y*img.Stride+offset:], letter.Pix[y*letter.Stride:(y+1)*letter.Stride])
+		}
+		offset += letter.Bounds().Dx() + 2
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// startServer serves the Solver service with solver and opts in memory and returns a Client connected to it.
+func startServer(t *testing.T, solver *amazoncaptcha.Solver, opts ...ServerOption) *Client {
+	t.Helper()
+	srv, err := NewServer(solver, opts...)
+	assert.NoError(t, err)
+	listener := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	RegisterSolverServer(s, srv)
+	go func() { _ = s.Serve(listener) }()
+	t.Cleanup(s.Stop)
+
+	client, err := Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestSolve(t *testing.T) {
+	client := startServer(t, nil)
+
+	solution, err := client.Solve(context.Background(), renderCaptcha(t, "ABCEFG"))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", solution.Text)
+	assert.Equal(t, 1.0, solution.Confidence)
+	assert.True(t, solution.Solved)
+	assert.Len(t, solution.LetterConfidence, 6)
+
+	_, err = client.Solve(context.Background(), []byte("captcha"))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.Solve(context.Background(), nil)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestSolveLimits(t *testing.T) {
+	// Images above the limits are refused from their header
+	client := startServer(t, nil, WithMaxImagePixels(100))
+	_, err := client.Solve(context.Background(), renderCaptcha(t, "ABCEFG"))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	client = startServer(t, nil, WithMaxImageSize(100))
+	_, err = client.Solve(context.Background(), renderCaptcha(t, "ABCEFG"))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	_, err = NewServer(nil, WithMaxImageSize(0))
+	assert.Error(t, err)
+
+	// A solver without training data is unavailable, as opposed to the image being invalid
+	solver, err := amazoncaptcha.NewSolver()
+	if !assert.NoError(t, err) {
+		return
+	}
+	solver.SetTrainingData(map[string]string{})
+	client = startServer(t, solver)
+	_, err = client.Solve(context.Background(), renderCaptcha(t, "ABCEFG"))
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestSolveAll(t *testing.T) {
+	client := startServer(t, nil)
+
+	images := [][]byte{renderCaptcha(t, "ABCEFG"), []byte("captcha"), renderCaptcha(t, "HJKLMN")}
+	solutions, err := client.SolveAll(context.Background(), images)
+	assert.NoError(t, err)
+	assert.Len(t, solutions, 3)
+	assert.Equal(t, "0", solutions[0].Id)
+	assert.Equal(t, "ABCEFG", solutions[0].Text)
+	assert.Contains(t, solutions[1].Error, "not a captcha")
+	assert.Empty(t, solutions[1].Text)
+	assert.Equal(t, "2", solutions[2].Id)
+	assert.Equal(t, "HJKLMN", solutions[2].Text)
+
+	solutions, err = client.SolveAll(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Empty(t, solutions)
+}
@@ -0,0 +1,187 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: solver.proto
+
+package grpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Solver_Solve_FullMethodName       = "/amazoncaptcha.v1.Solver/Solve"
+	Solver_SolveStream_FullMethodName = "/amazoncaptcha.v1.Solver/SolveStream"
+)
+
+// SolverClient is the client API for Solver service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SolverClient interface {
+	// Solve solves a single captcha image. Images that are not captchas fail with the code INVALID_ARGUMENT.
+	Solve(ctx context.Context, in *SolveRequest, opts ...grpc.CallOption) (*Solution, error)
+	// SolveStream solves the captcha images sent on the stream and answers every request in order, so that
+	// clients can pipeline many captchas over a single stream. Images that are not captchas are answered with
+	// a Solution holding the error, rather than ending the stream.
+	SolveStream(ctx context.Context, opts ...grpc.CallOption) (Solver_SolveStreamClient, error)
+}
+
+type solverClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSolverClient(cc grpc.ClientConnInterface) SolverClient {
+	return &solverClient{cc}
+}
+
+func (c *solverClient) Solve(ctx context.Context, in *SolveRequest, opts ...grpc.CallOption) (*Solution, error) {
+	out := new(Solution)
+	err := c.cc.Invoke(ctx, Solver_Solve_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *solverClient) SolveStream(ctx context.Context, opts ...grpc.CallOption) (Solver_SolveStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Solver_ServiceDesc.Streams[0], Solver_SolveStream_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &solverSolveStreamClient{stream}
+	return x, nil
+}
+
+type Solver_SolveStreamClient interface {
+	Send(*SolveRequest) error
+	Recv() (*Solution, error)
+	grpc.ClientStream
+}
+
+type solverSolveStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *solverSolveStreamClient) Send(m *SolveRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *solverSolveStreamClient) Recv() (*Solution, error) {
+	m := new(Solution)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SolverServer is the server API for Solver service.
+// All implementations must embed UnimplementedSolverServer
+// for forward compatibility
+type SolverServer interface {
+	// Solve solves a single captcha image. Images that are not captchas fail with the code INVALID_ARGUMENT.
+	Solve(context.Context, *SolveRequest) (*Solution, error)
+	// SolveStream solves the captcha images sent on the stream and answers every request in order, so that
+	// clients can pipeline many captchas over a single stream. Images that are not captchas are answered with
+	// a Solution holding the error, rather than ending the stream.
+	SolveStream(Solver_SolveStreamServer) error
+	mustEmbedUnimplementedSolverServer()
+}
+
+// UnimplementedSolverServer must be embedded to have forward compatible implementations.
+type UnimplementedSolverServer struct {
+}
+
+func (UnimplementedSolverServer) Solve(context.Context, *SolveRequest) (*Solution, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Solve not implemented")
+}
+func (UnimplementedSolverServer) SolveStream(Solver_SolveStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method SolveStream not implemented")
+}
+func (UnimplementedSolverServer) mustEmbedUnimplementedSolverServer() {}
+
+// UnsafeSolverServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SolverServer will
+// result in compilation errors.
+type UnsafeSolverServer interface {
+	mustEmbedUnimplementedSolverServer()
+}
+
+func RegisterSolverServer(s grpc.ServiceRegistrar, srv SolverServer) {
+	s.RegisterService(&Solver_ServiceDesc, srv)
+}
+
+func _Solver_Solve_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SolveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SolverServer).Solve(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Solver_Solve_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SolverServer).Solve(ctx, req.(*SolveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Solver_SolveStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(SolverServer).SolveStream(&solverSolveStreamServer{stream})
+}
+
+type Solver_SolveStreamServer interface {
+	Send(*Solution) error
+	Recv() (*SolveRequest, error)
+	grpc.ServerStream
+}
+
+type solverSolveStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *solverSolveStreamServer) Send(m *Solution) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *solverSolveStreamServer) Recv() (*SolveRequest, error) {
+	m := new(SolveRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Solver_ServiceDesc is the grpc.ServiceDesc for Solver service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Solver_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "amazoncaptcha.v1.Solver",
+	HandlerType: (*SolverServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Solve",
+			Handler:    _Solver_Solve_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SolveStream",
+			Handler:       _Solver_SolveStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "solver.proto",
+}
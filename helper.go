@@ -5,16 +5,35 @@ import (
 	"compress/zlib"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"image"
 	"image/color"
 	"image/png"
+	"io"
 	"os"
+	"sort"
 )
 
 // Grayscale generates a grayscale version of an image.
 func Grayscale(img image.Image) *image.Gray {
+	return grayscale(img, nil)
+}
+
+// grayscale implements Grayscale, allocating the grayscale image from a.
+func grayscale(img image.Image, a *arena) *image.Gray {
 	// Create a new grayscale image with the same bounds as the input image
-	grayImg := image.NewGray(img.Bounds())
+	grayImg := a.newGray(img.Bounds())
+
+	// Convert RGBA images plane by plane with the active preprocessor
+	if rgba, ok := img.(*image.RGBA); ok {
+		preprocessor := activePreprocessor()
+		width, height := rgba.Bounds().Dx(), rgba.Bounds().Dy()
+		for y := 0; y < height; y++ {
+			src := rgba.Pix[y*rgba.Stride : y*rgba.Stride+4*width]
+			preprocessor.Luma(grayImg.Pix[y*grayImg.Stride:y*grayImg.Stride+width], src)
+		}
+		return grayImg
+	}
 
 	// Loop through each pixel in the image and set its value in the grayscale image
 	for x := 0; x < img.Bounds().Dx(); x++ {
@@ -31,31 +50,69 @@ func Grayscale(img image.Image) *image.Gray {
 // MonoChrome generates a monochrome (binary) version of a grayscale image.
 // The threshold parameter is used to determine which pixels are converted to black and which are converted to white.
 func MonoChrome(img *image.Gray, threshold uint8) *image.Gray {
+	return monoChrome(img, threshold, nil)
+}
+
+// monoChrome implements MonoChrome, allocating the monochrome image from a.
+func monoChrome(img *image.Gray, threshold uint8, a *arena) *image.Gray {
 
 	// Create a new grayscale image with the same bounds as the input image
 	bounds := img.Bounds()
-	grayImg := image.NewGray(bounds)
-
-	// Loop through each pixel in the image and set its value in the monochrome image
-	for x := bounds.Min.X; x < bounds.Max.X; x++ {
-		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-			// Get the grayscale value of the current pixel
-			grayValue := img.GrayAt(x, y).Y
-
-			// If the grayscale value is below the threshold, set the pixel to black (0)
-			// Otherwise, set the pixel to white (255)
-			if grayValue <= threshold {
-				grayImg.SetGray(x, y, color.Gray{Y: 0})
-			} else {
-				grayImg.SetGray(x, y, color.Gray{Y: 255})
-			}
-		}
+	grayImg := a.newGray(bounds)
+
+	// Threshold the image row by row with the active preprocessor: pixels at or below the threshold
+	// become black (0), the others white (255)
+	preprocessor := activePreprocessor()
+	width := bounds.Dx()
+	for y := 0; y < bounds.Dy(); y++ {
+		src := img.Pix[img.PixOffset(bounds.Min.X, bounds.Min.Y+y):]
+		preprocessor.Threshold(grayImg.Pix[y*grayImg.Stride:y*grayImg.Stride+width], src[:width], threshold)
 	}
 
 	// Return the monochrome image
 	return grayImg
 }
 
+// OtsuThreshold returns the binarization threshold of a grayscale image found by Otsu's method: the threshold
+// maximizing the variance between the pixels at or below it and those above it, so that pixels at or below the
+// threshold are the ink. Captchas drawn with lighter strokes or smoothed by JPEG compression get a higher
+// threshold than MonoWeight, keeping their anti-aliased edge pixels. Images with a single gray level return 0.
+func OtsuThreshold(img *image.Gray) uint8 {
+
+	// Build the histogram of the gray levels
+	var histogram [256]int
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for _, v := range img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)] {
+			histogram[v]++
+		}
+	}
+	total, sum := 0, 0.0
+	for v, n := range histogram {
+		total += n
+		sum += float64(v * n)
+	}
+
+	// Find the threshold with the highest between-class variance, the lowest one on ties
+	best, bestVariance := 0, 0.0
+	below, belowSum := 0, 0.0
+	for t := 0; t < 255; t++ {
+		below += histogram[t]
+		belowSum += float64(t * histogram[t])
+		above := total - below
+		if below == 0 || above == 0 {
+			continue
+		}
+		meanBelow := belowSum / float64(below)
+		meanAbove := (sum - belowSum) / float64(above)
+		variance := float64(below) * float64(above) * (meanBelow - meanAbove) * (meanBelow - meanAbove)
+		if variance > bestVariance {
+			best, bestVariance = t, variance
+		}
+	}
+	return uint8(best)
+}
+
 // CutTheWhite removes the white border from a grayscale image by cropping it.
 func CutTheWhite(img *image.Gray) *image.Gray {
 	// Get the bounds of the input image
@@ -148,17 +205,12 @@ func FindLetterBoxes(img *image.Gray, maxLength int) []image.Rectangle {
 	// Get the dimensions of the input image
 	width, height := img.Bounds().Dx(), img.Bounds().Dy()
 
-	// Create a boolean array to keep track of which columns have black pixels
+	// Create a boolean array to keep track of which columns have black pixels,
+	// and fill it with the active preprocessor
 	colHasBlack := make([]bool, width)
-
-	// Loop through each pixel in the image and update the colHasBlack array as needed
-	for x := 0; x < width; x++ {
-		for y := 0; y < height; y++ {
-			if img.GrayAt(x, y).Y == 0 {
-				colHasBlack[x] = true
-				break
-			}
-		}
+	if width > 0 && height > 0 {
+		plane := img.Pix[img.PixOffset(img.Bounds().Min.X, img.Bounds().Min.Y):]
+		activePreprocessor().ColumnInk(colHasBlack, plane, width, height, img.Stride)
 	}
 
 	// Initialize variables to keep track of letter boxes and the starting column of a potential letter
@@ -204,13 +256,84 @@ func FindLetterBoxes(img *image.Gray, maxLength int) []image.Rectangle {
 	return letterBoxes
 }
 
+// CompareLetters measures how alike two monochrome letter images are.
+// Both images are trimmed to their black pixels with CutTheWhite, so that the same letter cropped with
+// different margins compares equal, and the score is the intersection over union of their black pixels
+// aligned at the top-left corners of the trimmed letters: 1 means both letters cover exactly the same
+// pixels, 0 means they share none. Two letters without any black pixels are considered identical.
+func CompareLetters(a, b *image.Gray) float64 {
+	a, b = trimLetter(a), trimLetter(b)
+
+	// Determine the size of the area covered by both images
+	width, height := 0, 0
+	for _, img := range []*image.Gray{a, b} {
+		if img == nil {
+			continue
+		}
+		if img.Bounds().Dx() > width {
+			width = img.Bounds().Dx()
+		}
+		if img.Bounds().Dy() > height {
+			height = img.Bounds().Dy()
+		}
+	}
+
+	// isBlack reports whether the pixel at the aligned position is black, treating pixels outside the image as white
+	isBlack := func(img *image.Gray, x, y int) bool {
+		if img == nil || x >= img.Bounds().Dx() || y >= img.Bounds().Dy() {
+			return false
+		}
+		return img.GrayAt(img.Bounds().Min.X+x, img.Bounds().Min.Y+y).Y == 0
+	}
+
+	// Count the black pixels shared by both images and the black pixels of either image
+	intersection, union := 0, 0
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			blackA, blackB := isBlack(a, x, y), isBlack(b, x, y)
+			if blackA && blackB {
+				intersection++
+			}
+			if blackA || blackB {
+				union++
+			}
+		}
+	}
+
+	if union == 0 {
+		return 1
+	}
+	return float64(intersection) / float64(union)
+}
+
+// trimLetter returns a letter cut to its black pixels with CutTheWhite, or nil if it has none.
+func trimLetter(img *image.Gray) *image.Gray {
+	if img == nil {
+		return nil
+	}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if img.GrayAt(x, y).Y == 0 {
+				return CutTheWhite(img)
+			}
+		}
+	}
+	return nil
+}
+
 // ExtractFeatures extracts image features and returns a binary string.
 func ExtractFeatures(img *image.Gray) (string, error) {
+	return extractFeatures(img, nil)
+}
+
+// extractFeatures implements ExtractFeatures, allocating the intermediate binary string from a.
+func extractFeatures(img *image.Gray, a *arena) (string, error) {
 	// Get the dimensions of the input image
 	bounds := img.Bounds()
 
 	// Pre-allocate a byte slice with enough capacity for the binary string
-	binaryStr := make([]byte, 0, bounds.Dx()*bounds.Dy())
+	binaryStr := a.alloc(bounds.Dx() * bounds.Dy())[:0]
 
 	// Loop over each pixel in the image and append its binary value to the byte slice
 	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
@@ -228,22 +351,55 @@ func ExtractFeatures(img *image.Gray) (string, error) {
 	}
 
 	// Compress the binary string using zlib compression
+	compressedData, err := a.compress(binaryStr)
+	if err != nil {
+		return "", err
+	}
+
+	// Return the hexadecimal string representation of the compressed binary data
+	return hex.EncodeToString(compressedData), nil
+}
+
+// compressFeature compresses the binary string of a letter using zlib compression.
+func compressFeature(binaryStr []byte) ([]byte, error) {
 	compressedData := new(bytes.Buffer)
 	compressor, err := zlib.NewWriterLevel(compressedData, zlib.BestCompression)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	_, err = compressor.Write(binaryStr)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	err = compressor.Close()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	return compressedData.Bytes(), nil
+}
 
-	// Return the hexadecimal string representation of the compressed binary data
-	return hex.EncodeToString(compressedData.Bytes()), nil
+// decodeFeature reverses ExtractFeatures and returns the binary string of a feature,
+// one byte per pixel with '1' for black and '0' for white.
+func decodeFeature(feature string) ([]byte, error) {
+	// Decode the hexadecimal string back into the compressed binary data
+	compressed, err := hex.DecodeString(feature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid feature encoding: %w", err)
+	}
+
+	// Decompress the binary string
+	decompressor, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("invalid feature data: %w", err)
+	}
+	defer decompressor.Close()
+
+	binaryStr, err := io.ReadAll(decompressor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid feature data: %w", err)
+	}
+
+	return binaryStr, nil
 }
 
 // SaveGrayToPNG saves a grayscale image to a PNG file.
@@ -264,3 +420,136 @@ func SaveGrayToPNG(fileName string, img *image.Gray) error {
 	// Return nil to indicate success
 	return nil
 }
+
+// Despeckle removes the isolated black specks of a monochrome image in place: every group of black pixels
+// touching each other, including diagonally, of at most maxBlobSize pixels turns white. JPEG compression leaves
+// such specks around the letters, which otherwise create phantom letter columns during segmentation. Letters
+// are far larger than a few pixels, so a small maxBlobSize, e.g. 4, keeps them whole.
+func Despeckle(img *image.Gray, maxBlobSize int) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	black := func(p int) bool {
+		return img.Pix[img.PixOffset(bounds.Min.X+p%width, bounds.Min.Y+p/width)] == 0
+	}
+
+	// Collect the black pixels component by component with a depth-first flood fill
+	visited := make([]bool, width*height)
+	var blob, stack []int
+	for start := range visited {
+		if visited[start] || !black(start) {
+			continue
+		}
+		visited[start] = true
+		blob = blob[:0]
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			p := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			blob = append(blob, p)
+			px, py := p%width, p/width
+
+			// Visit the eight neighbors of the pixel
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := px+dx, py+dy
+					if nx < 0 || ny < 0 || nx >= width || ny >= height {
+						continue
+					}
+					n := ny*width + nx
+					if !visited[n] && black(n) {
+						visited[n] = true
+						stack = append(stack, n)
+					}
+				}
+			}
+		}
+
+		// Whiten the component if it is a speck
+		if len(blob) <= maxBlobSize {
+			for _, p := range blob {
+				img.Pix[img.PixOffset(bounds.Min.X+p%width, bounds.Min.Y+p/width)] = 255
+			}
+		}
+	}
+}
+
+// FindComponentBoxes finds characters in a monochrome captcha image by their connected components:
+// groups of black pixels touching each other, including diagonally. Components overlapping horizontally
+// by at least half the width of the narrower one are merged, so that letters broken into several strokes
+// stay whole. Like FindLetterBoxes, boxes wider than maxLength are split in two halves, and the boxes
+// are returned from left to right. Unlike FindLetterBoxes, the boxes are tight vertically.
+func FindComponentBoxes(img *image.Gray, maxLength int) []image.Rectangle {
+
+	// Get the dimensions of the input image
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// Label the black pixels component by component with a depth-first flood fill
+	visited := make([]bool, width*height)
+	var components []image.Rectangle
+	var stack []int
+	for start := range visited {
+		x, y := start%width, start/width
+		if visited[start] || img.GrayAt(bounds.Min.X+x, bounds.Min.Y+y).Y != 0 {
+			continue
+		}
+		visited[start] = true
+		box := image.Rect(x, y, x+1, y+1)
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			p := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			px, py := p%width, p/width
+			box = box.Union(image.Rect(px, py, px+1, py+1))
+
+			// Visit the eight neighbors of the pixel
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := px+dx, py+dy
+					if nx < 0 || ny < 0 || nx >= width || ny >= height {
+						continue
+					}
+					n := ny*width + nx
+					if !visited[n] && img.GrayAt(bounds.Min.X+nx, bounds.Min.Y+ny).Y == 0 {
+						visited[n] = true
+						stack = append(stack, n)
+					}
+				}
+			}
+		}
+		components = append(components, box)
+	}
+
+	// Merge the components overlapping horizontally, from left to right
+	sort.Slice(components, func(i, j int) bool { return components[i].Min.X < components[j].Min.X })
+	var merged []image.Rectangle
+	for _, c := range components {
+		if n := len(merged); n > 0 {
+			last := merged[n-1]
+			overlap := last.Max.X - c.Min.X
+			narrower := c.Dx()
+			if last.Dx() < narrower {
+				narrower = last.Dx()
+			}
+			if overlap*2 >= narrower {
+				merged[n-1] = last.Union(c)
+				continue
+			}
+		}
+		merged = append(merged, c)
+	}
+
+	// Split the boxes that are too wide to be a single letter
+	letterBoxes := make([]image.Rectangle, 0, len(merged))
+	for _, box := range merged {
+		if box.Dx() <= maxLength {
+			letterBoxes = append(letterBoxes, box)
+			continue
+		}
+		mid := (box.Min.X + box.Max.X) / 2
+		letterBoxes = append(letterBoxes, image.Rect(box.Min.X, box.Min.Y, mid, box.Max.Y))
+		letterBoxes = append(letterBoxes, image.Rect(mid, box.Min.Y, box.Max.X, box.Max.Y))
+	}
+
+	return letterBoxes
+}
@@ -0,0 +1,114 @@
+package amazoncaptcha
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareLetters(t *testing.T) {
+	a := image.NewGray(image.Rect(0, 0, 4, 4))
+	b := image.NewGray(image.Rect(0, 0, 6, 4))
+	for i := range a.Pix {
+		a.Pix[i] = 255
+	}
+	for i := range b.Pix {
+		b.Pix[i] = 255
+	}
+	assert.Equal(t, 1.0, CompareLetters(a, b))
+
+	a.SetGray(1, 1, color.Gray{Y: 0})
+	a.SetGray(2, 2, color.Gray{Y: 0})
+	assert.Equal(t, 1.0, CompareLetters(a, a))
+	assert.Equal(t, 0.0, CompareLetters(a, b))
+
+	b.SetGray(1, 1, color.Gray{Y: 0})
+	b.SetGray(5, 3, color.Gray{Y: 0})
+	assert.InDelta(t, 1.0/3.0, CompareLetters(a, b), 1e-9)
+	assert.Equal(t, CompareLetters(a, b), CompareLetters(b, a))
+	assert.Equal(t, 0.0, CompareLetters(a, nil))
+}
+
+func TestCompareLettersShifted(t *testing.T) {
+	// The same glyph cropped with different margins
+	glyph := func(width, height, dx, dy int) *image.Gray {
+		img := image.NewGray(image.Rect(0, 0, width, height))
+		for i := range img.Pix {
+			img.Pix[i] = 255
+		}
+		for y := 0; y < 8; y++ {
+			img.SetGray(dx, dy+y, color.Gray{Y: 0})
+			img.SetGray(dx+y/2, dy+y, color.Gray{Y: 0})
+		}
+		for x := 0; x < 5; x++ {
+			img.SetGray(dx+x, dy+4, color.Gray{Y: 0})
+		}
+		return img
+	}
+	a := glyph(10, 12, 0, 0)
+	assert.Equal(t, 1.0, CompareLetters(a, glyph(10, 12, 1, 0)))
+	assert.Equal(t, 1.0, CompareLetters(a, glyph(12, 12, 3, 2)))
+	assert.Equal(t, 1.0, CompareLetters(glyph(10, 12, 2, 1), a))
+
+	// A sub-image is compared by its own pixels
+	shifted := glyph(20, 20, 7, 5)
+	assert.Equal(t, 1.0, CompareLetters(a, shifted.SubImage(image.Rect(5, 3, 15, 15)).(*image.Gray)))
+}
+
+func TestOtsuThreshold(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 10, 10))
+	for i := range img.Pix {
+		img.Pix[i] = 200
+	}
+	assert.Equal(t, uint8(0), OtsuThreshold(img))
+
+	// The ink is separated from the background at its lightest gray level
+	for i := 0; i < 30; i++ {
+		img.Pix[i] = 40
+	}
+	assert.Equal(t, uint8(40), OtsuThreshold(img))
+	img.Pix[30] = 90
+	img.Pix[31] = 150
+	assert.Equal(t, uint8(90), OtsuThreshold(img))
+
+	// Only the pixels of a sub-image count
+	sub := img.SubImage(image.Rect(0, 5, 10, 10)).(*image.Gray)
+	assert.Equal(t, uint8(0), OtsuThreshold(sub))
+}
+
+func TestDespeckle(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 12, 8))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	ink := func(points ...image.Point) {
+		for _, p := range points {
+			img.SetGray(p.X, p.Y, color.Gray{Y: 0})
+		}
+	}
+	// A speck of two diagonal pixels, and a blob of 3x3 pixels
+	ink(image.Pt(1, 1), image.Pt(2, 2))
+	for y := 4; y < 7; y++ {
+		for x := 6; x < 9; x++ {
+			ink(image.Pt(x, y))
+		}
+	}
+	ink(image.Pt(11, 0))
+
+	Despeckle(img, 4)
+	assert.Equal(t, uint8(255), img.GrayAt(1, 1).Y)
+	assert.Equal(t, uint8(255), img.GrayAt(2, 2).Y)
+	assert.Equal(t, uint8(255), img.GrayAt(11, 0).Y)
+	assert.Equal(t, uint8(0), img.GrayAt(7, 5).Y)
+
+	Despeckle(img, 9)
+	assert.Equal(t, uint8(255), img.GrayAt(7, 5).Y)
+
+	// Only the pixels of a sub-image are despeckled
+	ink(image.Pt(1, 1), image.Pt(10, 6))
+	Despeckle(img.SubImage(image.Rect(5, 0, 12, 8)).(*image.Gray), 4)
+	assert.Equal(t, uint8(0), img.GrayAt(1, 1).Y)
+	assert.Equal(t, uint8(255), img.GrayAt(10, 6).Y)
+}
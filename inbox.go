@@ -0,0 +1,135 @@
+package amazoncaptcha
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// UnknownLetter describes a letter that could not be matched against the training data.
+type UnknownLetter struct {
+	// Image is the segmented letter image, a copy the sink may retain after CaptureLetter returns.
+	Image *image.Gray `json:"-"`
+	// Feature is the feature string extracted from Image.
+	Feature string `json:"feature"`
+	// Position is the index of the letter in the captcha.
+	Position int `json:"position"`
+	// Guess is the best-guess letter, or an empty string if no similar training entry exists.
+	Guess string `json:"guess"`
+	// Distance is the number of differing pixels between the letter and the training entry behind Guess.
+	Distance int `json:"distance"`
+	// Answer is the (partial) answer of the captcha the letter belongs to.
+	Answer string `json:"answer"`
+	// Time is the moment the letter was captured.
+	Time time.Time `json:"time"`
+}
+
+// LetterSink receives the letters that could not be recognized while solving a captcha.
+type LetterSink interface {
+	CaptureLetter(letter *UnknownLetter) error
+}
+
+// letterHandler adapts a function to a LetterSink receiving only the image and position of every unknown letter.
+type letterHandler func(letter *image.Gray, position int)
+
+// CaptureLetter implements LetterSink.
+func (h letterHandler) CaptureLetter(letter *UnknownLetter) error {
+	h(letter.Image, letter.Position)
+	return nil
+}
+
+// DirSink is a LetterSink that writes every unknown letter into a training inbox directory.
+// Letters are pre-bucketed by their best guess: each one is saved as a PNG image plus a JSON
+// metadata file in the sub-directory named after Guess, or "_" when there is no guess.
+type DirSink struct {
+	Dir string
+}
+
+// CaptureLetter saves the letter image and its metadata into the inbox directory.
+func (s *DirSink) CaptureLetter(letter *UnknownLetter) error {
+	bucket := letter.Guess
+	if bucket == "" {
+		bucket = "_"
+	}
+
+	// Create the bucket directory for the best-guess letter
+	dir := filepath.Join(s.Dir, bucket)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return fmt.Errorf("failed to create inbox directory: %w", err)
+	}
+
+	// Name the files after the feature so that repeated captures of the same letter overwrite each other
+	name := filepath.Join(dir, featureFileName(letter.Feature))
+
+	if err := SaveGrayToPNG(name+".png", letter.Image); err != nil {
+		return fmt.Errorf("failed to save letter image: %w", err)
+	}
+
+	metadata, err := json.MarshalIndent(letter, "", "	")
+	if err != nil {
+		return fmt.Errorf("failed to marshal letter metadata: %w", err)
+	}
+	if err := os.WriteFile(name+".json", metadata, 0644); err != nil {
+		return fmt.Errorf("failed to save letter metadata: %w", err)
+	}
+
+	return nil
+}
+
+// SetLetterSink enables the automatic capture of unknown letters into sink.
+// Passing nil disables the capture, which is the default.
+func SetLetterSink(sink LetterSink) {
+	defaultSolver().SetLetterSink(sink)
+}
+
+// SetLetterSink enables the automatic capture of unknown letters of the Solver into sink.
+func (s *Solver) SetLetterSink(sink LetterSink) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+	s.letterSink = sink
+}
+
+// captureUnknownLetters hands every unknown letter of a solved captcha to the sink.
+// The letter images are copied, since they belong to the arena of the solve.
+// Errors returned by the sink are ignored so that capturing never fails a solve.
+func captureUnknownLetters(sink LetterSink, m *model, letters []*image.Gray, matches []letterMatch, result *Result) {
+	now := time.Now()
+	for _, i := range result.unknown {
+		guess, distance := guessLetter(m, matches[i].feature)
+		_ = sink.CaptureLetter(&UnknownLetter{
+			Image:    cloneGray(letters[i]),
+			Feature:  matches[i].feature,
+			Position: i,
+			Guess:    guess,
+			Distance: distance,
+			Answer:   result.Text,
+			Time:     now,
+		})
+	}
+}
+
+// guessLetter returns the letter of the training entry closest to feature
+// and the number of pixels in which they differ.
+func guessLetter(m *model, feature string) (string, int) {
+	b, err := decodeBitmap(feature)
+	if err != nil {
+		return "", 0
+	}
+
+	neighbors := m.nearest(b, 1, -1)
+	if len(neighbors) == 0 {
+		return "", 0
+	}
+
+	return neighbors[0].entry.letter, neighbors[0].distance
+}
+
+// cloneGray returns a copy of img allocated from the heap.
+func cloneGray(img *image.Gray) *image.Gray {
+	clone := image.NewGray(img.Bounds())
+	copy(clone.Pix, img.Pix)
+	return clone
+}
@@ -0,0 +1,97 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type collectingSink struct {
+	mu      sync.Mutex
+	letters []*UnknownLetter
+}
+
+func (s *collectingSink) CaptureLetter(letter *UnknownLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.letters = append(s.letters, letter)
+	return nil
+}
+
+func TestSolveCapturesUnknownLetters(t *testing.T) {
+	sink := &collectingSink{}
+	SetLetterSink(sink)
+	defer SetLetterSink(nil)
+
+	// Flip a white pixel of the third letter so it no longer matches the training data
+	buf := bytes.NewReader(flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2))
+
+	result, err := Solve(buf)
+	assert.True(t, errors.Is(err, ErrUnrecognizedLetter))
+	assert.Equal(t, "-", result[2:3])
+
+	var captured *UnknownLetter
+	for _, letter := range sink.letters {
+		if letter.Position == 2 {
+			captured = letter
+		}
+	}
+	if assert.NotNil(t, captured) {
+		assert.Equal(t, "C", captured.Guess)
+		assert.Equal(t, 1, captured.Distance)
+		assert.Equal(t, result, captured.Answer)
+
+		// The captured image is not recycled by later solves
+		pixels := append([]byte(nil), captured.Image.Pix...)
+		_, err := Solve(bytes.NewReader(syntheticCaptcha(t, "HJKLMN")))
+		assert.NoError(t, err)
+		assert.Equal(t, pixels, captured.Image.Pix)
+	}
+}
+
+func TestWithUnknownLetterHandler(t *testing.T) {
+	var positions []int
+	var images []*image.Gray
+	s, err := NewSolver(WithUnknownLetterHandler(func(letter *image.Gray, position int) {
+		positions = append(positions, position)
+		images = append(images, letter)
+	}))
+	assert.NoError(t, err)
+
+	_, err = s.Solve(bytes.NewReader(flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 4)))
+	assert.True(t, errors.Is(err, ErrUnrecognizedLetter))
+	assert.Equal(t, []int{4}, positions)
+	if assert.Len(t, images, 1) {
+		assert.NotZero(t, countInk(images[0]))
+	}
+}
+
+func TestDirSink(t *testing.T) {
+	dir := t.TempDir()
+	feature, _ := trainingLetter(t, "K")
+	letter := &UnknownLetter{
+		Image:   image.NewGray(image.Rect(0, 0, 20, 70)),
+		Feature: feature,
+		Guess:   "K",
+	}
+
+	sink := &DirSink{Dir: dir}
+	assert.NoError(t, sink.CaptureLetter(letter))
+	assert.NoError(t, sink.CaptureLetter(&UnknownLetter{Image: letter.Image, Feature: "00"}))
+
+	pngs, err := filepath.Glob(filepath.Join(dir, "K", "*.png"))
+	assert.NoError(t, err)
+	assert.Len(t, pngs, 1)
+	_, err = os.Stat(pngs[0][:len(pngs[0])-4] + ".json")
+	assert.NoError(t, err)
+
+	pngs, err = filepath.Glob(filepath.Join(dir, "_", "*.png"))
+	assert.NoError(t, err)
+	assert.Len(t, pngs, 1)
+}
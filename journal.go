@@ -0,0 +1,118 @@
+package amazoncaptcha
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// JournalEntry records a solve, or the outcome reported for a previous solve.
+type JournalEntry struct {
+	// Time is the moment the solve started or the outcome was reported.
+	Time time.Time `json:"time"`
+	// ImageHash identifies the captcha image, see ImageHash.
+	ImageHash string `json:"image_hash"`
+	// Answer is the solved text.
+	Answer string `json:"answer,omitempty"`
+	// Confidence is the confidence of the answer, between 0 and 1.
+	Confidence float64 `json:"confidence"`
+	// DurationMS is the time the solve took, in milliseconds.
+	DurationMS float64 `json:"duration_ms,omitempty"`
+	// Error is the error returned by the solve, if any.
+	Error string `json:"error,omitempty"`
+	// Accepted is the outcome reported for the answer, nil for solve records.
+	Accepted *bool `json:"accepted,omitempty"`
+}
+
+// Journal stores a record of every solve, e.g. for offline accuracy audits
+// or mining production traffic for new training data.
+type Journal interface {
+	Record(entry *JournalEntry) error
+}
+
+// JSONLJournal is a Journal that appends one JSON object per line to a writer.
+// It is safe for concurrent use.
+type JSONLJournal struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLJournal creates a JSONLJournal writing to w.
+func NewJSONLJournal(w io.Writer) *JSONLJournal {
+	return &JSONLJournal{w: w}
+}
+
+// OpenJSONLJournal opens the file at path for appending, creating it if necessary,
+// and returns a JSONLJournal writing to it. The journal must be closed after use.
+func OpenJSONLJournal(path string) (*JSONLJournal, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	return NewJSONLJournal(file), nil
+}
+
+// Close closes the underlying writer if it implements io.Closer.
+func (j *JSONLJournal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if closer, ok := j.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Record appends the entry to the journal as a single line.
+func (j *JSONLJournal) Record(entry *JournalEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.w.Write(line); err != nil {
+		return fmt.Errorf("failed to write journal entry: %w", err)
+	}
+	return nil
+}
+
+// SetJournal enables recording every solve into j. Passing nil disables the journal, which is the default.
+func SetJournal(j Journal) {
+	defaultSolver().SetJournal(j)
+}
+
+// SetJournal enables recording every solve of the Solver into j.
+func (s *Solver) SetJournal(j Journal) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+	s.journal = j
+}
+
+// ImageHash returns the hash identifying a captcha image in the journal: the hex encoded SHA-256 of its bytes.
+func ImageHash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// recordSolve records a solve into the journal. Errors returned by the journal are ignored
+// so that journaling never fails a solve.
+func recordSolve(j Journal, hash string, start time.Time, answer string, confidence float64, err error) {
+	entry := &JournalEntry{
+		Time:       start,
+		ImageHash:  hash,
+		Answer:     answer,
+		Confidence: confidence,
+		DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	_ = j.Record(entry)
+}
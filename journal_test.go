@@ -0,0 +1,58 @@
+package amazoncaptcha
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournal(t *testing.T) {
+	var buf bytes.Buffer
+	SetJournal(NewJSONLJournal(&buf))
+	defer SetJournal(nil)
+
+	captcha := syntheticCaptcha(t, "KLMNPR")
+	result, err := Solve(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	_, err = SolveBestEffort(context.Background(), bytes.NewReader(captcha))
+	assert.NoError(t, err)
+
+	scanner := bufio.NewScanner(&buf)
+	var entries []JournalEntry
+	for scanner.Scan() {
+		var entry JournalEntry
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	if assert.Len(t, entries, 2) {
+		for _, entry := range entries {
+			assert.Equal(t, ImageHash(captcha), entry.ImageHash)
+			assert.Equal(t, result, entry.Answer)
+			assert.Equal(t, 1.0, entry.Confidence)
+			assert.Nil(t, entry.Accepted)
+		}
+	}
+}
+
+func TestOpenJSONLJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	journal, err := OpenJSONLJournal(path)
+	assert.NoError(t, err)
+	assert.NoError(t, journal.Record(&JournalEntry{ImageHash: "a"}))
+	assert.NoError(t, journal.Close())
+
+	journal, err = OpenJSONLJournal(path)
+	assert.NoError(t, err)
+	assert.NoError(t, journal.Record(&JournalEntry{ImageHash: "b"}))
+	assert.NoError(t, journal.Close())
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, bytes.Count(b, []byte("\n")))
+}
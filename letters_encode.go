@@ -0,0 +1,56 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image/png"
+	"io"
+)
+
+// FindLettersPNG works like FindLetters but returns every letter already encoded as a PNG image,
+// ready to be shipped over the network or displayed by a web frontend.
+func FindLettersPNG(r io.Reader) ([][]byte, error) {
+	return defaultSolver().FindLettersPNG(r)
+}
+
+// FindLettersPNG works like the package-level FindLettersPNG, using the configuration of the Solver.
+func (s *Solver) FindLettersPNG(r io.Reader) ([][]byte, error) {
+	letters, err := s.FindLetters(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Encode each letter image as a PNG
+	encoded := make([][]byte, len(letters))
+	for i, letter := range letters {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, letter); err != nil {
+			return nil, fmt.Errorf("failed to encode letter %d: %w", i, err)
+		}
+		encoded[i] = buf.Bytes()
+	}
+
+	return encoded, nil
+}
+
+// FindLettersBase64 works like FindLettersPNG but returns every letter as a base64 encoded
+// "data:image/png;base64,..." URI that can be used directly as the src of an <img> tag.
+func FindLettersBase64(r io.Reader) ([]string, error) {
+	return defaultSolver().FindLettersBase64(r)
+}
+
+// FindLettersBase64 works like the package-level FindLettersBase64, using the configuration of the Solver.
+func (s *Solver) FindLettersBase64(r io.Reader) ([]string, error) {
+	encoded, err := s.FindLettersPNG(r)
+	if err != nil {
+		return nil, err
+	}
+
+	uris := make([]string, len(encoded))
+	for i, b := range encoded {
+		uris[i] = "data:image/png;base64," + base64.StdEncoding.EncodeToString(b)
+	}
+
+	return uris, nil
+}
@@ -0,0 +1,45 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindLettersPNG(t *testing.T) {
+	captcha := syntheticCaptcha(t, "HJKLMN")
+
+	letters, err := FindLetters(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+
+	encoded, err := FindLettersPNG(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Len(t, encoded, len(letters))
+	for i, b := range encoded {
+		img, err := png.Decode(bytes.NewReader(b))
+		assert.NoError(t, err)
+		assert.Equal(t, letters[i], img.(*image.Gray))
+	}
+}
+
+func TestFindLettersBase64(t *testing.T) {
+	captcha := syntheticCaptcha(t, "HJKLMN")
+
+	encoded, err := FindLettersPNG(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+
+	uris, err := FindLettersBase64(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Len(t, uris, len(encoded))
+	for i, uri := range uris {
+		assert.True(t, strings.HasPrefix(uri, "data:image/png;base64,"))
+		b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(uri, "data:image/png;base64,"))
+		assert.NoError(t, err)
+		assert.Equal(t, encoded[i], b)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build go1.23
+
+package amazoncaptcha
+
+import (
+	"image"
+	"io"
+	"iter"
+)
+
+// Letters returns an iterator over the letters of a captcha image, yielding each letter's
+// position and grayscale image. Unlike FindLetters, letters are cropped lazily as the
+// iteration proceeds, so breaking out of the loop early skips the remaining work.
+// If the image cannot be decoded or its letters cannot be segmented, the iterator yields nothing.
+// The yielded images are owned by the caller and may be retained after the iteration.
+func Letters(r io.Reader) iter.Seq2[int, *image.Gray] {
+	return defaultSolver().Letters(r)
+}
+
+// Letters works like the package-level Letters, using the configuration of the Solver.
+func (s *Solver) Letters(r io.Reader) iter.Seq2[int, *image.Gray] {
+	return func(yield func(int, *image.Gray) bool) {
+		grayImg, letterBoxes, err := s.locateLetters(r, nil)
+		if err != nil {
+			return
+		}
+		_ = s.walkLetters(grayImg, letterBoxes, nil, yield)
+	}
+}
@@ -0,0 +1,38 @@
+//go:build go1.23
+
+package amazoncaptcha
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLetters(t *testing.T) {
+	captcha := syntheticCaptcha(t, "KNXMTB")
+
+	letters, err := FindLetters(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+
+	var yielded []*image.Gray
+	for i, letter := range Letters(bytes.NewReader(captcha)) {
+		assert.Equal(t, len(yielded), i)
+		yielded = append(yielded, letter)
+	}
+	assert.Equal(t, letters, yielded)
+
+	// Stop after the first letter
+	count := 0
+	for range Letters(bytes.NewReader(captcha)) {
+		count++
+		break
+	}
+	assert.Equal(t, 1, count)
+
+	// Undecodable input yields nothing
+	for range Letters(bytes.NewReader([]byte("not an image"))) {
+		t.Fatal("unexpected letter")
+	}
+}
@@ -0,0 +1,103 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+)
+
+// ErrImageTooLarge is matched by the errors returned for images above the size limits of WithMaxImageSize or
+// LimitImageReader.
+var ErrImageTooLarge = errors.New("image too large")
+
+// LimitImageReader bounds how much of an image is read and buffered before it is decoded. It reads only the
+// header of the image from r, just enough to decode its format and dimensions, and fails with ErrImageTooLarge
+// if the image has more than maxPixels pixels, before the rest of the image is read. The returned reader replays
+// the header and streams the rest of the image, failing with ErrImageTooLarge once more than maxBytes bytes were
+// read in total. A limit of 0 disables it. An error is also returned if the header cannot be decoded.
+//
+// Servers can check uploads with it as they arrive, so that slow or oversized uploads are refused early instead
+// of pinning large buffers.
+func LimitImageReader(r io.Reader, maxBytes int64, maxPixels int) (io.Reader, error) {
+	limited, _, err := limitImage(r, maxBytes, maxPixels)
+	return limited, err
+}
+
+// limitImage implements LimitImageReader, also returning a function reporting whether the byte limit was
+// exceeded, since decoders do not always pass the errors of their readers on.
+func limitImage(r io.Reader, maxBytes int64, maxPixels int) (io.Reader, func() bool, error) {
+	lr := &limitedReader{r: r, n: maxBytes, max: maxBytes}
+	if maxBytes <= 0 {
+		lr.n = -1
+	}
+
+	// Decode the header, keeping the bytes read to replay them
+	var header bytes.Buffer
+	config, _, err := image.DecodeConfig(io.TeeReader(lr, &header))
+	if lr.exceeded {
+		return nil, nil, lr.err()
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("error decoding image: %v", err)
+	}
+	if maxPixels > 0 && config.Width*config.Height > maxPixels {
+		return nil, nil, fmt.Errorf("%w: %dx%d pixels exceed the maximum of %d pixels", ErrImageTooLarge, config.Width, config.Height, maxPixels)
+	}
+
+	exceeded := func() bool { return lr.exceeded }
+	return io.MultiReader(&header, lr), exceeded, nil
+}
+
+// readImage reads a whole image from r, e.g. to hash it for the journal and outcome reports. If the Solver
+// limits the bytes of images, no more than one byte above the limit is read, the image failing with
+// ErrImageTooLarge instead.
+func (s *Solver) readImage(r io.Reader) ([]byte, error) {
+	if s.maxImageBytes > 0 {
+		r = &limitedReader{r: r, n: s.maxImageBytes, max: s.maxImageBytes}
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	return b, nil
+}
+
+// limitedReader reads at most max bytes from r, failing with ErrImageTooLarge if there are more. n is the
+// number of bytes left, negative if there is no limit.
+type limitedReader struct {
+	r        io.Reader
+	n        int64
+	max      int64
+	exceeded bool
+}
+
+// Read implements io.Reader.
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.exceeded {
+		return 0, l.err()
+	}
+	if l.n < 0 {
+		return l.r.Read(p)
+	}
+
+	// Read one byte more than allowed to tell an image of exactly n bytes from a larger one
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.n {
+		l.exceeded = true
+		n = int(l.n)
+		l.n = 0
+		return n, l.err()
+	}
+	l.n -= int64(n)
+	return n, err
+}
+
+// err returns the error of an image above the byte limit.
+func (l *limitedReader) err() error {
+	return fmt.Errorf("%w: more than %d bytes", ErrImageTooLarge, l.max)
+}
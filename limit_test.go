@@ -0,0 +1,101 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slowReader returns one byte per read and counts the bytes read.
+type slowReader struct {
+	b    []byte
+	read int
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if r.read >= len(r.b) {
+		return 0, io.EOF
+	}
+	p[0] = r.b[r.read]
+	r.read++
+	return 1, nil
+}
+
+func TestLimitImageReader(t *testing.T) {
+	captcha := syntheticCaptcha(t, "ABCEFG")
+
+	// Images within the limits are replayed completely
+	r, err := LimitImageReader(bytes.NewReader(captcha), int64(len(captcha)), 200*CaptchaHeight)
+	if assert.NoError(t, err) {
+		b, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, captcha, b)
+	}
+
+	// Images with too many pixels are refused after reading their header only
+	slow := &slowReader{b: captcha}
+	_, err = LimitImageReader(slow, 0, 100)
+	assert.True(t, errors.Is(err, ErrImageTooLarge))
+	assert.Less(t, slow.read, 100)
+
+	// Images with too many bytes fail while streaming, or already while reading the header of small images
+	r, err = LimitImageReader(bytes.NewReader(captcha), int64(len(captcha))-1, 0)
+	if err == nil {
+		_, err = io.ReadAll(r)
+	}
+	assert.True(t, errors.Is(err, ErrImageTooLarge))
+	large := noisyPNG(t, 400, 140)
+	r, err = LimitImageReader(bytes.NewReader(large), int64(len(large))-1, 0)
+	if assert.NoError(t, err) {
+		_, err = io.ReadAll(r)
+		assert.True(t, errors.Is(err, ErrImageTooLarge))
+	}
+
+	// Headers that cannot be decoded fail
+	_, err = LimitImageReader(bytes.NewReader([]byte("not an image")), 0, 0)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrImageTooLarge))
+}
+
+func TestWithMaxImageSize(t *testing.T) {
+	captcha := syntheticCaptcha(t, "ABCEFG")
+
+	s, err := NewSolver(WithMaxImageSize(int64(len(captcha)), 0))
+	assert.NoError(t, err)
+	answer, err := s.Solve(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", answer)
+
+	s, err = NewSolver(WithMaxImageSize(int64(len(captcha))/2, 0))
+	assert.NoError(t, err)
+	_, err = s.Solve(bytes.NewReader(captcha))
+	assert.True(t, errors.Is(err, ErrImageTooLarge))
+
+	// Larger inputs are not read past the limit before they fail
+	for name, solve := range map[string]func(r io.Reader) error{
+		"Solve":         func(r io.Reader) error { _, err := s.Solve(r); return err },
+		"SolveDetailed": func(r io.Reader) error { _, err := s.SolveDetailed(r); return err },
+		"SolveBestEffort": func(r io.Reader) error {
+			_, err := s.SolveBestEffort(context.Background(), r)
+			return err
+		},
+		"SolveTopK": func(r io.Reader) error { _, err := s.SolveTopK(r, 2); return err },
+	} {
+		slow := &slowReader{b: append(captcha, make([]byte, 1<<20)...)}
+		err := solve(slow)
+		assert.True(t, errors.Is(err, ErrImageTooLarge), name)
+		assert.Equal(t, len(captcha)/2+1, slow.read, name)
+	}
+
+	s, err = NewSolver(WithMaxImageSize(0, 100))
+	assert.NoError(t, err)
+	_, err = s.Solve(bytes.NewReader(captcha))
+	assert.True(t, errors.Is(err, ErrImageTooLarge))
+
+	_, err = NewSolver(WithMaxImageSize(-1, 0))
+	assert.Error(t, err)
+}
@@ -0,0 +1,65 @@
+package amazoncaptcha
+
+import "image"
+
+// montageBorder is the gray level of the lines separating the letters of a montage, distinct from the black
+// and white of binarized letters.
+const montageBorder = 160
+
+// Montage tiles letter images into a single image of cols columns, e.g. to review the segmentation of a
+// captcha at a glance. Every letter is centered in a white cell as large as the largest letter, and the cells
+// are separated by one-pixel gray lines, so that the bounds of every letter stay visible. With cols below one,
+// all letters are placed in a single row; nil letters leave their cells empty. Without letters, an empty
+// image is returned.
+func Montage(letters []*image.Gray, cols int) *image.Gray {
+	if len(letters) == 0 {
+		return image.NewGray(image.Rectangle{})
+	}
+	if cols < 1 || cols > len(letters) {
+		cols = len(letters)
+	}
+	rows := (len(letters) + cols - 1) / cols
+
+	// Size the cells after the largest letter
+	cellWidth, cellHeight := 1, 1
+	for _, letter := range letters {
+		if letter == nil {
+			continue
+		}
+		if size := letter.Bounds().Size(); size.X > cellWidth {
+			cellWidth = size.X
+		}
+		if size := letter.Bounds().Size(); size.Y > cellHeight {
+			cellHeight = size.Y
+		}
+	}
+
+	// Draw the grid lines over a white background
+	montage := image.NewGray(image.Rect(0, 0, cols*(cellWidth+1)+1, rows*(cellHeight+1)+1))
+	for y := 0; y < montage.Rect.Dy(); y++ {
+		for x := 0; x < montage.Rect.Dx(); x++ {
+			if x%(cellWidth+1) == 0 || y%(cellHeight+1) == 0 {
+				montage.Pix[y*montage.Stride+x] = montageBorder
+			} else {
+				montage.Pix[y*montage.Stride+x] = 255
+			}
+		}
+	}
+
+	// Copy every letter into the center of its cell
+	for i, letter := range letters {
+		if letter == nil {
+			continue
+		}
+		bounds := letter.Bounds()
+		left := (i%cols)*(cellWidth+1) + 1 + (cellWidth-bounds.Dx())/2
+		top := (i/cols)*(cellHeight+1) + 1 + (cellHeight-bounds.Dy())/2
+		for y := 0; y < bounds.Dy(); y++ {
+			src := letter.PixOffset(bounds.Min.X, bounds.Min.Y+y)
+			dst := montage.PixOffset(left, top+y)
+			copy(montage.Pix[dst:dst+bounds.Dx()], letter.Pix[src:src+bounds.Dx()])
+		}
+	}
+
+	return montage
+}
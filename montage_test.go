@@ -0,0 +1,50 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMontage(t *testing.T) {
+	black := func(width, height int) *image.Gray {
+		return image.NewGray(image.Rect(0, 0, width, height))
+	}
+
+	// Three letters in two columns fill two rows of cells as large as the largest letter
+	m := Montage([]*image.Gray{black(4, 6), black(2, 6), black(4, 4)}, 2)
+	assert.Equal(t, image.Pt(2*5+1, 2*7+1), m.Bounds().Size())
+	assert.Equal(t, uint8(montageBorder), m.GrayAt(0, 0).Y)
+	assert.Equal(t, uint8(montageBorder), m.GrayAt(5, 3).Y)
+
+	// Letters are centered in their cells
+	assert.Equal(t, uint8(0), m.GrayAt(1, 1).Y)
+	assert.Equal(t, uint8(255), m.GrayAt(6, 1).Y)
+	assert.Equal(t, uint8(0), m.GrayAt(7, 1).Y)
+	assert.Equal(t, uint8(255), m.GrayAt(1, 8).Y)
+	assert.Equal(t, uint8(0), m.GrayAt(1, 9).Y)
+
+	// The last cell of the grid stays empty
+	assert.Equal(t, uint8(255), m.GrayAt(7, 10).Y)
+	assert.Equal(t, 6*4+6*2+4*4, countInk(m))
+
+	// Without a column count, the letters form a single row
+	assert.Equal(t, image.Pt(3*5+1, 6+2), Montage([]*image.Gray{black(4, 6), nil, black(2, 2)}, 0).Bounds().Size())
+	assert.True(t, Montage(nil, 3).Bounds().Empty())
+}
+
+func TestMontageOfCaptcha(t *testing.T) {
+	letters, err := FindLetters(bytes.NewReader(syntheticCaptcha(t, "ABCEFG")))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The montage holds the ink of all letters
+	ink := 0
+	for _, letter := range letters {
+		ink += countInk(letter)
+	}
+	assert.Equal(t, ink, countInk(Montage(letters, 3)))
+}
@@ -0,0 +1,137 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"image"
+	"sort"
+)
+
+// normalization selects the normalizations applied to every letter before its feature is extracted.
+// Letters are normalized the same way at training and recognition time: the training data is normalized
+// when first used by a Solver that normalizes letters.
+type normalization struct {
+	// stroke maps the strokes of the letter to StrokeWidth, see NormalizeStroke.
+	stroke bool
+	// align centers the letter on a fixed canvas, see AlignLetter.
+	align bool
+	// grid scales the letter to a fixed grid, see NormalizeGrid.
+	grid bool
+}
+
+// enabled reports whether any normalization is selected.
+func (n normalization) enabled() bool {
+	return n.stroke || n.align || n.grid
+}
+
+// apply normalizes a letter, allocating the intermediate images from a. Strokes are normalized first,
+// since they move the centroid of the letter slightly. Letters are scaled to the grid last, which crops
+// them to their ink and so undoes any alignment.
+func (n normalization) apply(letter *image.Gray, a *arena) *image.Gray {
+	if n.stroke {
+		letter = normalizeStroke(letter, a)
+	}
+	if n.align {
+		letter = alignLetter(letter, a)
+	}
+	if n.grid {
+		letter = normalizeGrid(letter, a)
+	}
+	return letter
+}
+
+// normalized returns the training data of the model with every letter normalized by n, building it on
+// first use. Entries whose letters are the same once normalized are merged; if they disagree on the letter,
+// the letter of most entries wins, ties broken alphabetically.
+//
+// The normalized features are compressed at the default level rather than the best one, which is an order
+// of magnitude faster for the whole training data. Their strings thus differ from those of ExtractFeatures,
+// and lookups match them by their decoded pixels, like training data produced by another zlib implementation.
+func (m *model) normalized(n normalization) *model {
+	m.normalizedMu.Lock()
+	defer m.normalizedMu.Unlock()
+	if normalized, ok := m.normalizedModels[n]; ok {
+		return normalized
+	}
+
+	var compressed bytes.Buffer
+	compressor := zlib.NewWriter(&compressed)
+	var binaryStr []byte
+	encode := func(img *image.Gray) (string, error) {
+		binaryStr = binaryStr[:0]
+		for _, p := range img.Pix {
+			if p == 0 {
+				binaryStr = append(binaryStr, '1')
+			} else {
+				binaryStr = append(binaryStr, '0')
+			}
+		}
+		compressed.Reset()
+		compressor.Reset(&compressed)
+		if _, err := compressor.Write(binaryStr); err != nil {
+			return "", err
+		}
+		if err := compressor.Close(); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(compressed.Bytes()), nil
+	}
+
+	votes := make(map[string]map[string]int, len(m.features))
+	for k, v := range m.features {
+		binaryStr, err := decodeFeature(k)
+		if err != nil {
+			continue
+		}
+		letter := featureImage(binaryStr, CaptchaHeight)
+		if letter == nil {
+			continue
+		}
+		feature, err := encode(n.apply(letter, nil))
+		if err != nil {
+			continue
+		}
+		if votes[feature] == nil {
+			votes[feature] = make(map[string]int)
+		}
+		votes[feature][v]++
+	}
+
+	features := make(map[string]string, len(votes))
+	for feature, letters := range votes {
+		candidates := make([]string, 0, len(letters))
+		for letter := range letters {
+			candidates = append(candidates, letter)
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			if letters[candidates[i]] != letters[candidates[j]] {
+				return letters[candidates[i]] > letters[candidates[j]]
+			}
+			return candidates[i] < candidates[j]
+		})
+		features[feature] = candidates[0]
+	}
+
+	normalized := &model{features: features}
+	if m.normalizedModels == nil {
+		m.normalizedModels = make(map[normalization]*model)
+	}
+	m.normalizedModels[n] = normalized
+	return normalized
+}
+
+// featureImage converts a binary string as produced by ExtractFeatures back into a monochrome letter image
+// of the given height, or returns nil if the binary string does not describe such an image.
+func featureImage(binaryStr []byte, height int) *image.Gray {
+	if height <= 0 || len(binaryStr)%height != 0 {
+		return nil
+	}
+	img := image.NewGray(image.Rect(0, 0, len(binaryStr)/height, height))
+	for i, c := range binaryStr {
+		if c != '1' {
+			img.Pix[i] = 255
+		}
+	}
+	return img
+}
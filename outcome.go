@@ -0,0 +1,189 @@
+package amazoncaptcha
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Stats aggregates the solves performed by a Solver and the outcomes reported for them.
+type Stats struct {
+	// Solves is the number of solve attempts.
+	Solves uint64
+	// Failures is the number of solves that returned an error.
+	Failures uint64
+	// Solved is the number of answers in which every letter was recognized.
+	Solved uint64
+	// Accepted is the number of answers reported as accepted.
+	Accepted uint64
+	// Rejected is the number of answers reported as rejected.
+	Rejected uint64
+	// Duplicates is the number of answers flagged by the duplicate-answer guard, see SetDuplicateGuard.
+	Duplicates uint64
+	// Letters holds the reported outcomes per answer letter.
+	Letters map[string]LetterStats
+}
+
+// LetterStats counts the reported outcomes of the answers containing a letter.
+type LetterStats struct {
+	Accepted uint64
+	Rejected uint64
+}
+
+// Accuracy returns the fraction of accepted answers among the reported answers containing the letter,
+// or 0 if no outcome was reported.
+func (s LetterStats) Accuracy() float64 {
+	if s.Accepted+s.Rejected == 0 {
+		return 0
+	}
+	return float64(s.Accepted) / float64(s.Accepted+s.Rejected)
+}
+
+// recentSolvesSize is the number of solves remembered for outcome reports.
+const recentSolvesSize = 4096
+
+// outcomeTracker keeps the statistics and remembers the answers of the most recent solves,
+// so that outcomes reported by image hash can be related to their answers.
+type outcomeTracker struct {
+	mu     sync.Mutex
+	stats  Stats
+	solves map[string]recentSolve
+	order  []string
+	next   int
+}
+
+// recentSolve is a remembered solve.
+type recentSolve struct {
+	answer string
+	// fuzzy maps the features of the letters recognized by approximate matching to their letters.
+	fuzzy map[string]string
+}
+
+// remember records the answer of a solve.
+func (t *outcomeTracker) remember(hash string, result *Result, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stats.Solves++
+	if err != nil {
+		t.stats.Failures++
+		return
+	}
+	if result.Solved {
+		t.stats.Solved++
+	}
+
+	// Forget the oldest answer once the ring of recent solves is full
+	if t.solves == nil {
+		t.solves = make(map[string]recentSolve, recentSolvesSize)
+		t.order = make([]string, recentSolvesSize)
+	}
+	if _, ok := t.solves[hash]; !ok {
+		delete(t.solves, t.order[t.next])
+		t.order[t.next] = hash
+		t.next = (t.next + 1) % recentSolvesSize
+	}
+	t.solves[hash] = recentSolve{answer: result.Text, fuzzy: result.fuzzy}
+}
+
+// report records the outcome of a solve and returns the solve, if it is still remembered.
+func (t *outcomeTracker) report(hash string, accepted bool) recentSolve {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if accepted {
+		t.stats.Accepted++
+	} else {
+		t.stats.Rejected++
+	}
+
+	solve := t.solves[hash]
+	if t.stats.Letters == nil {
+		t.stats.Letters = make(map[string]LetterStats)
+	}
+	for _, c := range solve.answer {
+		letter := t.stats.Letters[string(c)]
+		if accepted {
+			letter.Accepted++
+		} else {
+			letter.Rejected++
+		}
+		t.stats.Letters[string(c)] = letter
+	}
+
+	return solve
+}
+
+// countDuplicate records an answer flagged by the duplicate-answer guard.
+func (t *outcomeTracker) countDuplicate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.Duplicates++
+}
+
+// snapshot returns a copy of the statistics.
+func (t *outcomeTracker) snapshot() Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := t.stats
+	stats.Letters = make(map[string]LetterStats, len(t.stats.Letters))
+	for k, v := range t.stats.Letters {
+		stats.Letters[k] = v
+	}
+	return stats
+}
+
+// finishSolve records a finished solve in the statistics, the recent solves and the journal.
+// The result may be nil if the solve failed before producing an answer. Images are not archived when the
+// Solver has no training data, since they did not fail on their own.
+func (s *Solver) finishSolve(b []byte, start time.Time, result *Result, err error) {
+	if result == nil {
+		result = &Result{}
+	}
+	hash := ImageHash(b)
+	s.outcomes.remember(hash, result, err)
+	if err == nil {
+		s.checkDuplicate(hash, result)
+	}
+	if journal := s.currentJournal(); journal != nil {
+		recordSolve(journal, hash, start, result.Text, result.Confidence, err)
+	}
+	if store := s.currentFailureStore(); store != nil && !result.Solved && !errors.Is(err, ErrNoTrainingData) {
+		_ = store.Put(hash+imageExtension(b), b)
+	}
+}
+
+// ReportOutcome reports whether the answer solved from the image identified by imageHash was accepted
+// by Amazon. The outcome is recorded in the journal and in the statistics, including the per-letter
+// accuracy when the solve is among the most recent ones. See ImageHash for computing the hash.
+// With self-training enabled, accepted answers also teach the training data the letters that
+// were only recognized approximately, see SetSelfTraining.
+func ReportOutcome(imageHash string, accepted bool) {
+	defaultSolver().ReportOutcome(imageHash, accepted)
+}
+
+// ReportOutcome works like the package-level ReportOutcome, for the solves of the Solver.
+func (s *Solver) ReportOutcome(imageHash string, accepted bool) {
+	solve := s.outcomes.report(imageHash, accepted)
+	if journal := s.currentJournal(); journal != nil {
+		_ = journal.Record(&JournalEntry{
+			Time:      time.Now(),
+			ImageHash: imageHash,
+			Answer:    solve.answer,
+			Accepted:  &accepted,
+		})
+	}
+	if accepted && len(solve.fuzzy) > 0 {
+		s.selfTrain(solve.fuzzy)
+	}
+}
+
+// SolveStats returns a snapshot of the statistics of the solves and reported outcomes.
+func SolveStats() Stats {
+	return defaultSolver().Stats()
+}
+
+// Stats returns a snapshot of the statistics of the solves and reported outcomes of the Solver.
+func (s *Solver) Stats() Stats {
+	return s.outcomes.snapshot()
+}
@@ -0,0 +1,40 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportOutcome(t *testing.T) {
+	var buf bytes.Buffer
+	SetJournal(NewJSONLJournal(&buf))
+	defer SetJournal(nil)
+
+	before := SolveStats()
+	captcha := syntheticCaptcha(t, "AXBYCE")
+	answer, err := Solve(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	ReportOutcome(ImageHash(captcha), true)
+	ReportOutcome("unknown", false)
+
+	stats := SolveStats()
+	assert.Equal(t, before.Solves+1, stats.Solves)
+	assert.Equal(t, before.Solved+1, stats.Solved)
+	assert.Equal(t, before.Accepted+1, stats.Accepted)
+	assert.Equal(t, before.Rejected+1, stats.Rejected)
+	assert.Equal(t, before.Letters["X"].Accepted+1, stats.Letters["X"].Accepted)
+	assert.Equal(t, 1.0, LetterStats{Accepted: 3}.Accuracy())
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if assert.Len(t, lines, 3) {
+		var entry JournalEntry
+		assert.NoError(t, json.Unmarshal(lines[1], &entry))
+		assert.Equal(t, answer, entry.Answer)
+		if assert.NotNil(t, entry.Accepted) {
+			assert.True(t, *entry.Accepted)
+		}
+	}
+}
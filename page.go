@@ -0,0 +1,99 @@
+package amazoncaptcha
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// captchaFormSelector matches the captcha form of an Amazon captcha page by its Amazon-specific markers: its
+// validateCaptcha action or its amzn token. The field-keywords answer input alone is no marker, since the search
+// form of every Amazon page has one too.
+const captchaFormSelector = `form[action*="/errors/validateCaptcha"], form:has(input[type=hidden][name="amzn"])`
+
+// captchaImageSelectors match the captcha image inside the captcha form, most specific first: the image row of
+// the Amazon captcha page, then any image of the form, as on captcha pages of other layouts.
+var captchaImageSelectors = []string{"div.a-row.a-text-center > img", "img"}
+
+// captchaErrorSelector matches the error alert of a captcha page served in response to a wrong answer.
+const captchaErrorSelector = ".a-alert-error"
+
+// MaxPageSize is the size up to which pages are read to look for a captcha. Amazon captcha pages take a few
+// kilobytes, larger pages are not taken for captcha pages.
+const MaxPageSize = 1 << 20
+
+// MaxCaptchaImageBytes and MaxCaptchaImagePixels bound the captcha images downloaded from captcha pages, see
+// LimitImageReader. Amazon captcha images take a few kilobytes for 200x70 pixels, larger images are refused with
+// ErrImageTooLarge before they are buffered.
+const (
+	MaxCaptchaImageBytes  = 1 << 20
+	MaxCaptchaImagePixels = 1 << 18
+)
+
+// ErrNoCaptchaImage is returned by ExtractCaptchaURL when the page holds no captcha image, e.g. because Amazon
+// served the requested page or its automated access page instead of a captcha. Pages without the markers of the
+// Amazon captcha form, see IsCaptchaPage, hold no captcha image either.
+var ErrNoCaptchaImage = errors.New("no captcha image found")
+
+// ExtractCaptchaURL parses an Amazon captcha page and returns the URL of its captcha image, as found in the
+// page, which may be relative to the URL of the page. It returns ErrNoCaptchaImage if the page holds no
+// captcha image.
+func ExtractCaptchaURL(html io.Reader) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(html)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse captcha page: %w", err)
+	}
+	src, ok := captchaImage(doc).Attr("src")
+	if !ok || src == "" {
+		return "", ErrNoCaptchaImage
+	}
+	return src, nil
+}
+
+// IsCaptchaPage reports whether html is an Amazon captcha page, e.g. to detect that a scraped page must be
+// unlocked by solving a captcha first. A captcha page has a form submitting to /errors/validateCaptcha or
+// carrying the hidden amzn token of Amazon, with the captcha image inside; images in other forms do not count.
+func IsCaptchaPage(html io.Reader) bool {
+	_, err := ExtractCaptchaURL(html)
+	return err == nil
+}
+
+// captchaImage returns the captcha image of a parsed captcha page, or an empty selection if there is none. Only
+// images inside a captcha form count, so that images of forms on other pages are not taken for captchas.
+func captchaImage(doc *goquery.Document) *goquery.Selection {
+	forms := doc.Find(captchaFormSelector)
+	for _, selector := range captchaImageSelectors {
+		if img := forms.Find(selector).First(); img.Length() > 0 {
+			return img
+		}
+	}
+	return forms.Find(captchaImageSelectors[0])
+}
+
+// AnswerPageError returns the error of an answer to a captcha that Amazon answered with the page html, e.g. for
+// flows submitting answers themselves: nil if html is not a captcha page by the markers of the captcha form, see
+// IsCaptchaPage; ErrChallengeRejected if it is one showing an error alert, as for a wrong answer; and
+// ErrChallengeExpired otherwise, as for expired form tokens.
+func AnswerPageError(html io.Reader) error {
+	doc, err := goquery.NewDocumentFromReader(html)
+	if err != nil {
+		return nil
+	}
+	return challengeError(doc)
+}
+
+// challengeError returns the error of an answer to a captcha that was answered with the page doc: nil if doc is
+// not a captcha page by the markers of the captcha form, see IsCaptchaPage, whatever images or alerts it shows;
+// ErrChallengeRejected if it is one showing an error alert, as for a wrong answer; and ErrChallengeExpired
+// otherwise, as for expired form tokens.
+func challengeError(doc *goquery.Document) error {
+	if src, _ := captchaImage(doc).Attr("src"); src == "" {
+		return nil
+	}
+	if doc.Find(captchaErrorSelector).Length() > 0 {
+		return ErrChallengeRejected
+	}
+	return ErrChallengeExpired
+}
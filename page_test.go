@@ -0,0 +1,51 @@
+package amazoncaptcha
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractCaptchaURL(t *testing.T) {
+	page := `<html><body><form method="get" action="/errors/validateCaptcha">
+<div class="a-row a-spacing-large"><img src="/logo.png"></div>
+<div class="a-row a-text-center"><img src="https://images-na.ssl-images-amazon.com/captcha/abc/Captcha_xyz.jpg"></div>
+<input type="text" name="field-keywords">
+</form></body></html>`
+	src, err := ExtractCaptchaURL(strings.NewReader(page))
+	assert.NoError(t, err)
+	assert.Equal(t, "https://images-na.ssl-images-amazon.com/captcha/abc/Captcha_xyz.jpg", src)
+	assert.True(t, IsCaptchaPage(strings.NewReader(page)))
+
+	// Captcha pages of other layouts fall back to the first image of the captcha form
+	src, err = ExtractCaptchaURL(strings.NewReader(`<form><input type=hidden name="amzn" value="token"><img src="/captcha.png"></form>`))
+	assert.NoError(t, err)
+	assert.Equal(t, "/captcha.png", src)
+
+	// Other pages hold no captcha, even with images in forms or the answer input of Amazon
+	for _, page := range []string{
+		`<html><body><img src="/product.jpg"></body></html>`,
+		`<form action="/errors/validateCaptcha"><div class="a-row a-text-center"><img></div></form>`,
+		`<form action="/s"><img src="/logo.png"><input name="q"></form>`,
+		`<form action="/s"><div class="a-row a-text-center"><img src="/logo.png"></div><input type="text" name="field-keywords"></form>`,
+		`<div class="a-row a-text-center"><img src="/banner.png"></div><form action="/errors/validateCaptcha"></form>`,
+		"",
+	} {
+		_, err = ExtractCaptchaURL(strings.NewReader(page))
+		assert.True(t, errors.Is(err, ErrNoCaptchaImage), page)
+		assert.False(t, IsCaptchaPage(strings.NewReader(page)), page)
+	}
+}
+
+func TestAnswerPageError(t *testing.T) {
+	captcha := `<form action="/errors/validateCaptcha"><div class="a-row a-text-center"><img src="/captcha.png"></div></form>`
+	assert.True(t, errors.Is(AnswerPageError(strings.NewReader(captcha)), ErrChallengeExpired))
+	alert := `<div class="a-alert-error">Please try again.</div>` + captcha
+	assert.True(t, errors.Is(AnswerPageError(strings.NewReader(alert)), ErrChallengeRejected))
+
+	// Pages behind the captcha accept the answer, whatever alerts they show
+	assert.NoError(t, AnswerPageError(strings.NewReader(`<div class="a-alert-error">Out of stock</div>`)))
+	assert.NoError(t, AnswerPageError(strings.NewReader("")))
+}
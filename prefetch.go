@@ -0,0 +1,398 @@
+package amazoncaptcha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultCaptchaPageURL is the captcha page fetched by a Prefetcher unless configured otherwise.
+const DefaultCaptchaPageURL = "https://www.amazon.com/errors/validateCaptcha"
+
+// DefaultPrefetchTTL is how long a prefetched captcha is handed out after it was fetched, unless configured
+// otherwise. It is kept well below the lifetime of the form tokens of the captcha page.
+const DefaultPrefetchTTL = 5 * time.Minute
+
+// PrefetchConfig configures a Prefetcher.
+type PrefetchConfig struct {
+	// URL is the captcha page to fetch captchas from, DefaultCaptchaPageURL if empty.
+	URL string
+	// Client makes the requests, and must have a cookie jar: the form tokens of a captcha are only valid along
+	// with the session cookies set with the captcha page. Pass the client of the scraping session, with its cookie
+	// jar and proxy, so that the answers are submitted under that session. A client with a new jar if nil.
+	Client *http.Client
+	// Headers are set on every request, e.g. a realistic User-Agent.
+	Headers map[string]string
+	// Size is the number of solved captchas kept ready.
+	Size int
+	// TTL is how long a captcha is handed out after it was fetched, DefaultPrefetchTTL if 0.
+	TTL time.Duration
+	// RetryDelay is the delay before fetching again after a failed fetch, doubled after every consecutive
+	// failure up to a minute, one second if 0.
+	RetryDelay time.Duration
+	// SubmitAttempts is the number of captchas Submit tries before giving up on expired tokens, 3 if 0.
+	SubmitAttempts int
+	// Fallback governs what happens to captchas that are not solved well enough locally. By default, they are
+	// skipped and a new captcha is fetched after RetryDelay.
+	Fallback FallbackPolicy
+}
+
+// Challenge is a captcha fetched from the captcha page and solved ahead of time.
+type Challenge struct {
+	// Answer is the answer to the captcha.
+	Answer string
+	// ImageURL is the URL of the captcha image.
+	ImageURL string
+	// External reports whether the answer comes from the external solver of the fallback policy.
+	External bool
+	// FetchedAt is when the captcha page was fetched.
+	FetchedAt time.Time
+	// ExpiresAt is when the challenge stops being handed out.
+	ExpiresAt time.Time
+
+	// form is the captcha form the answer is submitted with, and client and headers are those of the session
+	// the captcha was fetched with, see Submit.
+	form    *CaptchaForm
+	client  *http.Client
+	headers map[string]string
+}
+
+// Action returns the absolute URL the captcha form is submitted to.
+func (c *Challenge) Action() string {
+	if c.form == nil {
+		return ""
+	}
+	return c.form.Action
+}
+
+// Form returns a copy of the fields submitted with the captcha form, its hidden tokens along with the answer.
+func (c *Challenge) Form() url.Values {
+	if c.form == nil {
+		return nil
+	}
+	fields := make(url.Values, len(c.form.Fields)+1)
+	for name, values := range c.form.Fields {
+		fields[name] = append([]string(nil), values...)
+	}
+	fields.Set(c.form.AnswerField, c.Answer)
+	return fields
+}
+
+// Domain returns the host name of the captcha page, the only one the answer is submitted to.
+func (c *Challenge) Domain() string {
+	if c.form == nil {
+		return ""
+	}
+	u, err := url.Parse(c.form.PageURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// SubmitURL returns the URL submitting the answer with the captcha form, see CaptchaForm.SubmitURL.
+func (c *Challenge) SubmitURL() (string, error) {
+	if c.form == nil {
+		return "", errors.New("captcha was not fetched by a prefetcher")
+	}
+	return c.form.SubmitURL(c.Answer)
+}
+
+// Prefetcher keeps a pool of captchas fetched from the captcha page and solved ahead of time, so that
+// scrapers hitting a captcha can submit a ready answer right away instead of solving it on the critical path.
+// Run fills the pool in the background and Get hands out the captchas, oldest first, skipping expired ones.
+type Prefetcher struct {
+	solver *Solver
+	config PrefetchConfig
+
+	mu    sync.Mutex
+	ready []*Challenge
+	added chan struct{}
+	taken chan struct{}
+
+	errMu sync.Mutex
+	err   error
+}
+
+// NewPrefetcher creates a Prefetcher configured by config, solving the captchas with the default configuration
+// and training data of the package.
+func NewPrefetcher(config PrefetchConfig) (*Prefetcher, error) {
+	return defaultSolver().NewPrefetcher(config)
+}
+
+// NewPrefetcher works like the package-level NewPrefetcher, solving the captchas with the Solver.
+func (s *Solver) NewPrefetcher(config PrefetchConfig) (*Prefetcher, error) {
+	if config.Size < 1 {
+		return nil, errors.New("prefetch size must be positive")
+	}
+	if config.TTL < 0 || config.RetryDelay < 0 {
+		return nil, errors.New("prefetch durations must not be negative")
+	}
+	if config.SubmitAttempts < 0 {
+		return nil, errors.New("submit attempts must not be negative")
+	}
+	if err := config.Fallback.validate(); err != nil {
+		return nil, err
+	}
+	if config.URL == "" {
+		config.URL = DefaultCaptchaPageURL
+	}
+	if config.Client == nil {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cookie jar: %w", err)
+		}
+		config.Client = &http.Client{Jar: jar}
+	} else if config.Client.Jar == nil {
+		return nil, errors.New("prefetch client must have a cookie jar")
+	}
+	if config.TTL == 0 {
+		config.TTL = DefaultPrefetchTTL
+	}
+	if config.RetryDelay == 0 {
+		config.RetryDelay = time.Second
+	}
+	if config.SubmitAttempts == 0 {
+		config.SubmitAttempts = 3
+	}
+	return &Prefetcher{
+		solver: s,
+		config: config,
+		added:  make(chan struct{}, 1),
+		taken:  make(chan struct{}, 1),
+	}, nil
+}
+
+// Run keeps the pool filled until ctx is done, and returns the error of the context. It is meant to run in its
+// own goroutine. Captchas that cannot be fetched or solved completely are skipped; the latest error is
+// returned by Err.
+func (p *Prefetcher) Run(ctx context.Context) error {
+	delay := p.config.RetryDelay
+	for {
+		n, next := p.prune()
+		if n < p.config.Size {
+			challenge, err := p.fetch(ctx)
+			if err == nil {
+				p.add(challenge)
+				delay = p.config.RetryDelay
+				continue
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			p.setErr(err)
+
+			// Back off before fetching again, so that a failing page is not hammered
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+			if delay *= 2; delay > time.Minute {
+				delay = time.Minute
+			}
+			continue
+		}
+
+		// Wait until a captcha is taken or the oldest one expires
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-p.taken:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// Get returns the oldest captcha of the pool that has not expired, waiting for one until ctx is done.
+// Every captcha is handed out once.
+func (p *Prefetcher) Get(ctx context.Context) (*Challenge, error) {
+	for {
+		p.prune()
+		p.mu.Lock()
+		if len(p.ready) > 0 {
+			challenge := p.ready[0]
+			p.ready = p.ready[1:]
+			left := len(p.ready)
+			p.mu.Unlock()
+
+			// Pass the signal on to the other waiting calls, signals of several captchas being merged
+			if left > 0 {
+				notify(p.added)
+			}
+			notify(p.taken)
+			return challenge, nil
+		}
+		p.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-p.added:
+		}
+	}
+}
+
+// Fetch fetches a captcha right away, bypassing the pool, and returns it unsolved along with its image, e.g. to
+// solve it elsewhere: set its Answer before submitting it.
+func (p *Prefetcher) Fetch(ctx context.Context) (*Challenge, []byte, error) {
+	return p.fetchCaptcha(ctx)
+}
+
+// Solve fetches a captcha right away, bypassing the pool, and solves it as governed by the fallback policy, like
+// the captchas of the pool, e.g. for flows solving captchas only when they hit one.
+func (p *Prefetcher) Solve(ctx context.Context) (*Challenge, error) {
+	return p.fetch(ctx)
+}
+
+// Len returns the number of captchas ready to be handed out, expired ones included until they are pruned.
+func (p *Prefetcher) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.ready)
+}
+
+// Err returns the error of the latest failed fetch, or nil if none failed.
+func (p *Prefetcher) Err() error {
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
+	return p.err
+}
+
+// setErr records the error of a failed fetch.
+func (p *Prefetcher) setErr(err error) {
+	p.errMu.Lock()
+	p.err = err
+	p.errMu.Unlock()
+}
+
+// add adds a solved captcha to the pool and wakes up a waiting Get.
+func (p *Prefetcher) add(challenge *Challenge) {
+	p.mu.Lock()
+	p.ready = append(p.ready, challenge)
+	p.mu.Unlock()
+	notify(p.added)
+}
+
+// prune drops the expired captchas, and returns the number of captchas left and when the oldest one expires.
+func (p *Prefetcher) prune() (int, time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	i := 0
+	for i < len(p.ready) && !now.Before(p.ready[i].ExpiresAt) {
+		i++
+	}
+	p.ready = p.ready[i:]
+	if len(p.ready) == 0 {
+		return 0, time.Time{}
+	}
+	return len(p.ready), p.ready[0].ExpiresAt
+}
+
+// notify signals ch without blocking, a pending signal standing for any number of events.
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// fetch fetches a captcha and solves it, as governed by the fallback policy: the captcha is solved locally,
+// captchas that are not solved well enough are replaced by new ones up to Retries times, and the last one is
+// handed over to the external solver, if any.
+func (p *Prefetcher) fetch(ctx context.Context) (*Challenge, error) {
+	policy := p.config.Fallback
+	for attempt := 0; ; attempt++ {
+		challenge, image, err := p.fetchCaptcha(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		// Solve the captcha locally, then fall back to a new captcha or to the external solver
+		answer, err := policy.solveLocally(p.solver, image)
+		if err != nil && attempt < policy.Retries && ctx.Err() == nil {
+			continue
+		}
+		if err != nil && policy.External != nil {
+			if answer, err = policy.External.Solve(ctx, image); err != nil {
+				return nil, fmt.Errorf("failed to solve captcha externally: %w", err)
+			}
+			challenge.External = true
+		}
+		if err != nil {
+			return nil, err
+		}
+		challenge.Answer = answer
+		return challenge, nil
+	}
+}
+
+// fetchCaptcha fetches the captcha page, parses its captcha form and downloads the captcha image, reading up to
+// MaxPageSize bytes of the page and refusing images above MaxCaptchaImageBytes or MaxCaptchaImagePixels. It
+// returns the Challenge without its answer and the image.
+func (p *Prefetcher) fetchCaptcha(ctx context.Context) (*Challenge, []byte, error) {
+	fetchedAt := time.Now()
+	resp, err := p.get(ctx, p.config.URL)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	form, err := ParseCaptchaForm(resp.Request.URL, io.LimitReader(resp.Body, MaxPageSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	challenge := &Challenge{
+		ImageURL:  form.ImageURL,
+		FetchedAt: fetchedAt,
+		ExpiresAt: fetchedAt.Add(p.config.TTL),
+		form:      form,
+		client:    p.config.Client,
+		headers:   p.config.Headers,
+	}
+
+	// Download the image with the same session
+	imageResp, err := p.get(ctx, challenge.ImageURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer imageResp.Body.Close()
+	limited, err := LimitImageReader(imageResp.Body, MaxCaptchaImageBytes, MaxCaptchaImagePixels)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read captcha image: %w", err)
+	}
+	image, err := io.ReadAll(limited)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read captcha image: %w", err)
+	}
+	return challenge, image, nil
+}
+
+// get makes a GET request with the client and headers of the Prefetcher, and fails unless the response is OK.
+func (p *Prefetcher) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	for k, v := range p.config.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := p.config.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make HTTP request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected HTTP status code: %d", resp.StatusCode)
+	}
+	return resp, nil
+}
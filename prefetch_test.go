@@ -0,0 +1,168 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// resultsPage is the page behind the captcha, with an image in its search form and an error alert, which do not
+// make it a captcha page.
+const resultsPage = `<html><body><form action="/s"><img src="/logo.png"><input type="text" name="field-keywords"></form>
+<div class="a-row a-text-center"><img src="/banner.png"></div><div class="a-alert-error">Out of stock</div>Results</body></html>`
+
+// captchaPage serves a captcha page modeled on the one of Amazon, with a new form token on every fetch and a
+// session cookie. Answers submitted along with the session cookie and a token accepted by accept are answered
+// with the requested page, others with the captcha page again, as Amazon does for expired tokens.
+func captchaPage(t *testing.T, captcha []byte, accept func(token string) bool) (*httptest.Server, *int32) {
+	t.Helper()
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/errors/validateCaptcha":
+			query := r.URL.Query()
+			_, err := r.Cookie("session-id")
+			if query.Get("field-keywords") != "" && err == nil && accept != nil && accept(query.Get("amzn")) {
+				_, _ = w.Write([]byte(resultsPage))
+				return
+			}
+			n := atomic.AddInt32(&fetches, 1)
+			http.SetCookie(w, &http.Cookie{Name: "session-id", Value: "session", Path: "/"})
+			fmt.Fprintf(w, `<html><body><form method="get" action="/errors/validateCaptcha">
+<input type=hidden name="amzn" value="token-%d" /><input type=hidden name="amzn-r" value="&#047;" />
+<div class="a-row a-text-center"><img src="/captcha/Captcha_%d.png"></div>
+<input id="captchacharacters" name="field-keywords" type="text">
+</form></body></html>`, n, n)
+		case "/broken":
+			_, _ = w.Write([]byte(`<html><body>No captcha here</body></html>`))
+		default:
+			_, _ = w.Write(captcha)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &fetches
+}
+
+// sessionClient returns a client of server with a cookie jar of its own, as a scraping session.
+func sessionClient(t *testing.T, server *httptest.Server) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	assert.NoError(t, err)
+	client := *server.Client()
+	client.Jar = jar
+	return &client
+}
+
+func TestPrefetcher(t *testing.T) {
+	server, fetches := captchaPage(t, syntheticCaptcha(t, "ABCEFG"), nil)
+	p, err := NewPrefetcher(PrefetchConfig{URL: server.URL + "/errors/validateCaptcha", Client: sessionClient(t, server), Size: 2})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- p.Run(ctx) }()
+
+	challenge, err := p.Get(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", challenge.Answer)
+	assert.Equal(t, "ABCEFG", challenge.Form().Get("field-keywords"))
+	assert.Equal(t, "token-1", challenge.Form().Get("amzn"))
+	assert.Equal(t, "/", challenge.Form().Get("amzn-r"))
+	assert.Equal(t, server.URL+"/captcha/Captcha_1.png", challenge.ImageURL)
+	submitURL, err := challenge.SubmitURL()
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/errors/validateCaptcha?amzn=token-1&amzn-r=%2F&field-keywords=ABCEFG", submitURL)
+	assert.Equal(t, DefaultPrefetchTTL, challenge.ExpiresAt.Sub(challenge.FetchedAt))
+
+	// The pool is refilled once a captcha is taken, and not beyond its size
+	challenge, err = p.Get(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "token-2", challenge.Form().Get("amzn"))
+	assert.Eventually(t, func() bool { return p.Len() == 2 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(4), atomic.LoadInt32(fetches))
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.NoError(t, p.Err())
+}
+
+func TestPrefetcherExpiry(t *testing.T) {
+	server, fetches := captchaPage(t, syntheticCaptcha(t, "ABCEFG"), nil)
+	p, err := NewPrefetcher(PrefetchConfig{URL: server.URL + "/errors/validateCaptcha", Client: sessionClient(t, server), Size: 1, TTL: time.Second})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	go func() { _ = p.Run(ctx) }()
+
+	// Expired captchas are replaced by fresh ones
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(fetches) >= 3 }, 10*time.Second, time.Millisecond)
+	challenge, err := p.Get(ctx)
+	assert.NoError(t, err)
+	assert.True(t, time.Now().Before(challenge.ExpiresAt))
+}
+
+func TestPrefetcherFetch(t *testing.T) {
+	captcha := syntheticCaptcha(t, "ABCEFG")
+	server, _ := captchaPage(t, captcha, nil)
+	p, err := NewPrefetcher(PrefetchConfig{URL: server.URL + "/errors/validateCaptcha", Client: sessionClient(t, server), Size: 1})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Captchas are fetched unsolved, or solved, right away
+	challenge, image, err := p.Fetch(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, challenge.Answer)
+	assert.Equal(t, captcha, image)
+	challenge, err = p.Solve(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", challenge.Answer)
+	assert.Zero(t, p.Len())
+
+	// Images above the limits are refused before they are read
+	server, _ = captchaPage(t, largeImage(t), nil)
+	p, err = NewPrefetcher(PrefetchConfig{URL: server.URL + "/errors/validateCaptcha", Client: sessionClient(t, server), Size: 1})
+	assert.NoError(t, err)
+	_, _, err = p.Fetch(ctx)
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+}
+
+// largeImage returns a PNG image above MaxCaptchaImagePixels.
+func largeImage(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1024, 1024))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestPrefetcherErrors(t *testing.T) {
+	server, _ := captchaPage(t, syntheticCaptcha(t, "ABCEFG"), nil)
+	p, err := NewPrefetcher(PrefetchConfig{URL: server.URL + "/broken", Client: sessionClient(t, server), Size: 1, RetryDelay: time.Millisecond})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	go func() { _ = p.Run(ctx) }()
+
+	_, err = p.Get(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, p.Err(), "captcha image")
+
+	_, err = NewPrefetcher(PrefetchConfig{})
+	assert.Error(t, err)
+	_, err = NewPrefetcher(PrefetchConfig{Size: 1, TTL: -time.Second})
+	assert.Error(t, err)
+	_, err = NewPrefetcher(PrefetchConfig{Client: server.Client(), Size: 1})
+	assert.ErrorContains(t, err, "cookie jar")
+}
@@ -0,0 +1,98 @@
+package amazoncaptcha
+
+import (
+	"sort"
+	"sync"
+)
+
+// Preprocessor implements the preprocessing stages of the recognition pipeline over flat pixel planes,
+// the layout of the Pix slices of the image package. The generic implementation is plain Go; faster ones,
+// e.g. written in assembly with SIMD instructions or offloading the work to a GPU through cgo, can be
+// provided by other packages with RegisterPreprocessor. Every implementation must produce exactly the
+// same output as the generic one, or features would no longer match the training data.
+type Preprocessor interface {
+	// Name identifies the implementation, e.g. in diagnostics.
+	Name() string
+	// Luma converts the premultiplied RGBA pixels of src, four bytes per pixel, into the gray levels of dst
+	// with the formula of color.GrayModel: (19595*R + 38470*G + 7471*B + 1<<15) >> 24 over 16-bit channels.
+	Luma(dst, src []byte)
+	// Threshold sets every pixel of dst to 0 if the pixel of src is at most threshold, and to 255 otherwise.
+	Threshold(dst, src []byte, threshold uint8)
+	// ColumnInk sets ink[x] to true for every column x of the plane that holds a black (0) pixel.
+	// The plane is width by height pixels, with rows stride bytes apart.
+	ColumnInk(ink []bool, plane []byte, width, height, stride int)
+}
+
+// registeredPreprocessor is a Preprocessor with the priority it was registered with.
+type registeredPreprocessor struct {
+	preprocessor Preprocessor
+	priority     int
+}
+
+var (
+	preprocessorsMu sync.RWMutex
+	preprocessors   = []registeredPreprocessor{{preprocessor: genericPreprocessor{}}}
+)
+
+// RegisterPreprocessor makes p available to the recognition pipeline, which automatically uses
+// the registered implementation with the highest priority. The generic implementation has priority 0.
+// Packages providing an implementation should register it from an init function, and only if it can run
+// on the current machine, e.g. after checking the CPU features it needs.
+func RegisterPreprocessor(p Preprocessor, priority int) {
+	preprocessorsMu.Lock()
+	defer preprocessorsMu.Unlock()
+	preprocessors = append(preprocessors, registeredPreprocessor{preprocessor: p, priority: priority})
+	sort.SliceStable(preprocessors, func(i, j int) bool { return preprocessors[i].priority > preprocessors[j].priority })
+}
+
+// ActivePreprocessor returns the name of the Preprocessor used by the recognition pipeline.
+func ActivePreprocessor() string {
+	return activePreprocessor().Name()
+}
+
+// activePreprocessor returns the registered Preprocessor with the highest priority.
+func activePreprocessor() Preprocessor {
+	preprocessorsMu.RLock()
+	defer preprocessorsMu.RUnlock()
+	return preprocessors[0].preprocessor
+}
+
+// genericPreprocessor is the plain Go Preprocessor.
+type genericPreprocessor struct{}
+
+// Name implements the Preprocessor interface.
+func (genericPreprocessor) Name() string {
+	return "generic"
+}
+
+// Luma implements the Preprocessor interface.
+func (genericPreprocessor) Luma(dst, src []byte) {
+	for i := range dst {
+		p := src[4*i : 4*i+4 : 4*i+4]
+		r, g, b := uint32(p[0])*0x101, uint32(p[1])*0x101, uint32(p[2])*0x101
+		dst[i] = uint8((19595*r + 38470*g + 7471*b + 1<<15) >> 24)
+	}
+}
+
+// Threshold implements the Preprocessor interface.
+func (genericPreprocessor) Threshold(dst, src []byte, threshold uint8) {
+	for i, v := range src[:len(dst)] {
+		if v <= threshold {
+			dst[i] = 0
+		} else {
+			dst[i] = 255
+		}
+	}
+}
+
+// ColumnInk implements the Preprocessor interface.
+func (genericPreprocessor) ColumnInk(ink []bool, plane []byte, width, height, stride int) {
+	for y := 0; y < height; y++ {
+		row := plane[y*stride : y*stride+width]
+		for x, v := range row {
+			if v == 0 {
+				ink[x] = true
+			}
+		}
+	}
+}
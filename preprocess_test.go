@@ -0,0 +1,64 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenericPreprocessor(t *testing.T) {
+	rgba := image.NewRGBA(image.Rect(0, 0, 37, 11))
+	rand.New(rand.NewSource(1)).Read(rgba.Pix)
+
+	// The plane stages match the per-pixel conversions
+	gray := Grayscale(rgba)
+	mono := MonoChrome(gray, 100)
+	for y := 0; y < 11; y++ {
+		for x := 0; x < 37; x++ {
+			want := color.GrayModel.Convert(rgba.At(x, y)).(color.Gray)
+			assert.Equal(t, want, gray.GrayAt(x, y))
+			assert.Equal(t, want.Y <= 100, mono.GrayAt(x, y).Y == 0)
+		}
+	}
+
+	// Sub-images are thresholded within their bounds
+	sub := mono.SubImage(image.Rect(5, 2, 20, 9)).(*image.Gray)
+	assert.Equal(t, sub.Bounds(), MonoChrome(sub, 0).Bounds())
+	assert.Equal(t, sub.GrayAt(7, 3), MonoChrome(sub, 0).GrayAt(7, 3))
+}
+
+// countingPreprocessor is a Preprocessor counting the calls to the generic implementation.
+type countingPreprocessor struct {
+	genericPreprocessor
+	calls int
+}
+
+func (p *countingPreprocessor) Name() string {
+	return "counting"
+}
+
+func (p *countingPreprocessor) Threshold(dst, src []byte, threshold uint8) {
+	p.calls++
+	p.genericPreprocessor.Threshold(dst, src, threshold)
+}
+
+func TestRegisterPreprocessor(t *testing.T) {
+	original := preprocessors
+	defer func() { preprocessors = original }()
+	assert.Equal(t, "generic", ActivePreprocessor())
+
+	// The registered implementation with the highest priority is used
+	counting := &countingPreprocessor{}
+	RegisterPreprocessor(counting, 10)
+	RegisterPreprocessor(genericPreprocessor{}, 5)
+	assert.Equal(t, "counting", ActivePreprocessor())
+
+	answer, err := Solve(bytes.NewReader(syntheticCaptcha(t, "ABCEFG")))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", answer)
+	assert.Greater(t, counting.calls, 0)
+}
@@ -0,0 +1,90 @@
+package amazoncaptcha
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// DefaultCaptchaLength is the number of letters of a captcha unless configured otherwise.
+const DefaultCaptchaLength = 6
+
+// Profile bundles the constants describing a variant of the Amazon captchas, so that supporting a new variant
+// is a matter of data rather than of code. The profiles package holds a registry of the known variants.
+type Profile struct {
+	// Name identifies the variant, e.g. "amazon-us".
+	Name string
+	// MonoThreshold is the threshold used to convert grayscale captchas to binary images, see WithMonoThreshold.
+	MonoThreshold uint8
+	// MaximumLetterLength is the maximum width of a single letter, see WithMaximumLetterLength.
+	MaximumLetterLength int
+	// MinimumLetterLength is the minimum width of the first letter, see WithMinimumLetterLength.
+	MinimumLetterLength int
+	// Length is the number of letters of a captcha.
+	Length int
+	// Charset holds the letters the captchas are made of. Letters recognized as any other letter are treated as
+	// unknown. An empty charset accepts every letter of the training data.
+	Charset string
+	// Width and Height are the canonical dimensions of the captchas. Images of other dimensions fail with a
+	// *DimensionError. Zero dimensions accept images of any size.
+	Width, Height int
+}
+
+// WithProfile configures the Solver with the constants of a captcha variant, replacing the mono threshold,
+// the letter lengths, the captcha length, the charset and the canonical dimensions. Options given after it
+// override single constants.
+func WithProfile(p Profile) Option {
+	return func(s *Solver) error {
+		if p.Length <= 0 {
+			return fmt.Errorf("invalid profile %q: length must be positive", p.Name)
+		}
+		if p.Width < 0 || p.Height < 0 {
+			return fmt.Errorf("invalid profile %q: dimensions must not be negative", p.Name)
+		}
+		if strings.ContainsRune(p.Charset, s.placeholder) {
+			return fmt.Errorf("invalid profile %q: charset contains the placeholder %q", p.Name, s.placeholder)
+		}
+		for _, opt := range []Option{WithMonoThreshold(p.MonoThreshold), WithMaximumLetterLength(p.MaximumLetterLength), WithMinimumLetterLength(p.MinimumLetterLength)} {
+			if err := opt(s); err != nil {
+				return fmt.Errorf("invalid profile %q: %w", p.Name, err)
+			}
+		}
+		s.captchaLength = p.Length
+		s.charset = p.Charset
+		s.width, s.height = p.Width, p.Height
+		return nil
+	}
+}
+
+// inCharset reports whether letter is one of the letters of the charset of the Solver.
+func (s *Solver) inCharset(letter string) bool {
+	return s.charset == "" || strings.Contains(s.charset, letter)
+}
+
+// DimensionError describes an image whose dimensions differ from the canonical dimensions of the profile
+// of the Solver. It matches ErrSegmentationFailed, since such an image is no captcha of the variant.
+type DimensionError struct {
+	// Size is the size of the image.
+	Size image.Point
+	// Want is the canonical size of the captchas.
+	Want image.Point
+}
+
+// Error implements the error interface.
+func (e *DimensionError) Error() string {
+	return fmt.Sprintf("%v: image of %dx%d pixels, want %dx%d", ErrSegmentationFailed, e.Size.X, e.Size.Y, e.Want.X, e.Want.Y)
+}
+
+// Unwrap returns ErrSegmentationFailed so that errors.Is can be used to detect the failure.
+func (e *DimensionError) Unwrap() error {
+	return ErrSegmentationFailed
+}
+
+// checkDimensions returns a *DimensionError if the size of an image differs from the canonical dimensions
+// of the Solver.
+func (s *Solver) checkDimensions(bounds image.Rectangle) error {
+	if (s.width == 0 || bounds.Dx() == s.width) && (s.height == 0 || bounds.Dy() == s.height) {
+		return nil
+	}
+	return &DimensionError{Size: bounds.Size(), Want: image.Pt(s.width, s.height)}
+}
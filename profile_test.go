@@ -0,0 +1,99 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// profile returns the profile of the default configuration, without canonical dimensions.
+func profile() Profile {
+	return Profile{
+		Name:                "test",
+		MonoThreshold:       MonoWeight,
+		MaximumLetterLength: MaximumLetterLength,
+		MinimumLetterLength: MinimumLetterLength,
+		Length:              DefaultCaptchaLength,
+	}
+}
+
+func TestWithProfile(t *testing.T) {
+	captcha := syntheticCaptcha(t, "ABCEFG")
+
+	// The default profile solves like the default Solver
+	solver, err := NewSolver(WithProfile(profile()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	text, err := solver.Solve(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", text)
+
+	// Letters outside the charset are unknown
+	p := profile()
+	p.Charset = "BCEFG"
+	solver, err = NewSolver(WithProfile(p))
+	assert.NoError(t, err)
+	text, err = solver.Solve(bytes.NewReader(captcha))
+	assert.ErrorIs(t, err, ErrUnrecognizedLetter)
+	assert.Equal(t, "-BCEFG", text)
+	letters, err := solver.SegmentLetters(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	if assert.Len(t, letters, 6) {
+		assert.Equal(t, "-", letters[0].Text)
+		assert.Zero(t, letters[0].Confidence)
+	}
+
+	// Captchas of another length need a profile of that length
+	short := syntheticCaptcha(t, "HJKLM")
+	_, err = Solve(bytes.NewReader(short))
+	assert.ErrorIs(t, err, ErrSegmentationFailed)
+	p = profile()
+	p.Length = 5
+	solver, err = NewSolver(WithProfile(p))
+	assert.NoError(t, err)
+	text, err = solver.Solve(bytes.NewReader(short))
+	assert.NoError(t, err)
+	assert.Equal(t, "HJKLM", text)
+}
+
+func TestWithProfileDimensions(t *testing.T) {
+	captcha := syntheticCaptcha(t, "ABCEFG")
+	size := imageSize(captcha)
+
+	// Captchas of the canonical dimensions are solved, others are no captchas of the variant
+	p := profile()
+	p.Width, p.Height = size.X, size.Y
+	solver, err := NewSolver(WithProfile(p))
+	assert.NoError(t, err)
+	_, err = solver.Solve(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+
+	p.Width++
+	solver, err = NewSolver(WithProfile(p))
+	assert.NoError(t, err)
+	_, err = solver.Solve(bytes.NewReader(captcha))
+	var dimensionErr *DimensionError
+	if assert.True(t, errors.As(err, &dimensionErr)) {
+		assert.Equal(t, size, dimensionErr.Size)
+		assert.Equal(t, image.Pt(size.X+1, size.Y), dimensionErr.Want)
+	}
+	assert.ErrorIs(t, err, ErrSegmentationFailed)
+}
+
+func TestWithProfileInvalid(t *testing.T) {
+	for _, invalid := range []func(*Profile){
+		func(p *Profile) { p.Length = 0 },
+		func(p *Profile) { p.Width = -1 },
+		func(p *Profile) { p.MaximumLetterLength = 0 },
+		func(p *Profile) { p.Charset = "AB-" },
+	} {
+		p := profile()
+		invalid(&p)
+		_, err := NewSolver(WithProfile(p))
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,86 @@
+// Package profiles is the registry of the known variants of the Amazon captchas. Every variant is described by
+// an amazoncaptcha.Profile, selected with amazoncaptcha.WithProfile:
+//
+//	solver, err := amazoncaptcha.NewSolver(amazoncaptcha.WithProfile(profiles.SellerCentral))
+//
+// A new variant is supported by adding its constants here, or by registering them at startup with Register,
+// e.g. to select a profile by a name from a configuration file with Lookup.
+package profiles
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// AmazonUS is the profile of the captchas of the Amazon store, e.g. www.amazon.com/errors/validateCaptcha,
+// which the embedded training data was collected from.
+var AmazonUS = amazoncaptcha.Profile{
+	Name:                "amazon-us",
+	MonoThreshold:       amazoncaptcha.MonoWeight,
+	MaximumLetterLength: amazoncaptcha.MaximumLetterLength,
+	MinimumLetterLength: amazoncaptcha.MinimumLetterLength,
+	Length:              amazoncaptcha.DefaultCaptchaLength,
+	Charset:             "ABCEFGHJKLMNPRTUXY",
+	Width:               200,
+	Height:              amazoncaptcha.CaptchaHeight,
+}
+
+// SellerCentral is the profile of the captchas of the Seller Central login. No difference to the store captchas
+// is known so far, so it shares the constants of AmazonUS under its own name, ready to diverge.
+var SellerCentral = amazoncaptcha.Profile{
+	Name:                "seller-central",
+	MonoThreshold:       AmazonUS.MonoThreshold,
+	MaximumLetterLength: AmazonUS.MaximumLetterLength,
+	MinimumLetterLength: AmazonUS.MinimumLetterLength,
+	Length:              AmazonUS.Length,
+	Charset:             AmazonUS.Charset,
+	Width:               AmazonUS.Width,
+	Height:              AmazonUS.Height,
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]amazoncaptcha.Profile{
+		AmazonUS.Name:      AmazonUS,
+		SellerCentral.Name: SellerCentral,
+	}
+)
+
+// Register adds a profile to the registry under its name. It returns an error if the name is empty or already
+// registered.
+func Register(p amazoncaptcha.Profile) error {
+	if p.Name == "" {
+		return errors.New("profile has no name")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[p.Name]; ok {
+		return fmt.Errorf("profile %q already registered", p.Name)
+	}
+	registry[p.Name] = p
+	return nil
+}
+
+// Lookup returns the registered profile named name, and whether it exists.
+func Lookup(name string) (amazoncaptcha.Profile, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := registry[name]
+	return p, ok
+}
+
+// Names returns the names of the registered profiles, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
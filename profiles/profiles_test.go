@@ -0,0 +1,37 @@
+package profiles
+
+import (
+	"testing"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfiles(t *testing.T) {
+	// The built-in profiles solve the self-test corpus
+	for _, p := range []amazoncaptcha.Profile{AmazonUS, SellerCentral} {
+		solver, err := amazoncaptcha.NewSolver(amazoncaptcha.WithProfile(p))
+		if assert.NoError(t, err, p.Name) {
+			assert.NoError(t, solver.SelfTest(), p.Name)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	p, ok := Lookup("amazon-us")
+	assert.True(t, ok)
+	assert.Equal(t, AmazonUS, p)
+	_, ok = Lookup("amazon-jp")
+	assert.False(t, ok)
+
+	// Profiles are registered once under their name
+	jp := AmazonUS
+	jp.Name = "amazon-jp"
+	assert.NoError(t, Register(jp))
+	assert.Error(t, Register(jp))
+	assert.Error(t, Register(amazoncaptcha.Profile{}))
+	p, ok = Lookup("amazon-jp")
+	assert.True(t, ok)
+	assert.Equal(t, jp, p)
+	assert.Equal(t, []string{"amazon-jp", "amazon-us", "seller-central"}, Names())
+}
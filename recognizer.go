@@ -0,0 +1,196 @@
+package amazoncaptcha
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// Recognizer recognizes segmented letters that are not found in the training data. A Solver configured with
+// WithRecognizer asks its recognizer about every such letter instead of leaving it unknown.
+type Recognizer interface {
+	// Recognize returns the letter shown by a segmented monochrome letter image and a confidence between 0
+	// and 1, or an empty letter if it cannot tell. The image must not be retained after Recognize returns.
+	Recognize(letter *image.Gray) (string, float64)
+}
+
+// DefaultTemplateShift is the number of pixels by which a TemplateMatcher shifts its templates
+// in every direction when searching for the best alignment.
+const DefaultTemplateShift = 3
+
+// DefaultTemplateScore is the lowest correlation at which a TemplateMatcher recognizes a letter.
+const DefaultTemplateScore = 0.6
+
+// TemplateMatcher is a Recognizer that slides stored letter templates over a segmented letter and scores their
+// normalized cross-correlation. Unlike the bitmap comparison of the training data, it tolerates letters that are
+// slightly misaligned within their segment. It is safe for concurrent use.
+type TemplateMatcher struct {
+	templates []letterTemplate
+	// Shift is the number of pixels by which the templates are shifted in every direction, DefaultTemplateShift by default.
+	Shift int
+	// MinScore is the lowest correlation, between 0 and 1, at which a letter is recognized, DefaultTemplateScore by default.
+	MinScore float64
+}
+
+// letterTemplate is a stored letter template, its pixels as ones for black and zeros for white.
+type letterTemplate struct {
+	letter string
+	width  int
+	height int
+	ink    []float64
+	// mean and norm are the mean of the pixels and the norm of their deviations from it.
+	mean float64
+	norm float64
+}
+
+// NewTemplateMatcher creates a TemplateMatcher from monochrome letter templates, keyed by the letter they show.
+func NewTemplateMatcher(templates map[string][]*image.Gray) *TemplateMatcher {
+	letters := make([]string, 0, len(templates))
+	for letter := range templates {
+		letters = append(letters, letter)
+	}
+	sort.Strings(letters)
+
+	t := &TemplateMatcher{Shift: DefaultTemplateShift, MinScore: DefaultTemplateScore}
+	for _, letter := range letters {
+		for _, img := range templates[letter] {
+			if tmpl, ok := newLetterTemplate(letter, img); ok {
+				t.templates = append(t.templates, tmpl)
+			}
+		}
+	}
+	return t
+}
+
+// newLetterTemplate converts a monochrome image into a template, and reports false for images without contrast.
+func newLetterTemplate(letter string, img *image.Gray) (letterTemplate, bool) {
+	bounds := img.Bounds()
+	tmpl := letterTemplate{letter: letter, width: bounds.Dx(), height: bounds.Dy(), ink: make([]float64, bounds.Dx()*bounds.Dy())}
+	sum := 0.0
+	for y := 0; y < tmpl.height; y++ {
+		for x := 0; x < tmpl.width; x++ {
+			if img.GrayAt(bounds.Min.X+x, bounds.Min.Y+y).Y == 0 {
+				tmpl.ink[y*tmpl.width+x] = 1
+				sum++
+			}
+		}
+	}
+	if len(tmpl.ink) == 0 {
+		return tmpl, false
+	}
+	tmpl.mean = sum / float64(len(tmpl.ink))
+	for _, v := range tmpl.ink {
+		tmpl.norm += (v - tmpl.mean) * (v - tmpl.mean)
+	}
+	tmpl.norm = math.Sqrt(tmpl.norm)
+	return tmpl, tmpl.norm > 0
+}
+
+// Recognize implements Recognizer: it returns the letter of the template correlating best with the letter at
+// any shift, with the correlation as its confidence, or an empty letter if no correlation reaches MinScore.
+func (t *TemplateMatcher) Recognize(letter *image.Gray) (string, float64) {
+	bounds := letter.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	ink := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if letter.GrayAt(bounds.Min.X+x, bounds.Min.Y+y).Y == 0 {
+				ink[y*width+x] = 1
+			}
+		}
+	}
+
+	best, bestScore := "", 0.0
+	for i := range t.templates {
+		tmpl := &t.templates[i]
+		for dy := -t.Shift; dy <= t.Shift; dy++ {
+			for dx := -t.Shift; dx <= t.Shift; dx++ {
+				if score := tmpl.correlate(ink, width, height, dx, dy); score > bestScore {
+					best, bestScore = tmpl.letter, score
+				}
+			}
+		}
+	}
+
+	if best == "" || bestScore < t.MinScore {
+		return "", 0
+	}
+	return best, bestScore
+}
+
+// correlate returns the normalized cross-correlation of the template placed at (dx, dy) over a letter,
+// taken over the area of the template. Letter pixels outside the letter count as white.
+func (tmpl *letterTemplate) correlate(ink []float64, width, height, dx, dy int) float64 {
+
+	// Collect the letter pixels under the template
+	sum, sumSquares, cross := 0.0, 0.0, 0.0
+	for y := 0; y < tmpl.height; y++ {
+		ly := y + dy
+		if ly < 0 || ly >= height {
+			continue
+		}
+		for x := 0; x < tmpl.width; x++ {
+			lx := x + dx
+			if lx < 0 || lx >= width {
+				continue
+			}
+			v := ink[ly*width+lx]
+			sum += v
+			sumSquares += v * v
+			cross += v * tmpl.ink[y*tmpl.width+x]
+		}
+	}
+
+	// Σ(a-ā)(b-b̄) = Σab - ā·Σb, and Σ(a-ā)² = Σa² - (Σa)²/n, over the n pixels of the template
+	n := float64(len(tmpl.ink))
+	variance := sumSquares - sum*sum/n
+	if variance <= 0 {
+		return 0
+	}
+	return (cross - tmpl.mean*sum) / (math.Sqrt(variance) * tmpl.norm)
+}
+
+// Templates returns monochrome letter images from the training data, at most perLetter for every letter,
+// to build a TemplateMatcher from. The entries are picked to cover the range of widths of every letter.
+func Templates(perLetter int) map[string][]*image.Gray {
+	return defaultSolver().Templates(perLetter)
+}
+
+// Templates works like the package-level Templates, using the training data of the Solver.
+func (s *Solver) Templates(perLetter int) map[string][]*image.Gray {
+	m := s.trainingData()
+	byLetter := make(map[string][]decodedFeature)
+	for _, entry := range m.index() {
+		byLetter[entry.letter] = append(byLetter[entry.letter], entry)
+	}
+
+	templates := make(map[string][]*image.Gray, len(byLetter))
+	for letter, entries := range byLetter {
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].bitmap.width != entries[j].bitmap.width {
+				return entries[i].bitmap.width < entries[j].bitmap.width
+			}
+			return entries[i].feature < entries[j].feature
+		})
+
+		// Pick entries evenly spread over the widths, from the narrowest to the widest
+		n := perLetter
+		if n > len(entries) {
+			n = len(entries)
+		}
+		for i := 0; i < n; i++ {
+			j := 0
+			if n > 1 {
+				j = i * (len(entries) - 1) / (n - 1)
+			}
+			binaryStr, err := decodeFeature(entries[j].feature)
+			if err != nil {
+				continue
+			}
+			if img := featureImage(binaryStr, CaptchaHeight); img != nil {
+				templates[letter] = append(templates[letter], img)
+			}
+		}
+	}
+	return templates
+}
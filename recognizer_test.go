@@ -0,0 +1,60 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// padLetter returns a copy of a letter with blank columns added on its left.
+func padLetter(letter *image.Gray, columns int) *image.Gray {
+	padded := image.NewGray(image.Rect(0, 0, letter.Bounds().Dx()+columns, letter.Bounds().Dy()))
+	for i := range padded.Pix {
+		padded.Pix[i] = 255
+	}
+	for y := 0; y < letter.Bounds().Dy(); y++ {
+		copy(padded.Pix[y*padded.Stride+columns:], letter.Pix[y*letter.Stride:(y+1)*letter.Stride])
+	}
+	return padded
+}
+
+func TestTemplateMatcher(t *testing.T) {
+	templates := make(map[string][]*image.Gray)
+	for _, letter := range []string{"K", "X", "H"} {
+		_, binaryStr := trainingLetter(t, letter)
+		templates[letter] = append(templates[letter], featureImage(binaryStr, CaptchaHeight))
+	}
+	matcher := NewTemplateMatcher(templates)
+
+	// Letters misaligned within their segment are recognized
+	for letter, images := range templates {
+		got, score := matcher.Recognize(padLetter(images[0], 2))
+		assert.Equal(t, letter, got)
+		assert.InDelta(t, 1, score, 1e-9)
+	}
+
+	// Letters correlating too weakly with every template are not recognized
+	blank := image.NewGray(image.Rect(0, 0, 20, CaptchaHeight))
+	for i := range blank.Pix {
+		blank.Pix[i] = 255
+	}
+	got, score := matcher.Recognize(blank)
+	assert.Equal(t, "", got)
+	assert.Equal(t, 0.0, score)
+}
+
+func TestNewSolverWithRecognizer(t *testing.T) {
+	captcha := flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)
+
+	templates := Templates(5)
+	assert.Len(t, templates["C"], 5)
+	solver, err := NewSolver(WithRecognizer(NewTemplateMatcher(templates)))
+	assert.NoError(t, err)
+	result, err := solver.SolveDetailed(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", result.Text)
+	assert.Equal(t, 1.0, result.LetterConfidence[0])
+	assert.Less(t, result.LetterConfidence[2], 1.0)
+}
@@ -0,0 +1,137 @@
+package amazoncaptcha
+
+import (
+	"crypto/sha256"
+	"strings"
+)
+
+// Result is a solved captcha together with an estimate of how trustworthy the answer is.
+type Result struct {
+	// Text is the answer, with the placeholder of the Solver, "-" by default, in place of every letter
+	// that could not be recognized.
+	Text string
+	// Confidence is the mean confidence of the letters, between 0 and 1.
+	Confidence float64
+	// LetterConfidence holds the confidence of every letter, between 0 and 1. Letters that could not
+	// be recognized have a confidence of 0, letters found in the training data a confidence of 1.
+	LetterConfidence []float64
+	// Solved reports whether every letter was recognized and the answer passed the answer filter, if any.
+	Solved bool
+	// Strategy is the name of the strategy that produced the answer.
+	Strategy string
+	// Candidates holds, for every letter, the nearest training letters with their distances, closest first,
+	// for re-ranking answers without recognizing the letters again. It is nil unless enabled with WithCandidates.
+	Candidates [][]Candidate
+
+	// unknown holds the positions of the letters that could not be recognized.
+	unknown []int
+	// rejected holds the error of an answer rejected by the answer filter.
+	rejected *AnswerRejectedError
+	// fuzzy maps the features of the letters recognized by approximate matching to their letters.
+	fuzzy map[string]string
+	// fingerprint identifies the pixels of the segmented letters, regardless of the encoding of the image.
+	fingerprint [sha256.Size]byte
+}
+
+// UnknownPositions returns the positions of the letters that could not be recognized, in ascending order.
+// Unlike scanning Text for the placeholder, it works with any placeholder.
+func (r *Result) UnknownPositions() []int {
+	positions := make([]int, len(r.unknown))
+	copy(positions, r.unknown)
+	return positions
+}
+
+// Rejected returns the *AnswerRejectedError of an answer in which every letter was recognized but that was
+// rejected by the answer filter of the Solver, see WithAnswerFilter, or nil otherwise.
+func (r *Result) Rejected() error {
+	if r.rejected == nil {
+		return nil
+	}
+	return r.rejected
+}
+
+// failure returns the error describing why the result is not solved, or nil if it is.
+func (r *Result) failure() error {
+	switch {
+	case r.Solved:
+		return nil
+	case r.rejected != nil:
+		return r.rejected
+	default:
+		return &UnrecognizedLetterError{Positions: r.UnknownPositions()}
+	}
+}
+
+// Candidate is a letter that a segmented letter may be, see Result.Candidates.
+type Candidate struct {
+	// Letter is the candidate letter.
+	Letter string
+	// Distance is the number of pixels in which the segmented letter differs from the nearest
+	// training entry of the letter, 0 for an exact match.
+	Distance int
+}
+
+// newResult builds the result of a strategy from its letter matches.
+func (s *Solver) newResult(strategy string, matches []letterMatch) *Result {
+	text := make([]string, len(matches))
+	confidence := 0.0
+	var fuzzy map[string]string
+	var unknown []int
+	letterConfidence := make([]float64, len(matches))
+	for i, match := range matches {
+		if !s.inCharset(match.letter) {
+			match = letterMatch{feature: match.feature}
+		}
+		if match.letter == "" {
+			text[i] = string(s.placeholder)
+			unknown = append(unknown, i)
+		} else {
+			text[i] = match.letter
+		}
+		confidence += match.confidence
+		letterConfidence[i] = match.confidence
+
+		// Remember the letters that were not matched exactly, they are candidates for self-training
+		if match.letter != "" && match.confidence < 1 {
+			if fuzzy == nil {
+				fuzzy = make(map[string]string)
+			}
+			fuzzy[match.feature] = match.letter
+		}
+	}
+	if len(matches) > 0 {
+		confidence /= float64(len(matches))
+	}
+
+	// Fingerprint the letters by their features, separated so that they cannot run into each other
+	h := sha256.New()
+	for _, match := range matches {
+		h.Write([]byte(match.feature))
+		h.Write([]byte{0})
+	}
+	var fingerprint [sha256.Size]byte
+	h.Sum(fingerprint[:0])
+
+	result := &Result{
+		Text:             strings.Join(text, ""),
+		Confidence:       confidence,
+		LetterConfidence: letterConfidence,
+		Solved:           len(matches) > 0 && len(unknown) == 0,
+		Strategy:         strategy,
+		unknown:          unknown,
+		fuzzy:            fuzzy,
+		fingerprint:      fingerprint,
+	}
+
+	// Reject complete answers failing the answer filter, without any confidence so that strategies ranking
+	// answers prefer every other one
+	if result.Solved && s.answerFilter != nil {
+		if err := s.answerFilter(result.Text); err != nil {
+			result.Solved = false
+			result.Confidence = 0
+			result.rejected = &AnswerRejectedError{Answer: result.Text, Err: err}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,532 @@
+package amazoncaptcha
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/gopkg-dev/amazoncaptcha/resultpb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Results can be serialized to protobuf and msgpack besides JSON, for shipping large numbers of solve records
+// into analytics pipelines. Both forms hold the exported fields of a Result and its unknown positions, so a
+// decoded Result reports the same UnknownPositions as the original one.
+
+// errTruncated is returned when serialized data ends in the middle of a value.
+var errTruncated = errors.New("unexpected end of data")
+
+// MarshalProto encodes the result in the protobuf wire format, as the message Result of resultpb/result.proto.
+// Like every proto3 encoder, it omits fields holding their zero value.
+func (r *Result) MarshalProto() ([]byte, error) {
+	msg := &resultpb.Result{
+		Text:             r.Text,
+		Confidence:       r.Confidence,
+		LetterConfidence: r.LetterConfidence,
+		Solved:           r.Solved,
+		Strategy:         r.Strategy,
+	}
+	for _, position := range r.unknown {
+		msg.UnknownPositions = append(msg.UnknownPositions, int32(position))
+	}
+	for _, candidates := range r.Candidates {
+		letter := &resultpb.LetterCandidates{}
+		for _, c := range candidates {
+			letter.Candidates = append(letter.Candidates, &resultpb.Candidate{Letter: c.Letter, Distance: int32(c.Distance)})
+		}
+		msg.Candidates = append(msg.Candidates, letter)
+	}
+
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal result: %w", err)
+	}
+	return b, nil
+}
+
+// UnmarshalProto decodes a result encoded by MarshalProto, or by any protobuf implementation using
+// resultpb/result.proto, into r. Unknown fields are skipped, so the schema can be extended compatibly.
+func (r *Result) UnmarshalProto(b []byte) error {
+	*r = Result{}
+	var msg resultpb.Result
+	if err := proto.Unmarshal(b, &msg); err != nil {
+		return fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	r.Text = msg.Text
+	r.Confidence = msg.Confidence
+	r.LetterConfidence = msg.LetterConfidence
+	r.Solved = msg.Solved
+	r.Strategy = msg.Strategy
+	for _, position := range msg.UnknownPositions {
+		r.unknown = append(r.unknown, int(position))
+	}
+	for _, letter := range msg.Candidates {
+		var candidates []Candidate
+		for _, c := range letter.Candidates {
+			candidates = append(candidates, Candidate{Letter: c.Letter, Distance: int(c.Distance)})
+		}
+		r.Candidates = append(r.Candidates, candidates)
+	}
+	return nil
+}
+
+// Keys of the msgpack form of a Result, matching the field names of resultpb/result.proto.
+const (
+	msgpackKeyText             = "text"
+	msgpackKeyConfidence       = "confidence"
+	msgpackKeyLetterConfidence = "letter_confidence"
+	msgpackKeySolved           = "solved"
+	msgpackKeyStrategy         = "strategy"
+	msgpackKeyUnknownPositions = "unknown_positions"
+	msgpackKeyCandidates       = "candidates"
+	msgpackKeyLetter           = "letter"
+	msgpackKeyDistance         = "distance"
+)
+
+// MarshalMsgpack encodes the result in the msgpack format, as a map keyed by the field names of resultpb/result.proto.
+// The candidates are an array holding an array of maps per letter.
+func (r *Result) MarshalMsgpack() ([]byte, error) {
+	b := []byte{0x87}
+	b = appendMsgpackString(b, msgpackKeyText)
+	b = appendMsgpackString(b, r.Text)
+	b = appendMsgpackString(b, msgpackKeyConfidence)
+	b = appendMsgpackFloat(b, r.Confidence)
+	b = appendMsgpackString(b, msgpackKeyLetterConfidence)
+	b = appendMsgpackArrayHeader(b, len(r.LetterConfidence))
+	for _, c := range r.LetterConfidence {
+		b = appendMsgpackFloat(b, c)
+	}
+	b = appendMsgpackString(b, msgpackKeySolved)
+	b = appendMsgpackBool(b, r.Solved)
+	b = appendMsgpackString(b, msgpackKeyStrategy)
+	b = appendMsgpackString(b, r.Strategy)
+	b = appendMsgpackString(b, msgpackKeyUnknownPositions)
+	b = appendMsgpackArrayHeader(b, len(r.unknown))
+	for _, position := range r.unknown {
+		b = appendMsgpackInt(b, int64(position))
+	}
+	b = appendMsgpackString(b, msgpackKeyCandidates)
+	if r.Candidates == nil {
+		b = append(b, 0xc0)
+		return b, nil
+	}
+	b = appendMsgpackArrayHeader(b, len(r.Candidates))
+	for _, candidates := range r.Candidates {
+		b = appendMsgpackArrayHeader(b, len(candidates))
+		for _, c := range candidates {
+			b = append(b, 0x82)
+			b = appendMsgpackString(b, msgpackKeyLetter)
+			b = appendMsgpackString(b, c.Letter)
+			b = appendMsgpackString(b, msgpackKeyDistance)
+			b = appendMsgpackInt(b, int64(c.Distance))
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalMsgpack decodes a result encoded by MarshalMsgpack, or by any msgpack implementation
+// using the same keys, into r. Unknown keys are skipped and nil values leave fields at their zero value.
+func (r *Result) UnmarshalMsgpack(b []byte) error {
+	*r = Result{}
+	m := msgpackReader{b: b}
+	n, err := m.mapHeader()
+	if err != nil {
+		return fmt.Errorf("invalid msgpack result: %w", err)
+	}
+	for i := 0; i < n; i++ {
+		key, err := m.readStr()
+		if err != nil {
+			return fmt.Errorf("invalid msgpack result: key: %w", err)
+		}
+		if m.skipNil() {
+			continue
+		}
+
+		switch key {
+		case msgpackKeyText:
+			r.Text, err = m.readStr()
+		case msgpackKeyConfidence:
+			r.Confidence, err = m.readFloat()
+		case msgpackKeyLetterConfidence:
+			var count int
+			if count, err = m.arrayHeader(); err == nil {
+				r.LetterConfidence = make([]float64, count)
+				for j := 0; j < count && err == nil; j++ {
+					r.LetterConfidence[j], err = m.readFloat()
+				}
+			}
+		case msgpackKeySolved:
+			r.Solved, err = m.readBool()
+		case msgpackKeyStrategy:
+			r.Strategy, err = m.readStr()
+		case msgpackKeyUnknownPositions:
+			var count int
+			if count, err = m.arrayHeader(); err == nil {
+				r.unknown = make([]int, count)
+				for j := 0; j < count && err == nil; j++ {
+					var position int64
+					position, err = m.readInt()
+					r.unknown[j] = int(position)
+				}
+			}
+		case msgpackKeyCandidates:
+			r.Candidates, err = m.readCandidates()
+		default:
+			err = m.skip()
+		}
+		if err != nil {
+			return fmt.Errorf("invalid msgpack result: %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// readCandidates reads the candidates of a result: an array holding an array of maps per letter.
+func (m *msgpackReader) readCandidates() ([][]Candidate, error) {
+	letters, err := m.arrayHeader()
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([][]Candidate, letters)
+	for i := range candidates {
+		if m.skipNil() {
+			continue
+		}
+		count, err := m.arrayHeader()
+		if err != nil {
+			return nil, err
+		}
+		candidates[i] = make([]Candidate, count)
+		for j := range candidates[i] {
+			keys, err := m.mapHeader()
+			if err != nil {
+				return nil, err
+			}
+			for k := 0; k < keys; k++ {
+				key, err := m.readStr()
+				if err != nil {
+					return nil, err
+				}
+				switch key {
+				case msgpackKeyLetter:
+					candidates[i][j].Letter, err = m.readStr()
+				case msgpackKeyDistance:
+					var distance int64
+					distance, err = m.readInt()
+					candidates[i][j].Distance = int(distance)
+				default:
+					err = m.skip()
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return candidates, nil
+}
+
+// appendMsgpackString appends s as a msgpack str.
+func appendMsgpackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, 0xda, byte(n>>8), byte(n))
+	default:
+		b = append(b, 0xdb, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(b, s...)
+}
+
+// appendMsgpackFloat appends f as a msgpack float 64.
+func appendMsgpackFloat(b []byte, f float64) []byte {
+	x := math.Float64bits(f)
+	return append(b, 0xcb, byte(x>>56), byte(x>>48), byte(x>>40), byte(x>>32), byte(x>>24), byte(x>>16), byte(x>>8), byte(x))
+}
+
+// appendMsgpackBool appends v as a msgpack bool.
+func appendMsgpackBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 0xc3)
+	}
+	return append(b, 0xc2)
+}
+
+// appendMsgpackInt appends x as a msgpack int in its shortest form.
+func appendMsgpackInt(b []byte, x int64) []byte {
+	switch {
+	case x >= 0 && x < 128:
+		return append(b, byte(x))
+	case x >= -32 && x < 0:
+		return append(b, byte(x))
+	case x >= math.MinInt32 && x <= math.MaxInt32:
+		return append(b, 0xd2, byte(x>>24), byte(x>>16), byte(x>>8), byte(x))
+	default:
+		return append(b, 0xd3, byte(x>>56), byte(x>>48), byte(x>>40), byte(x>>32), byte(x>>24), byte(x>>16), byte(x>>8), byte(x))
+	}
+}
+
+// appendMsgpackArrayHeader appends the header of a msgpack array of n elements.
+func appendMsgpackArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return append(b, 0xdc, byte(n>>8), byte(n))
+	default:
+		return append(b, 0xdd, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+}
+
+// msgpackReader reads the values of msgpack encoded data one at a time.
+type msgpackReader struct {
+	b []byte
+}
+
+// next reads n bytes.
+func (m *msgpackReader) next(n int) ([]byte, error) {
+	if n < 0 || n > len(m.b) {
+		return nil, errTruncated
+	}
+	v := m.b[:n]
+	m.b = m.b[n:]
+	return v, nil
+}
+
+// readUint reads a big-endian unsigned integer of n bytes.
+func (m *msgpackReader) readUint(n int) (uint64, error) {
+	v, err := m.next(n)
+	if err != nil {
+		return 0, err
+	}
+	var x uint64
+	for _, c := range v {
+		x = x<<8 | uint64(c)
+	}
+	return x, nil
+}
+
+// length reads the big-endian length of n bytes following a type byte. Lengths are bounded by the
+// remaining data, since every element takes at least one byte, to detect corrupt data early.
+func (m *msgpackReader) length(n int) (int, error) {
+	x, err := m.readUint(n)
+	if err != nil {
+		return 0, err
+	}
+	if x > uint64(len(m.b)) {
+		return 0, errTruncated
+	}
+	return int(x), nil
+}
+
+// typ reads the type byte of the next value.
+func (m *msgpackReader) typ() (byte, error) {
+	v, err := m.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return v[0], nil
+}
+
+// skipNil skips the next value and reports true if it is nil, and otherwise leaves it unread.
+func (m *msgpackReader) skipNil() bool {
+	if len(m.b) > 0 && m.b[0] == 0xc0 {
+		m.b = m.b[1:]
+		return true
+	}
+	return false
+}
+
+// mapHeader reads the header of a map and returns its number of entries.
+func (m *msgpackReader) mapHeader() (int, error) {
+	t, err := m.typ()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case t&0xf0 == 0x80:
+		return int(t & 0x0f), nil
+	case t == 0xde:
+		return m.length(2)
+	case t == 0xdf:
+		return m.length(4)
+	}
+	return 0, fmt.Errorf("expected map, got type 0x%02x", t)
+}
+
+// arrayHeader reads the header of an array and returns its number of elements.
+func (m *msgpackReader) arrayHeader() (int, error) {
+	t, err := m.typ()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case t&0xf0 == 0x90:
+		return int(t & 0x0f), nil
+	case t == 0xdc:
+		return m.length(2)
+	case t == 0xdd:
+		return m.length(4)
+	}
+	return 0, fmt.Errorf("expected array, got type 0x%02x", t)
+}
+
+// readStr reads a string.
+func (m *msgpackReader) readStr() (string, error) {
+	t, err := m.typ()
+	if err != nil {
+		return "", err
+	}
+	n := 0
+	switch {
+	case t&0xe0 == 0xa0:
+		n = int(t & 0x1f)
+	case t == 0xd9:
+		n, err = m.length(1)
+	case t == 0xda:
+		n, err = m.length(2)
+	case t == 0xdb:
+		n, err = m.length(4)
+	default:
+		return "", fmt.Errorf("expected string, got type 0x%02x", t)
+	}
+	if err != nil {
+		return "", err
+	}
+	v, err := m.next(n)
+	return string(v), err
+}
+
+// readBool reads a bool.
+func (m *msgpackReader) readBool() (bool, error) {
+	t, err := m.typ()
+	if err != nil {
+		return false, err
+	}
+	switch t {
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	}
+	return false, fmt.Errorf("expected bool, got type 0x%02x", t)
+}
+
+// readInt reads an integer of any width.
+func (m *msgpackReader) readInt() (int64, error) {
+	t, err := m.typ()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case t < 0x80:
+		return int64(t), nil
+	case t >= 0xe0:
+		return int64(int8(t)), nil
+	case t >= 0xcc && t <= 0xcf:
+		x, err := m.readUint(1 << (t - 0xcc))
+		if x > math.MaxInt64 {
+			return 0, errors.New("integer overflows int64")
+		}
+		return int64(x), err
+	case t >= 0xd0 && t <= 0xd3:
+		n := 1 << (t - 0xd0)
+		x, err := m.readUint(n)
+		// Sign-extend the value from its encoded width
+		shift := 64 - 8*n
+		return int64(x<<shift) >> shift, err
+	}
+	return 0, fmt.Errorf("expected integer, got type 0x%02x", t)
+}
+
+// readFloat reads a float of either width, or an integer.
+func (m *msgpackReader) readFloat() (float64, error) {
+	if len(m.b) == 0 {
+		return 0, errTruncated
+	}
+	switch m.b[0] {
+	case 0xca:
+		m.b = m.b[1:]
+		x, err := m.readUint(4)
+		return float64(math.Float32frombits(uint32(x))), err
+	case 0xcb:
+		m.b = m.b[1:]
+		x, err := m.readUint(8)
+		return math.Float64frombits(x), err
+	}
+	x, err := m.readInt()
+	return float64(x), err
+}
+
+// skip reads and discards the next value, including all elements of maps and arrays.
+func (m *msgpackReader) skip() error {
+	t, err := m.typ()
+	if err != nil {
+		return err
+	}
+	n := 0
+	elements := 0
+	switch {
+	case t < 0x80 || t >= 0xe0, t == 0xc0, t == 0xc2, t == 0xc3:
+		return nil
+	case t&0xf0 == 0x80:
+		elements = 2 * int(t&0x0f)
+	case t&0xf0 == 0x90:
+		elements = int(t & 0x0f)
+	case t&0xe0 == 0xa0:
+		n = int(t & 0x1f)
+	case t == 0xc4, t == 0xd9:
+		n, err = m.length(1)
+	case t == 0xc5, t == 0xda:
+		n, err = m.length(2)
+	case t == 0xc6, t == 0xdb:
+		n, err = m.length(4)
+	case t == 0xc7:
+		n, err = m.length(1)
+		n++
+	case t == 0xc8:
+		n, err = m.length(2)
+		n++
+	case t == 0xc9:
+		n, err = m.length(4)
+		n++
+	case t == 0xca:
+		n = 4
+	case t == 0xcb:
+		n = 8
+	case t >= 0xcc && t <= 0xcf:
+		n = 1 << (t - 0xcc)
+	case t >= 0xd0 && t <= 0xd3:
+		n = 1 << (t - 0xd0)
+	case t >= 0xd4 && t <= 0xd8:
+		n = 1 + 1<<(t-0xd4)
+	case t == 0xdc:
+		elements, err = m.length(2)
+	case t == 0xdd:
+		elements, err = m.length(4)
+	case t == 0xde:
+		elements, err = m.length(2)
+		elements *= 2
+	case t == 0xdf:
+		elements, err = m.length(4)
+		elements *= 2
+	default:
+		return fmt.Errorf("unsupported type 0x%02x", t)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := m.next(n); err != nil {
+		return err
+	}
+	for i := 0; i < elements; i++ {
+		if err := m.skip(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// candidateSolver is a Solver listing candidates, so that every field of its results is set.
+func candidateSolver(t *testing.T) *Solver {
+	t.Helper()
+	solver, err := NewSolver(WithCandidates(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return solver
+}
+
+func TestResultProto(t *testing.T) {
+	result, err := candidateSolver(t).SolveDetailed(bytes.NewReader(flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	b, err := result.MarshalProto()
+	assert.NoError(t, err)
+	var decoded Result
+	if assert.NoError(t, decoded.UnmarshalProto(b)) {
+		assert.Equal(t, result.Text, decoded.Text)
+		assert.Equal(t, result.Confidence, decoded.Confidence)
+		assert.Equal(t, result.LetterConfidence, decoded.LetterConfidence)
+		assert.Equal(t, result.Solved, decoded.Solved)
+		assert.Equal(t, result.Strategy, decoded.Strategy)
+		assert.Equal(t, []int{2}, decoded.UnknownPositions())
+		assert.Equal(t, result.Candidates, decoded.Candidates)
+	}
+
+	// Unknown fields are skipped
+	extended := protowire.AppendTag(append([]byte(nil), b...), 15, protowire.VarintType)
+	extended = protowire.AppendVarint(extended, 300)
+	assert.NoError(t, decoded.UnmarshalProto(extended))
+	assert.Equal(t, result.Text, decoded.Text)
+
+	// An empty message decodes into an empty result
+	assert.NoError(t, decoded.UnmarshalProto(nil))
+	assert.Equal(t, Result{}, decoded)
+
+	// Truncated data is rejected
+	assert.Error(t, decoded.UnmarshalProto(b[:len(b)-1]))
+}
+
+func TestResultMsgpack(t *testing.T) {
+	result, err := candidateSolver(t).SolveDetailed(bytes.NewReader(flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	b, err := result.MarshalMsgpack()
+	assert.NoError(t, err)
+	var decoded Result
+	if assert.NoError(t, decoded.UnmarshalMsgpack(b)) {
+		assert.Equal(t, result.Text, decoded.Text)
+		assert.Equal(t, result.Confidence, decoded.Confidence)
+		assert.Equal(t, result.LetterConfidence, decoded.LetterConfidence)
+		assert.Equal(t, result.Solved, decoded.Solved)
+		assert.Equal(t, result.Strategy, decoded.Strategy)
+		assert.Equal(t, []int{2}, decoded.UnknownPositions())
+		assert.Equal(t, result.Candidates, decoded.Candidates)
+	}
+
+	// Unknown keys and nil values are skipped, integers are accepted as floats
+	other := []byte{0x84}
+	other = appendMsgpackString(other, "extra")
+	other = append(other, 0x92, 0x81, 0xa1, 'k', 0xc0, 0xcd, 0x01, 0x00)
+	other = appendMsgpackString(other, msgpackKeyText)
+	other = appendMsgpackString(other, "XYTUKH")
+	other = appendMsgpackString(other, msgpackKeyConfidence)
+	other = append(other, 0x01)
+	other = appendMsgpackString(other, msgpackKeyStrategy)
+	other = append(other, 0xc0)
+	if assert.NoError(t, decoded.UnmarshalMsgpack(other)) {
+		assert.Equal(t, Result{Text: "XYTUKH", Confidence: 1}, decoded)
+	}
+
+	// Truncated data is rejected
+	assert.Error(t, decoded.UnmarshalMsgpack(b[:len(b)-1]))
+	assert.Error(t, decoded.UnmarshalMsgpack([]byte{0x91}))
+}
+
+func TestMsgpackInt(t *testing.T) {
+	for _, x := range []int64{0, 5, 127, 128, -1, -32, -33, 70000, -70000, 1 << 40, -1 << 40} {
+		m := msgpackReader{b: appendMsgpackInt(nil, x)}
+		got, err := m.readInt()
+		assert.NoError(t, err)
+		assert.Equal(t, x, got)
+		assert.Empty(t, m.b)
+	}
+}
@@ -0,0 +1,7 @@
+// Package resultpb holds the protobuf message of the serialized form of an amazoncaptcha.Result, generated from
+// result.proto, for pipelines decoding the records written by Result.MarshalProto with protobuf tooling.
+//
+// Regenerate result.pb.go with go generate after changing result.proto.
+package resultpb
+
+//go:generate protoc -I .. --go_out=.. --go_opt=paths=source_relative resultpb/result.proto
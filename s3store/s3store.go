@@ -0,0 +1,193 @@
+// Package s3store implements amazoncaptcha.Store for Amazon S3 and compatible object stores, e.g. MinIO, so that
+// the images of failed solves can be archived from containers without a persistent local disk:
+//
+//	store, err := s3store.FromEnv("my-bucket")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	store.Prefix = "failed/"
+//	amazoncaptcha.SetFailureStore(store)
+//
+// Objects are uploaded with a single PUT request signed with AWS Signature Version 4, without depending on the
+// AWS SDK.
+package s3store
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// The store implements the interface of the failure archive.
+var _ amazoncaptcha.Store = (*Store)(nil)
+
+// Store is an amazoncaptcha.Store uploading every image as an object of an S3 bucket.
+type Store struct {
+	// Bucket is the name of the bucket.
+	Bucket string
+	// Region is the region of the bucket, e.g. "us-east-1".
+	Region string
+	// Endpoint is the base URL of an S3 compatible service, e.g. "http://minio:9000", addressing buckets by
+	// path. If empty, the bucket is addressed as the virtual host <bucket>.s3.<region>.amazonaws.com.
+	Endpoint string
+	// Prefix is prepended to the name of every image to form its object key, e.g. "failed/".
+	Prefix string
+	// AccessKeyID, SecretAccessKey and SessionToken are the credentials signing the requests. SessionToken
+	// is only needed for temporary credentials.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Client makes the requests, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// FromEnv creates a Store for bucket with the credentials and region of the standard AWS environment variables:
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, the optional AWS_SESSION_TOKEN, and AWS_REGION or
+// AWS_DEFAULT_REGION. An S3 compatible service can be selected with AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL.
+// An error is returned if the credentials or the region are missing.
+func FromEnv(bucket string) (*Store, error) {
+	s := &Store{
+		Bucket:          bucket,
+		Region:          firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		Endpoint:        firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"),
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if s.AccessKeyID == "" || s.SecretAccessKey == "" {
+		return nil, errors.New("missing AWS credentials: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if s.Region == "" {
+		return nil, errors.New("missing AWS region: set AWS_REGION")
+	}
+	return s, nil
+}
+
+// firstEnv returns the value of the first environment variable of names that is set.
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Put implements amazoncaptcha.Store: it uploads b as the object named Prefix + name, replacing an existing one.
+func (s *Store) Put(name string, b []byte) error {
+	if s.Bucket == "" || s.Region == "" {
+		return errors.New("S3 store needs a bucket and a region")
+	}
+
+	// Address the object by virtual host on AWS, and by path on other services
+	key := s.Prefix + name
+	target := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.Bucket, s.Region, encodePath(key))
+	if s.Endpoint != "" {
+		target = fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(s.Endpoint, "/"), s.Bucket, encodePath(key))
+	}
+	req, err := http.NewRequest(http.MethodPut, target, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", http.DetectContentType(b))
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	s.sign(req, b, time.Now())
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload image to S3: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to upload image to S3: unexpected HTTP status code %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// sign signs a request with AWS Signature Version 4 at the given time, covering the host, every header already
+// set on the request and the payload.
+func (s *Store) sign(req *http.Request, payload []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256.Sum256(payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	// Canonicalize the signed headers, sorted by their lower-case names
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		encodePath(req.URL.Path),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	// Derive the signing key from the secret, scoped to the date, region and service
+	key := []byte("AWS4" + s.SecretAccessKey)
+	for _, part := range []string{date, s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		s.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// encodePath escapes an object key for use in a URL path as S3 expects: every byte but the unreserved
+// characters of RFC 3986 and the slashes separating its segments is percent-encoded.
+func encodePath(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,206 @@
+package amazoncaptcha
+
+import (
+	"fmt"
+	"image"
+	"io"
+)
+
+// SegmentationStrategy selects how Segment finds the letters of a captcha.
+type SegmentationStrategy int
+
+const (
+	// ColumnScan segments the captcha at the columns without black pixels, see FindLetterBoxes.
+	// It is the strategy used to solve captchas.
+	ColumnScan SegmentationStrategy = iota
+	// ConnectedComponents segments the captcha into groups of touching black pixels, see FindComponentBoxes.
+	ConnectedComponents
+)
+
+// String returns the name of the strategy.
+func (s SegmentationStrategy) String() string {
+	switch s {
+	case ColumnScan:
+		return "column-scan"
+	case ConnectedComponents:
+		return "connected-components"
+	default:
+		return fmt.Sprintf("SegmentationStrategy(%d)", int(s))
+	}
+}
+
+// Segment decodes a captcha image, converts it to monochrome and finds its letter boxes with strategy,
+// returning the boxes from left to right together with the monochrome image they refer to. It exposes
+// the decoding and segmentation stages of the package to recognizers of one's own: unlike FindLetters,
+// it returns the boxes as found, without checking that they form a captcha or merging wrapped letters.
+func Segment(r io.Reader, strategy SegmentationStrategy) ([]image.Rectangle, *image.Gray, error) {
+	return defaultSolver().Segment(r, strategy)
+}
+
+// Segment works like the package-level Segment, using the configuration of the Solver.
+func (s *Solver) Segment(r io.Reader, strategy SegmentationStrategy) ([]image.Rectangle, *image.Gray, error) {
+
+	// Decode the input image and convert it to monochrome
+	img, err := s.decodeImage(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	grayImg := Grayscale(img)
+	grayImg = s.binarize(grayImg, s.threshold(grayImg), nil)
+
+	// Find the letter boxes with the selected strategy
+	switch strategy {
+	case ColumnScan:
+		return FindLetterBoxes(grayImg, s.maxLetterLength), grayImg, nil
+	case ConnectedComponents:
+		return FindComponentBoxes(grayImg, s.maxLetterLength), grayImg, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown segmentation strategy: %v", strategy)
+	}
+}
+
+// DefaultSegmentationConfidence is the segmentation confidence below which a Solver segments captchas again by
+// their connected components, unless configured otherwise with WithSegmentationFallback.
+const DefaultSegmentationConfidence = 0.9
+
+// SegmentationConfidence estimates how likely letterBoxes, as found by Segment, are the letters of a captcha,
+// between 0 and 1, from their number, their widths and the gaps between them. Boxes of the wrong number score 0,
+// fragments far narrower than any letter and boxes touching each other, as the halves of a box split for being
+// too wide do, lower the confidence.
+func SegmentationConfidence(letterBoxes []image.Rectangle) float64 {
+	return defaultSolver().SegmentationConfidence(letterBoxes)
+}
+
+// SegmentationConfidence works like the package-level SegmentationConfidence, using the configuration of the Solver.
+func (s *Solver) SegmentationConfidence(letterBoxes []image.Rectangle) float64 {
+	n := s.captchaLength
+	if len(letterBoxes) != n && len(letterBoxes) != n+1 {
+		return 0
+	}
+
+	// One box too many is fine if it is the tail of the last letter wrapped around to the left edge
+	confidence := 1.0
+	letters := letterBoxes
+	if len(letterBoxes) == n+1 {
+		if letterBoxes[0].Min.X != 0 {
+			confidence /= 2
+		}
+		letters = letterBoxes[1:]
+	}
+
+	// Count the fragments and the boxes touching their left neighbor
+	fragments, touching := 0, 0
+	for i, box := range letters {
+		if box.Dx() < s.minLetterLength/2 {
+			fragments++
+		}
+		if i > 0 && box.Min.X <= letters[i-1].Max.X {
+			touching++
+		}
+	}
+	confidence *= 1 - float64(fragments)/float64(len(letters))
+	if len(letters) > 1 {
+		confidence *= 1 - float64(touching)/float64(len(letters)-1)
+	}
+	return confidence
+}
+
+// findLetterBoxes finds the letter boxes of a monochrome captcha like FindLetterBoxes, unless the segmentation
+// confidence of the boxes is below the minimum of the Solver: then the captcha is segmented again by its
+// connected components, which separates letters whose columns overlap, and the more confident boxes are kept.
+// Components are widened to the height of the captcha, like the letters of the training data, and specks far
+// narrower than any letter are left out.
+func (s *Solver) findLetterBoxes(mono *image.Gray) []image.Rectangle {
+	letterBoxes := FindLetterBoxes(mono, s.maxLetterLength)
+	confidence := s.SegmentationConfidence(letterBoxes)
+	if confidence >= s.minSegmentationConfidence {
+		return letterBoxes
+	}
+
+	bounds := mono.Bounds()
+	components := FindComponentBoxes(mono, s.maxLetterLength)
+	componentBoxes := make([]image.Rectangle, 0, len(components))
+	for _, box := range components {
+		atEdge := box.Min.X == 0 || box.Max.X == bounds.Dx()
+		if box.Dx() < s.minLetterLength/2 && !atEdge {
+			continue
+		}
+		componentBoxes = append(componentBoxes, image.Rect(box.Min.X, 0, box.Max.X, bounds.Dy()))
+	}
+	if s.SegmentationConfidence(componentBoxes) > confidence {
+		return componentBoxes
+	}
+	return letterBoxes
+}
+
+// Letter is a letter of a captcha located and recognized by SegmentLetters.
+type Letter struct {
+	// Image is the letter cropped out of the monochrome captcha. It is a copy owned by the caller, not a view
+	// into the buffers the Solver recycles between solves, so it may be retained and modified.
+	Image *image.Gray
+	// Bounds is where the letter was found in the captcha image.
+	Bounds image.Rectangle
+	// Tail is where the part of a letter wrapped around to the left edge of the captcha was found,
+	// or an empty rectangle if the letter is not wrapped. Image holds the letter with its tail merged back.
+	Tail image.Rectangle
+	// Feature is the feature of the letter the recognition is based on, see ExtractFeatures.
+	Feature string
+	// Text is the recognized letter, or the placeholder of the Solver if the letter could not be recognized.
+	Text string
+	// Confidence is the confidence of the recognition, between 0 and 1.
+	Confidence float64
+	// Guess is the letter of the training entry closest to a letter that could not be recognized, or an empty
+	// string if the letter was recognized or no similar training entry exists.
+	Guess string
+	// Distance is the number of differing pixels between the letter and the training entry behind Guess.
+	Distance int
+}
+
+// SegmentLetters locates and recognizes the letters of a captcha, returning them in captcha order with
+// where they were found in the image, e.g. for UI tools drawing overlays over the detected letters.
+// The letters are recognized like Solve does, but the solve is not recorded in the statistics.
+// If the letters could not be segmented, it returns a *SegmentationError matching ErrSegmentationFailed.
+func SegmentLetters(r io.Reader) ([]Letter, error) {
+	return defaultSolver().SegmentLetters(r)
+}
+
+// SegmentLetters works like the package-level SegmentLetters, using the configuration and training data of the Solver.
+func (s *Solver) SegmentLetters(r io.Reader) ([]Letter, error) {
+
+	// Decode the input image and find the letter boxes in it
+	grayImg, letterBoxes, err := s.locateLetters(r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Recognize the letters, allocated from the heap since they are handed out
+	m := s.recognitionModel()
+	letters, matches, err := s.recognizeLetters(m, grayImg, letterBoxes, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Map the letters back to their boxes, the first of one box too many being the tail of the last letter
+	boxes := letterBoxes
+	if len(boxes) == s.captchaLength+1 {
+		boxes = boxes[1:]
+	}
+	result := make([]Letter, len(letters))
+	for i, letter := range letters {
+		result[i] = Letter{
+			Image:      letter,
+			Bounds:     boxes[i],
+			Feature:    matches[i].feature,
+			Text:       matches[i].letter,
+			Confidence: matches[i].confidence,
+		}
+		if result[i].Text == "" {
+			result[i].Text = string(s.placeholder)
+			result[i].Guess, result[i].Distance = guessLetter(m, matches[i].feature)
+		}
+	}
+	if len(letterBoxes) == s.captchaLength+1 {
+		result[len(result)-1].Tail = letterBoxes[0]
+	}
+	return result, nil
+}
@@ -0,0 +1,147 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSegment(t *testing.T) {
+	captcha := syntheticCaptcha(t, "ABCEFG")
+
+	columns, mono, err := Segment(bytes.NewReader(captcha), ColumnScan)
+	assert.NoError(t, err)
+	assert.Len(t, columns, 6)
+	assert.Equal(t, CaptchaHeight, mono.Bounds().Dy())
+
+	// Well separated letters are found by both strategies, connected components fit them tightly
+	components, _, err := Segment(bytes.NewReader(captcha), ConnectedComponents)
+	assert.NoError(t, err)
+	if assert.Len(t, components, 6) {
+		for i := range components {
+			assert.Equal(t, columns[i].Min.X, components[i].Min.X)
+			assert.Equal(t, columns[i].Max.X, components[i].Max.X)
+			assert.True(t, components[i].In(columns[i]))
+		}
+	}
+
+	_, _, err = Segment(bytes.NewReader(captcha), SegmentationStrategy(42))
+	assert.Error(t, err)
+	_, _, err = Segment(bytes.NewReader([]byte("not an image")), ColumnScan)
+	assert.Error(t, err)
+}
+
+func TestSegmentLetters(t *testing.T) {
+	captcha := syntheticCaptcha(t, "ABCEFG")
+	boxes, _, err := Segment(bytes.NewReader(captcha), ColumnScan)
+	assert.NoError(t, err)
+
+	letters, err := SegmentLetters(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	if assert.Len(t, letters, 6) {
+		for i, letter := range letters {
+			assert.Equal(t, string("ABCEFG"[i]), letter.Text)
+			assert.Equal(t, 1.0, letter.Confidence)
+			assert.Equal(t, boxes[i], letter.Bounds)
+			assert.True(t, letter.Tail.Empty())
+			assert.Equal(t, letter.Bounds.Size(), letter.Image.Bounds().Size())
+			feature, err := ExtractFeatures(letter.Image)
+			assert.NoError(t, err)
+			assert.Equal(t, feature, letter.Feature)
+		}
+	}
+
+	// The letter images are not recycled by later solves
+	pixels := make([][]byte, len(letters))
+	for i, letter := range letters {
+		pixels[i] = append([]byte(nil), letter.Image.Pix...)
+	}
+	for _, answer := range []string{"HJKLMN", "PRTUXY"} {
+		_, err := Solve(bytes.NewReader(syntheticCaptcha(t, answer)))
+		assert.NoError(t, err)
+	}
+	for i, letter := range letters {
+		assert.Equal(t, pixels[i], letter.Image.Pix)
+	}
+
+	// Unknown letters hold the placeholder
+	letters, err = SegmentLetters(bytes.NewReader(flipPixel(t, captcha, 2)))
+	assert.NoError(t, err)
+	assert.Equal(t, "-", letters[2].Text)
+	assert.Zero(t, letters[2].Confidence)
+	assert.Equal(t, "C", letters[2].Guess)
+	assert.Equal(t, 1, letters[2].Distance)
+	assert.Empty(t, letters[1].Guess)
+
+	_, err = SegmentLetters(bytes.NewReader([]byte("not an image")))
+	assert.Error(t, err)
+}
+
+// speckBetween returns the captcha with a black speck filling the two columns of the gap after the letter at
+// position, touching neither letter, so that column scanning merges the letter with the next one.
+func speckBetween(t *testing.T, captcha []byte, position int) []byte {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(captcha))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gray := img.(*image.Gray)
+	x := FindLetterBoxes(gray, MaximumLetterLength)[position].Max.X
+	blank := func(x, y int) bool {
+		return gray.GrayAt(x, y-1).Y != 0 && gray.GrayAt(x, y).Y != 0 && gray.GrayAt(x, y+1).Y != 0
+	}
+	for y := 1; y < CaptchaHeight-1; y++ {
+		if blank(x-1, y) && blank(x+2, y) {
+			gray.SetGray(x, y, color.Gray{})
+			gray.SetGray(x+1, y, color.Gray{})
+			break
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, gray); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSegmentationConfidence(t *testing.T) {
+	boxes, _, err := Segment(bytes.NewReader(syntheticCaptcha(t, "ABCEFG")), ColumnScan)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, SegmentationConfidence(boxes))
+
+	// Boxes of the wrong number, fragments and touching boxes lower the confidence
+	assert.Zero(t, SegmentationConfidence(boxes[:4]))
+	assert.Equal(t, 0.5, SegmentationConfidence(append([]image.Rectangle{image.Rect(1, 0, 20, 70)}, boxes...)))
+	fragmented := append([]image.Rectangle(nil), boxes...)
+	fragmented[5] = image.Rect(fragmented[5].Min.X, 0, fragmented[5].Min.X+3, 70)
+	assert.InDelta(t, 5.0/6, SegmentationConfidence(fragmented), 1e-9)
+	touching := append([]image.Rectangle(nil), boxes...)
+	touching[3].Min.X = touching[2].Max.X
+	assert.InDelta(t, 4.0/5, SegmentationConfidence(touching), 1e-9)
+
+	// A speck between two letters merges them into a box split in touching halves, while their connected
+	// components stay apart, so that the Solver falls back to the components
+	captcha := speckBetween(t, syntheticCaptcha(t, "ABCEFG"), 2)
+	columns, _, err := Segment(bytes.NewReader(captcha), ColumnScan)
+	assert.NoError(t, err)
+	assert.Less(t, SegmentationConfidence(columns), DefaultSegmentationConfidence)
+
+	answer, err := Solve(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", answer)
+
+	// Without the fallback, the halves are not recognized
+	solver, err := NewSolver(WithSegmentationFallback(0))
+	assert.NoError(t, err)
+	answer, err = solver.Solve(bytes.NewReader(captcha))
+	assert.True(t, errors.Is(err, ErrUnrecognizedLetter))
+	assert.NotEqual(t, "ABCEFG", answer)
+
+	_, err = NewSolver(WithSegmentationFallback(2))
+	assert.Error(t, err)
+}
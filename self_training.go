@@ -0,0 +1,103 @@
+package amazoncaptcha
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SetSelfTraining turns self-training on or off. While it is on, every answer reported as accepted
+// through ReportOutcome adds the exact features of its approximately recognized letters, e.g. by
+// SolveBestEffort's nearest strategy, to the live training data, so that they are matched exactly
+// from then on. If persistPath is not empty, the extended training data is also written to that file
+// in its JSON form after every update. Self-training is off by default.
+func SetSelfTraining(enabled bool, persistPath string) {
+	defaultSolver().SetSelfTraining(enabled, persistPath)
+}
+
+// SetSelfTraining turns self-training of the Solver on or off.
+func (s *Solver) SetSelfTraining(enabled bool, persistPath string) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+	s.selfTraining = enabled
+	s.selfTrainingPath = persistPath
+}
+
+// selfTrain adds the features of letters confirmed by an accepted answer to the training data.
+// Persisting errors are ignored since outcome reports cannot fail.
+func (s *Solver) selfTrain(features map[string]string) {
+	s.hooksMu.RLock()
+	defer s.hooksMu.RUnlock()
+	if !s.selfTraining {
+		return
+	}
+
+	m := s.addTrainingData(features)
+	if s.selfTrainingPath != "" {
+		_ = writeTrainingDataFile(s.selfTrainingPath, m.features)
+	}
+}
+
+// Confirm teaches the training data the letters of a captcha whose answer is known to be correct, e.g. because
+// Amazon accepted it: the captcha is segmented again, and the feature of every letter is added to the live
+// training data with the letter of correctAnswer at its position, replacing an entry with the same pixels that
+// shows another letter. It returns the number of entries added or corrected, 0 if every letter was already
+// known. Unlike self-training from ReportOutcome, it does not depend on the solve being among the most recent
+// ones and it also learns the letters the Solver recognized wrong or not at all.
+//
+// If a persist path was configured with SetSelfTraining or WithSelfTraining, the extended training data is
+// written to it, and an error is returned if that fails. An error is also returned if correctAnswer is not
+// made of as many letters as the captchas, of their charset, see WithProfile, and a *SegmentationError if the
+// captcha cannot be segmented into as many letters.
+func Confirm(image io.Reader, correctAnswer string) (int, error) {
+	return defaultSolver().Confirm(image, correctAnswer)
+}
+
+// Confirm works like the package-level Confirm, for the training data of the Solver.
+func (s *Solver) Confirm(image io.Reader, correctAnswer string) (int, error) {
+	answer := strings.ToUpper(correctAnswer)
+	if !s.isLabel(answer) {
+		return 0, fmt.Errorf("invalid answer %q: must be %d letters of the captchas", correctAnswer, s.captchaLength)
+	}
+
+	// Extract the features of the letters as they are stored in the training data
+	letters, err := s.FindLetters(image)
+	if err != nil {
+		return 0, err
+	}
+	features := make([]string, len(letters))
+	for i, letter := range letters {
+		if features[i], err = ExtractFeatures(letter); err != nil {
+			return 0, err
+		}
+	}
+
+	// Add the letters the training data does not know yet, or knows as other letters
+	learned := 0
+	runes := []rune(answer)
+	m := s.updateTrainingData(func(current *model, entries map[string]string) bool {
+		for i, feature := range features {
+			letter := string(runes[i])
+			entry, known, ok := current.lookup(feature)
+			if (ok && known == letter) || entries[feature] == letter {
+				continue
+			}
+			if ok {
+				delete(entries, entry)
+			}
+			entries[feature] = letter
+			learned++
+		}
+		return learned > 0
+	})
+
+	s.hooksMu.RLock()
+	path := s.selfTrainingPath
+	s.hooksMu.RUnlock()
+	if learned > 0 && path != "" {
+		if err := writeTrainingDataFile(path, m.features); err != nil {
+			return learned, err
+		}
+	}
+	return learned, nil
+}
@@ -0,0 +1,105 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfTraining(t *testing.T) {
+	original := defaultSolver().trainingData()
+	defer defaultSolver().setTrainingData(original.features)
+
+	path := filepath.Join(t.TempDir(), "training_data.json")
+	SetSelfTraining(true, path)
+	defer SetSelfTraining(false, "")
+
+	captcha := flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)
+	result, err := SolveBestEffort(context.Background(), bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", result.Text)
+
+	// A rejected answer teaches nothing
+	ReportOutcome(ImageHash(captcha), false)
+	assert.Same(t, original, defaultSolver().trainingData())
+
+	// An accepted answer teaches the approximately recognized letter
+	ReportOutcome(ImageHash(captcha), true)
+	answer, err := Solve(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", answer)
+	assert.Len(t, defaultSolver().trainingData().features, len(original.features)+1)
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	persisted, err := parseTrainingData(b)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultSolver().trainingData().features, persisted)
+}
+
+func TestConfirm(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "training_data.json")
+	solver, err := NewSolver(WithSelfTraining(path))
+	if !assert.NoError(t, err) {
+		return
+	}
+	size := len(solver.TrainingData())
+
+	// Confirming an answer teaches the unknown letter
+	captcha := flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)
+	learned, err := solver.Confirm(bytes.NewReader(captcha), "abcefg")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, learned)
+	answer, err := solver.Solve(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", answer)
+	assert.Len(t, solver.TrainingData(), size+1)
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	persisted, err := parseTrainingData(b)
+	assert.NoError(t, err)
+	assert.Equal(t, solver.TrainingData(), persisted)
+
+	// Confirming it again teaches nothing
+	learned, err = solver.Confirm(bytes.NewReader(captcha), "ABCEFG")
+	assert.NoError(t, err)
+	assert.Zero(t, learned)
+
+	// Confirming another letter corrects the entry
+	learned, err = solver.Confirm(bytes.NewReader(captcha), "ABXEFG")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, learned)
+	answer, err = solver.Solve(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABXEFG", answer)
+	assert.Len(t, solver.TrainingData(), size+1)
+
+	_, err = solver.Confirm(bytes.NewReader(captcha), "ABCEF")
+	assert.Error(t, err)
+	_, err = solver.Confirm(bytes.NewReader(captcha), "ABCEF1")
+	assert.Error(t, err)
+	_, err = solver.Confirm(bytes.NewReader([]byte("not an image")), "ABCEFG")
+	assert.Error(t, err)
+
+	// Answers are checked against the length and charset of the profile of the Solver
+	p := profile()
+	p.Length = 5
+	p.Charset = "HJKLMN"
+	solver, err = NewSolver(WithProfile(p))
+	if !assert.NoError(t, err) {
+		return
+	}
+	short := syntheticCaptcha(t, "HJKLM")
+	learned, err = solver.Confirm(bytes.NewReader(short), "hjkln")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, learned)
+	_, err = solver.Confirm(bytes.NewReader(short), "HJKLMN")
+	assert.ErrorContains(t, err, "must be 5 letters")
+	_, err = solver.Confirm(bytes.NewReader(short), "HJKLA")
+	assert.ErrorContains(t, err, "must be 5 letters")
+}
@@ -0,0 +1,102 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// selfTestCorpus holds the captchas solved by SelfTest, each named after its answer.
+// The captchas are rendered from the training data, so they carry no third-party license.
+//
+//go:embed selftest/*.png
+var selfTestCorpus embed.FS
+
+// SelfTestFailure describes a self-test captcha that was not solved correctly.
+type SelfTestFailure struct {
+	// Name is the file name of the captcha in the corpus.
+	Name string
+	// Want is the known answer of the captcha.
+	Want string
+	// Got is the answer returned by the solver, if any.
+	Got string
+	// Err is the error returned by the solver, if any.
+	Err error
+}
+
+// SelfTestError is returned by SelfTest when some captchas of the corpus were not solved correctly.
+type SelfTestError struct {
+	// Total is the number of captchas in the corpus.
+	Total int
+	// Failures holds the captchas that were not solved correctly.
+	Failures []SelfTestFailure
+}
+
+// Error implements the error interface.
+func (e *SelfTestError) Error() string {
+	failures := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		if failure.Err != nil {
+			failures[i] = fmt.Sprintf("%s: %v", failure.Name, failure.Err)
+		} else {
+			failures[i] = fmt.Sprintf("%s: got %q, want %q", failure.Name, failure.Got, failure.Want)
+		}
+	}
+	return fmt.Sprintf("self-test failed for %d of %d captchas: %s", len(e.Failures), e.Total, strings.Join(failures, "; "))
+}
+
+// SelfTest solves a small embedded corpus of captchas with known answers and returns a *SelfTestError
+// if any of them is not solved correctly. It needs no external captcha files, which makes it suitable
+// for readiness checks of services and for smoke tests after loading custom training data.
+// Self-test solves are not recorded in the statistics or the journal, and trigger no capture hooks.
+func SelfTest() error {
+	return defaultSolver().SelfTest()
+}
+
+// SelfTest works like the package-level SelfTest, using the configuration and training data of the Solver.
+func (s *Solver) SelfTest() error {
+	captchas, err := SelfTestCaptchas()
+	if err != nil {
+		return err
+	}
+
+	// Solve the corpus without observing the solves
+	probe := s.detached()
+	selfTestErr := &SelfTestError{Total: len(captchas)}
+	for _, captcha := range captchas {
+		result, err := probe.solve(bytes.NewReader(captcha.Image))
+		if err != nil {
+			selfTestErr.Failures = append(selfTestErr.Failures, SelfTestFailure{Name: captcha.Name, Want: captcha.Answer, Err: err})
+			continue
+		}
+		if result.Text != captcha.Answer {
+			selfTestErr.Failures = append(selfTestErr.Failures, SelfTestFailure{Name: captcha.Name, Want: captcha.Answer, Got: result.Text})
+		}
+	}
+
+	if len(selfTestErr.Failures) > 0 {
+		return selfTestErr
+	}
+	return nil
+}
+
+// SelfTestCaptchas returns the embedded corpus solved by SelfTest, e.g. as sample input of benchmarks or of
+// evaluations that need no external captcha files.
+func SelfTestCaptchas() ([]LabeledCaptcha, error) {
+	entries, err := selfTestCorpus.ReadDir("selftest")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read self-test corpus: %w", err)
+	}
+	captchas := make([]LabeledCaptcha, 0, len(entries))
+	for _, entry := range entries {
+		b, err := selfTestCorpus.ReadFile(path.Join("selftest", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read self-test corpus: %w", err)
+		}
+		answer := strings.TrimSuffix(entry.Name(), path.Ext(entry.Name()))
+		captchas = append(captchas, LabeledCaptcha{Name: entry.Name(), Answer: answer, Image: b})
+	}
+	return captchas, nil
+}
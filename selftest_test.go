@@ -0,0 +1,37 @@
+package amazoncaptcha
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfTest(t *testing.T) {
+	requireTrainingData(t)
+	stats := SolveStats()
+	assert.NoError(t, SelfTest())
+	assert.Equal(t, stats.Solves, SolveStats().Solves)
+
+	// Training data missing most letters fails the self-test
+	feature, _ := trainingLetter(t, "A")
+	solver, err := NewSolver(WithTrainingData(strings.NewReader(fmt.Sprintf(`{%q: "A"}`, feature))))
+	assert.NoError(t, err)
+
+	var selfTestErr *SelfTestError
+	if assert.True(t, errors.As(solver.SelfTest(), &selfTestErr)) {
+		assert.Equal(t, 12, selfTestErr.Total)
+		assert.Len(t, selfTestErr.Failures, 12)
+		assert.Equal(t, "ABCEFG", selfTestErr.Failures[0].Want)
+		assert.Equal(t, "A-----", selfTestErr.Failures[0].Got)
+	}
+
+	captchas, err := SelfTestCaptchas()
+	assert.NoError(t, err)
+	if assert.Len(t, captchas, 12) {
+		assert.Equal(t, LabeledCaptcha{Name: "ABCEFG.png", Answer: "ABCEFG", Image: captchas[0].Image}, captchas[0])
+		assert.NotEmpty(t, captchas[0].Image)
+	}
+}
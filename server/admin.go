@@ -0,0 +1,295 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// maxModelHistory is the number of previous models kept for rolling back.
+const maxModelHistory = 5
+
+// maxModelSize is the size limit of uploaded training data, in bytes.
+const maxModelSize = 64 << 20
+
+// ModelInfo describes a model, the training data used by the solver of a Server.
+type ModelInfo struct {
+	// Version numbers the models activated since the Server was created, starting at 1.
+	Version int `json:"version"`
+	// Source tells where the model came from: "initial", "upload", "reload" or "rollback".
+	Source string `json:"source"`
+	// LoadedAt is when the model was activated.
+	LoadedAt time.Time `json:"loaded_at"`
+	// Entries is the number of training entries.
+	Entries int `json:"entries"`
+	// Letters counts the training entries per letter.
+	Letters map[string]int `json:"letters"`
+	// SHA256 identifies the training entries, regardless of the form they were uploaded in.
+	SHA256 string `json:"sha256"`
+}
+
+// model is a model activated by a Server, with the training data to roll back to.
+type model struct {
+	info     ModelInfo
+	features map[string]string
+}
+
+// admin holds the configuration of the admin API and the model managers of the models of a Server.
+type admin struct {
+	token    string
+	source   string
+	managers map[string]*modelManager
+}
+
+// modelManager keeps the active training data of a model and the previous ones.
+type modelManager struct {
+	solver *amazoncaptcha.Solver
+
+	mu      sync.Mutex
+	version int
+	active  model
+	history []model
+}
+
+// WithAdmin enables the admin API under /admin/, authenticated by the bearer token, to manage the model of the
+// Server at runtime:
+//
+//	GET  /admin/model           describes the active model and the models available for rolling back
+//	POST /admin/model           activates the training data of the body, in its JSON or binary form
+//	POST /admin/model/reload    activates the training data read again from source
+//	POST /admin/model/rollback  activates the model that was active before the current one
+//
+// The endpoints manage the default model, or the model hosted with WithModel named by the "model" query
+// parameter, each model keeping its own history. Source is the path of the training data file of the default
+// model reloaded by /admin/model/reload, which answers with the status code 409 if it is empty or for other
+// models. Every change of the model triggers an evaluation if one is configured with WithEvaluation, so that a
+// regressing model is caught right away. Activating training data replaces the whole training data of the
+// solver, see amazoncaptcha.Solver.SetTrainingData.
+func WithAdmin(token, source string) Option {
+	return func(s *Server) error {
+		if token == "" {
+			return errors.New("admin token must not be empty")
+		}
+		s.admin = &admin{token: token, source: source}
+		return nil
+	}
+}
+
+// registerAdmin records the initial training data of every model and registers the admin API.
+func (s *Server) registerAdmin() {
+	s.admin.managers = make(map[string]*modelManager, len(s.models))
+	for name, solver := range s.models {
+		m := &modelManager{solver: solver}
+		m.activate(solver.TrainingData(), "initial", false)
+		s.admin.managers[name] = m
+	}
+	s.mux.HandleFunc("/admin/model", s.authorized(s.handleModel))
+	s.mux.HandleFunc("/admin/model/reload", s.authorized(s.handleReload))
+	s.mux.HandleFunc("/admin/model/rollback", s.authorized(s.handleRollback))
+}
+
+// authorized wraps an admin handler, refusing requests without the admin token as a Bearer token.
+func (s *Server) authorized(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Require the Bearer scheme, the auth scheme being case-insensitive, before comparing the tokens
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || subtle.ConstantTimeCompare([]byte(token), []byte(s.admin.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// modelResponse is the JSON body of the responses of the admin API.
+type modelResponse struct {
+	Active  ModelInfo   `json:"active"`
+	History []ModelInfo `json:"history"`
+}
+
+// manager returns the model manager of the model named by the query of an admin request,
+// answering with the status code 404 if there is none.
+func (s *Server) manager(w http.ResponseWriter, r *http.Request) (string, *modelManager) {
+	name := r.URL.Query().Get("model")
+	if name == "" {
+		name = DefaultModel
+	}
+	m, ok := s.admin.managers[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown model %q", name))
+	}
+	return name, m
+}
+
+// handleModel implements GET and POST /admin/model.
+func (s *Server) handleModel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	name, m := s.manager(w, r)
+	if m == nil {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, m.describe())
+	case http.MethodPost:
+		features, err := amazoncaptcha.DecodeTrainingData(http.MaxBytesReader(w, r.Body, maxModelSize))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.activateModel(w, name, m, features, "upload")
+	}
+}
+
+// handleReload implements POST /admin/model/reload.
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	name, m := s.manager(w, r)
+	if m == nil {
+		return
+	}
+	if name != DefaultModel {
+		writeError(w, http.StatusConflict, "only the default model can be reloaded")
+		return
+	}
+	if s.admin.source == "" {
+		writeError(w, http.StatusConflict, "no training data source configured")
+		return
+	}
+	file, err := os.Open(s.admin.source)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to open training data: %v", err))
+		return
+	}
+	defer file.Close()
+	features, err := amazoncaptcha.DecodeTrainingData(file)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.activateModel(w, name, m, features, "reload")
+}
+
+// handleRollback implements POST /admin/model/rollback.
+func (s *Server) handleRollback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	name, m := s.manager(w, r)
+	if m == nil {
+		return
+	}
+	if !m.rollback() {
+		writeError(w, http.StatusConflict, "no previous model to roll back to")
+		return
+	}
+	s.evaluateModel(name)
+	writeJSON(w, http.StatusOK, m.describe())
+}
+
+// activateModel activates training data of the model named name and answers with the description of its models.
+func (s *Server) activateModel(w http.ResponseWriter, name string, m *modelManager, features map[string]string, source string) {
+	if len(features) == 0 {
+		writeError(w, http.StatusBadRequest, "training data is empty")
+		return
+	}
+	m.activate(features, source, true)
+	s.evaluateModel(name)
+	writeJSON(w, http.StatusOK, m.describe())
+}
+
+// evaluateModel evaluates the corpus in the background after the default model changed, if an evaluation is configured.
+func (s *Server) evaluateModel(name string) {
+	if s.evaluator != nil && name == DefaultModel {
+		go s.Evaluate(context.Background())
+	}
+}
+
+// activate makes features the training data of the solver, keeping the active model for rolling back if keep is set.
+func (m *modelManager) activate(features map[string]string, source string, keep bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if keep {
+		m.history = append(m.history, m.active)
+		if len(m.history) > maxModelHistory {
+			m.history = m.history[len(m.history)-maxModelHistory:]
+		}
+	}
+	m.version++
+	m.active = model{info: describeModel(m.version, source, features), features: features}
+	m.solver.SetTrainingData(features)
+}
+
+// rollback makes the previous model the training data of the solver again, and reports whether there was one.
+// The rolled back model is not kept, so rolling back repeatedly walks back through the history.
+func (m *modelManager) rollback() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.history) == 0 {
+		return false
+	}
+	previous := m.history[len(m.history)-1]
+	m.history = m.history[:len(m.history)-1]
+	m.version++
+	m.active = model{info: describeModel(m.version, "rollback", previous.features), features: previous.features}
+	m.solver.SetTrainingData(previous.features)
+	return true
+}
+
+// describe returns the description of the active model and of the previous ones, most recent first.
+func (m *modelManager) describe() modelResponse {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	resp := modelResponse{Active: m.active.info, History: make([]ModelInfo, 0, len(m.history))}
+	for i := len(m.history) - 1; i >= 0; i-- {
+		resp.History = append(resp.History, m.history[i].info)
+	}
+	return resp
+}
+
+// describeModel describes training data activated as the given version.
+func describeModel(version int, source string, features map[string]string) ModelInfo {
+	info := ModelInfo{
+		Version:  version,
+		Source:   source,
+		LoadedAt: time.Now(),
+		Entries:  len(features),
+		Letters:  make(map[string]int),
+	}
+
+	// Hash the entries in the order of their features, so that the hash does not depend on the form of the upload
+	keys := make([]string, 0, len(features))
+	for k, v := range features {
+		keys = append(keys, k)
+		info.Letters[v]++
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s\t%s\n", k, features[k])
+	}
+	info.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return info
+}
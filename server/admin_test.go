@@ -0,0 +1,131 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+	"github.com/stretchr/testify/assert"
+)
+
+// adminRequest builds an admin API request authenticated by token.
+func adminRequest(method, target, token string, body []byte) *http.Request {
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestAdminModel(t *testing.T) {
+	solver, err := amazoncaptcha.NewSolver()
+	assert.NoError(t, err)
+	initial := solver.TrainingData()
+	source := filepath.Join(t.TempDir(), "training_data.json")
+	s, err := New(WithSolver(solver), WithAdmin("secret", source))
+	assert.NoError(t, err)
+
+	// Requests without the token are refused
+	code, _ := serve(t, s, adminRequest(http.MethodGet, "/admin/model", "", nil))
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = serve(t, s, adminRequest(http.MethodGet, "/admin/model", "wrong", nil))
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// The token alone, without the Bearer scheme, is refused too
+	for _, header := range []string{"secret", "Basic secret", "Bearersecret"} {
+		req := adminRequest(http.MethodGet, "/admin/model", "", nil)
+		req.Header.Set("Authorization", header)
+		code, _ = serve(t, s, req)
+		assert.Equal(t, http.StatusUnauthorized, code, header)
+	}
+
+	code, body := serve(t, s, adminRequest(http.MethodGet, "/admin/model", "secret", nil))
+	assert.Equal(t, http.StatusOK, code)
+	active := body["active"].(map[string]interface{})
+	assert.Equal(t, 1.0, active["version"])
+	assert.Equal(t, "initial", active["source"])
+	assert.Equal(t, float64(len(initial)), active["entries"])
+	assert.Empty(t, body["history"])
+	code, _ = serve(t, s, adminRequest(http.MethodPost, "/admin/model/rollback", "secret", nil))
+	assert.Equal(t, http.StatusConflict, code)
+
+	// Uploading training data replaces the model, the binary form hashing like the JSON form
+	var upload bytes.Buffer
+	assert.NoError(t, amazoncaptcha.EncodeTrainingData(&upload, map[string]string{"00ff": "A"}))
+	code, body = serve(t, s, adminRequest(http.MethodPost, "/admin/model", "secret", upload.Bytes()))
+	assert.Equal(t, http.StatusOK, code)
+	active = body["active"].(map[string]interface{})
+	assert.Equal(t, 2.0, active["version"])
+	assert.Equal(t, 1.0, active["entries"])
+	assert.Equal(t, map[string]interface{}{"A": 1.0}, active["letters"])
+	assert.Len(t, body["history"], 1)
+	assert.Equal(t, map[string]string{"00ff": "A"}, solver.TrainingData())
+	assert.Equal(t, describeModel(0, "", map[string]string{"00ff": "A"}).SHA256, active["sha256"])
+
+	code, _ = serve(t, s, adminRequest(http.MethodPost, "/admin/model", "secret", []byte("{}")))
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = serve(t, s, adminRequest(http.MethodPost, "/admin/model", "secret", []byte("{")))
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// Reloading reads the source again
+	code, _ = serve(t, s, adminRequest(http.MethodPost, "/admin/model/reload", "secret", nil))
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.NoError(t, os.WriteFile(source, []byte(`{"00ff": "B", "ff00": "C"}`), 0o644))
+	code, body = serve(t, s, adminRequest(http.MethodPost, "/admin/model/reload", "secret", nil))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "reload", body["active"].(map[string]interface{})["source"])
+	assert.Equal(t, map[string]string{"00ff": "B", "ff00": "C"}, solver.TrainingData())
+
+	// Rolling back walks back through the history
+	code, body = serve(t, s, adminRequest(http.MethodPost, "/admin/model/rollback", "secret", nil))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "rollback", body["active"].(map[string]interface{})["source"])
+	assert.Equal(t, map[string]string{"00ff": "A"}, solver.TrainingData())
+	code, _ = serve(t, s, adminRequest(http.MethodPost, "/admin/model/rollback", "secret", nil))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, initial, solver.TrainingData())
+	code, _ = serve(t, s, adminRequest(http.MethodPost, "/admin/model/rollback", "secret", nil))
+	assert.Equal(t, http.StatusConflict, code)
+
+	code, _ = serve(t, s, adminRequest(http.MethodDelete, "/admin/model", "secret", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+	code, _ = serve(t, s, adminRequest(http.MethodGet, "/admin/model/reload", "secret", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+}
+
+func TestAdminDisabled(t *testing.T) {
+	s, err := New()
+	assert.NoError(t, err)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, adminRequest(http.MethodGet, "/admin/model", "secret", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	s, err = New(WithAdmin("secret", ""))
+	assert.NoError(t, err)
+	code, body := serve(t, s, adminRequest(http.MethodPost, "/admin/model/reload", "secret", nil))
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Contains(t, body["message"], "source")
+
+	// Named models are managed separately, but cannot be reloaded
+	solver, err := amazoncaptcha.NewSolver()
+	assert.NoError(t, err)
+	s, err = New(WithModel("other", solver), WithAdmin("secret", "training_data.json"))
+	assert.NoError(t, err)
+	code, _ = serve(t, s, adminRequest(http.MethodPost, "/admin/model?model=other", "secret", []byte(`{"00ff": "A"}`)))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]string{"00ff": "A"}, solver.TrainingData())
+	code, body = serve(t, s, adminRequest(http.MethodGet, "/admin/model", "secret", nil))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, body["history"])
+	code, _ = serve(t, s, adminRequest(http.MethodPost, "/admin/model/reload?model=other", "secret", nil))
+	assert.Equal(t, http.StatusConflict, code)
+	code, _ = serve(t, s, adminRequest(http.MethodGet, "/admin/model?model=missing", "secret", nil))
+	assert.Equal(t, http.StatusNotFound, code)
+
+	_, err = New(WithAdmin("", ""))
+	assert.Error(t, err)
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AnomalyKind names a suspicious pattern in the requests of a client.
+type AnomalyKind string
+
+const (
+	// AnomalyRepeatedImage flags a client submitting the same image over and over, e.g. a scraper stuck in a retry loop.
+	AnomalyRepeatedImage AnomalyKind = "repeated_image"
+	// AnomalyFailureRate flags a client whose requests mostly fail, e.g. one sending images that are not captchas.
+	AnomalyFailureRate AnomalyKind = "failure_rate"
+)
+
+// anomalyKinds lists the anomaly kinds, in the order of the metrics.
+var anomalyKinds = []AnomalyKind{AnomalyRepeatedImage, AnomalyFailureRate}
+
+// Anomaly is a suspicious pattern detected in the solve requests of a client, see WithAnomalyDetection.
+type Anomaly struct {
+	// Kind names the pattern.
+	Kind AnomalyKind
+	// Client identifies the client, see WithClientIdentifier.
+	Client string
+	// RequestID is the ID of the request that crossed the threshold.
+	RequestID string
+	// Time is when the anomaly was detected.
+	Time time.Time
+	// Requests is the number of solve requests of the client in the current window.
+	Requests int
+	// Count is the number of submissions of the repeated image, or the number of failed requests.
+	Count int
+	// ImageHash identifies the repeated image, see amazoncaptcha.ImageHash. It is empty for other anomalies.
+	ImageHash string
+}
+
+// AnomalyThresholds configures when the requests of a client are flagged as anomalous.
+type AnomalyThresholds struct {
+	// Window is the period over which the requests of a client are counted. Counts start over every window.
+	Window time.Duration
+	// MaxRepeats is the number of times a client may submit the same image within a window.
+	MaxRepeats int
+	// MaxFailureRate is the share of failed requests, between 0 and 1, above which a client is flagged.
+	MaxFailureRate float64
+	// MinRequests is the number of requests of a client within a window before its failure rate is checked.
+	MinRequests int
+}
+
+// DefaultAnomalyThresholds are thresholds that leave well-behaved scrapers alone: legitimate clients
+// rarely submit the same captcha twice, and Amazon captchas are solved far more often than not.
+var DefaultAnomalyThresholds = AnomalyThresholds{
+	Window:         10 * time.Minute,
+	MaxRepeats:     5,
+	MaxFailureRate: 0.5,
+	MinRequests:    20,
+}
+
+// maxTrackedClients is the number of clients tracked at once, bounding the memory used by the detection.
+const maxTrackedClients = 10000
+
+// maxTrackedImages is the number of distinct images tracked per client and window.
+const maxTrackedImages = 1000
+
+// anomalyDetector counts the requests of every client over tumbling windows and reports the anomalies.
+type anomalyDetector struct {
+	thresholds AnomalyThresholds
+	hook       func(Anomaly)
+
+	mu       sync.Mutex
+	clients  map[string]*clientWindow
+	detected map[AnomalyKind]uint64
+}
+
+// clientWindow counts the requests of a client within the current window.
+type clientWindow struct {
+	start    time.Time
+	requests int
+	failures int
+	images   map[string]int
+	flagged  bool
+}
+
+// WithAnomalyDetection makes the Server watch the solve requests of every client for suspicious patterns, as
+// configured by thresholds, e.g. DefaultAnomalyThresholds, so that operators of shared instances can detect
+// misbehaving clients: the same image submitted more than MaxRepeats times within a window, or a failure rate
+// above MaxFailureRate. Every anomaly is counted in the metrics and passed to hook, if not nil, once per client,
+// kind and window, or once per image for repeated images. The hook runs on the request goroutine and should
+// return quickly. Clients are told apart by their IP address unless configured with WithClientIdentifier.
+func WithAnomalyDetection(thresholds AnomalyThresholds, hook func(Anomaly)) Option {
+	return func(s *Server) error {
+		if thresholds.Window <= 0 {
+			return errors.New("anomaly window must be positive")
+		}
+		if thresholds.MaxRepeats < 1 {
+			return errors.New("maximum repeats must be positive")
+		}
+		if thresholds.MaxFailureRate < 0 || thresholds.MaxFailureRate > 1 {
+			return errors.New("maximum failure rate must be between 0 and 1")
+		}
+		s.anomalies = &anomalyDetector{
+			thresholds: thresholds,
+			hook:       hook,
+			clients:    make(map[string]*clientWindow),
+			detected:   make(map[AnomalyKind]uint64),
+		}
+		return nil
+	}
+}
+
+// WithClientIdentifier makes the Server tell clients apart by identify for the anomaly detection, e.g. by an
+// API key header, or by the X-Forwarded-For header behind a trusted proxy. By default, clients are told apart
+// by the IP address the requests come from.
+func WithClientIdentifier(identify func(*http.Request) string) Option {
+	return func(s *Server) error {
+		if identify == nil {
+			return errors.New("client identifier must not be nil")
+		}
+		s.identify = identify
+		return nil
+	}
+}
+
+// remoteIP identifies the client of a request by its IP address.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// observe counts a solve request of client, with the hash of its image if it could be read,
+// and reports the anomalies it reveals.
+func (d *anomalyDetector) observe(client, requestID, hash string, failed bool) {
+	now := time.Now()
+	var anomalies []Anomaly
+	d.mu.Lock()
+	c := d.window(client, now)
+	if c == nil {
+		d.mu.Unlock()
+		return
+	}
+	c.requests++
+	if failed {
+		c.failures++
+	}
+
+	// Report the repeated image once, when it crosses the threshold
+	if hash != "" && (c.images[hash] > 0 || len(c.images) < maxTrackedImages) {
+		c.images[hash]++
+		if c.images[hash] == d.thresholds.MaxRepeats+1 {
+			anomalies = append(anomalies, Anomaly{Kind: AnomalyRepeatedImage, Count: c.images[hash], ImageHash: hash})
+		}
+	}
+	if !c.flagged && c.requests >= d.thresholds.MinRequests &&
+		float64(c.failures) > d.thresholds.MaxFailureRate*float64(c.requests) {
+		c.flagged = true
+		anomalies = append(anomalies, Anomaly{Kind: AnomalyFailureRate, Count: c.failures})
+	}
+	for i := range anomalies {
+		anomalies[i].Client = client
+		anomalies[i].RequestID = requestID
+		anomalies[i].Time = now
+		anomalies[i].Requests = c.requests
+		d.detected[anomalies[i].Kind]++
+	}
+	d.mu.Unlock()
+
+	if d.hook != nil {
+		for _, anomaly := range anomalies {
+			d.hook(anomaly)
+		}
+	}
+}
+
+// window returns the current window of client, starting a new one if it expired, or nil if too many clients
+// are tracked already. It must be called with the lock held.
+func (d *anomalyDetector) window(client string, now time.Time) *clientWindow {
+	c, ok := d.clients[client]
+	if ok && now.Sub(c.start) < d.thresholds.Window {
+		return c
+	}
+	if !ok && len(d.clients) >= maxTrackedClients {
+		// Forget the clients whose window expired, and leave new clients untracked if there are none
+		for id, other := range d.clients {
+			if now.Sub(other.start) >= d.thresholds.Window {
+				delete(d.clients, id)
+			}
+		}
+		if len(d.clients) >= maxTrackedClients {
+			return nil
+		}
+	}
+	c = &clientWindow{start: now, images: make(map[string]int)}
+	d.clients[client] = c
+	return c
+}
+
+// counts returns the number of anomalies detected per kind, and the number of clients flagged in their current window.
+func (d *anomalyDetector) counts() (map[AnomalyKind]uint64, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	detected := make(map[AnomalyKind]uint64, len(d.detected))
+	for kind, n := range d.detected {
+		detected[kind] = n
+	}
+	flagged := 0
+	now := time.Now()
+	for _, c := range d.clients {
+		if now.Sub(c.start) >= d.thresholds.Window {
+			continue
+		}
+		repeated := false
+		for _, n := range c.images {
+			if n > d.thresholds.MaxRepeats {
+				repeated = true
+				break
+			}
+		}
+		if c.flagged || repeated {
+			flagged++
+		}
+	}
+	return detected, flagged
+}
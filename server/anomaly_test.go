@@ -0,0 +1,90 @@
+package server
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnomalyDetection(t *testing.T) {
+	var anomalies []Anomaly
+	thresholds := AnomalyThresholds{Window: time.Hour, MaxRepeats: 2, MaxFailureRate: 0.5, MinRequests: 4}
+	s, err := New(
+		WithAnomalyDetection(thresholds, func(a Anomaly) { anomalies = append(anomalies, a) }),
+		WithClientIdentifier(func(r *http.Request) string { return r.Header.Get("X-Client") }),
+	)
+	assert.NoError(t, err)
+	solve := func(client string, image []byte) {
+		req := upload(t, "image", image)
+		req.Header.Set("X-Client", client)
+		s.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// The same image submitted a third time is flagged once, for that client only
+	captcha := renderCaptcha(t, "ABCEFG")
+	for i := 0; i < 4; i++ {
+		solve("scraper", captcha)
+	}
+	solve("other", captcha)
+	if assert.Len(t, anomalies, 1) {
+		assert.Equal(t, AnomalyRepeatedImage, anomalies[0].Kind)
+		assert.Equal(t, "scraper", anomalies[0].Client)
+		assert.Equal(t, 3, anomalies[0].Count)
+		assert.Equal(t, 3, anomalies[0].Requests)
+		assert.Len(t, anomalies[0].ImageHash, 64)
+		assert.NotEmpty(t, anomalies[0].RequestID)
+	}
+
+	// Failing requests are flagged once the failure rate exceeds the maximum
+	var blank bytes.Buffer
+	assert.NoError(t, png.Encode(&blank, image.NewGray(image.Rect(0, 0, 200, 70))))
+	anomalies = nil
+	solve("broken", captcha)
+	for i := 0; i < 4; i++ {
+		solve("broken", blank.Bytes())
+	}
+	if assert.Len(t, anomalies, 2) {
+		assert.Equal(t, AnomalyRepeatedImage, anomalies[0].Kind)
+		assert.Equal(t, AnomalyFailureRate, anomalies[1].Kind)
+		assert.Equal(t, 3, anomalies[1].Count)
+		assert.Equal(t, 4, anomalies[1].Requests)
+		assert.Empty(t, anomalies[1].ImageHash)
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), "\namazoncaptcha_anomalies_total{kind=\"repeated_image\"} 2\n")
+	assert.Contains(t, rec.Body.String(), "\namazoncaptcha_anomalies_total{kind=\"failure_rate\"} 1\n")
+	assert.Contains(t, rec.Body.String(), "\namazoncaptcha_anomalous_clients 2\n")
+
+	_, err = New(WithAnomalyDetection(AnomalyThresholds{MaxRepeats: 1}, nil))
+	assert.Error(t, err)
+	_, err = New(WithAnomalyDetection(AnomalyThresholds{Window: time.Minute}, nil))
+	assert.Error(t, err)
+	_, err = New(WithClientIdentifier(nil))
+	assert.Error(t, err)
+}
+
+func TestAnomalyWindow(t *testing.T) {
+	d := &anomalyDetector{
+		thresholds: AnomalyThresholds{Window: time.Hour, MaxRepeats: 1, MaxFailureRate: 1},
+		clients:    make(map[string]*clientWindow),
+		detected:   make(map[AnomalyKind]uint64),
+	}
+	d.observe("client", "", "hash", false)
+	assert.Equal(t, 1, d.clients["client"].requests)
+
+	// Counts start over once the window expired
+	d.clients["client"].start = time.Now().Add(-2 * time.Hour)
+	d.observe("client", "", "hash", false)
+	assert.Equal(t, 1, d.clients["client"].requests)
+	assert.Zero(t, d.detected[AnomalyRepeatedImage])
+	d.observe("client", "", "hash", false)
+	assert.Equal(t, uint64(1), d.detected[AnomalyRepeatedImage])
+}
@@ -0,0 +1,349 @@
+package server
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// Corpus provides the labeled captchas evaluated periodically by a Server, see WithEvaluation.
+type Corpus interface {
+	// Captchas returns the labeled captchas of the corpus.
+	Captchas(ctx context.Context) ([]amazoncaptcha.LabeledCaptcha, error)
+}
+
+// imageExtensions are the file extensions of the captcha images picked up from a corpus.
+var imageExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".webp": true,
+}
+
+// labelOf returns the answer a captcha image is labeled with by its file name, e.g. "ABCDEF" for "abcdef.jpg",
+// or an empty string if the name is not made of 6 letters.
+func labelOf(name string) string {
+	base := path.Base(filepath.ToSlash(name))
+	label := strings.ToUpper(strings.TrimSuffix(base, path.Ext(base)))
+	if len(label) != 6 {
+		return ""
+	}
+	for _, c := range label {
+		if c < 'A' || c > 'Z' {
+			return ""
+		}
+	}
+	return label
+}
+
+// addCaptcha appends the captcha image named name to captchas if it is labeled by its file name.
+func addCaptcha(captchas []amazoncaptcha.LabeledCaptcha, name string, b []byte) []amazoncaptcha.LabeledCaptcha {
+	if !imageExtensions[strings.ToLower(path.Ext(filepath.ToSlash(name)))] {
+		return captchas
+	}
+	answer := labelOf(name)
+	if answer == "" {
+		return captchas
+	}
+	return append(captchas, amazoncaptcha.LabeledCaptcha{Name: name, Answer: answer, Image: b})
+}
+
+// DirCorpus returns a Corpus of the captcha images in dir named after their answers, e.g. ABCDEF.jpg.
+// Subdirectories are not searched, and the directory is read again for every evaluation.
+func DirCorpus(dir string) Corpus {
+	return dirCorpus(dir)
+}
+
+// dirCorpus is a corpus of the captcha images in a directory.
+type dirCorpus string
+
+// Captchas implements Corpus.
+func (d dirCorpus) Captchas(ctx context.Context) ([]amazoncaptcha.LabeledCaptcha, error) {
+	entries, err := os.ReadDir(string(d))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read corpus: %w", err)
+	}
+	var captchas []amazoncaptcha.LabeledCaptcha
+	for _, entry := range entries {
+		if entry.IsDir() || labelOf(entry.Name()) == "" {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(string(d), entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read corpus: %w", err)
+		}
+		captchas = addCaptcha(captchas, entry.Name(), b)
+	}
+	return captchas, nil
+}
+
+// ArchiveCorpus returns a Corpus of the captcha images in the zip or gzipped tar archive at url, named after
+// their answers, e.g. ABCDEF.jpg. The archive is downloaded with client, or http.DefaultClient if nil,
+// for every evaluation, so that the corpus can be updated without restarting the server.
+func ArchiveCorpus(url string, client *http.Client) Corpus {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &archiveCorpus{url: url, client: client}
+}
+
+// archiveCorpus is a corpus of the captcha images in a remote archive.
+type archiveCorpus struct {
+	url    string
+	client *http.Client
+}
+
+// maxArchiveSize is the size limit of corpus archives, in bytes.
+const maxArchiveSize = 256 << 20
+
+// Captchas implements Corpus.
+func (a *archiveCorpus) Captchas(ctx context.Context) ([]amazoncaptcha.LabeledCaptcha, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status code: %d", resp.StatusCode)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxArchiveSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download corpus: %w", err)
+	}
+	if len(b) > maxArchiveSize {
+		return nil, fmt.Errorf("corpus archive larger than %d bytes", maxArchiveSize)
+	}
+
+	// Tell the archive format by its magic number
+	switch {
+	case bytes.HasPrefix(b, []byte("PK\x03\x04")):
+		return readZip(b)
+	case bytes.HasPrefix(b, []byte{0x1f, 0x8b}):
+		return readTarGz(b)
+	default:
+		return nil, errors.New("corpus archive is neither a zip nor a gzipped tar archive")
+	}
+}
+
+// readZip returns the labeled captchas of a zip archive.
+func readZip(b []byte) ([]amazoncaptcha.LabeledCaptcha, error) {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read corpus archive: %w", err)
+	}
+	var captchas []amazoncaptcha.LabeledCaptcha
+	for _, file := range zr.File {
+		if file.FileInfo().IsDir() || labelOf(file.Name) == "" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read corpus archive: %w", err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read corpus archive: %w", err)
+		}
+		captchas = addCaptcha(captchas, file.Name, data)
+	}
+	return captchas, nil
+}
+
+// readTarGz returns the labeled captchas of a gzipped tar archive.
+func readTarGz(b []byte) ([]amazoncaptcha.LabeledCaptcha, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read corpus archive: %w", err)
+	}
+	defer zr.Close()
+	var captchas []amazoncaptcha.LabeledCaptcha
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read corpus archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg || labelOf(header.Name) == "" {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read corpus archive: %w", err)
+		}
+		captchas = addCaptcha(captchas, header.Name, data)
+	}
+	return captchas, nil
+}
+
+// EvaluationReport is the outcome of an evaluation of the corpus of a Server.
+type EvaluationReport struct {
+	// Time is when the evaluation finished.
+	Time time.Time
+	// Duration is the time taken by the evaluation, including loading the corpus.
+	Duration time.Duration
+	// Evaluation counts how the captchas of the corpus were solved, nil if the corpus could not be loaded.
+	Evaluation *amazoncaptcha.Evaluation
+	// Err is the error loading the corpus, if any.
+	Err error
+}
+
+// Accuracy returns the share of captchas of the corpus solved to their known answer, or 0 if the corpus could not be loaded.
+func (r *EvaluationReport) Accuracy() float64 {
+	if r.Evaluation == nil {
+		return 0
+	}
+	return r.Evaluation.Accuracy()
+}
+
+// evaluator runs the evaluations of a Server and keeps the latest report.
+type evaluator struct {
+	corpus      Corpus
+	interval    time.Duration
+	minAccuracy float64
+
+	mu     sync.Mutex
+	latest *EvaluationReport
+}
+
+// WithEvaluation makes the Server evaluate the labeled captchas of corpus with its default model every interval
+// while RunEvaluations runs, e.g. a DirCorpus or an ArchiveCorpus. The latest accuracy is exposed at /metrics and /readyz, and the
+// Server reports not to be ready while the accuracy is below minAccuracy, between 0 and 1, so that regressions,
+// e.g. after the training data was reloaded, are caught automatically.
+func WithEvaluation(corpus Corpus, interval time.Duration, minAccuracy float64) Option {
+	return func(s *Server) error {
+		if corpus == nil {
+			return errors.New("corpus must not be nil")
+		}
+		if interval <= 0 {
+			return errors.New("evaluation interval must be positive")
+		}
+		if minAccuracy < 0 || minAccuracy > 1 {
+			return errors.New("minimum accuracy must be between 0 and 1")
+		}
+		s.evaluator = &evaluator{corpus: corpus, interval: interval, minAccuracy: minAccuracy}
+		return nil
+	}
+}
+
+// RunEvaluations evaluates the corpus configured with WithEvaluation right away and then every interval,
+// until ctx is done, and returns the error of the context. It is meant to run in its own goroutine.
+// Without an evaluation configured, it returns an error immediately.
+func (s *Server) RunEvaluations(ctx context.Context) error {
+	if s.evaluator == nil {
+		return errors.New("no evaluation configured")
+	}
+	ticker := time.NewTicker(s.evaluator.interval)
+	defer ticker.Stop()
+	for {
+		s.Evaluate(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Evaluate evaluates the corpus configured with WithEvaluation right away, e.g. after the training data
+// of the solver was reloaded, and returns the report, which becomes the latest one unless ctx is done before
+// the evaluation finishes. It returns nil without an evaluation configured.
+func (s *Server) Evaluate(ctx context.Context) *EvaluationReport {
+	e := s.evaluator
+	if e == nil {
+		return nil
+	}
+	start := time.Now()
+	report := &EvaluationReport{}
+	captchas, err := e.corpus.Captchas(ctx)
+	if err != nil {
+		report.Err = err
+	} else {
+		report.Evaluation = s.solver.Evaluate(ctx, captchas)
+	}
+	report.Time = time.Now()
+	report.Duration = report.Time.Sub(start)
+
+	// Keep the latest report of an evaluation cut short by the context
+	if ctx.Err() != nil {
+		return report
+	}
+	e.mu.Lock()
+	e.latest = report
+	e.mu.Unlock()
+	return report
+}
+
+// LatestEvaluation returns the report of the latest evaluation, or nil if none has finished yet.
+func (s *Server) LatestEvaluation() *EvaluationReport {
+	if s.evaluator == nil {
+		return nil
+	}
+	s.evaluator.mu.Lock()
+	defer s.evaluator.mu.Unlock()
+	return s.evaluator.latest
+}
+
+// evaluationDetails describes the latest evaluation in the /readyz response.
+type evaluationDetails struct {
+	Time        time.Time      `json:"time"`
+	DurationMS  float64        `json:"duration_ms"`
+	Captchas    int            `json:"captchas"`
+	Accuracy    float64        `json:"accuracy"`
+	MinAccuracy float64        `json:"min_accuracy"`
+	Confusions  map[string]int `json:"confusions,omitempty"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// readiness returns whether the latest evaluation passed, and its details, nil if none has finished yet.
+// The Server counts as ready until the first evaluation has finished.
+func (e *evaluator) readiness() (bool, *evaluationDetails) {
+	e.mu.Lock()
+	report := e.latest
+	e.mu.Unlock()
+	if report == nil {
+		return true, nil
+	}
+
+	details := &evaluationDetails{
+		Time:        report.Time,
+		DurationMS:  float64(report.Duration) / float64(time.Millisecond),
+		Accuracy:    report.Accuracy(),
+		MinAccuracy: e.minAccuracy,
+	}
+	if report.Err != nil {
+		details.Error = report.Err.Error()
+		return false, details
+	}
+	details.Captchas = report.Evaluation.Total
+	for _, pair := range report.Evaluation.Confusions.Pairs(1) {
+		if details.Confusions == nil {
+			details.Confusions = make(map[string]int)
+		}
+		a, b := pair[0], pair[1]
+		details.Confusions[a+"/"+b] = report.Evaluation.Confusions[a][b] + report.Evaluation.Confusions[b][a]
+	}
+	return details.Accuracy >= e.minAccuracy, details
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// corpusFiles returns the files of a labeled corpus: one captcha labeled correctly, one mislabeled
+// and a file that is not a captcha.
+func corpusFiles(t *testing.T) map[string][]byte {
+	t.Helper()
+	return map[string][]byte{
+		"ABCEFG.png": renderCaptcha(t, "ABCEFG"),
+		"ABCEFX.png": renderCaptcha(t, "ABCEFG"),
+		"README.txt": []byte("labeled captchas"),
+	}
+}
+
+func TestDirCorpus(t *testing.T) {
+	dir := t.TempDir()
+	for name, b := range corpusFiles(t) {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), b, 0644))
+	}
+	captchas, err := DirCorpus(dir).Captchas(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, captchas, 2) {
+		assert.Equal(t, "ABCEFG", captchas[0].Answer)
+		assert.Equal(t, "ABCEFX", captchas[1].Answer)
+	}
+
+	_, err = DirCorpus(filepath.Join(dir, "missing")).Captchas(context.Background())
+	assert.Error(t, err)
+}
+
+func TestArchiveCorpus(t *testing.T) {
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	for name, b := range corpusFiles(t) {
+		w, err := zw.Create("corpus/" + name)
+		assert.NoError(t, err)
+		_, _ = w.Write(b)
+	}
+	assert.NoError(t, zw.Close())
+
+	var tarred bytes.Buffer
+	gw := gzip.NewWriter(&tarred)
+	tw := tar.NewWriter(gw)
+	for name, b := range corpusFiles(t) {
+		assert.NoError(t, tw.WriteHeader(&tar.Header{Name: "corpus/" + name, Mode: 0644, Size: int64(len(b)), Typeflag: tar.TypeReg}))
+		_, _ = tw.Write(b)
+	}
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, gw.Close())
+
+	archives := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/corpus.zip":
+			_, _ = w.Write(zipped.Bytes())
+		case "/corpus.tar.gz":
+			_, _ = w.Write(tarred.Bytes())
+		case "/corpus.txt":
+			_, _ = w.Write([]byte("not an archive"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer archives.Close()
+
+	for _, name := range []string{"/corpus.zip", "/corpus.tar.gz"} {
+		captchas, err := ArchiveCorpus(archives.URL+name, archives.Client()).Captchas(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, captchas, 2)
+	}
+	_, err := ArchiveCorpus(archives.URL+"/corpus.txt", archives.Client()).Captchas(context.Background())
+	assert.Error(t, err)
+	_, err = ArchiveCorpus(archives.URL+"/missing.zip", archives.Client()).Captchas(context.Background())
+	assert.Error(t, err)
+}
+
+func TestServerEvaluation(t *testing.T) {
+	dir := t.TempDir()
+	for name, b := range corpusFiles(t) {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), b, 0644))
+	}
+	s, err := New(WithEvaluation(DirCorpus(dir), time.Hour, 0.9))
+	assert.NoError(t, err)
+
+	// The server is ready until the first evaluation finishes
+	code, body := serve(t, s, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, body["ready"])
+	assert.Nil(t, s.LatestEvaluation())
+
+	// An accuracy below the minimum makes the server unready
+	report := s.Evaluate(context.Background())
+	assert.NoError(t, report.Err)
+	assert.Equal(t, 0.5, report.Accuracy())
+	assert.Same(t, report, s.LatestEvaluation())
+	code, body = serve(t, s, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, false, body["ready"])
+	if details, ok := body["evaluation"].(map[string]interface{}); assert.True(t, ok) {
+		assert.Equal(t, 2.0, details["captchas"])
+		assert.Equal(t, 0.5, details["accuracy"])
+		assert.Equal(t, map[string]interface{}{"G/X": 1.0}, details["confusions"])
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), "\namazoncaptcha_evaluation_accuracy 0.5\n")
+	assert.Contains(t, rec.Body.String(), "\namazoncaptcha_evaluation_captchas 2\n")
+	assert.Contains(t, rec.Body.String(), "# TYPE amazoncaptcha_solves_total counter\n")
+
+	// Fixing the corpus makes the server ready again with the next evaluation
+	assert.NoError(t, os.Remove(filepath.Join(dir, "ABCEFX.png")))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.RunEvaluations(ctx)
+	}()
+	assert.Eventually(t, func() bool {
+		return s.LatestEvaluation().Accuracy() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	code, _ = serve(t, s, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, code)
+
+	// A corpus that cannot be loaded makes the server unready
+	s, err = New(WithEvaluation(DirCorpus(filepath.Join(dir, "missing")), time.Hour, 0.9))
+	assert.NoError(t, err)
+	assert.Error(t, s.Evaluate(context.Background()).Err)
+	code, body = serve(t, s, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.True(t, strings.Contains(body["evaluation"].(map[string]interface{})["error"].(string), "failed to read corpus"))
+
+	// Without an evaluation, the server is always ready
+	s, err = New()
+	assert.NoError(t, err)
+	assert.Error(t, s.RunEvaluations(context.Background()))
+	assert.Nil(t, s.Evaluate(context.Background()))
+	code, _ = serve(t, s, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, code)
+
+	_, err = New(WithEvaluation(DirCorpus(dir), 0, 0.9))
+	assert.Error(t, err)
+	_, err = New(WithEvaluation(DirCorpus(dir), time.Hour, 1.5))
+	assert.Error(t, err)
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// readyzResponse is the JSON body of the /readyz response.
+type readyzResponse struct {
+	Ready      bool               `json:"ready"`
+	Evaluation *evaluationDetails `json:"evaluation,omitempty"`
+}
+
+// handleReadyz implements GET /readyz.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	resp := readyzResponse{Ready: true}
+	if s.evaluator != nil {
+		resp.Ready, resp.Evaluation = s.evaluator.readiness()
+	}
+	status := http.StatusOK
+	if !resp.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, resp)
+}
+
+// handleMetrics implements GET /metrics, writing the metrics in the Prometheus text format.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	metric := func(name, kind, help string, value float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, kind, name, value)
+	}
+
+	// Label the solve statistics with the model, in the order of the model names for stable output
+	names := make([]string, 0, len(s.models))
+	for name := range s.models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	stats := make([]amazoncaptcha.Stats, len(names))
+	for i, name := range names {
+		stats[i] = s.models[name].Stats()
+	}
+	modelMetric := func(name, help string, value func(amazoncaptcha.Stats) uint64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		for i, model := range names {
+			fmt.Fprintf(w, "%s{model=%q} %d\n", name, model, value(stats[i]))
+		}
+	}
+	modelMetric("amazoncaptcha_solves_total", "Number of solve attempts.", func(s amazoncaptcha.Stats) uint64 { return s.Solves })
+	modelMetric("amazoncaptcha_solve_failures_total", "Number of solves that returned an error.", func(s amazoncaptcha.Stats) uint64 { return s.Failures })
+	modelMetric("amazoncaptcha_solved_total", "Number of answers in which every letter was recognized.", func(s amazoncaptcha.Stats) uint64 { return s.Solved })
+
+	if s.anomalies != nil {
+		detected, flagged := s.anomalies.counts()
+		fmt.Fprintf(w, "# HELP amazoncaptcha_anomalies_total Number of anomalies detected in the requests of clients.\n# TYPE amazoncaptcha_anomalies_total counter\n")
+		for _, kind := range anomalyKinds {
+			fmt.Fprintf(w, "amazoncaptcha_anomalies_total{kind=%q} %d\n", kind, detected[kind])
+		}
+		metric("amazoncaptcha_anomalous_clients", "gauge", "Number of clients flagged in their current window.", float64(flagged))
+	}
+
+	report := s.LatestEvaluation()
+	if report == nil {
+		return
+	}
+	success := 0.0
+	if report.Err == nil {
+		success = 1
+	}
+	metric("amazoncaptcha_evaluation_success", "gauge", "Whether the corpus of the latest evaluation could be loaded.", success)
+	metric("amazoncaptcha_evaluation_accuracy", "gauge", "Share of the captchas of the latest evaluation solved correctly.", report.Accuracy())
+	if report.Evaluation != nil {
+		metric("amazoncaptcha_evaluation_captchas", "gauge", "Number of captchas in the latest evaluation.", float64(report.Evaluation.Total))
+	}
+	metric("amazoncaptcha_evaluation_timestamp_seconds", "gauge", "Time the latest evaluation finished.", float64(report.Time.UnixNano())/1e9)
+	metric("amazoncaptcha_evaluation_duration_seconds", "gauge", "Time taken by the latest evaluation.", report.Duration.Seconds())
+}
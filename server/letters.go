@@ -0,0 +1,99 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image/png"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// lettersResponse is the JSON body of a successful response of POST /letters.
+type lettersResponse struct {
+	Model      string           `json:"model"`
+	Text       string           `json:"text"`
+	Letters    []letterResponse `json:"letters"`
+	DurationMS float64          `json:"duration_ms"`
+}
+
+// letterResponse describes a segmented letter of a captcha, see POST /letters.
+type letterResponse struct {
+	Position   int     `json:"position"`
+	Image      string  `json:"image"`
+	Feature    string  `json:"feature"`
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence"`
+	Guess      string  `json:"guess,omitempty"`
+	Distance   int     `json:"distance,omitempty"`
+	X          int     `json:"x"`
+	Y          int     `json:"y"`
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+}
+
+// handleLetters implements POST /letters, the backend of labeling tools: it segments a captcha, given like to
+// POST /solve, and returns every letter as a PNG data URI together with its recognition, the nearest training
+// letter of the letters that could not be recognized, and where it was found in the captcha. The feature of a
+// letter labeled by a user can be added to the training data as is. The request is not counted as a solve.
+func (s *Server) handleLetters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	start := time.Now()
+	b, name, err := s.readImage(r)
+	if err == nil && name == "" {
+		name = DefaultModel
+	}
+	solver, ok := s.models[name]
+	if err == nil && !ok {
+		err = &requestError{status: http.StatusNotFound, err: fmt.Errorf("unknown model %q", name)}
+	}
+	if err != nil {
+		var reqErr *requestError
+		if !errors.As(err, &reqErr) {
+			reqErr = &requestError{status: http.StatusBadRequest, err: err}
+		}
+		writeError(w, reqErr.status, reqErr.Error())
+		return
+	}
+
+	letters, err := solver.SegmentLetters(bytes.NewReader(b))
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("not a captcha: %v", err))
+		return
+	}
+
+	// Encode every letter as a data URI that labeling tools can use as the src of an <img> tag
+	resp := lettersResponse{Model: name, Letters: make([]letterResponse, len(letters))}
+	var text strings.Builder
+	for i, letter := range letters {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, letter.Image); err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to encode letter %d: %v", i, err))
+			return
+		}
+		text.WriteString(letter.Text)
+		resp.Letters[i] = letterResponse{
+			Position:   i,
+			Image:      "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+			Feature:    letter.Feature,
+			Text:       letter.Text,
+			Confidence: letter.Confidence,
+			Guess:      letter.Guess,
+			Distance:   letter.Distance,
+			X:          letter.Bounds.Min.X,
+			Y:          letter.Bounds.Min.Y,
+			Width:      letter.Bounds.Dx(),
+			Height:     letter.Bounds.Dy(),
+		}
+	}
+	resp.Text = text.String()
+	resp.DurationMS = float64(time.Since(start)) / float64(time.Millisecond)
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+	"github.com/stretchr/testify/assert"
+)
+
+// postLetters builds a request uploading image to POST /letters.
+func postLetters(t *testing.T, image []byte) *http.Request {
+	t.Helper()
+	req := upload(t, "image", image)
+	req.URL.Path = "/letters"
+	return req
+}
+
+func TestLetters(t *testing.T) {
+	// A model missing the training entry of the rendered C cannot recognize it
+	solver, err := amazoncaptcha.NewSolver()
+	assert.NoError(t, err)
+	feature, err := amazoncaptcha.ExtractFeatures(amazoncaptcha.Templates(3)["C"][1])
+	assert.NoError(t, err)
+	assert.True(t, solver.RemoveFeature(feature))
+	s, err := New(WithSolver(solver))
+	assert.NoError(t, err)
+
+	code, body := serve(t, s, postLetters(t, renderCaptcha(t, "ABCEFG")))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, DefaultModel, body["model"])
+	assert.Equal(t, "AB-EFG", body["text"])
+	letters, _ := body["letters"].([]interface{})
+	if !assert.Len(t, letters, 6) {
+		return
+	}
+
+	first := letters[0].(map[string]interface{})
+	assert.Equal(t, "A", first["text"])
+	assert.Equal(t, 1.0, first["confidence"])
+	assert.Equal(t, 2.0, first["x"])
+	assert.Equal(t, float64(amazoncaptcha.CaptchaHeight), first["height"])
+	assert.NotContains(t, first, "guess")
+
+	// The letter images are PNG data URIs of the size of the letter
+	uri, _ := first["image"].(string)
+	assert.True(t, strings.HasPrefix(uri, "data:image/png;base64,"))
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(uri, "data:image/png;base64,"))
+	assert.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(b))
+	if assert.NoError(t, err) {
+		assert.Equal(t, first["width"], float64(img.Bounds().Dx()))
+	}
+
+	// The unknown letter comes with the nearest training letter as guess
+	unknown := letters[2].(map[string]interface{})
+	assert.Equal(t, "-", unknown["text"])
+	assert.Equal(t, 2.0, unknown["position"])
+	assert.Equal(t, feature, unknown["feature"])
+	assert.Equal(t, "C", unknown["guess"])
+	assert.Greater(t, unknown["distance"], 0.0)
+
+	// Segmenting is not counted as a solve
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), "\namazoncaptcha_solves_total{model=\"default\"} 0\n")
+
+	code, _ = serve(t, s, httptest.NewRequest(http.MethodGet, "/letters", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+	code, _ = serve(t, s, postLetters(t, []byte("not an image")))
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+}
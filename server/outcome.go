@@ -0,0 +1,67 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// outcomeRequest is the JSON body of POST /outcome.
+type outcomeRequest struct {
+	ImageHash string `json:"image_hash"`
+	Accepted  *bool  `json:"accepted"`
+	Model     string `json:"model"`
+}
+
+// outcomeResponse is the JSON body of a successful response of POST /outcome.
+type outcomeResponse struct {
+	Model     string `json:"model"`
+	ImageHash string `json:"image_hash"`
+	Accepted  bool   `json:"accepted"`
+}
+
+// handleOutcome implements POST /outcome, reporting whether Amazon accepted the answer to a captcha solved by the
+// server. The captcha is identified by the image_hash of its solve response, and the outcome is reported to the
+// model selected like for POST /solve, which must be the model that solved it, see amazoncaptcha.ReportOutcome.
+func (s *Server) handleOutcome(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, "content type must be application/json")
+		return
+	}
+
+	var req outcomeRequest
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 4<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("failed to decode request: %v", err))
+		return
+	}
+	req.ImageHash = strings.ToLower(req.ImageHash)
+	if hash, err := hex.DecodeString(req.ImageHash); err != nil || len(hash) != sha256.Size {
+		writeError(w, http.StatusBadRequest, "image_hash must be the hex-encoded SHA-256 hash of the image")
+		return
+	}
+	if req.Accepted == nil {
+		writeError(w, http.StatusBadRequest, "accepted must be true or false")
+		return
+	}
+	name := selectedModel(r, req.Model)
+	if name == "" {
+		name = DefaultModel
+	}
+	solver, ok := s.models[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown model %q", name))
+		return
+	}
+
+	solver.ReportOutcome(req.ImageHash, *req.Accepted)
+	writeJSON(w, http.StatusOK, outcomeResponse{Model: name, ImageHash: req.ImageHash, Accepted: *req.Accepted})
+}
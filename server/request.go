@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is the header carrying the ID of a request, propagated from the client when given,
+// and generated by the Server otherwise.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the length limit of request IDs given by clients, longer ones being replaced.
+const maxRequestIDLength = 128
+
+// ErrorResponse is the JSON body of a failed response.
+type ErrorResponse struct {
+	// Code names the kind of failure, e.g. "invalid_request" or "not_a_captcha", see the package documentation.
+	Code string `json:"code"`
+	// Message describes the failure.
+	Message string `json:"message"`
+	// RequestID is the ID of the failed request, also returned in the RequestIDHeader header.
+	RequestID string `json:"request_id"`
+}
+
+// errorCodes maps the status codes of failed responses to their error codes.
+var errorCodes = map[int]string{
+	http.StatusBadRequest:            "invalid_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusRequestEntityTooLarge: "image_too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusUnprocessableEntity:   "not_a_captcha",
+	http.StatusInternalServerError:   "internal",
+	http.StatusBadGateway:            "download_failed",
+	http.StatusServiceUnavailable:    "no_training_data",
+}
+
+// requestIDKey is the context key of the request ID.
+type requestIDKey struct{}
+
+// RequestID returns the request ID stored in the context of a request served by a Server,
+// or an empty string if there is none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestID returns the request ID given by a request, or a new one if it has none or an invalid one.
+func requestID(r *http.Request) string {
+	id := r.Header.Get(RequestIDHeader)
+	if id == "" || len(id) > maxRequestIDLength {
+		return uuid.NewString()
+	}
+	for _, c := range id {
+		// Keep to printable ASCII, so that the ID cannot forge log lines
+		if c < 0x21 || c > 0x7e {
+			return uuid.NewString()
+		}
+	}
+	return id
+}
+
+// tracedWriter records the status code and error message of a response for logging.
+type tracedWriter struct {
+	http.ResponseWriter
+	requestID string
+	status    int
+	message   string
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *tracedWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// writeError writes an ErrorResponse with the given status code and message.
+func writeError(w http.ResponseWriter, status int, message string) {
+	resp := ErrorResponse{Code: errorCodes[status], Message: message}
+	if resp.Code == "" {
+		resp.Code = "error"
+	}
+	if tw, ok := w.(*tracedWriter); ok {
+		resp.RequestID = tw.requestID
+		tw.message = message
+	}
+	writeJSON(w, status, resp)
+}
+
+// writeJSON writes v as the JSON body of a response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// logRequest logs a served request if a logger is configured.
+func (s *Server) logRequest(w *tracedWriter, r *http.Request, duration time.Duration) {
+	if s.logger == nil {
+		return
+	}
+	line := []interface{}{w.requestID, r.Method, r.URL.Path, w.status, duration}
+	format := "request_id=%s method=%s path=%q status=%d duration=%s"
+	if w.message != "" {
+		format += " error=%q"
+		line = append(line, w.message)
+	}
+	s.logger.Printf(format, line...)
+}
@@ -0,0 +1,442 @@
+// Package server exposes an amazoncaptcha Solver over HTTP, so that scrapers written in other languages
+// can run the solver as a sidecar instead of wrapping it themselves.
+//
+// The server answers POST /solve with a captcha image given either as a multipart/form-data upload in the
+// "image" field, or as a JSON body {"url": "..."} naming an image to download. The answer is returned as
+//
+//	{"model": "default", "text": "ABCDEF", "confidence": 1, "image_hash": "...", "duration_ms": 1.5}
+//
+// where model names the model that solved the captcha, text holds the placeholder of the Solver in place of
+// every unrecognized letter, image_hash is the SHA-256 hash of the image, see amazoncaptcha.ImageHash, and
+// duration_ms is the time taken to read or download the image and solve it.
+// Besides the default model, the server can host named models configured by WithModel, selected by the
+// X-Captcha-Model header or the "model" field of the request. Failures are returned as
+//
+//	{"code": "not_a_captcha", "message": "...", "request_id": "..."}
+//
+// with the status code 400 for malformed requests, 404 for unknown models, 413 for images above the size
+// limit, 415 for other content types, 422 for images that are not captchas, 502 for images that could not be
+// downloaded and 503 while the model has no training data, and a code naming the status, see ErrorResponse.
+//
+// POST /letters takes a captcha image like POST /solve and returns its segmented letters, as the backend of
+// browser-based labeling tools contributing training data:
+//
+//	{"model": "default", "text": "AB-DEF", "letters": [{"position": 0, "image": "data:image/png;base64,...",
+//	 "feature": "...", "text": "A", "confidence": 1, "x": 2, "y": 0, "width": 28, "height": 70}, ...]}
+//
+// Every letter comes as a PNG data URI with its feature, its recognition and its bounds in the captcha. Letters
+// that could not be recognized hold the placeholder of the Solver as text, and the letter of the nearest training
+// entry and the number of pixels they differ in as guess and distance, if there is a similar entry.
+//
+// POST /outcome reports whether Amazon accepted an answer, as feedback for the statistics, the journal and the
+// self-training of the model that solved the captcha, see amazoncaptcha.ReportOutcome. It takes a JSON body
+//
+//	{"image_hash": "...", "accepted": true, "model": "default"}
+//
+// naming the captcha by the image_hash of its solve response, and selects the model like POST /solve.
+//
+// With WithAnomalyDetection, the server watches the solve requests of every client for suspicious patterns,
+// such as the same image submitted over and over or a very high failure rate, and reports them to a hook and
+// in the metrics.
+//
+// Every response carries the X-Request-ID header of its request, or a generated one if the request had none.
+// The ID is forwarded when downloading images, included in error bodies and logged with WithLogger, so that
+// failed solves can be traced across services.
+//
+// GET /readyz answers {"ready": true} with the status code 200 while the server is ready, and with 503 otherwise.
+// With a periodic evaluation configured by WithEvaluation, the response details the latest evaluation, and the
+// server is not ready while its accuracy is below the configured minimum. GET /metrics exposes the solve
+// statistics of every model and the latest evaluation in the Prometheus text format.
+//
+// With an admin token configured by WithAdmin, the endpoints under /admin/ let operators upload new training
+// data, reload it from its file, roll back to the previous model and inspect the active one without restarting
+// the server. They require the header "Authorization: Bearer <token>" and answer 401 otherwise.
+//
+// Downloads are made from the host running the server, to any URL given, so the server is meant to be
+// reachable by trusted clients only.
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// DefaultModel is the name of the model solving the captchas of the requests that select no model.
+const DefaultModel = "default"
+
+// ModelHeader is the request header selecting the model that solves a captcha, see WithModel.
+const ModelHeader = "X-Captcha-Model"
+
+// DefaultMaxImageSize is the size limit of captcha images, in bytes, unless configured otherwise.
+// Amazon captchas are a few kilobytes large.
+const DefaultMaxImageSize = 1 << 20
+
+// DefaultMaxImagePixels is the limit of the number of pixels of captcha images, unless configured otherwise.
+// Amazon captchas have 200 by 70 pixels.
+const DefaultMaxImagePixels = 1 << 22
+
+// Server is an http.Handler solving captchas at POST /solve, segmenting them at POST /letters and taking the
+// outcomes of the answers at POST /outcome. It also serves its readiness at GET /readyz and its metrics in the
+// Prometheus text format at GET /metrics.
+type Server struct {
+	solver       *amazoncaptcha.Solver
+	models       map[string]*amazoncaptcha.Solver
+	client       *http.Client
+	maxImageSize int64
+	maxPixels    int
+	evaluator    *evaluator
+	admin        *admin
+	anomalies    *anomalyDetector
+	identify     func(*http.Request) string
+	logger       *log.Logger
+	mux          *http.ServeMux
+}
+
+// Option configures a Server.
+type Option func(*Server) error
+
+// New creates a Server configured by opts. Without options, the Server solves captchas with a Solver
+// of the default configuration, downloads images with http.DefaultClient and accepts images of up to
+// DefaultMaxImageSize bytes and DefaultMaxImagePixels pixels.
+func New(opts ...Option) (*Server, error) {
+	s := &Server{
+		models:       make(map[string]*amazoncaptcha.Solver),
+		client:       http.DefaultClient,
+		maxImageSize: DefaultMaxImageSize,
+		maxPixels:    DefaultMaxImagePixels,
+		identify:     remoteIP,
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	if s.solver == nil {
+		solver, err := amazoncaptcha.NewSolver()
+		if err != nil {
+			return nil, err
+		}
+		s.solver = solver
+	}
+	s.models[DefaultModel] = s.solver
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("/solve", s.handleSolve)
+	s.mux.HandleFunc("/letters", s.handleLetters)
+	s.mux.HandleFunc("/outcome", s.handleOutcome)
+	s.mux.HandleFunc("/readyz", s.handleReadyz)
+	s.mux.HandleFunc("/metrics", s.handleMetrics)
+	if s.admin != nil {
+		s.registerAdmin()
+	}
+	return s, nil
+}
+
+// WithSolver makes the Server solve captchas with solver, e.g. one with custom training data. It is the model
+// named DefaultModel, which solves the captchas of the requests that select no model.
+func WithSolver(solver *amazoncaptcha.Solver) Option {
+	return func(s *Server) error {
+		if solver == nil {
+			return errors.New("solver must not be nil")
+		}
+		s.solver = solver
+		return nil
+	}
+}
+
+// WithModel makes the Server host solver as the model named name, e.g. "amazon-jp" for a solver trained on the
+// captchas of another Amazon site, next to the default model. Requests select the model solving their captcha by
+// name, in the ModelHeader header, in the "model" field of a multipart/form-data upload or in the "model" member
+// of a JSON body. The models share the process, the HTTP client and the size limits of the Server.
+func WithModel(name string, solver *amazoncaptcha.Solver) Option {
+	return func(s *Server) error {
+		if name == "" || name == DefaultModel {
+			return fmt.Errorf("invalid model name %q", name)
+		}
+		if solver == nil {
+			return errors.New("solver must not be nil")
+		}
+		if _, ok := s.models[name]; ok {
+			return fmt.Errorf("duplicate model %q", name)
+		}
+		s.models[name] = solver
+		return nil
+	}
+}
+
+// WithHTTPClient makes the Server download the images given by URL with client, e.g. one with a proxy or a timeout.
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Server) error {
+		if client == nil {
+			return errors.New("HTTP client must not be nil")
+		}
+		s.client = client
+		return nil
+	}
+}
+
+// WithLogger makes the Server log every request with logger, one line holding its request ID, method, path,
+// status code and duration, and the error message of failed requests. The Server logs nothing by default.
+func WithLogger(logger *log.Logger) Option {
+	return func(s *Server) error {
+		if logger == nil {
+			return errors.New("logger must not be nil")
+		}
+		s.logger = logger
+		return nil
+	}
+}
+
+// WithMaxImageSize sets the size limit of uploaded and downloaded images, DefaultMaxImageSize by default.
+func WithMaxImageSize(size int64) Option {
+	return func(s *Server) error {
+		if size <= 0 {
+			return errors.New("maximum image size must be positive")
+		}
+		s.maxImageSize = size
+		return nil
+	}
+}
+
+// WithMaxImagePixels sets the limit of the number of pixels of uploaded and downloaded images,
+// DefaultMaxImagePixels by default. It is checked from the header of an image, before the rest is read.
+func WithMaxImagePixels(pixels int) Option {
+	return func(s *Server) error {
+		if pixels <= 0 {
+			return errors.New("maximum number of pixels must be positive")
+		}
+		s.maxPixels = pixels
+		return nil
+	}
+}
+
+// ServeHTTP implements http.Handler, tagging every request with its request ID, see RequestIDHeader.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	tw := &tracedWriter{ResponseWriter: w, requestID: requestID(r), status: http.StatusOK}
+	tw.Header().Set(RequestIDHeader, tw.requestID)
+	s.mux.ServeHTTP(tw, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, tw.requestID)))
+	s.logRequest(tw, r, time.Since(start))
+}
+
+// solveRequest is the JSON body of a request naming an image to download.
+type solveRequest struct {
+	URL   string `json:"url"`
+	Model string `json:"model"`
+}
+
+// solveResponse is the JSON body of a successful response.
+type solveResponse struct {
+	Model      string  `json:"model"`
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence"`
+	ImageHash  string  `json:"image_hash"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// requestError is a failure to be reported to the client with a status code.
+type requestError struct {
+	status int
+	err    error
+}
+
+// Error implements the error interface.
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+// handleSolve implements POST /solve.
+func (s *Server) handleSolve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	// Watch the client for suspicious patterns once the request is answered
+	var hash string
+	failed := true
+	if s.anomalies != nil {
+		defer func() { s.anomalies.observe(s.identify(r), RequestID(r.Context()), hash, failed) }()
+	}
+
+	start := time.Now()
+	b, name, err := s.readImage(r)
+	if err == nil {
+		hash = amazoncaptcha.ImageHash(b)
+	}
+	if err == nil && name == "" {
+		name = DefaultModel
+	}
+	solver, ok := s.models[name]
+	if err == nil && !ok {
+		err = &requestError{status: http.StatusNotFound, err: fmt.Errorf("unknown model %q", name)}
+	}
+	if err != nil {
+		var reqErr *requestError
+		if !errors.As(err, &reqErr) {
+			reqErr = &requestError{status: http.StatusBadRequest, err: err}
+		}
+		writeError(w, reqErr.status, reqErr.Error())
+		return
+	}
+
+	result, err := solver.SolveDetailed(bytes.NewReader(b))
+	if errors.Is(err, amazoncaptcha.ErrNoTrainingData) {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("not a captcha: %v", err))
+		return
+	}
+	failed = false
+	writeJSON(w, http.StatusOK, solveResponse{
+		Model:      name,
+		Text:       result.Text,
+		Confidence: result.Confidence,
+		ImageHash:  hash,
+		DurationMS: float64(time.Since(start)) / float64(time.Millisecond),
+	})
+}
+
+// readImage reads the captcha image of a request, uploaded as multipart/form-data or named by a JSON body,
+// and the name of the model selected by the request, if any.
+func (s *Server) readImage(r *http.Request) ([]byte, string, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, "", &requestError{status: http.StatusUnsupportedMediaType, err: errors.New("missing or invalid content type")}
+	}
+
+	switch mediaType {
+	case "multipart/form-data":
+		// Leave room for the multipart headers and boundaries around the image
+		limit := s.maxImageSize + 64<<10
+		if r.ContentLength > limit {
+			return nil, "", &requestError{status: http.StatusRequestEntityTooLarge, err: fmt.Errorf("image larger than %d bytes", s.maxImageSize)}
+		}
+		r.Body = http.MaxBytesReader(nil, r.Body, limit)
+		return s.readMultipart(r)
+
+	case "application/json":
+		var req solveRequest
+		if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 64<<10)).Decode(&req); err != nil {
+			return nil, "", fmt.Errorf("failed to decode request: %w", err)
+		}
+		u, err := url.Parse(req.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, "", errors.New("url must be an http or https URL")
+		}
+		name := selectedModel(r, req.Model)
+		if _, ok := s.models[name]; !ok && name != "" {
+			// Do not download images for models that do not exist
+			return nil, name, nil
+		}
+		b, err := s.download(r, u.String())
+		return b, name, err
+
+	default:
+		return nil, "", &requestError{status: http.StatusUnsupportedMediaType, err: fmt.Errorf("unsupported content type %q", mediaType)}
+	}
+}
+
+// selectedModel returns the name of the model selected by the ModelHeader header of a request,
+// or by the model field of its body otherwise.
+func selectedModel(r *http.Request, field string) string {
+	if name := r.Header.Get(ModelHeader); name != "" {
+		return name
+	}
+	return field
+}
+
+// download downloads the captcha image at url, canceling the download if the client goes away.
+func (s *Server) download(r *http.Request, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set(RequestIDHeader, RequestID(r.Context()))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, &requestError{status: http.StatusBadGateway, err: fmt.Errorf("failed to make HTTP request: %w", err)}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &requestError{status: http.StatusBadGateway, err: fmt.Errorf("unexpected HTTP status code: %d", resp.StatusCode)}
+	}
+	b, err := s.readLimited(resp.Body)
+	if err != nil {
+		var reqErr *requestError
+		if !errors.As(err, &reqErr) {
+			err = &requestError{status: http.StatusBadGateway, err: err}
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// readMultipart reads the image field of a multipart/form-data request and the model field, if any, streaming
+// the parts instead of buffering the whole form.
+func (s *Server) readMultipart(r *http.Request) ([]byte, string, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read image field: %w", err)
+	}
+	var b []byte
+	var model string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read form: %w", err)
+		}
+		switch part.FormName() {
+		case "image":
+			if b, err = s.readLimited(part); err != nil {
+				return nil, "", err
+			}
+		case "model":
+			v, err := io.ReadAll(io.LimitReader(part, 256))
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to read form: %w", err)
+			}
+			model = string(v)
+		}
+	}
+	if b == nil {
+		return nil, "", errors.New("failed to read image field: missing image")
+	}
+	return b, selectedModel(r, model), nil
+}
+
+// readLimited reads an image of at most the maximum image size and number of pixels. The number of pixels is
+// checked from the header of the image, so that oversized images are refused before the rest is read.
+func (s *Server) readLimited(r io.Reader) ([]byte, error) {
+	limited, err := amazoncaptcha.LimitImageReader(r, s.maxImageSize, s.maxPixels)
+	if errors.Is(err, amazoncaptcha.ErrImageTooLarge) {
+		return nil, &requestError{status: http.StatusRequestEntityTooLarge, err: err}
+	}
+	if err != nil {
+		return nil, &requestError{status: http.StatusUnprocessableEntity, err: fmt.Errorf("not a captcha: %w", err)}
+	}
+	b, err := io.ReadAll(limited)
+	if errors.Is(err, amazoncaptcha.ErrImageTooLarge) {
+		return nil, &requestError{status: http.StatusRequestEntityTooLarge, err: err}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	return b, nil
+}
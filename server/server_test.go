@@ -0,0 +1,345 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+	"github.com/stretchr/testify/assert"
+)
+
+// renderCaptcha renders a captcha showing answer from letters of the training data as PNG.
+func renderCaptcha(t *testing.T, answer string) []byte {
+	t.Helper()
+	templates := amazoncaptcha.Templates(3)
+	width := 2
+	for _, c := range answer {
+		width += templates[string(c)][1].Bounds().Dx() + 2
+	}
+	img := image.NewGray(image.Rect(0, 0, width, amazoncaptcha.CaptchaHeight))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	offset := 2
+	for _, c := range answer {
+		letter := templates[string(c)][1]
+		for y := 0; y < letter.Bounds().Dy(); y++ {
+			copy(img.Pix[y*img.Stride+offset:], letter.Pix[y*letter.Stride:(y+1)*letter.Stride])
+		}
+		offset += letter.Bounds().Dx() + 2
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// upload builds a multipart/form-data request uploading image in the given field.
+func upload(t *testing.T, field string, image []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile(field, "captcha.png")
+	assert.NoError(t, err)
+	_, _ = part.Write(image)
+	assert.NoError(t, mw.Close())
+	req := httptest.NewRequest(http.MethodPost, "/solve", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+// serve runs a request against s and decodes the JSON response.
+func serve(t *testing.T, s http.Handler, req *http.Request) (int, map[string]interface{}) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return rec.Code, body
+}
+
+func TestSolveUpload(t *testing.T) {
+	s, err := New()
+	assert.NoError(t, err)
+
+	code, body := serve(t, s, upload(t, "image", renderCaptcha(t, "ABCEFG")))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ABCEFG", body["text"])
+	assert.Equal(t, 1.0, body["confidence"])
+	assert.Contains(t, body, "duration_ms")
+
+	code, body = serve(t, s, upload(t, "file", renderCaptcha(t, "ABCEFG")))
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body["message"], "image field")
+
+	// Images that are not captchas cannot be processed
+	var blank bytes.Buffer
+	assert.NoError(t, png.Encode(&blank, image.NewGray(image.Rect(0, 0, 200, 70))))
+	code, body = serve(t, s, upload(t, "image", blank.Bytes()))
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Contains(t, body["message"], "not a captcha")
+
+	// Images above the limit are refused
+	s, err = New(WithMaxImageSize(100))
+	assert.NoError(t, err)
+	code, _ = serve(t, s, upload(t, "image", renderCaptcha(t, "ABCEFG")))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+	s, err = New(WithMaxImagePixels(100))
+	assert.NoError(t, err)
+	code, body = serve(t, s, upload(t, "image", renderCaptcha(t, "ABCEFG")))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+	assert.Contains(t, body["message"], "pixels")
+
+	// Uploads that are not images are refused from their header
+	code, body = serve(t, s, upload(t, "image", []byte("not an image")))
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Contains(t, body["message"], "not a captcha")
+}
+
+func TestSolveNoTrainingData(t *testing.T) {
+	solver, err := amazoncaptcha.NewSolver()
+	assert.NoError(t, err)
+	solver.SetTrainingData(map[string]string{})
+	s, err := New(WithSolver(solver))
+	assert.NoError(t, err)
+
+	// A model without training data is unavailable for solving, but still segments letters
+	code, body := serve(t, s, upload(t, "image", renderCaptcha(t, "ABCEFG")))
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "no_training_data", body["code"])
+	req := upload(t, "image", renderCaptcha(t, "ABCEFG"))
+	req.URL.Path = "/letters"
+	code, _ = serve(t, s, req)
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestSolveUploadModelField(t *testing.T) {
+	empty, err := amazoncaptcha.NewSolver()
+	assert.NoError(t, err)
+	empty.SetTrainingData(map[string]string{"00ff": "A"})
+	s, err := New(WithModel("empty", empty))
+	assert.NoError(t, err)
+
+	// The model field is read from the streamed form before or after the image
+	for _, modelFirst := range []bool{true, false} {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		if modelFirst {
+			assert.NoError(t, mw.WriteField("model", "empty"))
+		}
+		part, err := mw.CreateFormFile("image", "captcha.png")
+		assert.NoError(t, err)
+		_, _ = part.Write(renderCaptcha(t, "ABCEFG"))
+		if !modelFirst {
+			assert.NoError(t, mw.WriteField("model", "empty"))
+		}
+		assert.NoError(t, mw.Close())
+		req := httptest.NewRequest(http.MethodPost, "/solve", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+
+		code, resp := serve(t, s, req)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "empty", resp["model"])
+	}
+}
+
+func TestSolveURL(t *testing.T) {
+	captcha := renderCaptcha(t, "HJKLMN")
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/captcha.png" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(captcha)
+	}))
+	defer images.Close()
+
+	s, err := New(WithHTTPClient(images.Client()))
+	assert.NoError(t, err)
+	request := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/solve", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	code, body := serve(t, s, request(`{"url": "`+images.URL+`/captcha.png"}`))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "HJKLMN", body["text"])
+
+	code, body = serve(t, s, request(`{"url": "`+images.URL+`/missing.png"}`))
+	assert.Equal(t, http.StatusBadGateway, code)
+	assert.Contains(t, body["message"], "404")
+
+	code, _ = serve(t, s, request(`{"url": "file:///etc/passwd"}`))
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = serve(t, s, request(`{"url":`))
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestSolveInvalidRequests(t *testing.T) {
+	s, err := New()
+	assert.NoError(t, err)
+
+	code, _ := serve(t, s, httptest.NewRequest(http.MethodGet, "/solve", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+
+	req := httptest.NewRequest(http.MethodPost, "/solve", strings.NewReader("captcha"))
+	req.Header.Set("Content-Type", "text/plain")
+	code, _ = serve(t, s, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, code)
+
+	_, err = New(WithSolver(nil))
+	assert.Error(t, err)
+	_, err = New(WithMaxImageSize(0))
+	assert.Error(t, err)
+	_, err = New(WithMaxImagePixels(0))
+	assert.Error(t, err)
+}
+
+func TestSolveModels(t *testing.T) {
+	// A model without training data recognizes no letter
+	empty, err := amazoncaptcha.NewSolver()
+	assert.NoError(t, err)
+	empty.SetTrainingData(map[string]string{"00ff": "A"})
+	s, err := New(WithModel("empty", empty))
+	assert.NoError(t, err)
+
+	code, body := serve(t, s, upload(t, "image", renderCaptcha(t, "ABCEFG")))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, DefaultModel, body["model"])
+	assert.Equal(t, "ABCEFG", body["text"])
+
+	req := upload(t, "image", renderCaptcha(t, "ABCEFG"))
+	req.Header.Set(ModelHeader, "empty")
+	code, body = serve(t, s, req)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "empty", body["model"])
+	assert.NotEqual(t, "ABCEFG", body["text"])
+
+	req = upload(t, "image", renderCaptcha(t, "ABCEFG"))
+	req.Header.Set(ModelHeader, "missing")
+	code, body = serve(t, s, req)
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Contains(t, body["message"], "missing")
+
+	// Unknown models are refused before the image is downloaded
+	req = httptest.NewRequest(http.MethodPost, "/solve", strings.NewReader(`{"url": "http://invalid.test/captcha.png", "model": "missing"}`))
+	req.Header.Set("Content-Type", "application/json")
+	code, _ = serve(t, s, req)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), "\namazoncaptcha_solves_total{model=\"default\"} 1\n")
+	assert.Contains(t, rec.Body.String(), "\namazoncaptcha_solves_total{model=\"empty\"} 1\n")
+
+	_, err = New(WithModel(DefaultModel, empty))
+	assert.Error(t, err)
+	_, err = New(WithModel("empty", empty), WithModel("empty", empty))
+	assert.Error(t, err)
+	_, err = New(WithModel("empty", nil))
+	assert.Error(t, err)
+}
+
+func TestOutcome(t *testing.T) {
+	solver, err := amazoncaptcha.NewSolver()
+	assert.NoError(t, err)
+	other, err := amazoncaptcha.NewSolver()
+	assert.NoError(t, err)
+	s, err := New(WithSolver(solver), WithModel("other", other))
+	assert.NoError(t, err)
+	outcome := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/outcome", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	// The outcome of a solve is reported by the image hash of its response, to the model that solved it
+	captcha := renderCaptcha(t, "ABCEFG")
+	code, body := serve(t, s, upload(t, "image", captcha))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, amazoncaptcha.ImageHash(captcha), body["image_hash"])
+	code, body = serve(t, s, outcome(`{"image_hash": "`+strings.ToUpper(amazoncaptcha.ImageHash(captcha))+`", "accepted": true}`))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, DefaultModel, body["model"])
+	assert.Equal(t, true, body["accepted"])
+	stats := solver.Stats()
+	assert.Equal(t, uint64(1), stats.Accepted)
+	assert.Equal(t, uint64(1), stats.Letters["A"].Accepted)
+
+	req := outcome(`{"image_hash": "` + amazoncaptcha.ImageHash(captcha) + `", "accepted": false}`)
+	req.Header.Set(ModelHeader, "other")
+	code, body = serve(t, s, req)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "other", body["model"])
+	assert.Equal(t, uint64(1), other.Stats().Rejected)
+	assert.Equal(t, uint64(0), solver.Stats().Rejected)
+
+	// Malformed reports are refused
+	for body, status := range map[string]int{
+		`{"image_hash": "abc", "accepted": true}`:                                                      http.StatusBadRequest,
+		`{"image_hash": "` + amazoncaptcha.ImageHash(captcha) + `"}`:                                   http.StatusBadRequest,
+		`{"image_hash": "` + amazoncaptcha.ImageHash(captcha) + `", "accepted": "yes"}`:                http.StatusBadRequest,
+		`{"image_hash": "` + amazoncaptcha.ImageHash(captcha) + `", "model": "missing"}`:               http.StatusBadRequest,
+		`{"image_hash": "` + amazoncaptcha.ImageHash(nil) + `", "accepted": true, "model": "missing"}`: http.StatusNotFound,
+	} {
+		code, _ = serve(t, s, outcome(body))
+		assert.Equal(t, status, code, body)
+	}
+	req = outcome(`{}`)
+	req.Header.Set("Content-Type", "text/plain")
+	code, _ = serve(t, s, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, code)
+	code, _ = serve(t, s, httptest.NewRequest(http.MethodGet, "/outcome", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+}
+
+func TestRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	var forwarded string
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get(RequestIDHeader)
+		http.NotFound(w, r)
+	}))
+	defer images.Close()
+	s, err := New(WithHTTPClient(images.Client()), WithLogger(log.New(&logs, "", 0)))
+	assert.NoError(t, err)
+
+	// Request IDs are propagated to downloads, error bodies and logs
+	req := httptest.NewRequest(http.MethodPost, "/solve", strings.NewReader(`{"url": "`+images.URL+`/captcha.png"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(RequestIDHeader, "trace-42")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	assert.Equal(t, "trace-42", rec.Header().Get(RequestIDHeader))
+	assert.Equal(t, "trace-42", forwarded)
+	var body ErrorResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, ErrorResponse{Code: "download_failed", Message: "unexpected HTTP status code: 404", RequestID: "trace-42"}, body)
+	assert.Contains(t, logs.String(), `request_id=trace-42 method=POST path="/solve" status=502`)
+	assert.Contains(t, logs.String(), `error="unexpected HTTP status code: 404"`)
+
+	// Requests without a valid ID get a generated one
+	for _, id := range []string{"", "bad id", strings.Repeat("x", 200)} {
+		req = upload(t, "image", renderCaptcha(t, "ABCEFG"))
+		req.Header.Set(RequestIDHeader, id)
+		rec = httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Len(t, rec.Header().Get(RequestIDHeader), 36)
+	}
+
+	code, resp := serve(t, s, httptest.NewRequest(http.MethodGet, "/solve", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+	assert.Equal(t, "method_not_allowed", resp["code"])
+	assert.NotEmpty(t, resp["request_id"])
+}
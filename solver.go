@@ -0,0 +1,582 @@
+package amazoncaptcha
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Solver solves captchas using its own configuration and training data.
+// A Solver is safe for concurrent use by multiple goroutines.
+type Solver struct {
+	monoWeight                uint8
+	maxLetterLength           int
+	minLetterLength           int
+	placeholder               rune
+	fuzzyDistance             int
+	candidates                int
+	normalization             normalization
+	recognizer                Recognizer
+	rules                     []DisambiguationRule
+	fallbackThresholds        []uint8
+	cutTheWhite               bool
+	adaptiveThreshold         bool
+	despeckle                 int
+	maxImageBytes             int64
+	maxImagePixels            int
+	captchaLength             int
+	charset                   string
+	width, height             int
+	answerFilter              func(string) error
+	minSegmentationConfidence float64
+
+	modelMu sync.RWMutex
+	model   *model
+
+	hooksMu          sync.RWMutex
+	letterSink       LetterSink
+	journal          Journal
+	failureStore     Store
+	selfTraining     bool
+	selfTrainingPath string
+
+	usage      usageTracker
+	outcomes   outcomeTracker
+	duplicates duplicateGuard
+	drift      driftMonitor
+}
+
+// Option configures a Solver.
+type Option func(*Solver) error
+
+// DefaultPlaceholder is the rune standing in for every letter that could not be recognized.
+const DefaultPlaceholder = '-'
+
+// NewSolver creates a Solver configured by opts. Without options, the Solver behaves like the
+// package-level functions: it uses the thresholds MonoWeight, MaximumLetterLength and
+// MinimumLetterLength, captchas of DefaultCaptchaLength letters of any size, the placeholder DefaultPlaceholder and the embedded training data.
+// An error is returned if the embedded training data is needed and corrupt. Without embedded training data,
+// e.g. in a build with the noembed tag, the Solver is created anyway: it segments letters, but its solves fail
+// with ErrNoTrainingData until training data is loaded.
+func NewSolver(opts ...Option) (*Solver, error) {
+	s := newSolver()
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	if s.model == nil {
+		m, err := loadEmbeddedModel()
+		if err != nil && !errors.Is(err, ErrNoTrainingData) {
+			return nil, err
+		}
+		s.model = m
+	}
+	return s, nil
+}
+
+// newSolver creates a Solver with the default configuration, whose training data is left unset
+// until it is first used or configured.
+func newSolver() *Solver {
+	return &Solver{
+		monoWeight:                MonoWeight,
+		maxLetterLength:           MaximumLetterLength,
+		minLetterLength:           MinimumLetterLength,
+		placeholder:               DefaultPlaceholder,
+		captchaLength:             DefaultCaptchaLength,
+		minSegmentationConfidence: DefaultSegmentationConfidence,
+	}
+}
+
+// WithMonoThreshold sets the threshold used to convert grayscale images to binary images,
+// MonoWeight by default. Pixels at or below the threshold become black.
+func WithMonoThreshold(threshold uint8) Option {
+	return func(s *Solver) error {
+		s.monoWeight = threshold
+		return nil
+	}
+}
+
+// WithAdaptiveThreshold makes the Solver binarize every captcha at its own OtsuThreshold instead of the mono
+// threshold, so that captchas with lighter strokes or JPEG smoothing keep their anti-aliased edge pixels.
+// Letters binarized at a higher threshold are bolder than the embedded training data, so it works best with
+// training data collected with the same option, or combined with WithStrokeNormalization or fuzzy matching.
+// Members of an EnsembleSolver without a threshold of their own also use the adaptive threshold.
+func WithAdaptiveThreshold() Option {
+	return func(s *Solver) error {
+		s.adaptiveThreshold = true
+		return nil
+	}
+}
+
+// threshold returns the threshold at which the Solver binarizes a grayscale captcha.
+func (s *Solver) threshold(grayImg *image.Gray) uint8 {
+	if s.adaptiveThreshold {
+		return OtsuThreshold(grayImg)
+	}
+	return s.monoWeight
+}
+
+// WithDespeckle makes the Solver remove the specks of at most maxBlobSize pixels from every binarized captcha
+// with Despeckle before segmenting it, so that JPEG artifacts no longer create phantom letters breaking the
+// segmentation into 6 or 7 segments. Despeckling is disabled by default.
+func WithDespeckle(maxBlobSize int) Option {
+	return func(s *Solver) error {
+		if maxBlobSize <= 0 {
+			return errors.New("maximum speck size must be positive")
+		}
+		s.despeckle = maxBlobSize
+		return nil
+	}
+}
+
+// binarize converts a grayscale captcha to monochrome at threshold, allocating it from a, and removes its
+// specks if enabled.
+func (s *Solver) binarize(grayImg *image.Gray, threshold uint8, a *arena) *image.Gray {
+	mono := monoChrome(grayImg, threshold, a)
+	if s.despeckle > 0 {
+		Despeckle(mono, s.despeckle)
+	}
+	return mono
+}
+
+// WithMaxImageSize bounds the images the Solver decodes to maxBytes bytes and maxPixels pixels, see
+// LimitImageReader. Solves buffer the image to hash it for the journal and outcome reports, reading no more than
+// maxBytes bytes of it, plus one to tell larger images, and the dimensions are checked from the header of the
+// image before it is decoded. The frames of an animated GIF count towards the pixel
+// limit together. Images above a limit fail with ErrImageTooLarge. A limit of 0 disables it; images are not
+// limited by default.
+func WithMaxImageSize(maxBytes int64, maxPixels int) Option {
+	return func(s *Solver) error {
+		if maxBytes < 0 || maxPixels < 0 {
+			return errors.New("maximum image size must not be negative")
+		}
+		s.maxImageBytes = maxBytes
+		s.maxImagePixels = maxPixels
+		return nil
+	}
+}
+
+// WithMaximumLetterLength sets the maximum width of a single letter, MaximumLetterLength by default.
+// Wider segments are split in two.
+func WithMaximumLetterLength(length int) Option {
+	return func(s *Solver) error {
+		if length <= 0 {
+			return errors.New("maximum letter length must be positive")
+		}
+		s.maxLetterLength = length
+		return nil
+	}
+}
+
+// WithMinimumLetterLength sets the minimum width of the first letter, MinimumLetterLength by default.
+func WithMinimumLetterLength(length int) Option {
+	return func(s *Solver) error {
+		if length < 0 {
+			return errors.New("minimum letter length must not be negative")
+		}
+		s.minLetterLength = length
+		return nil
+	}
+}
+
+// WithPlaceholder sets the rune standing in for every letter that could not be recognized,
+// DefaultPlaceholder by default. Letters are upper case, so any other rune is unambiguous.
+func WithPlaceholder(placeholder rune) Option {
+	return func(s *Solver) error {
+		if placeholder >= 'A' && placeholder <= 'Z' {
+			return fmt.Errorf("placeholder %q is a letter", placeholder)
+		}
+		s.placeholder = placeholder
+		return nil
+	}
+}
+
+// WithFuzzyMatching enables approximate matching: when a letter is not found in the training data,
+// it is recognized as the letter of the nearest training entry that differs in at most maxDistance
+// pixels, with a confidence below 1 derived from the distance. Letters further away from every entry
+// remain unknown. Approximate matching is disabled by default.
+func WithFuzzyMatching(maxDistance int) Option {
+	return func(s *Solver) error {
+		if maxDistance <= 0 {
+			return errors.New("maximum fuzzy distance must be positive")
+		}
+		s.fuzzyDistance = maxDistance
+		return nil
+	}
+}
+
+// WithCandidates makes results list the n letters nearest to every segmented letter in Result.Candidates,
+// e.g. 3 for the top three. Listing candidates costs a nearest neighbor search per letter, so it is disabled
+// by default.
+func WithCandidates(n int) Option {
+	return func(s *Solver) error {
+		if n <= 0 {
+			return errors.New("number of candidates must be positive")
+		}
+		s.candidates = n
+		return nil
+	}
+}
+
+// WithLetterAlignment makes the Solver center every letter with AlignLetter before extracting its feature,
+// so that horizontal jitter within a segment no longer yields distinct features for the same glyph.
+// The training data is aligned the same way when first used, so existing training data keeps working.
+// Alignment is disabled by default, since features of aligned letters differ from those of ExtractFeatures.
+func WithLetterAlignment() Option {
+	return func(s *Solver) error {
+		s.normalization.align = true
+		return nil
+	}
+}
+
+// WithStrokeNormalization makes the Solver map the strokes of every letter to StrokeWidth with NormalizeStroke
+// before extracting its feature, so that letters binarized thinner or bolder, e.g. from captchas saved at another
+// JPEG quality, match the same training entries. Like letter alignment, it normalizes the training data the same
+// way when first used, and it is disabled by default. Combined with WithLetterAlignment, strokes are normalized
+// before letters are aligned.
+func WithStrokeNormalization() Option {
+	return func(s *Solver) error {
+		s.normalization.stroke = true
+		return nil
+	}
+}
+
+// WithGridNormalization makes the Solver crop every letter to its black pixels and scale it to a grid of
+// GridWidth by CaptchaHeight pixels with NormalizeGrid before extracting its feature, so that features no longer
+// depend on small shifts of the segmentation or on the size of the glyph. Unlike cropping letters with
+// CutTheWhite alone, the training data is normalized the same way when first used, so it keeps matching. Grid
+// normalization is disabled by default, and it runs after stroke normalization and letter alignment.
+func WithGridNormalization() Option {
+	return func(s *Solver) error {
+		s.normalization.grid = true
+		return nil
+	}
+}
+
+// WithRecognizer makes the Solver recognize the letters that are not found in the training data with r,
+// e.g. a TemplateMatcher, instead of leaving them unknown. With fuzzy matching enabled, r is only asked
+// about the letters that approximate matching does not recognize either.
+func WithRecognizer(r Recognizer) Option {
+	return func(s *Solver) error {
+		s.recognizer = r
+		return nil
+	}
+}
+
+// WithDisambiguation makes the Solver re-examine the letters it recognized without an exact match, by fuzzy
+// matching or a Recognizer, with rules, e.g. those learned by DisambiguationRules: a letter recognized as a
+// letter of a rule is taken for the letter the rule decides on. The first rule naming the letter applies.
+// Exact matches are trusted and not re-examined.
+func WithDisambiguation(rules ...DisambiguationRule) Option {
+	return func(s *Solver) error {
+		if err := validateRules(rules); err != nil {
+			return err
+		}
+		s.rules = append([]DisambiguationRule(nil), rules...)
+		return nil
+	}
+}
+
+// WithThresholdFallback makes the Solver retry captchas with unrecognized letters at other binarization
+// thresholds, e.g. 1, 32 and 64, in the given order, and answer with the first attempt that recognizes every
+// letter, whose Result.Strategy is StrategyThreshold. Many near misses come from JPEG artifacts that the mono
+// threshold turns into stray or missing pixels. Without an attempt recognizing every letter, the answer at the
+// mono threshold is kept. Retrying is disabled by default, since every attempt costs a full recognition.
+func WithThresholdFallback(thresholds ...uint8) Option {
+	return func(s *Solver) error {
+		if len(thresholds) == 0 {
+			return errors.New("no fallback thresholds given")
+		}
+		s.fallbackThresholds = append([]uint8(nil), thresholds...)
+		return nil
+	}
+}
+
+// WithSegmentationFallback sets the segmentation confidence, see SegmentationConfidence, below which the Solver
+// segments captchas again by their connected components before recognizing their letters,
+// DefaultSegmentationConfidence by default. Column scanning merges letters whose columns overlap, e.g. across
+// a speck between them, which connected components keep apart. The components are only used if they are more
+// confident than the columns. 0 disables the fallback.
+func WithSegmentationFallback(minConfidence float64) Option {
+	return func(s *Solver) error {
+		if minConfidence < 0 || minConfidence > 1 {
+			return errors.New("minimum segmentation confidence must be between 0 and 1")
+		}
+		s.minSegmentationConfidence = minConfidence
+		return nil
+	}
+}
+
+// WithAnswerFilter makes the Solver check every answer in which all letters were recognized with filter
+// before returning it, so that answers violating known constraints, e.g. of a length or with letters a site
+// never uses, are rejected by returning an error. A rejected answer counts as unsolved: the retry strategies
+// of the Solver, such as the thresholds of WithThresholdFallback or the strategies of SolveBestEffort, go on
+// looking for another answer, and if none passes the filter, Solve fails with an *AnswerRejectedError.
+// The filter must be safe for concurrent use.
+func WithAnswerFilter(filter func(answer string) error) Option {
+	return func(s *Solver) error {
+		if filter == nil {
+			return errors.New("no answer filter given")
+		}
+		s.answerFilter = filter
+		return nil
+	}
+}
+
+// WithTrainingData makes the Solver use the training data read from r, in its binary or JSON form,
+// instead of the embedded training data.
+func WithTrainingData(r io.Reader) Option {
+	return func(s *Solver) error {
+		features, err := readTrainingData(r)
+		if err != nil {
+			return err
+		}
+		s.model = &model{features: features}
+		return nil
+	}
+}
+
+// WithTrainingDataURL makes the Solver use training data downloaded from url instead of the embedded
+// training data, caching it at cachePath. See LoadTrainingDataFromURL for the caching behavior.
+func WithTrainingDataURL(url, cachePath string) Option {
+	return func(s *Solver) error {
+		features, err := fetchTrainingData(http.DefaultClient, url, cachePath)
+		if err != nil {
+			return err
+		}
+		s.model = &model{features: features}
+		return nil
+	}
+}
+
+// WithLetterSink enables the automatic capture of unknown letters into sink.
+func WithLetterSink(sink LetterSink) Option {
+	return func(s *Solver) error {
+		s.letterSink = sink
+		return nil
+	}
+}
+
+// WithUnknownLetterHandler enables passing every letter that could not be recognized to handler, together with
+// its position in the captcha, e.g. to collect letter crops for a training pipeline. The image is a copy the
+// handler may retain. The handler is a LetterSink, so it replaces any sink set with WithLetterSink.
+func WithUnknownLetterHandler(handler func(letter *image.Gray, position int)) Option {
+	return func(s *Solver) error {
+		s.letterSink = letterHandler(handler)
+		return nil
+	}
+}
+
+// WithJournal enables recording every solve into j.
+func WithJournal(j Journal) Option {
+	return func(s *Solver) error {
+		s.journal = j
+		return nil
+	}
+}
+
+// WithFailureStore enables archiving the images of the captchas that could not be solved into store,
+// see SetFailureStore.
+func WithFailureStore(store Store) Option {
+	return func(s *Solver) error {
+		s.failureStore = store
+		return nil
+	}
+}
+
+// WithUsageTracking enables counting how often each training entry matches a letter.
+func WithUsageTracking() Option {
+	return func(s *Solver) error {
+		s.usage.enabled = 1
+		return nil
+	}
+}
+
+// WithSelfTraining enables self-training from accepted answers, optionally persisting
+// the extended training data to persistPath. See SetSelfTraining.
+func WithSelfTraining(persistPath string) Option {
+	return func(s *Solver) error {
+		s.selfTraining = true
+		s.selfTrainingPath = persistPath
+		return nil
+	}
+}
+
+// WithDuplicateGuard enables flagging answers repeated for different captchas within window, see SetDuplicateGuard.
+func WithDuplicateGuard(window time.Duration, hook func(*DuplicateAnswerError)) Option {
+	return func(s *Solver) error {
+		if window <= 0 {
+			return errors.New("duplicate guard window must be positive")
+		}
+		s.duplicates.window = window
+		s.duplicates.hook = hook
+		return nil
+	}
+}
+
+// customSolver holds the Solver set with SetDefaultSolver, if any.
+var customSolver atomic.Value
+
+// builtinSolver backs the package-level functions unless another Solver is set. It is created on first use,
+// and its training data is loaded on first use as well.
+var (
+	builtinOnce   sync.Once
+	builtinSolver *Solver
+)
+
+// SetDefaultSolver makes s back the package-level functions, such as Solve, SolveDetailed and SetLetterSink, so
+// that libraries calling the simple API inherit custom options and training data without changing their call
+// sites. A nil s restores the Solver with the default configuration and embedded training data. It is safe to
+// call concurrently with solves: solves that already started finish with the previous Solver.
+func SetDefaultSolver(s *Solver) {
+	customSolver.Store(s)
+}
+
+// DefaultSolver returns the Solver backing the package-level functions, see SetDefaultSolver.
+func DefaultSolver() *Solver {
+	return defaultSolver()
+}
+
+// defaultSolver returns the Solver backing the package-level functions.
+func defaultSolver() *Solver {
+	if s, _ := customSolver.Load().(*Solver); s != nil {
+		return s
+	}
+	builtinOnce.Do(func() { builtinSolver = newSolver() })
+	return builtinSolver
+}
+
+// detached returns a Solver with the configuration and current training data of s, but none of its hooks,
+// statistics or usage tracking, for solving captchas that must not be observed, e.g. self-test captchas.
+func (s *Solver) detached() *Solver {
+	return &Solver{
+		monoWeight:                s.monoWeight,
+		maxLetterLength:           s.maxLetterLength,
+		minLetterLength:           s.minLetterLength,
+		placeholder:               s.placeholder,
+		fuzzyDistance:             s.fuzzyDistance,
+		candidates:                s.candidates,
+		normalization:             s.normalization,
+		recognizer:                s.recognizer,
+		rules:                     s.rules,
+		fallbackThresholds:        s.fallbackThresholds,
+		cutTheWhite:               s.cutTheWhite,
+		adaptiveThreshold:         s.adaptiveThreshold,
+		despeckle:                 s.despeckle,
+		maxImageBytes:             s.maxImageBytes,
+		maxImagePixels:            s.maxImagePixels,
+		captchaLength:             s.captchaLength,
+		charset:                   s.charset,
+		width:                     s.width,
+		height:                    s.height,
+		answerFilter:              s.answerFilter,
+		minSegmentationConfidence: s.minSegmentationConfidence,
+		model:                     s.trainingData(),
+	}
+}
+
+// trainingData returns the current training data snapshot of the Solver,
+// loading the embedded training data if none is set yet.
+func (s *Solver) trainingData() *model {
+	s.modelMu.RLock()
+	m := s.model
+	s.modelMu.RUnlock()
+	if m != nil {
+		return m
+	}
+
+	s.modelMu.Lock()
+	defer s.modelMu.Unlock()
+	s.loadModelLocked()
+	return s.model
+}
+
+// loadModelLocked sets the training data to the embedded training data if none is set yet.
+// The caller must hold modelMu for writing.
+func (s *Solver) loadModelLocked() {
+	if s.model == nil {
+		s.model, _ = loadEmbeddedModel()
+	}
+}
+
+// recognitionModel returns the training data letters are recognized with: the current snapshot,
+// normalized if the Solver normalizes letters.
+func (s *Solver) recognitionModel() *model {
+	m := s.trainingData()
+	if s.normalization.enabled() {
+		return m.normalized(s.normalization)
+	}
+	return m
+}
+
+// checkTrainingData returns ErrNoTrainingData if the Solver has neither training data nor a recognizer to
+// recognize letters with, so that solves fail before decoding rather than answering only placeholders.
+func (s *Solver) checkTrainingData() error {
+	if s.recognizer == nil && len(s.trainingData().features) == 0 {
+		return ErrNoTrainingData
+	}
+	return nil
+}
+
+// letterFeature extracts the feature of a segmented letter, normalizing the letter first if the Solver
+// normalizes letters. The intermediate images are allocated from a.
+func (s *Solver) letterFeature(letter *image.Gray, a *arena) (string, error) {
+	return extractFeatures(s.normalization.apply(letter, a), a)
+}
+
+// setTrainingData replaces the training data used for recognition.
+func (s *Solver) setTrainingData(features map[string]string) {
+	m := &model{features: features}
+	s.modelMu.Lock()
+	defer s.modelMu.Unlock()
+	s.model = m
+}
+
+// updateTrainingData replaces the training data snapshot with a copy modified by update, which receives
+// the current snapshot and the features of the copy, and returns the new snapshot. If update reports
+// no change, the current snapshot is kept. Solves running concurrently keep using the snapshot they
+// started with.
+func (s *Solver) updateTrainingData(update func(current *model, features map[string]string) bool) *model {
+	s.modelMu.Lock()
+	defer s.modelMu.Unlock()
+	s.loadModelLocked()
+	features := make(map[string]string, len(s.model.features)+1)
+	for k, v := range s.model.features {
+		features[k] = v
+	}
+	if update(s.model, features) {
+		s.model = &model{features: features}
+	}
+	return s.model
+}
+
+// addTrainingData adds entries to the training data by replacing the snapshot with an extended copy,
+// and returns the new snapshot.
+func (s *Solver) addTrainingData(entries map[string]string) *model {
+	return s.updateTrainingData(func(_ *model, features map[string]string) bool {
+		for k, v := range entries {
+			features[k] = v
+		}
+		return true
+	})
+}
+
+// currentLetterSink returns the configured letter sink, or nil if capture is disabled.
+func (s *Solver) currentLetterSink() LetterSink {
+	s.hooksMu.RLock()
+	defer s.hooksMu.RUnlock()
+	return s.letterSink
+}
+
+// currentJournal returns the configured journal, or nil if the journal is disabled.
+func (s *Solver) currentJournal() Journal {
+	s.hooksMu.RLock()
+	defer s.hooksMu.RUnlock()
+	return s.journal
+}
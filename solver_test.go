@@ -0,0 +1,308 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSolver(t *testing.T) {
+	captcha := syntheticCaptcha(t, "ABCEFG")
+
+	// The default configuration matches the package-level functions
+	solver, err := NewSolver()
+	assert.NoError(t, err)
+	answer, err := solver.Solve(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", answer)
+
+	// Solves of a Solver do not show up in the package-level statistics
+	assert.Equal(t, uint64(1), solver.Stats().Solves)
+
+	// A larger minimum letter length rejects the segmentation
+	solver, err = NewSolver(WithMinimumLetterLength(MaximumLetterLength + 1))
+	assert.NoError(t, err)
+	answer, err = solver.Solve(bytes.NewReader(captcha))
+	assert.True(t, errors.Is(err, ErrSegmentationFailed))
+	assert.Empty(t, answer)
+
+	_, err = NewSolver(WithMaximumLetterLength(0))
+	assert.Error(t, err)
+}
+
+func TestNewSolverWithTrainingData(t *testing.T) {
+	feature, _ := trainingLetter(t, "A")
+	b, err := json.Marshal(map[string]string{feature: "A"})
+	assert.NoError(t, err)
+
+	solver, err := NewSolver(WithTrainingData(bytes.NewReader(b)))
+	assert.NoError(t, err)
+	assert.Len(t, solver.trainingData().features, 1)
+
+	// Only the letter present in the custom training data is recognized
+	answer, err := solver.Solve(bytes.NewReader(syntheticCaptcha(t, "ABACAE")))
+	assert.True(t, errors.Is(err, ErrUnrecognizedLetter))
+	assert.Equal(t, "A-A-A-", answer)
+
+	_, err = NewSolver(WithTrainingData(bytes.NewReader([]byte("invalid"))))
+	assert.Error(t, err)
+}
+
+func TestNewSolverWithPlaceholder(t *testing.T) {
+	solver, err := NewSolver(WithPlaceholder('?'))
+	assert.NoError(t, err)
+
+	captcha := flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)
+	answer, err := solver.Solve(bytes.NewReader(captcha))
+	assert.True(t, errors.Is(err, ErrUnrecognizedLetter))
+	assert.Equal(t, "AB?EFG", answer)
+
+	_, err = NewSolver(WithPlaceholder('A'))
+	assert.Error(t, err)
+}
+
+func TestNewSolverWithFuzzyMatching(t *testing.T) {
+	captcha := flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)
+
+	solver, err := NewSolver(WithFuzzyMatching(10))
+	assert.NoError(t, err)
+	result, err := solver.SolveDetailed(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", result.Text)
+	assert.True(t, result.Solved)
+	assert.Greater(t, result.LetterConfidence[2], 0.9)
+	assert.Less(t, result.LetterConfidence[2], 1.0)
+
+	_, err = NewSolver(WithFuzzyMatching(0))
+	assert.Error(t, err)
+}
+
+func TestNewSolverWithCandidates(t *testing.T) {
+	captcha := flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)
+
+	solver, err := NewSolver(WithCandidates(3))
+	assert.NoError(t, err)
+	result, err := solver.SolveDetailed(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "AB-EFG", result.Text)
+	if assert.Len(t, result.Candidates, 6) {
+		for i, candidates := range result.Candidates {
+			if !assert.Len(t, candidates, 3) {
+				continue
+			}
+
+			// The candidates are distinct letters, closest first, led by the recognized letter
+			assert.Equal(t, "ABCEFG"[i:i+1], candidates[0].Letter)
+			assert.NotEqual(t, candidates[0].Letter, candidates[1].Letter)
+			assert.NotEqual(t, candidates[1].Letter, candidates[2].Letter)
+			assert.NotEqual(t, candidates[0].Letter, candidates[2].Letter)
+			assert.LessOrEqual(t, candidates[0].Distance, candidates[1].Distance)
+			assert.LessOrEqual(t, candidates[1].Distance, candidates[2].Distance)
+		}
+		assert.Equal(t, 0, result.Candidates[0][0].Distance)
+		assert.Equal(t, 1, result.Candidates[2][0].Distance)
+	}
+
+	// Best-effort results list candidates too
+	result, err = solver.SolveBestEffort(context.Background(), bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Len(t, result.Candidates, 6)
+
+	// Candidates are disabled by default
+	result, err = SolveDetailed(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Nil(t, result.Candidates)
+
+	_, err = NewSolver(WithCandidates(0))
+	assert.Error(t, err)
+}
+
+func TestNewSolverWithThresholdFallback(t *testing.T) {
+
+	// Lighten a black pixel of the third letter, as JPEG artifacts do, so that it turns white at MonoWeight
+	img, err := png.Decode(bytes.NewReader(syntheticCaptcha(t, "ABCEFG")))
+	assert.NoError(t, err)
+	gray := img.(*image.Gray)
+	box := FindLetterBoxes(gray, MaximumLetterLength)[2]
+	for i := box.Min.X; i < box.Max.X; i++ {
+		if gray.Pix[35*gray.Stride+i] == 0 {
+			gray.Pix[35*gray.Stride+i] = 48
+			break
+		}
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, gray))
+	captcha := buf.Bytes()
+
+	result, err := SolveDetailed(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "AB-EFG", result.Text)
+
+	// The first threshold turning the pixel black again solves the captcha
+	solver, err := NewSolver(WithThresholdFallback(1, 32, 64))
+	assert.NoError(t, err)
+	result, err = solver.SolveDetailed(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", result.Text)
+	assert.True(t, result.Solved)
+	assert.Equal(t, StrategyThreshold, result.Strategy)
+
+	// Without such a threshold, the answer at the mono threshold is kept
+	solver, err = NewSolver(WithThresholdFallback(32))
+	assert.NoError(t, err)
+	result, err = solver.SolveDetailed(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "AB-EFG", result.Text)
+	assert.Equal(t, StrategyExact, result.Strategy)
+
+	_, err = NewSolver(WithThresholdFallback())
+	assert.Error(t, err)
+}
+
+func TestNewSolverWithAnswerFilter(t *testing.T) {
+	captcha := syntheticCaptcha(t, "ABCEFG")
+	errBanned := errors.New("answer contains A")
+
+	// Rejected answers fail the solve, with the answer and the error of the filter
+	solver, err := NewSolver(WithAnswerFilter(func(answer string) error {
+		if strings.Contains(answer, "A") {
+			return errBanned
+		}
+		return nil
+	}))
+	assert.NoError(t, err)
+	answer, err := solver.Solve(bytes.NewReader(captcha))
+	assert.Equal(t, "ABCEFG", answer)
+	assert.True(t, errors.Is(err, ErrAnswerRejected))
+	assert.True(t, errors.Is(err, errBanned))
+	var rejected *AnswerRejectedError
+	if assert.True(t, errors.As(err, &rejected)) {
+		assert.Equal(t, "ABCEFG", rejected.Answer)
+	}
+
+	result, err := solver.SolveDetailed(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.False(t, result.Solved)
+	assert.Zero(t, result.Confidence)
+	assert.True(t, errors.Is(result.Rejected(), errBanned))
+	assert.Empty(t, result.UnknownPositions())
+
+	// Other answers pass the filter
+	answer, err = solver.Solve(bytes.NewReader(syntheticCaptcha(t, "HJKLMN")))
+	assert.NoError(t, err)
+	assert.Equal(t, "HJKLMN", answer)
+
+	// A rejected answer makes the Solver retry at the fallback thresholds
+	calls := 0
+	solver, err = NewSolver(WithThresholdFallback(32), WithAnswerFilter(func(answer string) error {
+		calls++
+		if calls == 1 {
+			return errBanned
+		}
+		return nil
+	}))
+	assert.NoError(t, err)
+	result, err = solver.SolveDetailed(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.True(t, result.Solved)
+	assert.Nil(t, result.Rejected())
+	assert.Equal(t, StrategyThreshold, result.Strategy)
+	assert.Equal(t, 2, calls)
+
+	// Unrecognized letters are not handed to the filter
+	calls = 0
+	_, err = solver.Solve(bytes.NewReader(flipPixel(t, captcha, 2)))
+	assert.True(t, errors.Is(err, ErrUnrecognizedLetter))
+	assert.Zero(t, calls)
+
+	_, err = NewSolver(WithAnswerFilter(nil))
+	assert.Error(t, err)
+}
+
+func TestNewSolverWithAdaptiveThreshold(t *testing.T) {
+
+	// Draw the strokes in gray, as captchas with lighter strokes are, so that they turn white at MonoWeight
+	img, err := png.Decode(bytes.NewReader(syntheticCaptcha(t, "ABCEFG")))
+	assert.NoError(t, err)
+	gray := img.(*image.Gray)
+	for i, v := range gray.Pix {
+		if v == 0 {
+			gray.Pix[i] = 100
+		}
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, gray))
+	captcha := buf.Bytes()
+
+	_, err = SolveDetailed(bytes.NewReader(captcha))
+	assert.ErrorIs(t, err, ErrSegmentationFailed)
+
+	solver, err := NewSolver(WithAdaptiveThreshold())
+	assert.NoError(t, err)
+	result, err := solver.SolveDetailed(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", result.Text)
+	assert.Equal(t, StrategyExact, result.Strategy)
+	assert.Equal(t, uint8(100), OtsuThreshold(gray))
+}
+
+func TestNewSolverWithDespeckle(t *testing.T) {
+
+	// Add a speck in the right margin, as JPEG artifacts do, which is taken for a seventh segment
+	img, err := png.Decode(bytes.NewReader(syntheticCaptcha(t, "ABCEFG")))
+	assert.NoError(t, err)
+	gray := img.(*image.Gray)
+	gray.Pix[35*gray.Stride+gray.Bounds().Dx()-1] = 0
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, gray))
+	captcha := buf.Bytes()
+
+	result, err := SolveDetailed(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.NotEqual(t, "ABCEFG", result.Text)
+
+	solver, err := NewSolver(WithDespeckle(4))
+	assert.NoError(t, err)
+	result, err = solver.SolveDetailed(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", result.Text)
+
+	_, err = NewSolver(WithDespeckle(0))
+	assert.Error(t, err)
+}
+
+func TestSetDefaultSolver(t *testing.T) {
+	builtin := DefaultSolver()
+	solver, err := NewSolver(WithPlaceholder('?'))
+	assert.NoError(t, err)
+	SetDefaultSolver(solver)
+	defer SetDefaultSolver(nil)
+
+	// The package-level functions use the options of the default Solver
+	captcha := flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)
+	assert.Same(t, solver, DefaultSolver())
+	answer, err := Solve(bytes.NewReader(captcha))
+	assert.ErrorIs(t, err, ErrUnrecognizedLetter)
+	assert.Equal(t, "AB?EFG", answer)
+
+	// Solves keep working while the default Solver is replaced
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			_, _ = SolveDetailed(bytes.NewReader(captcha))
+		}
+	}()
+	SetDefaultSolver(nil)
+	<-done
+	assert.Same(t, builtin, DefaultSolver())
+	answer, _ = Solve(bytes.NewReader(captcha))
+	assert.Equal(t, "AB-EFG", answer)
+}
@@ -0,0 +1,101 @@
+package amazoncaptcha
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// StatsRegistry aggregates the statistics of several Solvers, e.g. one per captcha variant or model, so that
+// the accuracy of all of them is observable at one place. Solvers are registered under a name and their
+// statistics are snapshotted when the registry is read, so registering a Solver costs its solves nothing.
+// A StatsRegistry is safe for concurrent use by multiple goroutines.
+type StatsRegistry struct {
+	mu      sync.RWMutex
+	solvers map[string]*Solver
+}
+
+// NewStatsRegistry creates an empty StatsRegistry.
+func NewStatsRegistry() *StatsRegistry {
+	return &StatsRegistry{solvers: make(map[string]*Solver)}
+}
+
+// Register adds solver to the registry under name. It fails if the name is empty or already registered.
+func (r *StatsRegistry) Register(name string, solver *Solver) error {
+	if name == "" {
+		return errors.New("solver name is empty")
+	}
+	if solver == nil {
+		return errors.New("solver is nil")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.solvers[name]; ok {
+		return fmt.Errorf("solver %q is already registered", name)
+	}
+	r.solvers[name] = solver
+	return nil
+}
+
+// Unregister removes the Solver registered under name, if any, e.g. when a model is retired.
+// Its statistics no longer count towards the combined statistics.
+func (r *StatsRegistry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.solvers, name)
+}
+
+// Names returns the names of the registered Solvers, sorted.
+func (r *StatsRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.solvers))
+	for name := range r.solvers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StatsByName returns a snapshot of the statistics of every registered Solver, by name.
+func (r *StatsRegistry) StatsByName() map[string]Stats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	stats := make(map[string]Stats, len(r.solvers))
+	for name, solver := range r.solvers {
+		stats[name] = solver.Stats()
+	}
+	return stats
+}
+
+// Stats returns the statistics of all registered Solvers combined: the counters and the outcomes per letter
+// are summed. Every Solver is snapshotted on its own, so solves finishing meanwhile may be counted for some
+// Solvers but not for others.
+func (r *StatsRegistry) Stats() Stats {
+	combined := Stats{Letters: make(map[string]LetterStats)}
+	for _, stats := range r.StatsByName() {
+		combined.add(stats)
+	}
+	return combined
+}
+
+// add adds the counters and letter outcomes of other to the statistics.
+func (s *Stats) add(other Stats) {
+	s.Solves += other.Solves
+	s.Failures += other.Failures
+	s.Solved += other.Solved
+	s.Accepted += other.Accepted
+	s.Rejected += other.Rejected
+	s.Duplicates += other.Duplicates
+	if len(other.Letters) > 0 && s.Letters == nil {
+		s.Letters = make(map[string]LetterStats, len(other.Letters))
+	}
+	for letter, outcomes := range other.Letters {
+		total := s.Letters[letter]
+		total.Accepted += outcomes.Accepted
+		total.Rejected += outcomes.Rejected
+		s.Letters[letter] = total
+	}
+}
@@ -0,0 +1,62 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsRegistry(t *testing.T) {
+	us, err := NewSolver()
+	assert.NoError(t, err)
+	jp, err := NewSolver()
+	assert.NoError(t, err)
+
+	registry := NewStatsRegistry()
+	assert.NoError(t, registry.Register("amazon-us", us))
+	assert.NoError(t, registry.Register("amazon-jp", jp))
+	assert.Error(t, registry.Register("amazon-us", jp))
+	assert.Error(t, registry.Register("", jp))
+	assert.Error(t, registry.Register("nil", nil))
+	assert.Equal(t, []string{"amazon-jp", "amazon-us"}, registry.Names())
+
+	// Solve concurrently with both Solvers while reading the registry
+	captcha := syntheticCaptcha(t, "AXBYCE")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(solver *Solver) {
+			defer wg.Done()
+			_, err := solver.Solve(bytes.NewReader(captcha))
+			assert.NoError(t, err)
+			_ = registry.Stats()
+		}([]*Solver{us, jp}[i%2])
+	}
+	wg.Wait()
+	_, err = jp.Solve(bytes.NewReader([]byte("not an image")))
+	assert.Error(t, err)
+	us.ReportOutcome(ImageHash(captcha), true)
+	jp.ReportOutcome(ImageHash(captcha), false)
+
+	byName := registry.StatsByName()
+	assert.Equal(t, uint64(2), byName["amazon-us"].Solves)
+	assert.Equal(t, uint64(3), byName["amazon-jp"].Solves)
+
+	// The combined statistics sum the counters and letter outcomes of every Solver
+	stats := registry.Stats()
+	assert.Equal(t, uint64(5), stats.Solves)
+	assert.Equal(t, uint64(1), stats.Failures)
+	assert.Equal(t, uint64(4), stats.Solved)
+	assert.Equal(t, uint64(1), stats.Accepted)
+	assert.Equal(t, uint64(1), stats.Rejected)
+	assert.Equal(t, LetterStats{Accepted: 1, Rejected: 1}, stats.Letters["X"])
+	assert.Equal(t, 0.5, stats.Letters["X"].Accuracy())
+
+	// Unregistered Solvers no longer count
+	registry.Unregister("amazon-jp")
+	assert.Equal(t, us.Stats(), registry.Stats())
+	registry.Unregister("amazon-us")
+	assert.Zero(t, registry.Stats().Solves)
+}
@@ -0,0 +1,115 @@
+package amazoncaptcha
+
+import "image"
+
+// StrokeWidth is the width, in pixels, NormalizeStroke maps the strokes of every letter to.
+const StrokeWidth = 3
+
+// NormalizeStroke maps the strokes of a letter to a canonical width of StrokeWidth pixels: the letter is
+// thinned to a skeleton one pixel wide, which is then thickened evenly. Captchas saved at different JPEG
+// qualities binarize to strokes of different widths, which otherwise need separate training entries.
+// The returned image has the bounds of img; ink that would be thickened past them is dropped.
+func NormalizeStroke(img *image.Gray) *image.Gray {
+	return normalizeStroke(img, nil)
+}
+
+// normalizeStroke implements NormalizeStroke, allocating the images from a.
+func normalizeStroke(img *image.Gray, a *arena) *image.Gray {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// Collect the black pixels as a plane of ones and zeros
+	ink := a.alloc(width * height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if img.GrayAt(bounds.Min.X+x, bounds.Min.Y+y).Y == 0 {
+				ink[y*width+x] = 1
+			}
+		}
+	}
+
+	thin(ink, width, height, a)
+
+	// Thicken the skeleton by drawing a square of StrokeWidth pixels around every remaining pixel
+	normalized := a.newGray(image.Rect(0, 0, width, height))
+	for i := range normalized.Pix {
+		normalized.Pix[i] = 255
+	}
+	radius := StrokeWidth / 2
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if ink[y*width+x] == 0 {
+				continue
+			}
+			for ny := y - radius; ny <= y+radius; ny++ {
+				for nx := x - radius; nx <= x+radius; nx++ {
+					if nx >= 0 && nx < width && ny >= 0 && ny < height {
+						normalized.Pix[ny*normalized.Stride+nx] = 0
+					}
+				}
+			}
+		}
+	}
+
+	return normalized
+}
+
+// thin reduces the strokes of a plane of ones and zeros to a skeleton one pixel wide in place,
+// with the Zhang-Suen thinning algorithm. The scratch plane is allocated from a.
+func thin(ink []byte, width, height int, a *arena) {
+	at := func(x, y int) byte {
+		if x < 0 || x >= width || y < 0 || y >= height {
+			return 0
+		}
+		return ink[y*width+x]
+	}
+	remove := a.alloc(width * height)
+
+	for changed := true; changed; {
+		changed = false
+		for step := 0; step < 2; step++ {
+			for y := 0; y < height; y++ {
+				for x := 0; x < width; x++ {
+					remove[y*width+x] = 0
+					if ink[y*width+x] == 0 {
+						continue
+					}
+
+					// The neighbors clockwise from the top: P2 to P9
+					p := [8]byte{
+						at(x, y-1), at(x+1, y-1), at(x+1, y), at(x+1, y+1),
+						at(x, y+1), at(x-1, y+1), at(x-1, y), at(x-1, y-1),
+					}
+
+					// The pixel must have 2 to 6 black neighbors, forming a single run clockwise
+					neighbors, transitions := 0, 0
+					for i := range p {
+						neighbors += int(p[i])
+						if p[i] == 0 && p[(i+1)%8] == 1 {
+							transitions++
+						}
+					}
+					if neighbors < 2 || neighbors > 6 || transitions != 1 {
+						continue
+					}
+
+					// The first step removes south-east boundary pixels and north-west corners,
+					// the second step north-west boundary pixels and south-east corners
+					if step == 0 && (p[0]*p[2]*p[4] != 0 || p[2]*p[4]*p[6] != 0) {
+						continue
+					}
+					if step == 1 && (p[0]*p[2]*p[6] != 0 || p[0]*p[4]*p[6] != 0) {
+						continue
+					}
+					remove[y*width+x] = 1
+				}
+			}
+			for i, r := range remove {
+				if r == 1 {
+					ink[i] = 0
+					changed = true
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// boldLetter returns a copy of a letter with every stroke thickened by one pixel on each side.
+func boldLetter(letter *image.Gray) *image.Gray {
+	bold := image.NewGray(letter.Bounds())
+	copy(bold.Pix, letter.Pix)
+	width, height := letter.Bounds().Dx(), letter.Bounds().Dy()
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if letter.Pix[y*letter.Stride+x] != 0 {
+				continue
+			}
+			for _, d := range []image.Point{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+				if p := image.Pt(x, y).Add(d); p.In(bold.Rect) {
+					bold.Pix[p.Y*bold.Stride+p.X] = 0
+				}
+			}
+		}
+	}
+	return bold
+}
+
+// pixelDifferences returns the number of pixels in which two images of the same bounds differ.
+func pixelDifferences(a, b *image.Gray) int {
+	differences := 0
+	for i := range a.Pix {
+		if a.Pix[i] != b.Pix[i] {
+			differences++
+		}
+	}
+	return differences
+}
+
+func TestNormalizeStroke(t *testing.T) {
+	_, binaryStr := trainingLetter(t, "H")
+	letter := featureImage(binaryStr, CaptchaHeight)
+	if !assert.NotNil(t, letter) {
+		return
+	}
+
+	normalized := NormalizeStroke(letter)
+	assert.Equal(t, letter.Bounds(), normalized.Bounds())
+	assert.Greater(t, countInk(normalized), 0)
+
+	// A bolder letter is closer to the letter once both are normalized
+	bold := boldLetter(letter)
+	assert.Less(t, pixelDifferences(normalized, NormalizeStroke(bold)), pixelDifferences(letter, bold))
+
+	// Normalizing a blank letter keeps it blank
+	blank := image.NewGray(image.Rect(0, 0, 10, CaptchaHeight))
+	for i := range blank.Pix {
+		blank.Pix[i] = 255
+	}
+	assert.Equal(t, 0, countInk(NormalizeStroke(blank)))
+}
+
+func TestNewSolverWithStrokeNormalization(t *testing.T) {
+	solver, err := NewSolver(WithStrokeNormalization(), WithLetterAlignment())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	answer, err := solver.Solve(bytes.NewReader(syntheticCaptcha(t, "ABCEFG")))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", answer)
+}
@@ -0,0 +1,93 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Submit submits the answer to a prefetched captcha, and returns the response of the page behind the captcha.
+// Amazon serves its captcha page again when the form tokens of a captcha expired, e.g. because the captcha
+// waited too long in the pool or the session changed, and for wrong answers; Submit detects these responses and
+// transparently retries with fresh captchas, up to SubmitAttempts captchas in total and until ctx is done. Once
+// they are all used up, the returned error matches ErrChallengeExpired or ErrChallengeRejected, as for the last
+// captcha.
+//
+// As for Challenge.Submit, the first bytes of the body of the returned response have been read already; it must
+// still be closed.
+func (p *Prefetcher) Submit(ctx context.Context) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		challenge, err := p.Get(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get captcha: %w", err)
+		}
+		resp, err := challenge.Submit(ctx)
+		if !errors.Is(err, ErrChallengeExpired) && !errors.Is(err, ErrChallengeRejected) {
+			return resp, err
+		}
+		if attempt >= p.config.SubmitAttempts {
+			return nil, fmt.Errorf("failed to submit captcha after %d attempts: %w", attempt, err)
+		}
+	}
+}
+
+// Submit submits the answer to the captcha, and returns the response of the page behind the captcha. If Amazon
+// served its captcha page again, the error matches ErrChallengeRejected if the page shows an error alert, as for
+// a wrong answer, and ErrChallengeExpired otherwise, as for expired form tokens. The answer is submitted with
+// the captcha form under the session the captcha was fetched with, with its client, cookie jar and headers, and
+// only to the domain of the captcha page, see CaptchaForm.SubmitURL; challenges that were not fetched by a
+// Prefetcher cannot be submitted.
+//
+// Up to MaxPageSize bytes of the body of the returned response have been read already to look for a captcha page,
+// and are replayed before the rest of the body; it must still be closed.
+func (c *Challenge) Submit(ctx context.Context) (*http.Response, error) {
+	if c.client == nil || c.form == nil {
+		return nil, errors.New("captcha was not fetched by a prefetcher")
+	}
+	submitURL, err := c.SubmitURL()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, submitURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make HTTP request: %w", err)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxPageSize+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(body) > MaxPageSize {
+		resp.Body = replayedBody{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	// A captcha page in the response means the answer was not accepted
+	if doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body)); err == nil {
+		if err := challengeError(doc); err != nil {
+			return nil, err
+		}
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// replayedBody is the body of a response whose first bytes were read already, replaying them before the rest.
+type replayedBody struct {
+	io.Reader
+	io.Closer
+}
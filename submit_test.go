@@ -0,0 +1,144 @@
+package amazoncaptcha
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubmit(t *testing.T) {
+	// The first two tokens expired, the third one is accepted
+	server, fetches := captchaPage(t, syntheticCaptcha(t, "ABCEFG"), func(token string) bool { return token == "token-3" })
+	p, err := NewPrefetcher(PrefetchConfig{URL: server.URL + "/errors/validateCaptcha", Client: sessionClient(t, server), Size: 1})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go func() { _ = p.Run(ctx) }()
+
+	resp, err := p.Submit(ctx)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, resultsPage, string(body))
+	assert.Equal(t, "token-3", resp.Request.URL.Query().Get("amzn"))
+	assert.GreaterOrEqual(t, atomic.LoadInt32(fetches), int32(3))
+}
+
+func TestSubmitSession(t *testing.T) {
+	server, _ := captchaPage(t, syntheticCaptcha(t, "ABCEFG"), func(string) bool { return true })
+	p, err := NewPrefetcher(PrefetchConfig{URL: server.URL + "/errors/validateCaptcha", Client: sessionClient(t, server), Size: 1})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go func() { _ = p.Run(ctx) }()
+
+	challenge, err := p.Get(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", challenge.Domain())
+
+	// Copies of the challenge cannot be submitted under another session or to another origin
+	copied := &Challenge{Answer: challenge.Answer, ImageURL: challenge.ImageURL}
+	_, err = copied.Submit(ctx)
+	assert.ErrorContains(t, err, "not fetched by a prefetcher")
+	moved := *challenge
+	form := *challenge.form
+	form.Action = "https://www.amazon.com/errors/validateCaptcha"
+	moved.form = &form
+	_, err = moved.Submit(ctx)
+	assert.ErrorContains(t, err, "submits to another origin")
+
+	// The answer is only accepted along with the cookies of its session
+	other := *challenge
+	other.client = sessionClient(t, server)
+	_, err = other.Submit(ctx)
+	assert.ErrorIs(t, err, ErrChallengeExpired)
+	resp, err := challenge.Submit(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, resp.Body.Close())
+}
+
+func TestSubmitExpired(t *testing.T) {
+	server, _ := captchaPage(t, syntheticCaptcha(t, "ABCEFG"), nil)
+	p, err := NewPrefetcher(PrefetchConfig{URL: server.URL + "/errors/validateCaptcha", Client: sessionClient(t, server), Size: 1, SubmitAttempts: 2})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go func() { _ = p.Run(ctx) }()
+
+	_, err = p.Submit(ctx)
+	assert.ErrorIs(t, err, ErrChallengeExpired)
+	assert.NotErrorIs(t, err, ErrChallengeRejected)
+	assert.ErrorContains(t, err, "after 2 attempts")
+
+	_, err = NewPrefetcher(PrefetchConfig{Size: 1, SubmitAttempts: -1})
+	assert.Error(t, err)
+}
+
+func TestSubmitRejected(t *testing.T) {
+	// Every answer is wrong, and answered with a new captcha page showing an error alert
+	captcha := syntheticCaptcha(t, "ABCEFG")
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/errors/validateCaptcha" {
+			_, _ = w.Write(captcha)
+			return
+		}
+		alert := ""
+		if r.URL.Query().Get("field-keywords") != "" {
+			alert = `<div class="a-box a-alert a-alert-error"><div class="a-box-inner"><h4>Please try again.</h4></div></div>`
+		}
+		n := atomic.AddInt32(&fetches, 1)
+		fmt.Fprintf(w, `<html><body>%s<form method="get" action="/errors/validateCaptcha">
+<input type=hidden name="amzn" value="token-%d" />
+<div class="a-row a-text-center"><img src="/captcha/Captcha_%d.png"></div>
+<input id="captchacharacters" name="field-keywords" type="text">
+</form></body></html>`, alert, n, n)
+	}))
+	defer server.Close()
+	p, err := NewPrefetcher(PrefetchConfig{URL: server.URL + "/errors/validateCaptcha", Client: sessionClient(t, server), Size: 1, SubmitAttempts: 2})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go func() { _ = p.Run(ctx) }()
+
+	challenge, err := p.Get(ctx)
+	assert.NoError(t, err)
+	_, err = challenge.Submit(ctx)
+	assert.ErrorIs(t, err, ErrChallengeRejected)
+	assert.NotErrorIs(t, err, ErrChallengeExpired)
+
+	_, err = p.Submit(ctx)
+	assert.ErrorIs(t, err, ErrChallengeRejected)
+	assert.ErrorContains(t, err, "after 2 attempts")
+}
+
+func TestSubmitLargePage(t *testing.T) {
+	// Pages above MaxPageSize are no captcha pages, and are returned whole without being buffered
+	page := `<html><body><form action="/errors/validateCaptcha"><img src="/captcha.png"></form>` + strings.Repeat(" ", MaxPageSize)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(page))
+	}))
+	defer server.Close()
+	challenge := &Challenge{
+		Answer: "ABCEFG",
+		form:   &CaptchaForm{PageURL: server.URL, Action: server.URL + "/errors/validateCaptcha", AnswerField: AnswerField},
+		client: server.Client(),
+	}
+	resp, err := challenge.Submit(context.Background())
+	if !assert.NoError(t, err) {
+		return
+	}
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.NoError(t, resp.Body.Close())
+	assert.Equal(t, page, string(body))
+}
@@ -0,0 +1,154 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// AnswerCandidate is an alternative answer of a captcha, see SolveTopK.
+type AnswerCandidate struct {
+	// Text is the answer.
+	Text string
+	// Distance is the number of pixels in which the letters of the answer differ from their nearest training
+	// entries, summed over the letters. The letters the Solver recognized count as close as the nearest
+	// training letter at their position, so that its own answer ranks first.
+	Distance int
+}
+
+// SolveTopK solves a captcha and returns up to k alternative answers, closest first, combining the k nearest
+// training letters of every position, see WithCandidates. The first answer is the one Solve returns if every
+// letter was recognized, and the following ones change the letters that are the least certain. When a form
+// rejects an answer, scrapers can then submit the next one instead of fetching a new captcha. Ties are
+// broken in favor of the letters ranked higher at the earlier positions.
+//
+// Letters without any training entry to compare with hold the placeholder of the Solver. If the letters could
+// not be segmented, it returns a *SegmentationError matching ErrSegmentationFailed. An error is also returned
+// if k is not positive.
+func SolveTopK(r io.Reader, k int) ([]AnswerCandidate, error) {
+	return defaultSolver().SolveTopK(r, k)
+}
+
+// SolveTopK works like the package-level SolveTopK, using the configuration and training data of the Solver.
+func (s *Solver) SolveTopK(r io.Reader, k int) ([]AnswerCandidate, error) {
+	if k <= 0 {
+		return nil, errors.New("number of answers must be positive")
+	}
+
+	// Read the whole input so that it can be hashed for the journal and outcome reports
+	b, err := s.readImage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Solve the captcha listing the k nearest letters of every position
+	probe := s.detached()
+	probe.candidates = k
+	start := time.Now()
+	result, err := probe.solve(bytes.NewReader(b))
+	s.finishSolve(b, start, result, err)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rank the letters of every position, the recognized letter first
+	letters := []rune(result.Text)
+	positions := make([][]Candidate, len(result.Candidates))
+	for i, candidates := range result.Candidates {
+		recognized := string(letters[i])
+		if len(candidates) == 0 {
+			positions[i] = []Candidate{{Letter: recognized}}
+			continue
+		}
+		if recognized != string(s.placeholder) {
+			positions[i] = append(positions[i], Candidate{Letter: recognized, Distance: candidates[0].Distance})
+		}
+		for _, candidate := range candidates {
+			if candidate.Letter != recognized {
+				positions[i] = append(positions[i], candidate)
+			}
+		}
+	}
+
+	return topAnswers(positions, k), nil
+}
+
+// topAnswers returns the k combinations of the letters of every position with the smallest summed distances,
+// closest first. The letters of every position must be sorted by distance.
+func topAnswers(positions [][]Candidate, k int) []AnswerCandidate {
+
+	// Search the combinations best first, starting from the first letters of every position and
+	// moving one position at a time to its next letter
+	start := &answerState{ranks: make([]int, len(positions))}
+	for i := range positions {
+		start.distance += positions[i][0].Distance
+	}
+	queue := &answerQueue{start}
+	seen := map[string]bool{start.key(): true}
+	var answers []AnswerCandidate
+	for queue.Len() > 0 && len(answers) < k {
+		state := heap.Pop(queue).(*answerState)
+		var text strings.Builder
+		for i, rank := range state.ranks {
+			text.WriteString(positions[i][rank].Letter)
+		}
+		answers = append(answers, AnswerCandidate{Text: text.String(), Distance: state.distance})
+
+		for i, rank := range state.ranks {
+			if rank+1 >= len(positions[i]) {
+				continue
+			}
+			next := &answerState{ranks: append([]int(nil), state.ranks...), distance: state.distance}
+			next.ranks[i]++
+			next.distance += positions[i][rank+1].Distance - positions[i][rank].Distance
+			if key := next.key(); !seen[key] {
+				seen[key] = true
+				heap.Push(queue, next)
+			}
+		}
+	}
+	return answers
+}
+
+// answerState is a combination of the letters of every position, by their ranks, searched by topAnswers.
+type answerState struct {
+	ranks    []int
+	distance int
+}
+
+// key identifies the combination of the state.
+func (a *answerState) key() string {
+	return fmt.Sprint(a.ranks)
+}
+
+// answerQueue is a priority queue of combinations, closest first, then by their ranks from the first position.
+type answerQueue []*answerState
+
+func (q answerQueue) Len() int { return len(q) }
+
+func (q answerQueue) Less(i, j int) bool {
+	if q[i].distance != q[j].distance {
+		return q[i].distance < q[j].distance
+	}
+	for p := range q[i].ranks {
+		if q[i].ranks[p] != q[j].ranks[p] {
+			return q[i].ranks[p] < q[j].ranks[p]
+		}
+	}
+	return false
+}
+
+func (q answerQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *answerQueue) Push(x interface{}) { *q = append(*q, x.(*answerState)) }
+
+func (q *answerQueue) Pop() interface{} {
+	old := *q
+	state := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return state
+}
@@ -0,0 +1,48 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSolveTopK(t *testing.T) {
+	// The recognized answer ranks first, followed by the answers changing its least certain letter
+	captcha := flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)
+	answers, err := SolveTopK(bytes.NewReader(captcha), 3)
+	assert.NoError(t, err)
+	if assert.Len(t, answers, 3) {
+		assert.Equal(t, AnswerCandidate{Text: "ABCEFG", Distance: 1}, answers[0])
+		for _, answer := range answers[1:] {
+			assert.NotEqual(t, "ABCEFG", answer.Text)
+			assert.GreaterOrEqual(t, answer.Distance, answers[0].Distance)
+		}
+		assert.LessOrEqual(t, answers[1].Distance, answers[2].Distance)
+	}
+
+	answers, err = SolveTopK(bytes.NewReader(syntheticCaptcha(t, "ABCEFG")), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []AnswerCandidate{{Text: "ABCEFG"}}, answers)
+
+	_, err = SolveTopK(bytes.NewReader(captcha), 0)
+	assert.Error(t, err)
+	_, err = SolveTopK(bytes.NewReader([]byte("not an image")), 3)
+	assert.Error(t, err)
+}
+
+func TestTopAnswers(t *testing.T) {
+	positions := [][]Candidate{
+		{{Letter: "A", Distance: 0}, {Letter: "R", Distance: 5}},
+		{{Letter: "B", Distance: 1}, {Letter: "E", Distance: 3}, {Letter: "P", Distance: 9}},
+	}
+	assert.Equal(t, []AnswerCandidate{
+		{Text: "AB", Distance: 1},
+		{Text: "AE", Distance: 3},
+		{Text: "RB", Distance: 6},
+		{Text: "RE", Distance: 8},
+		{Text: "AP", Distance: 9},
+		{Text: "RP", Distance: 14},
+	}, topAnswers(positions, 10))
+	assert.Len(t, topAnswers(positions, 2), 2)
+}
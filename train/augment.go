@@ -0,0 +1,301 @@
+package train
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math"
+	"math/rand"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// Default augmentation of Augment.
+const (
+	// DefaultShift is the number of pixels letters are shifted by in every direction.
+	DefaultShift = 1
+	// DefaultNoiseVariants is the number of noise-perturbed variants of a letter.
+	DefaultNoiseVariants = 4
+	// DefaultNoisePixels is the number of pixels flipped in every noise-perturbed variant.
+	DefaultNoisePixels = 2
+)
+
+// DefaultScales are the factors letters are scaled by.
+var DefaultScales = []float64{0.95, 1.05}
+
+// AugmentOption configures the variants produced by Augment.
+type AugmentOption func(*augmentation)
+
+// augmentation holds the configuration of Augment.
+type augmentation struct {
+	shift         int
+	scales        []float64
+	noiseVariants int
+	noisePixels   int
+	seed          int64
+}
+
+// WithShift sets the number of pixels letters are shifted by, DefaultShift by default. 0 disables shifting.
+func WithShift(pixels int) AugmentOption {
+	return func(a *augmentation) {
+		a.shift = pixels
+	}
+}
+
+// WithScales sets the factors letters are scaled by, DefaultScales by default. No factors disable scaling,
+// factors of 1 or below 0.5 are ignored.
+func WithScales(factors ...float64) AugmentOption {
+	return func(a *augmentation) {
+		a.scales = append([]float64(nil), factors...)
+	}
+}
+
+// WithNoise sets the number of noise-perturbed variants of a letter and the number of pixels flipped in each
+// of them, DefaultNoiseVariants and DefaultNoisePixels by default. 0 variants disable noise.
+func WithNoise(variants, pixels int) AugmentOption {
+	return func(a *augmentation) {
+		a.noiseVariants = variants
+		a.noisePixels = pixels
+	}
+}
+
+// WithSeed seeds the choice of the pixels flipped by noise, 1 by default, so that augmenting the same letter
+// twice yields the same variants.
+func WithSeed(seed int64) AugmentOption {
+	return func(a *augmentation) {
+		a.seed = seed
+	}
+}
+
+// Augment returns variants of a monochrome letter, as cropped by amazoncaptcha.FindLetters, reproducing the
+// pixel-level variance of captchas that makes exact-match recognition miss letters: the letter shifted up and
+// down and padded by blank columns on either side, as a stray pixel widens its box, slightly scaled around its
+// center, and with a few pixels on the edges of its strokes flipped, as JPEG artifacts do. Every variant keeps
+// the height of the letter, which the features of the training data rely on. The letter itself is not among
+// the variants, and neither are variants that would push ink out of the image.
+func Augment(letter *image.Gray, opts ...AugmentOption) []*image.Gray {
+	a := &augmentation{
+		shift:         DefaultShift,
+		scales:        DefaultScales,
+		noiseVariants: DefaultNoiseVariants,
+		noisePixels:   DefaultNoisePixels,
+		seed:          1,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	letter = normalizeLetter(letter)
+	var variants []*image.Gray
+
+	// Shift the letter vertically within its box, and horizontally by padding it with blank columns
+	for d := 1; d <= a.shift; d++ {
+		for _, dy := range []int{-d, d} {
+			if shifted := shiftLetter(letter, dy); shifted != nil {
+				variants = append(variants, shifted)
+			}
+		}
+		variants = append(variants, padLetter(letter, d, 0), padLetter(letter, 0, d))
+	}
+
+	// Scale the letter around its center
+	for _, factor := range a.scales {
+		if factor == 1 || factor < 0.5 {
+			continue
+		}
+		variants = append(variants, scaleLetter(letter, factor))
+	}
+
+	// Flip a few pixels on the edges of the strokes
+	if a.noisePixels > 0 {
+		rnd := rand.New(rand.NewSource(a.seed))
+		edges := edgePixels(letter)
+		for i := 0; i < a.noiseVariants && len(edges) > 0; i++ {
+			variants = append(variants, perturbLetter(letter, edges, a.noisePixels, rnd))
+		}
+	}
+
+	return variants
+}
+
+// AddAugmented adds the feature of a letter image labeled with letter, together with the features of its
+// variants produced by Augment with opts. Like every feature added, variants already labeled with another
+// letter keep their letter and are recorded as conflicts, so the real letters of a dataset should be added
+// before augmenting any.
+func (d *Dataset) AddAugmented(img *image.Gray, letter string, opts ...AugmentOption) error {
+	features, err := augmentedFeatures(img, opts)
+	if err != nil {
+		return err
+	}
+	for _, feature := range features {
+		d.add(feature, letter)
+	}
+	return nil
+}
+
+// AddAugmentedCaptcha works like AddCaptcha, but also adds the features of the variants of every letter
+// produced by Augment with opts. See AddAugmented.
+func (d *Dataset) AddAugmentedCaptcha(answer string, image []byte, opts ...AugmentOption) error {
+	letters, err := splitCaptcha(answer, image)
+	if err != nil {
+		return err
+	}
+
+	// Extract all features before adding any, so that a failing letter leaves the dataset unchanged
+	features := make([][]string, len(letters))
+	for i, letter := range letters {
+		if features[i], err = augmentedFeatures(letter, opts); err != nil {
+			return fmt.Errorf("failed to extract features of letter %d: %w", i, err)
+		}
+	}
+
+	// Add the letters before their variants, so that a variant never takes the place of a real letter
+	for i, letterFeatures := range features {
+		d.add(letterFeatures[0], answer[i:i+1])
+	}
+	for i, letterFeatures := range features {
+		for _, feature := range letterFeatures[1:] {
+			d.add(feature, answer[i:i+1])
+		}
+	}
+	d.Captchas++
+	return nil
+}
+
+// augmentedFeatures returns the feature of a letter image followed by the features of its variants.
+func augmentedFeatures(img *image.Gray, opts []AugmentOption) ([]string, error) {
+	variants := Augment(img, opts...)
+	features := make([]string, 0, len(variants)+1)
+	for _, variant := range append([]*image.Gray{img}, variants...) {
+		feature, err := amazoncaptcha.ExtractFeatures(variant)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract features: %w", err)
+		}
+		features = append(features, feature)
+	}
+	return features, nil
+}
+
+// normalizeLetter returns a copy of a letter with its bounds at the origin and only black and white pixels,
+// the pixels darker than mid-gray turning black.
+func normalizeLetter(letter *image.Gray) *image.Gray {
+	bounds := letter.Bounds()
+	normalized := image.NewGray(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			if letter.GrayAt(bounds.Min.X+x, bounds.Min.Y+y).Y >= 128 {
+				normalized.Pix[y*normalized.Stride+x] = 255
+			}
+		}
+	}
+	return normalized
+}
+
+// blankLetter returns a white image of the given size.
+func blankLetter(width, height int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	return img
+}
+
+// shiftLetter returns the letter moved down by dy rows, or up for a negative dy, within its bounds,
+// or nil if ink would be pushed out.
+func shiftLetter(letter *image.Gray, dy int) *image.Gray {
+	width, height := letter.Bounds().Dx(), letter.Bounds().Dy()
+	shifted := blankLetter(width, height)
+	for y := 0; y < height; y++ {
+		row := letter.Pix[y*letter.Stride : y*letter.Stride+width]
+		if y+dy < 0 || y+dy >= height {
+			if bytes.IndexByte(row, 0) >= 0 {
+				return nil
+			}
+			continue
+		}
+		copy(shifted.Pix[(y+dy)*shifted.Stride:], row)
+	}
+	return shifted
+}
+
+// padLetter returns the letter with left and right blank columns added on either side.
+func padLetter(letter *image.Gray, left, right int) *image.Gray {
+	width, height := letter.Bounds().Dx(), letter.Bounds().Dy()
+	padded := blankLetter(width+left+right, height)
+	for y := 0; y < height; y++ {
+		copy(padded.Pix[y*padded.Stride+left:], letter.Pix[y*letter.Stride:y*letter.Stride+width])
+	}
+	return padded
+}
+
+// scaleLetter returns the letter scaled by factor around the vertical center of its ink, sampling the nearest
+// pixels. The width scales with the letter, the height is kept.
+func scaleLetter(letter *image.Gray, factor float64) *image.Gray {
+	width, height := letter.Bounds().Dx(), letter.Bounds().Dy()
+
+	// Find the vertical center of the ink, the letter being cropped tightly horizontally only
+	top, bottom := height, 0
+	for y := 0; y < height; y++ {
+		if bytes.IndexByte(letter.Pix[y*letter.Stride:y*letter.Stride+width], 0) >= 0 {
+			if y < top {
+				top = y
+			}
+			bottom = y + 1
+		}
+	}
+	center := float64(height) / 2
+	if top < bottom {
+		center = float64(top+bottom) / 2
+	}
+
+	scaledWidth := int(math.Round(float64(width) * factor))
+	if scaledWidth < 1 {
+		scaledWidth = 1
+	}
+	scaled := blankLetter(scaledWidth, height)
+	for y := 0; y < height; y++ {
+		sy := int(math.Floor(center + (float64(y)+0.5-center)/factor))
+		if sy < 0 || sy >= height {
+			continue
+		}
+		for x := 0; x < scaledWidth; x++ {
+			sx := int((float64(x) + 0.5) / factor)
+			if sx >= width {
+				sx = width - 1
+			}
+			scaled.Pix[y*scaled.Stride+x] = letter.Pix[sy*letter.Stride+sx]
+		}
+	}
+	return scaled
+}
+
+// edgePixels returns the offsets of the pixels of a letter that differ from one of their four neighbors,
+// the pixels on the edges of its strokes.
+func edgePixels(letter *image.Gray) []int {
+	width, height := letter.Bounds().Dx(), letter.Bounds().Dy()
+	var edges []int
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*letter.Stride + x
+			pixel := letter.Pix[i]
+			if (x > 0 && letter.Pix[i-1] != pixel) || (x < width-1 && letter.Pix[i+1] != pixel) ||
+				(y > 0 && letter.Pix[i-letter.Stride] != pixel) || (y < height-1 && letter.Pix[i+letter.Stride] != pixel) {
+				edges = append(edges, i)
+			}
+		}
+	}
+	return edges
+}
+
+// perturbLetter returns a copy of the letter with up to pixels of its edge pixels, chosen by rnd, flipped.
+func perturbLetter(letter *image.Gray, edges []int, pixels int, rnd *rand.Rand) *image.Gray {
+	perturbed := image.NewGray(letter.Bounds())
+	copy(perturbed.Pix, letter.Pix)
+	if pixels > len(edges) {
+		pixels = len(edges)
+	}
+	for _, i := range rnd.Perm(len(edges))[:pixels] {
+		perturbed.Pix[edges[i]] = 255 - perturbed.Pix[edges[i]]
+	}
+	return perturbed
+}
@@ -0,0 +1,112 @@
+package train
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAugment(t *testing.T) {
+	captchas, err := amazoncaptcha.SelfTestCaptchas()
+	if !assert.NoError(t, err) || !assert.NotEmpty(t, captchas) {
+		return
+	}
+	letters, err := amazoncaptcha.FindLetters(bytes.NewReader(captchas[0].Image))
+	if !assert.NoError(t, err) {
+		return
+	}
+	letter := letters[0]
+
+	// By default, every letter has shifted, padded, scaled and noisy variants of its height
+	variants := Augment(letter)
+	assert.Len(t, variants, 2+2+len(DefaultScales)+DefaultNoiseVariants)
+	feature, err := amazoncaptcha.ExtractFeatures(letter)
+	assert.NoError(t, err)
+	for _, variant := range variants {
+		assert.Equal(t, letter.Bounds().Dy(), variant.Bounds().Dy())
+		variantFeature, err := amazoncaptcha.ExtractFeatures(variant)
+		assert.NoError(t, err)
+		assert.NotEqual(t, feature, variantFeature)
+	}
+	assert.Equal(t, letter.Bounds().Dx()+1, variants[2].Bounds().Dx())
+
+	// Noise is reproducible with the same seed
+	noisy := Augment(letter, WithShift(0), WithScales(), WithSeed(7))
+	assert.Len(t, noisy, DefaultNoiseVariants)
+	assert.Equal(t, noisy, Augment(letter, WithShift(0), WithScales(), WithSeed(7)))
+
+	assert.Empty(t, Augment(letter, WithShift(0), WithScales(), WithNoise(0, 0)))
+	assert.Len(t, Augment(letter, WithShift(2), WithScales(1, 0.1), WithNoise(0, 0)), 8)
+}
+
+// shiftCaptchaLetter returns the captcha with the ink of its i-th letter moved up by one row.
+func shiftCaptchaLetter(t *testing.T, captcha []byte, i int) []byte {
+	img, err := png.Decode(bytes.NewReader(captcha))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	gray := amazoncaptcha.Grayscale(img)
+	box := amazoncaptcha.FindLetterBoxes(gray, amazoncaptcha.MaximumLetterLength)[i]
+	for y := 0; y < gray.Bounds().Dy()-1; y++ {
+		for x := box.Min.X; x < box.Max.X; x++ {
+			gray.SetGray(x, y, gray.GrayAt(x, y+1))
+		}
+	}
+	for x := box.Min.X; x < box.Max.X; x++ {
+		gray.Pix[(gray.Bounds().Dy()-1)*gray.Stride+x] = 255
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, gray))
+	return buf.Bytes()
+}
+
+func TestAddAugmentedCaptcha(t *testing.T) {
+	captchas, err := amazoncaptcha.SelfTestCaptchas()
+	if !assert.NoError(t, err) || !assert.NotEmpty(t, captchas) {
+		return
+	}
+	captcha := captchas[0]
+	shifted := shiftCaptchaLetter(t, captcha.Image, 2)
+
+	solve := func(d *Dataset) string {
+		var buf bytes.Buffer
+		assert.NoError(t, d.WriteJSON(&buf))
+		solver, err := amazoncaptcha.NewSolver(amazoncaptcha.WithTrainingData(&buf))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		result, err := solver.SolveDetailed(bytes.NewReader(shifted))
+		assert.NoError(t, err)
+		return result.Text
+	}
+
+	// Exact matches miss the shifted letter, unless the dataset holds its shifted variants
+	plain := NewDataset()
+	assert.NoError(t, plain.AddCaptcha(captcha.Answer, captcha.Image))
+	assert.Equal(t, captcha.Answer[:2]+"-"+captcha.Answer[3:], solve(plain))
+
+	augmented := NewDataset()
+	assert.NoError(t, augmented.AddAugmentedCaptcha(captcha.Answer, captcha.Image))
+	assert.Equal(t, captcha.Answer, solve(augmented))
+	assert.Greater(t, len(augmented.Features), len(plain.Features))
+	assert.Equal(t, 1, augmented.Captchas)
+	for feature, letter := range plain.Features {
+		assert.Equal(t, letter, augmented.Features[feature])
+	}
+
+	// Answers of the wrong length add nothing
+	assert.Error(t, augmented.AddAugmentedCaptcha("ABC", captcha.Image))
+	assert.Equal(t, 1, augmented.Captchas)
+
+	// Single letters are augmented alike
+	letters, err := amazoncaptcha.FindLetters(bytes.NewReader(captcha.Image))
+	if !assert.NoError(t, err) {
+		return
+	}
+	d := NewDataset()
+	assert.NoError(t, d.AddAugmented(letters[0], captcha.Answer[:1]))
+	assert.Len(t, d.Features, 1+len(Augment(letters[0])))
+}
@@ -0,0 +1,233 @@
+// Package train builds training data for amazoncaptcha from captchas labeled with their answers, so that the
+// solver can be retrained on new captcha styles without the manual split and feature extraction steps:
+//
+//	dataset, err := train.BuildDataset("captchas")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	file, err := os.Create("training_data.json")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer file.Close()
+//	if err := dataset.WriteJSON(file); err != nil {
+//		log.Fatal(err)
+//	}
+//
+// The written file is loaded with amazoncaptcha.WithTrainingData or amazoncaptcha.LoadTrainingData.
+package train
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// Dataset maps the features of letters to the letters they show, in the form of the training data of the
+// solver.
+type Dataset struct {
+	// Features maps the feature of every letter, as extracted by amazoncaptcha.ExtractFeatures, to the letter.
+	Features map[string]string
+	// Captchas is the number of captchas the letters were taken from.
+	Captchas int
+	// Skipped holds the captchas that could not be split into the letters of their answers.
+	Skipped []SkippedCaptcha
+	// Conflicts holds the features found labeled with different letters. The letter found first is kept.
+	Conflicts []Conflict
+	// Duplicates is the number of features found again with the letter already in the dataset.
+	Duplicates int
+
+	// conflicts indexes Conflicts by feature.
+	conflicts map[string]int
+}
+
+// Conflict describes a feature labeled with different letters.
+type Conflict struct {
+	// Feature is the conflicting feature.
+	Feature string
+	// Letters holds the letters the feature was labeled with, in the order they were found. The first one is
+	// the letter kept in the dataset.
+	Letters []string
+}
+
+// SkippedCaptcha describes a captcha that was left out of a Dataset.
+type SkippedCaptcha struct {
+	// Name is the file name of the captcha.
+	Name string
+	// Err is the reason it was skipped.
+	Err error
+}
+
+// NewDataset creates an empty Dataset.
+func NewDataset() *Dataset {
+	return &Dataset{Features: make(map[string]string)}
+}
+
+// ReadJSON reads a Dataset from training data in its JSON form, e.g. to merge new letters into the training
+// data shipped with the package.
+func ReadJSON(r io.Reader) (*Dataset, error) {
+	d := NewDataset()
+	if err := json.NewDecoder(r).Decode(&d.Features); err != nil {
+		return nil, fmt.Errorf("failed to decode training data: %w", err)
+	}
+	return d, nil
+}
+
+// BuildDataset splits the captchas in labeledDir, named after their answers like ABCDEF.jpg, into their letters
+// and returns the features of the letters. Captchas that cannot be decoded or are not split into as many letters
+// as their answers have are skipped and listed in Dataset.Skipped. An error is returned if the directory cannot
+// be read.
+func BuildDataset(labeledDir string) (*Dataset, error) {
+	captchas, err := amazoncaptcha.ReadLabeledCaptchas(labeledDir)
+	if err != nil {
+		return nil, err
+	}
+	d := NewDataset()
+	for _, captcha := range captchas {
+		if err := d.AddCaptcha(captcha.Answer, captcha.Image); err != nil {
+			d.Skipped = append(d.Skipped, SkippedCaptcha{Name: captcha.Name, Err: err})
+		}
+	}
+	return d, nil
+}
+
+// AddCaptcha splits the captcha image into its letters and adds their features, labeled with the letters of
+// answer. Nothing is added and an error is returned if the image cannot be split into len(answer) letters.
+func (d *Dataset) AddCaptcha(answer string, image []byte) error {
+	letters, err := splitCaptcha(answer, image)
+	if err != nil {
+		return err
+	}
+
+	// Extract all features before adding any, so that a failing letter leaves the dataset unchanged
+	features := make([]string, len(letters))
+	for i, letter := range letters {
+		if features[i], err = amazoncaptcha.ExtractFeatures(letter); err != nil {
+			return fmt.Errorf("failed to extract features of letter %d: %w", i, err)
+		}
+	}
+	for i, feature := range features {
+		d.add(feature, answer[i:i+1])
+	}
+	d.Captchas++
+	return nil
+}
+
+// splitCaptcha splits the captcha image into its letters, failing unless there are as many as answer has.
+func splitCaptcha(answer string, image []byte) ([]*image.Gray, error) {
+	letters, err := amazoncaptcha.FindLetters(bytes.NewReader(image))
+	if err != nil {
+		return nil, err
+	}
+	if len(letters) != len(answer) {
+		return nil, fmt.Errorf("found %d letters for a %d-letter answer", len(letters), len(answer))
+	}
+	return letters, nil
+}
+
+// Merge adds the features of other that are not in the dataset yet, and its captchas, skipped captchas,
+// conflicts and duplicates. Features other labels with another letter become conflicts and keep their letter,
+// features it labels with the same letter count as duplicates.
+func (d *Dataset) Merge(other *Dataset) {
+	// Add the features in a fixed order, so that conflicts are reported deterministically
+	features := make([]string, 0, len(other.Features))
+	for feature := range other.Features {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+	for _, feature := range features {
+		d.add(feature, other.Features[feature])
+	}
+	for _, conflict := range other.Conflicts {
+		for _, letter := range conflict.Letters {
+			if letter != d.Features[conflict.Feature] {
+				d.add(conflict.Feature, letter)
+			}
+		}
+	}
+	d.Captchas += other.Captchas
+	d.Skipped = append(d.Skipped, other.Skipped...)
+	d.Duplicates += other.Duplicates
+}
+
+// add labels feature with letter. If it is already labeled, the letter is kept, and the new one is recorded as
+// a conflict or counted as a duplicate.
+func (d *Dataset) add(feature, letter string) {
+	if d.Features == nil {
+		d.Features = make(map[string]string)
+	}
+	known, ok := d.Features[feature]
+	if !ok {
+		d.Features[feature] = letter
+		return
+	}
+	if known == letter {
+		d.Duplicates++
+		return
+	}
+
+	// Record the letter with the other letters of the feature
+	if d.conflicts == nil {
+		d.conflicts = make(map[string]int)
+	}
+	i, ok := d.conflicts[feature]
+	if !ok {
+		i = len(d.Conflicts)
+		d.conflicts[feature] = i
+		d.Conflicts = append(d.Conflicts, Conflict{Feature: feature, Letters: []string{known}})
+	}
+	for _, l := range d.Conflicts[i].Letters {
+		if l == letter {
+			return
+		}
+	}
+	d.Conflicts[i].Letters = append(d.Conflicts[i].Letters, letter)
+}
+
+// MergeDatasets reads the training data files at paths, in their JSON form like training_data.json, and
+// unions them into a single Dataset. Files earlier in paths take precedence: features labeled with different
+// letters keep the letter of the first file and are listed in Dataset.Conflicts, and features found in several
+// files with the same letter are counted in Dataset.Duplicates. An error is returned if a file cannot be read.
+func MergeDatasets(paths ...string) (*Dataset, error) {
+	d := NewDataset()
+	for _, path := range paths {
+		other, err := readJSONFile(path)
+		if err != nil {
+			return nil, err
+		}
+		d.Merge(other)
+	}
+	return d, nil
+}
+
+// readJSONFile reads a Dataset from the training data file at path, see ReadJSON.
+func readJSONFile(path string) (*Dataset, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open training data: %w", err)
+	}
+	defer file.Close()
+	d, err := ReadJSON(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return d, nil
+}
+
+// WriteJSON writes the features in the JSON form of the training data, like amazoncaptcha.SaveTrainingData.
+func (d *Dataset) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(d.Features, "", "	")
+	if err != nil {
+		return fmt.Errorf("failed to marshal training data: %w", err)
+	}
+	if _, err := w.Write(b); err != nil {
+		return fmt.Errorf("failed to write training data: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package train
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeSelfTestCaptchas writes the self-test corpus into a directory, named after the answers.
+func writeSelfTestCaptchas(t *testing.T) (string, []amazoncaptcha.LabeledCaptcha) {
+	captchas, err := amazoncaptcha.SelfTestCaptchas()
+	if !assert.NoError(t, err) || !assert.NotEmpty(t, captchas) {
+		t.FailNow()
+	}
+	dir := t.TempDir()
+	for _, captcha := range captchas {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, captcha.Name), captcha.Image, 0644))
+	}
+	return dir, captchas
+}
+
+func TestBuildDataset(t *testing.T) {
+	dir, captchas := writeSelfTestCaptchas(t)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "ZZZZZZ.png"), []byte("not an image"), 0644))
+
+	d, err := BuildDataset(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, len(captchas), d.Captchas)
+	assert.Empty(t, d.Conflicts)
+	if assert.Len(t, d.Skipped, 1) {
+		assert.Equal(t, "ZZZZZZ.png", d.Skipped[0].Name)
+		assert.Error(t, d.Skipped[0].Err)
+	}
+
+	// A solver trained on the dataset alone solves the captchas it was built from
+	var buf bytes.Buffer
+	assert.NoError(t, d.WriteJSON(&buf))
+	solver, err := amazoncaptcha.NewSolver(amazoncaptcha.WithTrainingData(&buf))
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, captcha := range captchas {
+		answer, err := solver.Solve(bytes.NewReader(captcha.Image))
+		assert.NoError(t, err)
+		assert.Equal(t, captcha.Answer, answer)
+	}
+
+	// Missing directories fail
+	_, err = BuildDataset(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestDatasetMerge(t *testing.T) {
+	d := &Dataset{Features: map[string]string{"a": "A", "b": "B"}, Captchas: 1}
+	other := &Dataset{
+		Features:   map[string]string{"a": "A", "b": "C", "c": "C"},
+		Captchas:   2,
+		Conflicts:  []Conflict{{Feature: "c", Letters: []string{"C", "D"}}},
+		Duplicates: 1,
+	}
+	d.Merge(other)
+	assert.Equal(t, map[string]string{"a": "A", "b": "B", "c": "C"}, d.Features)
+	assert.Equal(t, 3, d.Captchas)
+	assert.Equal(t, []Conflict{{Feature: "b", Letters: []string{"B", "C"}}, {Feature: "c", Letters: []string{"C", "D"}}}, d.Conflicts)
+	assert.Equal(t, 2, d.Duplicates)
+}
+
+func TestMergeDatasets(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+	first := write("first.json", `{"a": "A", "b": "B"}`)
+	second := write("second.json", `{"a": "A", "b": "D", "c": "C"}`)
+	third := write("third.json", `{"b": "E", "c": "C"}`)
+
+	d, err := MergeDatasets(first, second, third)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]string{"a": "A", "b": "B", "c": "C"}, d.Features)
+	assert.Equal(t, []Conflict{{Feature: "b", Letters: []string{"B", "D", "E"}}}, d.Conflicts)
+	assert.Equal(t, 2, d.Duplicates)
+
+	// Missing and malformed files fail
+	_, err = MergeDatasets(first, filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+	_, err = MergeDatasets(write("malformed.json", "not json"))
+	assert.Error(t, err)
+}
+
+func TestReadJSON(t *testing.T) {
+	d, err := ReadJSON(strings.NewReader(`{"a": "A"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "A"}, d.Features)
+
+	_, err = ReadJSON(strings.NewReader("not json"))
+	assert.Error(t, err)
+}
+
+func TestAddCaptcha(t *testing.T) {
+	captchas, err := amazoncaptcha.SelfTestCaptchas()
+	if !assert.NoError(t, err) || !assert.NotEmpty(t, captchas) {
+		return
+	}
+
+	// Answers of the wrong length add nothing
+	d := NewDataset()
+	assert.Error(t, d.AddCaptcha("ABC", captchas[0].Image))
+	assert.Empty(t, d.Features)
+	assert.Zero(t, d.Captchas)
+
+	assert.NoError(t, d.AddCaptcha(captchas[0].Answer, captchas[0].Image))
+	assert.NotEmpty(t, d.Features)
+	assert.Equal(t, 1, d.Captchas)
+}
@@ -1,22 +1,329 @@
 package amazoncaptcha
 
 import (
-	_ "embed"
+	"crypto/sha256"
 	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
 )
 
-// Embed the training data file as a byte slice using the embed package
+// training_data.bin is generated from training_data.json, the form edited by the training tools,
+// in the binary format written by EncodeTrainingData. It is embedded as data unless built with the noembed tag.
 
-//go:embed training_data.json
-var data []byte
+//go:generate go run ./cmd/amazoncaptcha convert --lossy training_data.json training_data.bin
 
-// featureMap is a map that stores training data with string keys and values.
-// WARNING: featureMap is not safe for concurrent modification.
-// It should only be accessed for reading in a concurrent setting.
-var featureMap map[string]string
+// model is an immutable snapshot of training data: a map from features to the letters they represent.
+// Replacing the training data swaps the whole snapshot, so a solve always sees a consistent model.
+type model struct {
+	// features stores the training data with feature keys and letter values.
+	features map[string]string
 
-// Define an init function to run at module initialization time
-func init() {
-	// Unmarshal the training data from the embedded byte slice into the map
-	_ = json.Unmarshal(data, &featureMap)
+	// The decoded index is built on first use: it is only needed for lookups that miss
+	// and for guessing unknown letters.
+	indexOnce sync.Once
+	bitmaps   []decodedFeature
+	canonical map[[sha256.Size]byte]string
+
+	// The BK-tree over the decoded entries is built on first use by nearest neighbor searches.
+	treeOnce sync.Once
+	tree     *bkTree
+
+	// The normalized copies of the training data are built on first use by solvers normalizing letters.
+	normalizedMu     sync.Mutex
+	normalizedModels map[normalization]*model
+
+	// The statistics of the letters are computed on first use by drift reports.
+	statsOnce sync.Once
+	stats     CorpusStats
+}
+
+// decodedFeature is a training entry with its feature already decoded into a bitmap.
+type decodedFeature struct {
+	feature string
+	bitmap  *bitmap
+	letter  string
+}
+
+// The embedded training data is decoded on first use, shared by every Solver that does not load its own,
+// so that programs importing the package without solving captchas do not pay for it.
+var (
+	embeddedOnce  sync.Once
+	embeddedModel *model
+	embeddedErr   error
+)
+
+// loadEmbeddedModel decodes the embedded training data on first use. If it is corrupt, an error
+// is returned together with an empty model.
+func loadEmbeddedModel() (*model, error) {
+	embeddedOnce.Do(func() {
+		embeddedModel, embeddedErr = decodeEmbeddedModel(data)
+	})
+	return embeddedModel, embeddedErr
+}
+
+// decodeEmbeddedModel unmarshals the embedded training data into a model, which is empty on error.
+// Without embedded training data, the error is ErrNoTrainingData.
+func decodeEmbeddedModel(b []byte) (*model, error) {
+	if len(b) == 0 {
+		return &model{features: map[string]string{}}, fmt.Errorf("failed to load embedded training data: %w", ErrNoTrainingData)
+	}
+	features, err := parseTrainingData(b)
+	if err != nil {
+		return &model{features: map[string]string{}}, fmt.Errorf("failed to load embedded training data: %w", err)
+	}
+	return &model{features: features}, nil
+}
+
+// Init loads the embedded training data and returns an error if it is corrupt, or ErrNoTrainingData if it is
+// missing from a build with the noembed tag. Calling Init is optional: the training data is otherwise loaded by
+// the first solve, which would then fail with ErrNoTrainingData. Services should call Init, or MustInit, at
+// startup to detect a broken build early.
+func Init() error {
+	_, err := loadEmbeddedModel()
+	return err
+}
+
+// MustInit is like Init but panics if the embedded training data is corrupt or missing.
+func MustInit() {
+	if err := Init(); err != nil {
+		panic(err)
+	}
+}
+
+// parseTrainingData unmarshals training data in its binary or JSON form.
+func parseTrainingData(b []byte) (map[string]string, error) {
+	if isBinaryTrainingData(b) {
+		return decodeBinaryTrainingData(b)
+	}
+	var features map[string]string
+	if err := json.Unmarshal(b, &features); err != nil {
+		return nil, fmt.Errorf("invalid training data: %w", err)
+	}
+	return features, nil
+}
+
+// readTrainingData reads and unmarshals training data in its binary or JSON form.
+func readTrainingData(r io.Reader) (map[string]string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read training data: %w", err)
+	}
+	return parseTrainingData(b)
+}
+
+// LoadTrainingData extends the training data with the entries read from r, either in the binary form
+// written by EncodeTrainingData or in the JSON form of training_data.json: an object mapping features
+// to letters. Entries whose feature is already known override its letter, so newly observed letter
+// shapes can be added and mislabeled entries corrected at runtime without rebuilding the package.
+func LoadTrainingData(r io.Reader) error {
+	return defaultSolver().LoadTrainingData(r)
+}
+
+// LoadTrainingData works like the package-level LoadTrainingData, extending the training data of the Solver.
+func (s *Solver) LoadTrainingData(r io.Reader) error {
+	features, err := readTrainingData(r)
+	if err != nil {
+		return err
+	}
+	s.addTrainingData(features)
+	return nil
+}
+
+// LoadTrainingDataFromFile extends the training data with the entries of the file at path, see LoadTrainingData.
+func LoadTrainingDataFromFile(path string) error {
+	return defaultSolver().LoadTrainingDataFromFile(path)
+}
+
+// LoadTrainingDataFromFile works like the package-level LoadTrainingDataFromFile, extending the training data of the Solver.
+func (s *Solver) LoadTrainingDataFromFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open training data: %w", err)
+	}
+	defer file.Close()
+
+	return s.LoadTrainingData(file)
+}
+
+// TrainingData returns a copy of the current training data, mapping features to letters.
+func TrainingData() map[string]string {
+	return defaultSolver().TrainingData()
+}
+
+// TrainingData works like the package-level TrainingData, returning the training data of the Solver.
+func (s *Solver) TrainingData() map[string]string {
+	current := s.trainingData().features
+	features := make(map[string]string, len(current))
+	for k, v := range current {
+		features[k] = v
+	}
+	return features
+}
+
+// SetTrainingData replaces the whole training data with a copy of features, e.g. to roll back to training
+// data returned by TrainingData. Unlike LoadTrainingData, the entries missing from features are dropped.
+// Solves running concurrently finish with the previous training data.
+func SetTrainingData(features map[string]string) {
+	defaultSolver().SetTrainingData(features)
+}
+
+// SetTrainingData works like the package-level SetTrainingData, replacing the training data of the Solver.
+func (s *Solver) SetTrainingData(features map[string]string) {
+	copied := make(map[string]string, len(features))
+	for k, v := range features {
+		copied[k] = v
+	}
+	s.setTrainingData(copied)
+}
+
+// AddFeature adds a training entry mapping feature, as returned by ExtractFeatures, to letter.
+// An entry with the same pixels is replaced, so AddFeature can also correct a mislabeled entry.
+// It is safe to call while captchas are being solved concurrently: solves that are already running
+// finish with the previous training data.
+func AddFeature(feature, letter string) error {
+	return defaultSolver().AddFeature(feature, letter)
+}
+
+// AddFeature works like the package-level AddFeature, for the training data of the Solver.
+func (s *Solver) AddFeature(feature, letter string) error {
+	if _, err := decodeFeature(feature); err != nil {
+		return fmt.Errorf("invalid feature: %w", err)
+	}
+	s.updateTrainingData(func(current *model, features map[string]string) bool {
+		if entry, _, ok := current.lookup(feature); ok {
+			delete(features, entry)
+		}
+		features[feature] = letter
+		return true
+	})
+	return nil
+}
+
+// RemoveFeature removes the training entry with the same pixels as feature, and reports whether there
+// was one. Like AddFeature, it is safe to call while captchas are being solved concurrently.
+func RemoveFeature(feature string) bool {
+	return defaultSolver().RemoveFeature(feature)
+}
+
+// RemoveFeature works like the package-level RemoveFeature, for the training data of the Solver.
+func (s *Solver) RemoveFeature(feature string) bool {
+	removed := false
+	s.updateTrainingData(func(current *model, features map[string]string) bool {
+		if entry, _, ok := current.lookup(feature); ok {
+			delete(features, entry)
+			removed = true
+		}
+		return removed
+	})
+	return removed
+}
+
+// SaveTrainingData writes the current training data to w in the JSON form of training_data.json, including
+// the entries added at runtime, e.g. by AddFeature, LoadTrainingData, Confirm or self-training, so that they
+// survive a restart when loaded again with LoadTrainingData or WithTrainingData. Use EncodeTrainingData with
+// TrainingData for the compact binary form. The JSON object has its keys sorted, so the output is reproducible.
+func SaveTrainingData(w io.Writer) error {
+	return defaultSolver().SaveTrainingData(w)
+}
+
+// SaveTrainingData works like the package-level SaveTrainingData, writing the training data of the Solver.
+func (s *Solver) SaveTrainingData(w io.Writer) error {
+	b, err := json.MarshalIndent(s.trainingData().features, "", "	")
+	if err != nil {
+		return fmt.Errorf("failed to marshal training data: %w", err)
+	}
+	if _, err := w.Write(b); err != nil {
+		return fmt.Errorf("failed to write training data: %w", err)
+	}
+	return nil
+}
+
+// SaveTrainingDataToFile writes the current training data to the file at path, see SaveTrainingData. The file
+// is replaced atomically, so a crash while saving leaves the previous training data intact.
+func SaveTrainingDataToFile(path string) error {
+	return defaultSolver().SaveTrainingDataToFile(path)
+}
+
+// SaveTrainingDataToFile works like the package-level SaveTrainingDataToFile, writing the training data of the Solver.
+func (s *Solver) SaveTrainingDataToFile(path string) error {
+	return writeTrainingDataFile(path, s.trainingData().features)
+}
+
+// writeTrainingDataFile writes training data in its JSON form to the file at path, replacing it atomically.
+func writeTrainingDataFile(path string, features map[string]string) error {
+	b, err := json.MarshalIndent(features, "", "	")
+	if err != nil {
+		return fmt.Errorf("failed to marshal training data: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, b, 0644); err != nil {
+		return fmt.Errorf("failed to write training data: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to write training data: %w", err)
+	}
+	return nil
+}
+
+// index decodes every training entry, building the bitmaps used for distance computations and
+// the map from decoded pixels to letters used by lookup.
+func (m *model) index() []decodedFeature {
+	m.indexOnce.Do(func() {
+		m.canonical = make(map[[sha256.Size]byte]string, len(m.features))
+		for k, v := range m.features {
+			binaryStr, err := decodeFeature(k)
+			if err != nil {
+				continue
+			}
+			m.canonical[sha256.Sum256(binaryStr)] = k
+			decoded, err := newBitmap(binaryStr, CaptchaHeight)
+			if err != nil {
+				continue
+			}
+			m.bitmaps = append(m.bitmaps, decodedFeature{feature: k, bitmap: decoded, letter: v})
+		}
+	})
+	return m.bitmaps
+}
+
+// nearest returns the k training entries nearest to b within maxDistance pixels, closest first.
+// A negative maxDistance means no limit.
+func (m *model) nearest(b *bitmap, k int, maxDistance int) []neighbor {
+	return m.bkTree().nearest(b, k, maxDistance)
+}
+
+// nearestLetters returns the nearest training entry of each of the k letters nearest to b, closest first.
+func (m *model) nearestLetters(b *bitmap, k int) []neighbor {
+	return m.bkTree().nearestLetters(b, k)
+}
+
+// bkTree returns the BK-tree over the decoded training entries, building it on first use.
+func (m *model) bkTree() *bkTree {
+	m.treeOnce.Do(func() {
+		m.tree = newBKTree(m.index())
+	})
+	return m.tree
+}
+
+// lookup returns the training entry matching feature and its letter.
+// Features are compared by their decoded pixels when the encoded strings differ, because the
+// same letter compresses to different bytes with different zlib implementations, e.g. training
+// data produced by another Go release.
+func (m *model) lookup(feature string) (string, string, bool) {
+	if letter, ok := m.features[feature]; ok {
+		return feature, letter, true
+	}
+
+	binaryStr, err := decodeFeature(feature)
+	if err != nil {
+		return "", "", false
+	}
+	m.index()
+	entry, ok := m.canonical[sha256.Sum256(binaryStr)]
+	if !ok {
+		return "", "", false
+	}
+	return entry, m.features[entry], true
 }
@@ -0,0 +1,131 @@
+package amazoncaptcha
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// trainingDataMagic starts the binary training data format, after decompression.
+const trainingDataMagic = "ACTD"
+
+// trainingDataVersion is the version of the binary training data format.
+const trainingDataVersion = 1
+
+// EncodeTrainingData writes training data in the compact binary format loaded by this package, which is
+// less than half the size of the JSON form and much faster to load. The format is a gzip stream holding
+// the magic "ACTD", a version byte and the number of entries, followed by every entry as its letter and
+// its hex-decoded feature, each prefixed with its length. Entries are sorted by feature so that the output
+// is reproducible. Features must be hex strings as returned by ExtractFeatures.
+func EncodeTrainingData(w io.Writer, features map[string]string) error {
+	keys := make([]string, 0, len(features))
+	for k := range features {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	scratch := make([]byte, binary.MaxVarintLen64)
+	writeUvarint := func(x uint64) {
+		buf.Write(scratch[:binary.PutUvarint(scratch, x)])
+	}
+	buf.WriteString(trainingDataMagic)
+	buf.WriteByte(trainingDataVersion)
+	writeUvarint(uint64(len(keys)))
+	for _, k := range keys {
+		raw, err := hex.DecodeString(k)
+		if err != nil {
+			return fmt.Errorf("invalid feature %q: %w", k, err)
+		}
+		letter := features[k]
+		writeUvarint(uint64(len(letter)))
+		buf.WriteString(letter)
+		writeUvarint(uint64(len(raw)))
+		buf.Write(raw)
+	}
+
+	zw, err := gzip.NewWriterLevel(w, gzip.BestCompression)
+	if err != nil {
+		return fmt.Errorf("failed to compress training data: %w", err)
+	}
+	if _, err := zw.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write training data: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write training data: %w", err)
+	}
+	return nil
+}
+
+// DecodeTrainingData reads training data in the binary form written by EncodeTrainingData
+// or in the JSON form of training_data.json, and returns its entries.
+func DecodeTrainingData(r io.Reader) (map[string]string, error) {
+	return readTrainingData(r)
+}
+
+// isBinaryTrainingData reports whether b starts like training data in the binary format, i.e. a gzip stream.
+func isBinaryTrainingData(b []byte) bool {
+	return len(b) >= 2 && b[0] == 0x1f && b[1] == 0x8b
+}
+
+// decodeBinaryTrainingData decodes training data in the binary format written by EncodeTrainingData.
+func decodeBinaryTrainingData(b []byte) (map[string]string, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("invalid training data: %w", err)
+	}
+	defer zr.Close()
+	r := bufio.NewReader(zr)
+
+	header := make([]byte, len(trainingDataMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(trainingDataMagic)]) != trainingDataMagic {
+		return nil, errors.New("invalid training data: missing header")
+	}
+	if header[len(trainingDataMagic)] != trainingDataVersion {
+		return nil, fmt.Errorf("invalid training data: unsupported version %d", header[len(trainingDataMagic)])
+	}
+
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid training data: %w", err)
+	}
+	// readField reads a length-prefixed field, bounding the length to detect corrupt data early
+	readField := func() ([]byte, error) {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if n > 1<<16 {
+			return nil, fmt.Errorf("field too long: %d bytes", n)
+		}
+		field := make([]byte, n)
+		if _, err := io.ReadFull(r, field); err != nil {
+			return nil, err
+		}
+		return field, nil
+	}
+
+	hint := count
+	if hint > 1<<16 {
+		hint = 1 << 16
+	}
+	features := make(map[string]string, hint)
+	for i := uint64(0); i < count; i++ {
+		letter, err := readField()
+		if err != nil {
+			return nil, fmt.Errorf("invalid training data: entry %d: %w", i, err)
+		}
+		raw, err := readField()
+		if err != nil {
+			return nil, fmt.Errorf("invalid training data: entry %d: %w", i, err)
+		}
+		features[hex.EncodeToString(raw)] = string(letter)
+	}
+	return features, nil
+}
@@ -0,0 +1,49 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// trainingDataJSON is the JSON form of the training data, embedded when the tests are compiled so that tests
+// writing training data files cannot change it.
+//
+//go:embed training_data.json
+var trainingDataJSON []byte
+
+func TestEncodeTrainingData(t *testing.T) {
+	featureA, _ := trainingLetter(t, "A")
+	featureB, _ := trainingLetter(t, "B")
+	features := map[string]string{featureA: "A", featureB: "B"}
+
+	var buf bytes.Buffer
+	assert.NoError(t, EncodeTrainingData(&buf, features))
+	decoded, err := DecodeTrainingData(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, features, decoded)
+
+	// Truncated data is rejected
+	_, err = DecodeTrainingData(bytes.NewReader(buf.Bytes()[:buf.Len()/2]))
+	assert.Error(t, err)
+
+	assert.Error(t, EncodeTrainingData(&buf, map[string]string{"not hex": "A"}))
+}
+
+func TestEmbeddedTrainingDataMatchesJSON(t *testing.T) {
+	requireTrainingData(t)
+	var features map[string]string
+	assert.NoError(t, json.Unmarshal(trainingDataJSON, &features))
+
+	// The embedded binary form holds every entry of the JSON form whose feature is valid
+	m, err := loadEmbeddedModel()
+	assert.NoError(t, err)
+	embedded := m.features
+	for feature, letter := range embedded {
+		assert.Equal(t, features[feature], letter)
+	}
+	assert.InDelta(t, len(features), len(embedded), 5)
+}
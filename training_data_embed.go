@@ -0,0 +1,10 @@
+//go:build !noembed
+
+package amazoncaptcha
+
+import _ "embed"
+
+// data holds the embedded training data.
+//
+//go:embed training_data.bin
+var data []byte
@@ -0,0 +1,8 @@
+//go:build noembed
+
+package amazoncaptcha
+
+// data holds no training data in builds with the noembed tag, which leave the 700-odd kilobytes of the embedded
+// training data out of binaries loading their own, e.g. with WithTrainingData or WithTrainingDataURL. Until they
+// do, solves fail with ErrNoTrainingData.
+var data []byte
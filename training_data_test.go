@@ -0,0 +1,219 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelLookupComparesPixels(t *testing.T) {
+	feature, bits := trainingLetter(t, "R")
+
+	// Compress the same pixels differently from the training data
+	var buf bytes.Buffer
+	w, err := zlib.NewWriterLevel(&buf, zlib.NoCompression)
+	assert.NoError(t, err)
+	_, _ = w.Write(bits)
+	assert.NoError(t, w.Close())
+	recompressed := hex.EncodeToString(buf.Bytes())
+	assert.NotEqual(t, feature, recompressed)
+
+	entry, letter, ok := defaultSolver().trainingData().lookup(recompressed)
+	assert.True(t, ok)
+	assert.Equal(t, "R", letter)
+	assert.Equal(t, feature, entry)
+
+	_, _, ok = defaultSolver().trainingData().lookup("78da")
+	assert.False(t, ok)
+}
+
+func TestLoadTrainingData(t *testing.T) {
+	featureA, _ := trainingLetter(t, "A")
+	featureB, _ := trainingLetter(t, "B")
+
+	solver, err := NewSolver(WithTrainingData(strings.NewReader(fmt.Sprintf(`{%q: "A"}`, featureA))))
+	assert.NoError(t, err)
+
+	// New entries are added and known entries are relabeled
+	path := filepath.Join(t.TempDir(), "training_data.json")
+	assert.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(`{%q: "B", %q: "X"}`, featureB, featureA)), 0644))
+	assert.NoError(t, solver.LoadTrainingDataFromFile(path))
+	assert.Equal(t, map[string]string{featureA: "X", featureB: "B"}, solver.trainingData().features)
+
+	assert.Error(t, solver.LoadTrainingData(strings.NewReader("invalid")))
+	assert.Error(t, solver.LoadTrainingDataFromFile(filepath.Join(t.TempDir(), "missing.json")))
+	assert.Len(t, solver.trainingData().features, 2)
+}
+
+func TestSetTrainingData(t *testing.T) {
+	featureA, _ := trainingLetter(t, "A")
+	featureB, _ := trainingLetter(t, "B")
+
+	solver, err := NewSolver(WithTrainingData(strings.NewReader(fmt.Sprintf(`{%q: "A"}`, featureA))))
+	assert.NoError(t, err)
+	previous := solver.TrainingData()
+	assert.Equal(t, map[string]string{featureA: "A"}, previous)
+
+	// The training data is replaced, not extended, and the caller's maps are not shared
+	replacement := map[string]string{featureB: "B"}
+	solver.SetTrainingData(replacement)
+	replacement[featureA] = "A"
+	assert.Equal(t, map[string]string{featureB: "B"}, solver.TrainingData())
+	solver.TrainingData()[featureA] = "A"
+	assert.Len(t, solver.trainingData().features, 1)
+
+	solver.SetTrainingData(previous)
+	assert.Equal(t, map[string]string{featureA: "A"}, solver.TrainingData())
+}
+
+func TestAddAndRemoveFeature(t *testing.T) {
+	featureA, bits := trainingLetter(t, "A")
+	featureB, _ := trainingLetter(t, "B")
+
+	solver, err := NewSolver(WithTrainingData(strings.NewReader(fmt.Sprintf(`{%q: "A"}`, featureA))))
+	assert.NoError(t, err)
+
+	assert.NoError(t, solver.AddFeature(featureB, "B"))
+	assert.Error(t, solver.AddFeature("invalid", "C"))
+
+	// The same pixels compressed differently relabel the existing entry
+	var buf bytes.Buffer
+	w, err := zlib.NewWriterLevel(&buf, zlib.NoCompression)
+	assert.NoError(t, err)
+	_, _ = w.Write(bits)
+	assert.NoError(t, w.Close())
+	recompressed := hex.EncodeToString(buf.Bytes())
+	assert.NoError(t, solver.AddFeature(recompressed, "X"))
+	assert.Equal(t, map[string]string{recompressed: "X", featureB: "B"}, solver.trainingData().features)
+
+	assert.True(t, solver.RemoveFeature(featureA))
+	assert.False(t, solver.RemoveFeature(featureA))
+	assert.Equal(t, map[string]string{featureB: "B"}, solver.trainingData().features)
+}
+
+func TestSaveTrainingData(t *testing.T) {
+	featureA, _ := trainingLetter(t, "A")
+	featureB, _ := trainingLetter(t, "B")
+
+	solver, err := NewSolver(WithTrainingData(strings.NewReader(fmt.Sprintf(`{%q: "A"}`, featureA))))
+	assert.NoError(t, err)
+	assert.NoError(t, solver.AddFeature(featureB, "B"))
+	want := map[string]string{featureA: "A", featureB: "B"}
+
+	// The entries added at runtime are saved, and load back into another solver
+	var buf bytes.Buffer
+	assert.NoError(t, solver.SaveTrainingData(&buf))
+	saved, err := parseTrainingData(buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, want, saved)
+	restored, err := NewSolver(WithTrainingData(&buf))
+	assert.NoError(t, err)
+	assert.Equal(t, want, restored.TrainingData())
+
+	path := filepath.Join(t.TempDir(), "training_data.json")
+	assert.NoError(t, solver.SaveTrainingDataToFile(path))
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	saved, err = parseTrainingData(b)
+	assert.NoError(t, err)
+	assert.Equal(t, want, saved)
+
+	assert.Error(t, solver.SaveTrainingDataToFile(filepath.Join(t.TempDir(), "missing", "training_data.json")))
+}
+
+func TestAddFeatureConcurrently(t *testing.T) {
+	solver, err := NewSolver()
+	assert.NoError(t, err)
+	captcha := syntheticCaptcha(t, "ABCEFG")
+	feature, _ := trainingLetter(t, "C")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			answer, err := solver.Solve(bytes.NewReader(captcha))
+			assert.NoError(t, err)
+			assert.Equal(t, "ABCEFG", answer)
+		}()
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, solver.AddFeature(feature, "C"))
+		}()
+	}
+	wg.Wait()
+}
+
+func TestInit(t *testing.T) {
+	requireTrainingData(t)
+	assert.NoError(t, Init())
+	assert.NotPanics(t, MustInit)
+
+	// Corrupt training data is reported instead of leaving an unusable model behind
+	m, err := decodeEmbeddedModel([]byte("corrupt"))
+	assert.Error(t, err)
+	assert.Empty(t, m.features)
+
+	// The default solver loads the embedded training data on first use
+	solver := newSolver()
+	embedded, err := loadEmbeddedModel()
+	assert.NoError(t, err)
+	assert.Same(t, embedded, solver.trainingData())
+
+	// Missing training data is reported as such
+	m, err = decodeEmbeddedModel(nil)
+	assert.ErrorIs(t, err, ErrNoTrainingData)
+	assert.Empty(t, m.features)
+}
+
+func TestNoTrainingData(t *testing.T) {
+	store := &memoryStore{images: make(map[string][]byte)}
+	solver, err := NewSolver(WithFailureStore(store))
+	if !assert.NoError(t, err) {
+		return
+	}
+	solver.SetTrainingData(map[string]string{})
+	captcha := syntheticCaptcha(t, "ABCEFG")
+
+	// Solves fail right away instead of answering placeholders, and the images are not archived
+	text, err := solver.Solve(bytes.NewReader(captcha))
+	assert.ErrorIs(t, err, ErrNoTrainingData)
+	assert.Empty(t, text)
+	_, err = solver.Solve(bytes.NewReader([]byte("not an image")))
+	assert.ErrorIs(t, err, ErrNoTrainingData)
+	_, err = solver.SolveBestEffort(context.Background(), bytes.NewReader(captcha))
+	assert.ErrorIs(t, err, ErrNoTrainingData)
+	assert.ErrorIs(t, solver.Warmup(), ErrNoTrainingData)
+	ensemble, err := solver.NewEnsembleSolver()
+	assert.NoError(t, err)
+	_, err = ensemble.Solve(bytes.NewReader(captcha))
+	assert.ErrorIs(t, err, ErrNoTrainingData)
+	assert.Empty(t, store.images)
+
+	// Letters are still located and segmented
+	letters, err := solver.FindLetters(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Len(t, letters, 6)
+	segmented, err := solver.SegmentLetters(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	if assert.Len(t, segmented, 6) {
+		assert.Equal(t, "-", segmented[0].Text)
+	}
+
+	// A recognizer recognizes letters without training data
+	recognizing, err := NewSolver(WithRecognizer(NewTemplateMatcher(Templates(3))))
+	assert.NoError(t, err)
+	recognizing.SetTrainingData(map[string]string{})
+	text, err = recognizing.Solve(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Len(t, text, 6)
+}
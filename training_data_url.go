@@ -0,0 +1,131 @@
+package amazoncaptcha
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// trainingDataTimeout bounds the download of training data, so that a startup without network access falls
+// back to the cached copy instead of hanging.
+const trainingDataTimeout = 30 * time.Second
+
+// trainingDataClient downloads training data.
+var trainingDataClient = &http.Client{Timeout: trainingDataTimeout}
+
+// trainingDataCacheMeta holds the validators of a cached training data download.
+type trainingDataCacheMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// LoadTrainingDataFromURL replaces the training data with a model downloaded from url, so binaries
+// can be deployed without relying on the embedded training data.
+//
+// The downloaded model is cached at cachePath. On later calls the cached copy is revalidated with
+// a conditional request and only downloaded again when the remote model has changed. If the remote
+// model cannot be fetched within 30 seconds, the cached copy is used instead, which allows starting
+// offline. A cached copy downloaded from another URL is never used.
+func LoadTrainingDataFromURL(url, cachePath string) error {
+	return defaultSolver().LoadTrainingDataFromURL(url, cachePath)
+}
+
+// LoadTrainingDataFromURL works like the package-level LoadTrainingDataFromURL, replacing the training data of the Solver.
+func (s *Solver) LoadTrainingDataFromURL(url, cachePath string) error {
+	features, err := fetchTrainingData(trainingDataClient, url, cachePath)
+	if err != nil {
+		return err
+	}
+	s.setTrainingData(features)
+	return nil
+}
+
+// fetchTrainingData downloads the training data at url, falling back to and refreshing the cache at cachePath.
+func fetchTrainingData(client *http.Client, url, cachePath string) (map[string]string, error) {
+	metaPath := cachePath + ".meta"
+
+	// Load the cached copy and its validators, if any, ignoring a copy downloaded from another URL
+	var meta trainingDataCacheMeta
+	cached, cacheErr := os.ReadFile(cachePath)
+	if cacheErr == nil {
+		if b, err := os.ReadFile(metaPath); err == nil {
+			_ = json.Unmarshal(b, &meta)
+		}
+		if meta.URL != url {
+			meta = trainingDataCacheMeta{}
+			cacheErr = fmt.Errorf("cached training data was downloaded from another URL")
+		}
+	}
+
+	// useCache falls back to the cached copy when the remote model is unavailable
+	useCache := func(cause error) (map[string]string, error) {
+		if cacheErr != nil {
+			return nil, cause
+		}
+		return parseTrainingData(cached)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	if cacheErr == nil {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return useCache(fmt.Errorf("failed to make HTTP request: %w", err))
+	}
+	defer resp.Body.Close()
+
+	// Check the HTTP response status, a 304 response means the cached copy is still current
+	if resp.StatusCode != http.StatusOK {
+		return useCache(fmt.Errorf("unexpected HTTP status code: %d", resp.StatusCode))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return useCache(fmt.Errorf("failed to read training data: %w", err))
+	}
+	features, err := parseTrainingData(body)
+	if err != nil {
+		return useCache(err)
+	}
+
+	// Replace the cached copy atomically, then record its validators
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0777); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	tmpPath := cachePath + ".tmp"
+	if err := os.WriteFile(tmpPath, body, 0644); err != nil {
+		return nil, fmt.Errorf("failed to cache training data: %w", err)
+	}
+	if err := os.Rename(tmpPath, cachePath); err != nil {
+		return nil, fmt.Errorf("failed to cache training data: %w", err)
+	}
+	meta = trainingDataCacheMeta{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cache training data: %w", err)
+	}
+	if err := os.WriteFile(metaPath, b, 0644); err != nil {
+		return nil, fmt.Errorf("failed to cache training data: %w", err)
+	}
+
+	return features, nil
+}
@@ -0,0 +1,75 @@
+package amazoncaptcha
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchTrainingData(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"78da": "A"}`))
+	}))
+
+	cachePath := filepath.Join(t.TempDir(), "model", "training_data.json")
+
+	// The first call downloads the model and caches it
+	features, err := fetchTrainingData(server.Client(), server.URL, cachePath)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"78da": "A"}, features)
+
+	// The second call revalidates the cached copy
+	features, err = fetchTrainingData(server.Client(), server.URL, cachePath)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"78da": "A"}, features)
+	assert.Equal(t, 2, requests)
+
+	// The cached copy is used while the remote model is unavailable
+	server.Close()
+	features, err = fetchTrainingData(server.Client(), server.URL, cachePath)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"78da": "A"}, features)
+
+	_, err = fetchTrainingData(server.Client(), server.URL, filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+
+	// The cached copy of another URL is not used
+	_, err = fetchTrainingData(server.Client(), server.URL+"/other", cachePath)
+	assert.Error(t, err)
+}
+
+func TestFetchTrainingDataTimeout(t *testing.T) {
+	served := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-served:
+			<-r.Context().Done()
+		default:
+			close(served)
+			_, _ = w.Write([]byte(`{"78da": "A"}`))
+		}
+	}))
+	defer server.Close()
+
+	cachePath := filepath.Join(t.TempDir(), "training_data.json")
+	client := &http.Client{Timeout: 100 * time.Millisecond}
+	_, err := fetchTrainingData(client, server.URL, cachePath)
+	assert.NoError(t, err)
+
+	// A server that does not answer times out to the cached copy
+	features, err := fetchTrainingData(client, server.URL, cachePath)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"78da": "A"}, features)
+	assert.NotZero(t, trainingDataClient.Timeout)
+}
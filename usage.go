@@ -0,0 +1,72 @@
+package amazoncaptcha
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// usageTracker counts how often each training entry matches a letter.
+type usageTracker struct {
+	enabled int32
+	mu      sync.Mutex
+	counts  map[string]uint64
+}
+
+// EnableUsageTracking turns the counting of training entry matches on or off.
+// Tracking is disabled by default because it adds a lock to every recognized letter.
+func EnableUsageTracking(enabled bool) {
+	defaultSolver().EnableUsageTracking(enabled)
+}
+
+// EnableUsageTracking turns the counting of training entry matches of the Solver on or off.
+func (s *Solver) EnableUsageTracking(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&s.usage.enabled, 1)
+	} else {
+		atomic.StoreInt32(&s.usage.enabled, 0)
+	}
+}
+
+// record counts a match of a training entry if usage tracking is enabled.
+func (t *usageTracker) record(feature string) {
+	if atomic.LoadInt32(&t.enabled) == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.counts == nil {
+		t.counts = make(map[string]uint64)
+	}
+	t.counts[feature]++
+}
+
+// FeatureUsage returns how often each training entry has matched a letter while usage tracking was enabled.
+// Every training entry is present in the returned map, so entries that never matched have a count of zero
+// and are candidates for pruning.
+func FeatureUsage() map[string]uint64 {
+	return defaultSolver().FeatureUsage()
+}
+
+// FeatureUsage works like the package-level FeatureUsage, for the training data of the Solver.
+func (s *Solver) FeatureUsage() map[string]uint64 {
+	s.usage.mu.Lock()
+	defer s.usage.mu.Unlock()
+	features := s.trainingData().features
+	snapshot := make(map[string]uint64, len(features))
+	for feature := range features {
+		snapshot[feature] = s.usage.counts[feature]
+	}
+	return snapshot
+}
+
+// ResetFeatureUsage sets the usage counter of every training entry back to zero.
+func ResetFeatureUsage() {
+	defaultSolver().ResetFeatureUsage()
+}
+
+// ResetFeatureUsage sets the usage counter of every training entry of the Solver back to zero.
+func (s *Solver) ResetFeatureUsage() {
+	s.usage.mu.Lock()
+	defer s.usage.mu.Unlock()
+	s.usage.counts = nil
+}
@@ -0,0 +1,27 @@
+package amazoncaptcha
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureUsage(t *testing.T) {
+	feature, _ := trainingLetter(t, "A")
+
+	defaultSolver().usage.record(feature)
+	assert.Equal(t, uint64(0), FeatureUsage()[feature])
+
+	EnableUsageTracking(true)
+	defer EnableUsageTracking(false)
+	defer ResetFeatureUsage()
+
+	defaultSolver().usage.record(feature)
+	defaultSolver().usage.record(feature)
+	snapshot := FeatureUsage()
+	assert.Len(t, snapshot, len(defaultSolver().trainingData().features))
+	assert.Equal(t, uint64(2), snapshot[feature])
+
+	ResetFeatureUsage()
+	assert.Equal(t, uint64(0), FeatureUsage()[feature])
+}
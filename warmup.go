@@ -0,0 +1,101 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"sort"
+)
+
+// Warmup performs the one-time initialization work of the package ahead of the first solve,
+// so that latency-sensitive services pay for it at deploy time rather than on user traffic.
+// It warms up the default Solver, which may be set with SetDefaultSolver: it loads the training data,
+// decodes it into the index used to guess unknown letters and dry-runs the recognition pipeline on a
+// synthetic captcha, without triggering any capture hooks.
+func Warmup() error {
+	return defaultSolver().Warmup()
+}
+
+// Warmup works like the package-level Warmup, for the configuration and training data of the Solver.
+// The training data is normalized first if the Solver normalizes letters, and the synthetic captcha is
+// assembled from its own training letters, so that decoding, segmentation, recognition and the nearest
+// neighbor index are all built before the first real solve. The dry run is not recorded in the statistics.
+// Like its solves, Warmup returns ErrNoTrainingData if the Solver has neither training data nor a recognizer,
+// e.g. if the embedded training data is missing or corrupt; Init reports why.
+func (s *Solver) Warmup() error {
+	if err := s.checkTrainingData(); err != nil {
+		return err
+	}
+
+	// Build the index of decoded training entries and the BK-tree over them, normalized if needed
+	m := s.trainingData()
+	m.index()
+	recognition := s.recognitionModel()
+	recognition.bkTree()
+
+	captcha, err := s.warmupCaptcha(m)
+	if err != nil {
+		return fmt.Errorf("failed to warm up: %w", err)
+	}
+
+	// Dry-run the whole solve, detached from the hooks and statistics of the Solver but sharing its model
+	if _, err := s.detached().solve(bytes.NewReader(captcha)); err != nil {
+		var dimensionErr *DimensionError
+		if !errors.Is(err, ErrSegmentationFailed) && !errors.As(err, &dimensionErr) {
+			return fmt.Errorf("failed to warm up: %w", err)
+		}
+	}
+	return nil
+}
+
+// warmupCaptcha assembles a PNG captcha from letters of the training data, one per distinct letter in
+// feature order, separated by blank columns so that they segment like the letters of a real captcha.
+func (s *Solver) warmupCaptcha(m *model) ([]byte, error) {
+	features := make([]string, 0, len(m.features))
+	for feature := range m.features {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+
+	// Pick letters of plausible widths, each letter once
+	const gap = 2
+	var letters []*image.Gray
+	seen := make(map[string]bool)
+	width := gap
+	for _, feature := range features {
+		if len(letters) == s.captchaLength {
+			break
+		}
+		if letter := m.features[feature]; seen[letter] {
+			continue
+		}
+		img, err := FeatureImage(feature)
+		if err != nil || img.Bounds().Dx() < s.minLetterLength || img.Bounds().Dx() > s.maxLetterLength {
+			continue
+		}
+		seen[m.features[feature]] = true
+		letters = append(letters, img)
+		width += img.Bounds().Dx() + gap
+	}
+	if s.width > width {
+		width = s.width
+	}
+
+	// Draw the letters side by side over a white canvas
+	canvas := image.NewGray(image.Rect(0, 0, width, CaptchaHeight))
+	draw.Draw(canvas, canvas.Bounds(), image.White, image.Point{}, draw.Src)
+	x := gap
+	for _, letter := range letters {
+		draw.Draw(canvas, letter.Bounds().Add(image.Pt(x, 0)), letter, image.Point{}, draw.Src)
+		x += letter.Bounds().Dx() + gap
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, fmt.Errorf("failed to encode warm-up captcha: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,58 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarmup(t *testing.T) {
+	requireTrainingData(t)
+	sink := &collectingSink{}
+	SetLetterSink(sink)
+	defer SetLetterSink(nil)
+
+	assert.NoError(t, Warmup())
+	assert.NotEmpty(t, defaultSolver().trainingData().index())
+	assert.Empty(t, sink.letters)
+}
+
+func TestWarmupDefaultSolver(t *testing.T) {
+	requireTrainingData(t)
+	// The package-level Warmup warms up the default Solver only, whatever its training data
+	solver, err := NewSolver()
+	assert.NoError(t, err)
+	solver.SetTrainingData(DefaultSolver().TrainingData())
+	SetDefaultSolver(solver)
+	defer SetDefaultSolver(nil)
+
+	assert.NoError(t, Warmup())
+	assert.NotEmpty(t, solver.trainingData().bitmaps)
+
+	solver.SetTrainingData(map[string]string{})
+	assert.ErrorIs(t, Warmup(), ErrNoTrainingData)
+}
+
+func TestSolverWarmup(t *testing.T) {
+	requireTrainingData(t)
+	solver, err := NewSolver(WithStrokeNormalization())
+	assert.NoError(t, err)
+	assert.NoError(t, solver.Warmup())
+
+	// The index, the BK-tree and the normalized model are built, and the dry run is not recorded
+	m := solver.trainingData()
+	assert.NotEmpty(t, m.bitmaps)
+	assert.Contains(t, m.normalizedModels, solver.normalization)
+	normalized := solver.recognitionModel()
+	assert.NotNil(t, normalized.tree)
+	assert.Zero(t, solver.Stats().Solves)
+
+	// The warm-up captcha holds real letters that the Solver recognizes
+	captcha, err := solver.warmupCaptcha(m)
+	assert.NoError(t, err)
+	result, err := solver.SolveDetailed(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.True(t, result.Solved)
+	assert.Len(t, result.LetterConfidence, DefaultCaptchaLength)
+}
@@ -0,0 +1,112 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Package wasm exposes an amazoncaptcha Solver to JavaScript when compiled to WebAssembly. Register sets the
+// global functions
+//
+//	solveCaptcha(image: Uint8Array): Promise<string>
+//	findLetters(image: Uint8Array): Promise<string[]>
+//
+// which return right away and settle their Promise once the captcha is processed: solveCaptcha resolves to the
+// answer and findLetters to the letters as "data:image/png;base64,..." URIs, ready to be used as the src of
+// <img> tags. Both reject with an Error for images that cannot be processed, instead of killing the instance.
+//
+// WebAssembly runs on the thread of the page that loaded it, so the processing itself still runs on that thread,
+// after the call returned. To keep the UI responsive, load the module in a Web Worker, as example/wasm does.
+package wasm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"syscall/js"
+
+	"github.com/gopkg-dev/amazoncaptcha"
+)
+
+// Register sets the global functions solveCaptcha and findLetters, processing captchas with solver, or with
+// the default configuration and training data of the package if nil. It also sets SolveCaptcha, the
+// synchronous function of earlier versions returning {result, error}, for existing front-ends.
+func Register(solver *amazoncaptcha.Solver) {
+	solve := amazoncaptcha.Solve
+	findLetters := amazoncaptcha.FindLettersBase64
+	if solver != nil {
+		solve = solver.Solve
+		findLetters = solver.FindLettersBase64
+	}
+
+	global := js.Global()
+	global.Set("solveCaptcha", js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		image, err := imageArg(args)
+		return promise(func() (interface{}, error) {
+			if err != nil {
+				return nil, err
+			}
+			return solve(bytes.NewReader(image))
+		})
+	}))
+	global.Set("findLetters", js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		image, err := imageArg(args)
+		return promise(func() (interface{}, error) {
+			if err != nil {
+				return nil, err
+			}
+			letters, err := findLetters(bytes.NewReader(image))
+			if err != nil {
+				return nil, err
+			}
+			values := make([]interface{}, len(letters))
+			for i, letter := range letters {
+				values[i] = letter
+			}
+			return values, nil
+		})
+	}))
+	global.Set("SolveCaptcha", js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		image, err := imageArg(args)
+		if err == nil {
+			var answer string
+			if answer, err = solve(bytes.NewReader(image)); err == nil {
+				return map[string]interface{}{"result": answer, "error": nil}
+			}
+		}
+		return map[string]interface{}{"result": nil, "error": err.Error()}
+	}))
+}
+
+// imageArg copies the captcha image out of the arguments of a call, which must be a single Uint8Array.
+// The image is copied before the call returns, so that the caller may reuse the array right away.
+func imageArg(args []js.Value) ([]byte, error) {
+	if len(args) != 1 || args[0].Type() != js.TypeObject || !args[0].InstanceOf(js.Global().Get("Uint8Array")) {
+		return nil, errors.New("expected a single Uint8Array argument")
+	}
+	image := make([]byte, args[0].Length())
+	js.CopyBytesToGo(image, args[0])
+	return image, nil
+}
+
+// promise returns a JS Promise settled by work, which runs in its own goroutine so that the call creating
+// the Promise returns right away. The Promise is rejected with an Error if work fails or panics.
+func promise(work func() (interface{}, error)) js.Value {
+	executor := js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		resolve, reject := args[0], args[1]
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					reject.Invoke(js.Global().Get("Error").New(fmt.Sprint(r)))
+				}
+			}()
+			v, err := work()
+			if err != nil {
+				reject.Invoke(js.Global().Get("Error").New(err.Error()))
+				return
+			}
+			resolve.Invoke(v)
+		}()
+		return nil
+	})
+	defer executor.Release()
+
+	// The Promise constructor calls the executor before returning, so it can be released right after
+	return js.Global().Get("Promise").New(executor)
+}
@@ -0,0 +1,9 @@
+//go:build webp
+// +build webp
+
+package amazoncaptcha
+
+// Captchas proxied through image optimizers are sometimes re-encoded as WebP. Building with the webp tag
+// registers the WebP decoder of golang.org/x/image, so that Solve and the other functions decoding images
+// accept image/webp inputs. It is behind a build tag to keep the dependency out of default builds.
+import _ "golang.org/x/image/webp"
@@ -0,0 +1,24 @@
+//go:build webp
+// +build webp
+
+package amazoncaptcha
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebPDecoderRegistered(t *testing.T) {
+	// A truncated WebP image is recognized as WebP and rejected by its decoder
+	_, err := FindLetters(bytes.NewReader([]byte("RIFF\x0c\x00\x00\x00WEBPVP8 ")))
+	if assert.Error(t, err) {
+		assert.NotContains(t, err.Error(), "unknown format")
+	}
+
+	_, err = FindLetters(bytes.NewReader([]byte("not an image")))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown format")
+	}
+}
@@ -0,0 +1,128 @@
+package amazoncaptcha
+
+import (
+	"errors"
+	"image"
+	"math"
+	"sort"
+)
+
+// Default grid of the zone features of a ZoneMatcher: letters are about three times as high as wide.
+const (
+	DefaultZoneRows    = 7
+	DefaultZoneColumns = 3
+)
+
+// DefaultZoneDistance is the largest distance between zone features at which a ZoneMatcher recognizes a letter.
+const DefaultZoneDistance = 0.2
+
+// ZoneFeatures divides the black pixels of a monochrome letter into a grid of rows by columns zones and returns
+// the share of black pixels in every zone, row by row, between 0 and 1. The grid spans the box of the black
+// pixels, so the vector does not depend on where the letter lies within its segment, and neighboring letters
+// drawn a few pixels apart have close vectors instead of unrelated features, unlike ExtractFeatures. A letter
+// without black pixels has a vector of zeros. It returns nil if rows or columns is not positive.
+func ZoneFeatures(img *image.Gray, rows, columns int) []float64 {
+	if rows <= 0 || columns <= 0 {
+		return nil
+	}
+	zones := make([]float64, rows*columns)
+
+	// Find the box of the black pixels the grid spans
+	bounds := img.Bounds()
+	box := image.Rectangle{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if img.GrayAt(x, y).Y == 0 {
+				box = box.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if box.Empty() {
+		return zones
+	}
+
+	// Count the black pixels of every zone, zones of boxes smaller than the grid sharing their pixels
+	for row := 0; row < rows; row++ {
+		y0 := box.Min.Y + row*box.Dy()/rows
+		y1 := box.Min.Y + ((row+1)*box.Dy()+rows-1)/rows
+		for column := 0; column < columns; column++ {
+			x0 := box.Min.X + column*box.Dx()/columns
+			x1 := box.Min.X + ((column+1)*box.Dx()+columns-1)/columns
+			ink := 0
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					if img.GrayAt(x, y).Y == 0 {
+						ink++
+					}
+				}
+			}
+			zones[row*columns+column] = float64(ink) / float64((x1-x0)*(y1-y0))
+		}
+	}
+	return zones
+}
+
+// ZoneMatcher is a Recognizer that compares the zone features of a segmented letter, see ZoneFeatures, with
+// those of stored letter templates, and recognizes the letter of the nearest one. A few dozen numbers per
+// template replace the whole bitmap of the training data, and letters differing from every template by a
+// few pixels still find their nearest one. It is safe for concurrent use.
+type ZoneMatcher struct {
+	rows, columns int
+	templates     []zoneTemplate
+	// MaxDistance is the largest distance, the root mean square of the differences between the zones, at which
+	// a letter is recognized, DefaultZoneDistance by default.
+	MaxDistance float64
+}
+
+// zoneTemplate is the zone features of a stored letter template.
+type zoneTemplate struct {
+	letter string
+	zones  []float64
+}
+
+// NewZoneMatcher creates a ZoneMatcher dividing letters into grids of rows by columns zones, e.g.
+// DefaultZoneRows by DefaultZoneColumns, from monochrome letter templates keyed by the letter they show,
+// e.g. from Templates.
+func NewZoneMatcher(templates map[string][]*image.Gray, rows, columns int) (*ZoneMatcher, error) {
+	if rows <= 0 || columns <= 0 {
+		return nil, errors.New("zone grid must have a positive number of rows and columns")
+	}
+	letters := make([]string, 0, len(templates))
+	for letter := range templates {
+		letters = append(letters, letter)
+	}
+	sort.Strings(letters)
+
+	z := &ZoneMatcher{rows: rows, columns: columns, MaxDistance: DefaultZoneDistance}
+	for _, letter := range letters {
+		for _, img := range templates[letter] {
+			z.templates = append(z.templates, zoneTemplate{letter: letter, zones: ZoneFeatures(img, rows, columns)})
+		}
+	}
+	return z, nil
+}
+
+// Recognize implements Recognizer: it returns the letter of the template whose zone features are nearest to
+// those of the letter, with one minus its distance relative to MaxDistance as confidence, or an empty letter if
+// no template is within MaxDistance. Ties go to the alphabetically first letter.
+func (z *ZoneMatcher) Recognize(letter *image.Gray) (string, float64) {
+	zones := ZoneFeatures(letter, z.rows, z.columns)
+	best, bestDistance := "", math.Inf(1)
+	for _, tmpl := range z.templates {
+		sum := 0.0
+		for i, v := range tmpl.zones {
+			sum += (v - zones[i]) * (v - zones[i])
+		}
+		if distance := math.Sqrt(sum / float64(len(zones))); distance < bestDistance {
+			best, bestDistance = tmpl.letter, distance
+		}
+	}
+
+	if best == "" || bestDistance > z.MaxDistance {
+		return "", 0
+	}
+	if z.MaxDistance == 0 {
+		return best, 1
+	}
+	return best, 1 - bestDistance/z.MaxDistance
+}
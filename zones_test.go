@@ -0,0 +1,81 @@
+package amazoncaptcha
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZoneFeatures(t *testing.T) {
+	// A 4x4 square whose left half is black
+	img := image.NewGray(image.Rect(0, 0, 6, 4))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	for y := 0; y < 4; y++ {
+		img.Pix[y*img.Stride+1], img.Pix[y*img.Stride+2] = 0, 0
+		img.Pix[y*img.Stride+4] = 0
+	}
+	assert.Equal(t, []float64{1, 0.5, 1, 0.5}, ZoneFeatures(img, 2, 2))
+
+	// Shifting the letter within its segment does not change its zones
+	_, binaryStr := trainingLetter(t, "K")
+	letter := featureImage(binaryStr, CaptchaHeight)
+	zones := ZoneFeatures(letter, DefaultZoneRows, DefaultZoneColumns)
+	assert.Len(t, zones, DefaultZoneRows*DefaultZoneColumns)
+	assert.Equal(t, zones, ZoneFeatures(padLetter(letter, 3), DefaultZoneRows, DefaultZoneColumns))
+
+	// Blank letters have empty zones
+	blank := image.NewGray(image.Rect(0, 0, 20, CaptchaHeight))
+	for i := range blank.Pix {
+		blank.Pix[i] = 255
+	}
+	assert.Equal(t, make([]float64, 6), ZoneFeatures(blank, 3, 2))
+	assert.Nil(t, ZoneFeatures(blank, 0, 2))
+}
+
+func TestZoneMatcher(t *testing.T) {
+	_, err := NewZoneMatcher(Templates(1), 0, DefaultZoneColumns)
+	assert.Error(t, err)
+
+	templates := make(map[string][]*image.Gray)
+	for _, letter := range []string{"K", "X", "H"} {
+		_, binaryStr := trainingLetter(t, letter)
+		templates[letter] = append(templates[letter], featureImage(binaryStr, CaptchaHeight))
+	}
+	matcher, err := NewZoneMatcher(templates, DefaultZoneRows, DefaultZoneColumns)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Letters misaligned within their segment are recognized
+	for letter, images := range templates {
+		got, confidence := matcher.Recognize(padLetter(images[0], 2))
+		assert.Equal(t, letter, got)
+		assert.Equal(t, 1.0, confidence)
+	}
+
+	// Letters far from every template are not recognized
+	blank := image.NewGray(image.Rect(0, 0, 20, CaptchaHeight))
+	for i := range blank.Pix {
+		blank.Pix[i] = 255
+	}
+	got, confidence := matcher.Recognize(blank)
+	assert.Equal(t, "", got)
+	assert.Equal(t, 0.0, confidence)
+}
+
+func TestNewSolverWithZoneMatcher(t *testing.T) {
+	captcha := flipPixel(t, syntheticCaptcha(t, "ABCEFG"), 2)
+
+	matcher, err := NewZoneMatcher(Templates(5), DefaultZoneRows, DefaultZoneColumns)
+	assert.NoError(t, err)
+	solver, err := NewSolver(WithRecognizer(matcher))
+	assert.NoError(t, err)
+	result, err := solver.SolveDetailed(bytes.NewReader(captcha))
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCEFG", result.Text)
+	assert.Less(t, result.LetterConfidence[2], 1.0)
+}